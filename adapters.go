@@ -0,0 +1,66 @@
+package mustache
+
+import (
+	"flag"
+	"net/textproto"
+	"strings"
+)
+
+// Values adapts multi-valued maps, such as url.Values from a query string or form, for use as contexts, so that
+// {{page}} renders the first value of the page parameter rather than the list of its values:
+//
+//	tmpl.Render(map[string]interface{}{"query": mustache.Values(r.URL.Query())})
+//
+// A name which is present without values is an empty string, and falsy in sections. To iterate over all the values of
+// a name, render the map without adapting it.
+type Values map[string][]string
+
+// LookupName implements NameLookuper.
+func (v Values) LookupName(name string) (interface{}, bool) {
+	return firstValue(v, name)
+}
+
+// Header adapts HTTP headers, such as an http.Header, for use as contexts like Values. Names are matched regardless
+// of case, so {{content-type}} and {{Content-Type}} both render the first Content-Type header.
+type Header map[string][]string
+
+// LookupName implements NameLookuper.
+func (h Header) LookupName(name string) (interface{}, bool) {
+	if value, ok := firstValue(h, name); ok {
+		return value, true
+	}
+	return firstValue(h, textproto.CanonicalMIMEHeaderKey(name))
+}
+
+func firstValue(m map[string][]string, name string) (interface{}, bool) {
+	values, ok := m[name]
+	if !ok {
+		return nil, false
+	}
+	if len(values) == 0 {
+		return "", true
+	}
+	return values[0], true
+}
+
+// Environ returns a context holding environment variables in the KEY=value form of os.Environ, so that templates can
+// refer to them as {{HOME}}. Entries without an '=' are skipped, and later entries replace earlier ones.
+func Environ(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, kv := range env {
+		if key, value, ok := strings.Cut(kv, "="); ok && key != "" {
+			m[key] = value
+		}
+	}
+	return m
+}
+
+// Flags returns a context holding the value of each flag of a flag set, as given on the command line or by default,
+// so that templates rendered by command line tools can refer to flags such as -max-items as {{max-items}}.
+func Flags(fs *flag.FlagSet) map[string]string {
+	m := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		m[f.Name] = f.Value.String()
+	})
+	return m
+}
@@ -0,0 +1,58 @@
+package mustache
+
+import (
+	"flag"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestAdapters(t *testing.T) {
+	query, err := url.ParseQuery("page=2&page=3&tag=go&empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "text/html")
+	header.Add("Accept", "a")
+	header.Add("Accept", "b")
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.Int("max-items", 10, "")
+	fs.String("title", "", "")
+	if err := fs.Parse([]string{"-title", "Home"}); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{
+		"query":  Values(query),
+		"header": Header(header),
+		"env":    Environ([]string{"HOME=/root", "EMPTY=", "=C:=C:\\", "BAD", "HOME=/home/jo"}),
+		"flags":  Flags(fs),
+	}
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{"{{query.page}} {{query.tag}} [{{query.empty}}]{{^query.empty}} empty{{/query.empty}} [{{query.missing}}]", "2 go [] empty []"},
+		{"{{#query}}{{page}}{{/query}}", "2"},
+		{"{{header.content-type}} {{header.Accept}} {{header.accept}} [{{header.X-Missing}}]", "text/html a a []"},
+		{"{{env.HOME}} [{{env.EMPTY}}] {{#env}}{{BAD}}{{/env}}", "/home/jo [] "},
+		{"{{flags.max-items}} {{flags.title}}", "10 Home"},
+	}
+	for _, test := range tests {
+		tmpl, err := New().CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output, err := tmpl.Render(data); err != nil || output != test.expected {
+			t.Errorf("%q: expected %q, got %q, %v", test.tmpl, test.expected, output, err)
+		}
+	}
+
+	tmpl, err := New().WithErrors(true).CompileString("{{query.missing}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(data); err == nil {
+		t.Error("expected a missing query parameter to be an error in strict mode")
+	}
+}
@@ -0,0 +1,180 @@
+package mustache
+
+import (
+	"sort"
+	"sync"
+)
+
+// TagUsage counts the evaluations of a tag across the renders recorded by a UsageReport.
+type TagUsage struct {
+	// Path identifies the tag by the name of the template or partial it is in, the sections enclosing it, and the tag
+	// itself, separated by slashes, as in "page/#items/^-last/name". Sections are prefixed with # or ^, partials with
+	// >, and helper calls are written with their arguments. Tags with the same path, such as a variable used twice in
+	// the same section, are counted together.
+	Path      string
+	Evaluated int64 // the number of times the tag was evaluated
+	Produced  int64 // the number of evaluations which wrote output, or for sections, rendered the section's contents
+}
+
+// UsageReport records how often each tag of the templates rendered with it is evaluated, and how often it produces
+// output, for finding branches of templates which are never rendered and variables which are always empty. Set one
+// with WithUsageReport, and read it with Tags once enough renders have been recorded, for instance after a day of
+// production traffic. It is safe for concurrent use.
+type UsageReport struct {
+	mu   sync.Mutex
+	tags map[string]*TagUsage
+	// templates holds the templates whose tags have been listed, so that tags which are never evaluated are reported
+	templates map[templateKey]bool
+}
+
+// templateKey identifies a compiled template by its name and elements, which copies of a partial made to give it
+// another escape mode share.
+type templateKey struct {
+	name  string
+	elems *interface{}
+}
+
+func keyOf(tmpl *Template) templateKey {
+	key := templateKey{name: tmpl.name}
+	if len(tmpl.elems) > 0 {
+		key.elems = &tmpl.elems[0]
+	}
+	return key
+}
+
+// NewUsageReport returns an empty UsageReport.
+func NewUsageReport() *UsageReport {
+	return &UsageReport{tags: make(map[string]*TagUsage), templates: make(map[templateKey]bool)}
+}
+
+// WithUsageReport records the usage of the tags of the compiled templates, and of the partials they include, in
+// report. Recording adds some overhead to each render.
+func (r *Compiler) WithUsageReport(report *UsageReport) *Compiler {
+	r.usageReport = report
+	return r
+}
+
+// Tags returns the usage of every tag of the templates and partials rendered so far, sorted by path. A tag with a
+// Produced count of zero never wrote anything, and one with an Evaluated count of zero is in a section which was never
+// rendered.
+func (u *UsageReport) Tags() []TagUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	tags := make([]TagUsage, 0, len(u.tags))
+	for _, tag := range u.tags {
+		tags = append(tags, *tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Path < tags[j].Path })
+	return tags
+}
+
+// Reset clears the report.
+func (u *UsageReport) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.tags = make(map[string]*TagUsage)
+	u.templates = make(map[templateKey]bool)
+}
+
+// merge adds the counts of a single render to the report.
+func (u *UsageReport) merge(counts *tagCounts) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, tmpl := range counts.templates {
+		if key := keyOf(tmpl); !u.templates[key] {
+			u.templates[key] = true
+			u.list(tmpl.name, tmpl.elems)
+		}
+	}
+	for path, count := range counts.tags {
+		tag := u.tag(path)
+		tag.Evaluated += count.Evaluated
+		tag.Produced += count.Produced
+	}
+}
+
+// list adds the tags of elems, with zero counts if they haven't been evaluated.
+func (u *UsageReport) list(path string, elems []interface{}) {
+	for _, elem := range elems {
+		if key := tagKey(elem); key != "" {
+			u.tag(tagPath(path, key))
+		}
+		if section, ok := elem.(*sectionElement); ok {
+			u.list(tagPath(path, tagKey(section)), section.elems)
+		}
+	}
+}
+
+func (u *UsageReport) tag(path string) *TagUsage {
+	tag, ok := u.tags[path]
+	if !ok {
+		tag = &TagUsage{Path: path}
+		u.tags[path] = tag
+	}
+	return tag
+}
+
+// tagCounts holds the tag usage of a single render, which is merged into the UsageReport once the render finishes.
+type tagCounts struct {
+	tags      map[string]*TagUsage
+	templates []*Template
+}
+
+func newTagCounts() *tagCounts {
+	return &tagCounts{tags: make(map[string]*TagUsage)}
+}
+
+// record counts an evaluation of the tag at path.
+func (c *tagCounts) record(path string, produced bool) {
+	tag, ok := c.tags[path]
+	if !ok {
+		tag = &TagUsage{Path: path}
+		c.tags[path] = tag
+	}
+	tag.Evaluated++
+	if produced {
+		tag.Produced++
+	}
+}
+
+// rendered notes that a template or partial was rendered, so that its tags are listed in the report.
+func (c *tagCounts) rendered(tmpl *Template) {
+	for _, t := range c.templates {
+		if keyOf(t) == keyOf(tmpl) {
+			return
+		}
+	}
+	c.templates = append(c.templates, tmpl)
+}
+
+// tagKey returns the last component of the path of a tag, or an empty string for text.
+func tagKey(elem interface{}) string {
+	switch elem := elem.(type) {
+	case *varElement:
+		return elem.name
+	case *sectionElement:
+		if elem.sigil != 0 {
+			return string(elem.sigil) + elem.name
+		}
+		if elem.inverted {
+			return "^" + elem.name
+		}
+		return "#" + elem.name
+	case *partialElement:
+		return ">" + elem.name
+	case *helperElement:
+		return elem.String()
+	case *exprElement:
+		return elem.src
+	case *customTagElement:
+		return elem.String()
+	}
+	return ""
+}
+
+func tagPath(base, key string) string {
+	if base == "" {
+		return key
+	}
+	return base + "/" + key
+}
@@ -0,0 +1,54 @@
+package mustache
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestUsageReport(t *testing.T) {
+	report := NewUsageReport()
+	partials := &StaticProvider{Partials: map[string]string{"item": "<{{name}}{{^name}}?{{/name}}>"}}
+	tmpl, err := New().WithPartials(partials).WithUsageReport(report).
+		CompileString("{{title}}{{empty}}{{#items}}{{>item}}{{^-last}},{{/-last}}{{/items}}{{#never}}{{unused}}{{/never}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.name = "page"
+	data := map[string]interface{}{
+		"title": "T",
+		"empty": "",
+		"items": []map[string]string{{"name": "a"}, {}},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tmpl.Render(data); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	expected := []TagUsage{
+		{"item/^name", 4, 2},
+		{"item/name", 4, 2},
+		{"page/#items", 2, 2},
+		{"page/#items/>item", 4, 4},
+		{"page/#items/^-last", 4, 2},
+		{"page/#never", 2, 0},
+		{"page/#never/unused", 0, 0},
+		{"page/empty", 2, 0},
+		{"page/title", 2, 2},
+	}
+	if tags := report.Tags(); !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected %+v\ngot %+v", expected, tags)
+	}
+
+	report.Reset()
+	if tags := report.Tags(); len(tags) != 0 {
+		t.Errorf("expected an empty report, got %+v", tags)
+	}
+}
@@ -0,0 +1,281 @@
+package mustache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ASTVersion is the version of the JSON AST format written by Template.MarshalJSON. It is increased when the format
+// changes in a way older readers would misread.
+const ASTVersion = 1
+
+// The types of ASTNode.
+const (
+	NodeText       = "text"
+	NodeVariable   = "variable"
+	NodeSection    = "section"
+	NodePartial    = "partial"
+	NodeHelper     = "helper"
+	NodeExpression = "expression"
+	NodeCustom     = "custom"
+	NodeParent     = "parent"
+	NodeBlock      = "block"
+)
+
+// AST is the JSON form of a parsed template, for tools written in other languages, such as linters and template
+// editors. A template is marshaled to an AST with Template.MarshalJSON, and compiled from one with
+// Compiler.CompileJSON. Comments, pragmas other than ESCAPE and delimiter changes are resolved by the parser, so they
+// do not appear in the AST, and standalone tags have already had their lines removed.
+type AST struct {
+	Version int       `json:"version"`
+	Name    string    `json:"name,omitempty"`   // the name of the template, as returned by Template.Name
+	Escape  string    `json:"escape,omitempty"` // the escape mode set by an ESCAPE pragma, if any
+	Nodes   []ASTNode `json:"nodes"`
+}
+
+// ASTNode is a node of an AST. Type is one of NodeText, NodeVariable, NodeSection, NodePartial, NodeHelper,
+// NodeExpression, NodeCustom, NodeParent or NodeBlock, and determines which of the other fields are used. The Name of an expression is its
+// source text, such as "price * quantity", and that of a custom tag is its text with its sigil, such as "@flag beta",
+// which are parsed again by CompileJSON.
+type ASTNode struct {
+	Type      string     `json:"type"`
+	Text      string     `json:"text,omitempty"`      // text: the literal text
+	Name      string     `json:"name,omitempty"`      // variable, section, partial, helper, parent and block: the name; expression and custom: the source
+	Raw       bool       `json:"raw,omitempty"`       // variable, helper and expression: written without escaping; partial: included verbatim
+	Inverted  bool       `json:"inverted,omitempty"`  // section: whether the section renders when its value is empty
+	Condition bool       `json:"condition,omitempty"` // section: whether the section renders once without pushing its value
+	Line      int        `json:"line,omitempty"`      // section, parent and block: the line of the opening tag
+	Column    int        `json:"column,omitempty"`    // section, parent and block: the column of the opening tag, counting bytes from 1
+	Nodes     []ASTNode  `json:"nodes,omitempty"`     // section, parent and block: the contents of the tag
+	Indent    string     `json:"indent,omitempty"`    // partial, parent and block: the indentation of a standalone tag
+	Context   string     `json:"context,omitempty"`   // partial: the name of a Handlebars partial's context
+	Params    []ASTParam `json:"params,omitempty"`    // partial: the hash parameters of a Handlebars partial
+	Args      []ASTParam `json:"args,omitempty"`      // helper: the arguments, without keys
+}
+
+// ASTParam is a hash parameter of a Handlebars partial or an argument of a helper, with either the name its value is
+// looked up by, or if Name is empty, a literal string, number or boolean value.
+type ASTParam struct {
+	Key   string      `json:"key,omitempty"`
+	Name  string      `json:"name,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// AST returns the parsed form of the template.
+func (tmpl *Template) AST() AST {
+	ast := AST{Version: ASTVersion, Name: tmpl.name, Nodes: astNodes(tmpl.elems)}
+	if tmpl.escapePragma {
+		ast.Escape = tmpl.outputMode.String()
+	}
+	return ast
+}
+
+// MarshalJSON returns the AST of the template as JSON.
+func (tmpl *Template) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tmpl.AST())
+}
+
+func astNodes(elems []interface{}) []ASTNode {
+	nodes := make([]ASTNode, 0, len(elems))
+	for _, elem := range elems {
+		switch elem := elem.(type) {
+		case *textElement:
+			// the parser leaves empty text elements around tags
+			if len(elem.text) > 0 {
+				nodes = append(nodes, ASTNode{Type: NodeText, Text: string(elem.text)})
+			}
+		case *varElement:
+			nodes = append(nodes, ASTNode{Type: NodeVariable, Name: elem.name, Raw: elem.raw})
+		case *sectionElement:
+			if elem.sigil != 0 {
+				node := ASTNode{Type: NodeParent, Name: elem.name, Indent: elem.indent, Line: elem.startline, Column: elem.startcol, Nodes: astNodes(elem.elems)}
+				if elem.sigil == blockSigil {
+					node.Type = NodeBlock
+				}
+				nodes = append(nodes, node)
+				continue
+			}
+			nodes = append(nodes, ASTNode{
+				Type:      NodeSection,
+				Name:      elem.name,
+				Inverted:  elem.inverted,
+				Condition: elem.cond,
+				Line:      elem.startline,
+				Column:    elem.startcol,
+				Nodes:     astNodes(elem.elems),
+			})
+		case *partialElement:
+			node := ASTNode{Type: NodePartial, Name: elem.name, Indent: elem.indent, Context: elem.context, Raw: elem.raw}
+			for _, param := range elem.params {
+				node.Params = append(node.Params, ASTParam{Key: param.key, Name: param.name, Value: param.value})
+			}
+			nodes = append(nodes, node)
+		case *helperElement:
+			node := ASTNode{Type: NodeHelper, Name: elem.name, Raw: elem.raw}
+			for _, arg := range elem.args {
+				node.Args = append(node.Args, ASTParam{Name: arg.name, Value: arg.value})
+			}
+			nodes = append(nodes, node)
+		case *exprElement:
+			nodes = append(nodes, ASTNode{Type: NodeExpression, Name: elem.src, Raw: elem.raw})
+		case *customTagElement:
+			nodes = append(nodes, ASTNode{Type: NodeCustom, Name: elem.String()})
+		}
+	}
+	return nodes
+}
+
+// CompileJSON compiles a template from the JSON form of its AST, as written by Template.MarshalJSON, using the
+// compiler's options as CompileBytes would.
+func (r *Compiler) CompileJSON(data []byte) (_ *Template, err error) {
+	defer recoverInternal(&err, "compiling an AST")
+	if r.maxTemplateBytes > 0 && len(data) > r.maxTemplateBytes {
+		return nil, &LimitError{Err: ErrTemplateTooLarge, Max: r.maxTemplateBytes}
+	}
+	var ast AST
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&ast); err != nil {
+		return nil, fmt.Errorf("mustache: invalid AST: %w", err)
+	}
+	if ast.Version < 1 || ast.Version > ASTVersion {
+		return nil, fmt.Errorf("mustache: unsupported AST version %d", ast.Version)
+	}
+
+	tmpl := &Template{
+		otag:           "{{",
+		ctag:           "}}",
+		curline:        1,
+		partial:        r.partial,
+		outputMode:     r.outputMode,
+		valueStringer:  r.valueStringer,
+		errorOnMissing: r.errorOnMissing,
+		parent:         r,
+		name:           ast.Name,
+	}
+	if ast.Escape != "" {
+		mode, ok := parseEscapeMode(ast.Escape)
+		if !ok {
+			return nil, fmt.Errorf("mustache: invalid AST: unknown escape mode %q", ast.Escape)
+		}
+		tmpl.outputMode = mode
+		tmpl.escapePragma = true
+	}
+	elems, err := tmpl.astElems(ast.Nodes, 0)
+	if err != nil {
+		return nil, fmt.Errorf("mustache: invalid AST: %w", err)
+	}
+	tmpl.elems = elems
+	tmpl.foldDefines()
+	tmpl.expandConstants()
+	return tmpl, nil
+}
+
+// astElems converts AST nodes at the given section depth to template elements.
+func (tmpl *Template) astElems(nodes []ASTNode, depth int) ([]interface{}, error) {
+	elems := make([]interface{}, 0, len(nodes))
+	for _, node := range nodes {
+		switch node.Type {
+		case NodeVariable, NodeSection, NodePartial, NodeHelper, NodeExpression, NodeCustom, NodeParent, NodeBlock:
+			if node.Name == "" {
+				return nil, fmt.Errorf("%s node without a name", node.Type)
+			}
+		}
+		switch node.Type {
+		case NodeText:
+			elems = append(elems, &textElement{[]byte(node.Text)})
+		case NodeVariable:
+			elems = append(elems, &varElement{name: node.Name, raw: node.Raw})
+		case NodeSection, NodeParent, NodeBlock:
+			if limit := tmpl.parent.maxSectionDepth; limit > 0 && depth >= limit {
+				return nil, &LimitError{Err: ErrSectionTooDeep, Max: limit, Line: node.Line}
+			}
+			children, err := tmpl.astElems(node.Nodes, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			se := &sectionElement{
+				name:      node.Name,
+				inverted:  node.Inverted,
+				cond:      node.Condition,
+				startline: node.Line,
+				startcol:  node.Column,
+				elems:     children,
+			}
+			switch node.Type {
+			case NodeParent:
+				se.sigil, se.indent, se.prov = parentSigil, node.Indent, tmpl.partial
+			case NodeBlock:
+				se.sigil, se.indent = blockSigil, node.Indent
+			}
+			elems = append(elems, se)
+		case NodePartial:
+			partial, err := tmpl.parsePartial(node.Name, []byte(node.Indent))
+			if err != nil {
+				return nil, err
+			}
+			partial.context = node.Context
+			partial.raw = node.Raw
+			for _, param := range node.Params {
+				value, err := astValue(param.Value)
+				if err != nil {
+					return nil, fmt.Errorf("partial %s: parameter %s: %w", node.Name, param.Key, err)
+				}
+				partial.params = append(partial.params, partialParam{key: param.Key, name: param.Name, value: value})
+			}
+			elems = append(elems, partial)
+		case NodeHelper:
+			h, ok := tmpl.parent.helper(node.Name)
+			if !ok {
+				return nil, fmt.Errorf("unknown helper %q", node.Name)
+			}
+			elem := &helperElement{name: node.Name, helper: h, raw: node.Raw}
+			for _, arg := range node.Args {
+				value, err := astValue(arg.Value)
+				if err != nil {
+					return nil, fmt.Errorf("helper %s: %w", node.Name, err)
+				}
+				elem.args = append(elem.args, helperArg{name: arg.Name, value: value})
+			}
+			elems = append(elems, elem)
+		case NodeExpression:
+			if !tmpl.parent.expressions {
+				return nil, fmt.Errorf("expression %q: expressions are not enabled", node.Name)
+			}
+			elem, err := tmpl.parseExpression(node.Name, node.Raw)
+			if err != nil {
+				return nil, err
+			}
+			elem.line, elem.col, elem.offset = 0, 0, 0
+			elems = append(elems, elem)
+		case NodeCustom:
+			elem, err := tmpl.parseCustomTag(node.Name)
+			if err != nil {
+				return nil, err
+			}
+			if elem == nil {
+				return nil, fmt.Errorf("custom tag %q: no handler for its sigil", node.Name)
+			}
+			elem.line, elem.col, elem.offset = 0, 0, 0
+			elems = append(elems, elem)
+		default:
+			return nil, fmt.Errorf("unknown node type %q", node.Type)
+		}
+	}
+	return elems, nil
+}
+
+// astValue converts a literal parameter value decoded from JSON to the type the parser would have produced.
+func astValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, string, bool:
+		return v, nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	}
+	return nil, fmt.Errorf("unsupported value %v", v)
+}
@@ -0,0 +1,87 @@
+package mustache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestASTJSON(t *testing.T) {
+	tmpl, err := New().CompileString("Hi {{name}}!\n{{#items}}\n  {{>item}}\n{{/items}}\n{{^items}}{{{none}}}{{/items}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := tmpl.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"version":1,"nodes":[{"type":"text","text":"Hi "},{"type":"variable","name":"name"},{"type":"text","text":"!\n"},` +
+		`{"type":"section","name":"items","line":2,"column":1,"nodes":[{"type":"partial","name":"item","indent":"  "}]},` +
+		`{"type":"section","name":"items","inverted":true,"line":5,"column":1,"nodes":[{"type":"variable","name":"none","raw":true}]}]}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+}
+
+func TestASTRoundTrip(t *testing.T) {
+	partials := &StaticProvider{Partials: map[string]string{"item": "<{{n}}>\n", "card": "[{{name}}:{{label}}:{{size}}:{{ok}}]", "layout": "<{{$body}}x{{/body}}>\n"}}
+	data := map[string]interface{}{
+		"name":   "<b>",
+		"items":  []map[string]string{{"n": "a"}, {"n": "b"}},
+		"author": map[string]string{"name": "Cy"},
+	}
+	tests := []struct {
+		tmpl string
+		cmpl *Compiler
+	}{
+		{"Hi {{name}} {{{name}}}{{! comment }}", New()},
+		{"{{=<% %>=}}<% name %><%#items%><%n%><%/items%>", New()},
+		{"{{%ESCAPE JSON}}{{name}}", New()},
+		{"{{#items}}\n  {{>item}}\n{{/items}}\n", New().WithPartials(partials)},
+		{"{{#items}}\n  {{>item raw}}\n{{/items}}\n", New().WithPartials(partials)},
+		{"{{#items}}\n  {{<layout}}{{$body}}{{n}}{{/body}}{{/layout}}\n{{/items}}\n", New().WithPartials(partials)},
+		{"{{#each items}}{{@index}}{{else}}none{{/each}}{{#if name}}{{> card author label='x' size=2 ok=false}}{{/if}}", New().WithSyntax(Handlebars).WithPartials(partials)},
+	}
+	for _, test := range tests {
+		tmpl, err := test.cmpl.CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast, err := tmpl.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := test.cmpl.CompileJSON(ast)
+		if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+			continue
+		}
+		expected, _ := tmpl.Render(data)
+		if output, err := loaded.Render(data); err != nil || output != expected {
+			t.Errorf("%q: expected %q, got %q, %v", test.tmpl, expected, output, err)
+		}
+		if again, _ := loaded.MarshalJSON(); string(again) != string(ast) {
+			t.Errorf("%q: expected the same AST, got %s", test.tmpl, again)
+		}
+	}
+}
+
+func TestCompileJSONErrors(t *testing.T) {
+	tests := []struct {
+		ast string
+		err string
+	}{
+		{`{"nodes":[]}`, "unsupported AST version 0"},
+		{`{"version":1,"nodes":[{"type":"tag"}]}`, `unknown node type "tag"`},
+		{`{"version":1,"nodes":[{"type":"variable"}]}`, "variable node without a name"},
+		{`{"version":1,"escape":"XML","nodes":[]}`, `unknown escape mode "XML"`},
+		{`{"version":1,"nodes":[{"type":"partial","name":"p","params":[{"key":"k","value":[1]}]}]}`, "partial p: parameter k: unsupported value"},
+		{`{"version":1,"nodes":[{"type":"section","name":"a","nodes":[{"type":"section","name":"b"}]}]}`, "sections nested too deeply"},
+		{`[`, "invalid AST"},
+	}
+	for _, test := range tests {
+		_, err := New().WithMaxSectionDepth(1).CompileJSON([]byte(test.ast))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got %v", test.ast, test.err, err)
+		}
+	}
+}
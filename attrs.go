@@ -0,0 +1,59 @@
+package mustache
+
+import (
+	"sort"
+	"strings"
+)
+
+// Attrs is a set of HTML attributes which a variable tag writes as escaped attributes in name order, such as
+// class="card" data-id="7", so that templates can add a varying set of attributes to an element without building the
+// string themselves:
+//
+//	<div {{attrs}}>
+//
+// In the HTML escape mode, escaped and raw tags both write the attributes as they are, as they are already escaped,
+// and raw sanitizers are not applied. Names which are not valid attribute names, such as those holding spaces, quotes
+// or =, are left out. Values are escaped but not otherwise checked, so values from users should not be given to
+// event handler attributes such as onclick, or to URL attributes such as href.
+type Attrs map[string]string
+
+// String returns the attributes as HTML.
+func (a Attrs) String() string {
+	names := make([]string, 0, len(a))
+	for name := range a {
+		if isAttrName(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		_ = htmlEscape(&b, a[name])
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// isAttrName reports whether name is a valid HTML attribute name.
+func isAttrName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c <= ' ' || c == 0x7f || strings.ContainsRune("\"'>/=<`", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isAttrs reports whether a value written by a tag is Attrs which are written as they are.
+func (tmpl *Template) isAttrs(value interface{}) bool {
+	_, ok := indirectValue(value).(Attrs)
+	return ok && tmpl.outputMode == EscapeHTML
+}
@@ -0,0 +1,33 @@
+package mustache
+
+import "testing"
+
+func TestAttrs(t *testing.T) {
+	attrs := Attrs{"data-id": "7", "class": `card "big"`, "title": "<b>&", "bad name": "x", `"><script>`: "x", "hidden": ""}
+	expected := `class="card &#34;big&#34;" data-id="7" hidden="" title="&lt;b&gt;&amp;"`
+	if s := attrs.String(); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+
+	sanitized := SanitizerFunc(func(string) string { return "sanitized" })
+	tmpl, err := New().WithRawSanitizer(sanitized).CompileString("<div {{attrs}}></div><p {{{attrs}}}></p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := tmpl.Render(map[string]interface{}{"attrs": attrs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "<div "+expected+"></div><p "+expected+"></p>" {
+		t.Errorf("unexpected output %s", output)
+	}
+
+	// other escape modes escape the attributes like any other string
+	tmpl, err = New().WithEscapeMode(EscapeJSON).CompileString(`"{{attrs}}"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(map[string]interface{}{"attrs": Attrs{"id": "a"}}); err != nil || output != `"id=\"a\""` {
+		t.Errorf("got %s, %v", output, err)
+	}
+}
@@ -0,0 +1,63 @@
+//go:build !mustache_minimal
+
+package mustache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"unicode/utf16"
+)
+
+func utf16File(s string, bigEndian bool) []byte {
+	out := []byte{0xff, 0xfe}
+	if bigEndian {
+		out = []byte{0xfe, 0xff}
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			out = append(out, byte(u>>8), byte(u))
+		} else {
+			out = append(out, byte(u), byte(u>>8))
+		}
+	}
+	return out
+}
+
+func TestByteOrderMarks(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		// the section tag on the first line stands alone despite the BOM
+		"page.mustache": []byte("\xef\xbb\xbf{{#items}}\n{{>item}}\n{{/items}}\n"),
+		"item.mustache": []byte("\xef\xbb\xbf- {{name}}\n"),
+		"le.mustache":   utf16File("{{name}} ☃ 😀", false),
+		"be.mustache":   utf16File("{{name}} ☃ 😀", true),
+		"odd.mustache":  []byte{0xff, 0xfe, 'a'},
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmpl := New().WithPartials(&FileProvider{Paths: []string{dir}})
+	data := map[string]interface{}{"name": "Jo", "items": []map[string]string{{"name": "a"}, {"name": "b"}}}
+	expected := map[string]string{"page.mustache": "- a\n- b\n", "le.mustache": "Jo ☃ 😀", "be.mustache": "Jo ☃ 😀"}
+	for name, want := range expected {
+		tmpl, err := cmpl.CompileFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output, err := tmpl.Render(data); err != nil || output != want {
+			t.Errorf("%s: expected %q, got %q, %v", name, want, output, err)
+		}
+	}
+	if _, err := cmpl.CompileFile(filepath.Join(dir, "odd.mustache")); err == nil {
+		t.Error("expected an error for truncated UTF-16")
+	}
+
+	fsys := fstest.MapFS{"item.mustache": {Data: files["item.mustache"]}}
+	if s, err := (&FSProvider{FS: fsys}).Get("item"); err != nil || s != "- {{name}}\n" {
+		t.Errorf("expected the BOM to be dropped, got %q, %v", s, err)
+	}
+}
@@ -0,0 +1,46 @@
+package mustache
+
+// WithMaxLambdaCalls limits the number of times a render may call lambdas to n, so that a template which is valid but
+// calls an expensive lambda in every row of a large list cannot monopolize a render farm shared by many tenants. A
+// render exceeding the limit fails with a *LimitError wrapping ErrTooManyLambdaCalls. Zero, the default, means no limit.
+func (r *Compiler) WithMaxLambdaCalls(n int) *Compiler {
+	r.budget.lambdaCalls = n
+	return r
+}
+
+// WithMaxPartialFetches limits the number of partials a render may fetch from its PartialProvider to n, counting
+// parents and each inclusion of a partial whose output is not reused by WithPartialCache, whether or not the provider
+// caches its sources. A render exceeding the limit fails with a *LimitError wrapping ErrTooManyPartialFetches. Zero,
+// the default, means no limit.
+func (r *Compiler) WithMaxPartialFetches(n int) *Compiler {
+	r.budget.partialFetches = n
+	return r
+}
+
+// WithMaxLookups limits the number of names a render may look up to n, counting each variable, section and helper
+// argument, in partials and lambda text as well as the template itself, so that templates which nest lists within
+// lists cannot render for ever. A render exceeding the limit fails with a *LimitError wrapping ErrTooManyLookups. Zero,
+// the default, means no limit.
+func (r *Compiler) WithMaxLookups(n int) *Compiler {
+	r.budget.lookups = n
+	return r
+}
+
+// renderBudget holds the limits set on a compiler, or the amounts spent by a render.
+type renderBudget struct {
+	lambdaCalls    int
+	partialFetches int
+	lookups        int
+}
+
+// spend counts one more use against a limit of max, returning a *LimitError wrapping err once it is exceeded.
+func spend(spent *int, max int, err error) error {
+	if max <= 0 {
+		return nil
+	}
+	*spent++
+	if *spent > max {
+		return &LimitError{Err: err, Max: max}
+	}
+	return nil
+}
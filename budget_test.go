@@ -0,0 +1,62 @@
+package mustache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRenderBudget(t *testing.T) {
+	upper := func(text string, render RenderFn) (string, error) {
+		return render(text)
+	}
+	data := map[string]interface{}{
+		"rows":  []map[string]int{{"n": 1}, {"n": 2}, {"n": 3}},
+		"same":  []map[string]int{{"n": 1}, {"n": 1}},
+		"upper": upper,
+	}
+	partials := &StaticProvider{Partials: map[string]string{"row": "{{n}}"}}
+	tests := []struct {
+		cmpl     *Compiler
+		template string
+		err      error
+		max      int
+		line     int
+	}{
+		{New().WithMaxLookups(3), "{{#rows}}{{n}}{{/rows}}", ErrTooManyLookups, 3, 1},
+		{New().WithMaxLookups(4), "{{#rows}}{{n}}{{/rows}}", nil, 0, 0},
+		{New().WithMaxLambdaCalls(2), "{{#rows}}\n{{#upper}}{{n}}{{/upper}}{{/rows}}", ErrTooManyLambdaCalls, 2, 2},
+		{New().WithMaxLambdaCalls(3), "{{#rows}}{{#upper}}{{n}}{{/upper}}{{/rows}}", nil, 0, 0},
+		{New().WithMaxPartialFetches(1).WithPartials(partials), "{{#rows}}\n\n{{>row}}{{/rows}}", ErrTooManyPartialFetches, 1, 3},
+		// partials whose output is reused are not fetched again
+		{New().WithMaxPartialFetches(1).WithPartials(partials).WithPartialCache(true), "{{#same}}{{>row}}{{/same}}", nil, 0, 0},
+	}
+	for _, test := range tests {
+		tmpl, err := test.cmpl.CompileString(test.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tmpl.Render(data)
+		if test.err == nil {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", test.template, err)
+			}
+			continue
+		}
+		var le *LimitError
+		var re *RenderError
+		if !errors.Is(err, test.err) || !errors.As(err, &le) || le.Max != test.max || !errors.As(err, &re) || re.Line != test.line {
+			t.Errorf("%q: expected %v at line %d, got %v", test.template, test.err, test.line, err)
+		}
+	}
+
+	// each render has a budget of its own
+	tmpl, err := New().WithMaxLookups(1).CompileString("{{n}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if output, err := tmpl.Render(map[string]int{"n": 1}); err != nil || output != "1" {
+			t.Errorf("expected %q, got %q, %v", "1", output, err)
+		}
+	}
+}
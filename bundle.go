@@ -0,0 +1,321 @@
+//go:build !mustache_minimal
+
+package mustache
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// The names of the files at the root of a template bundle which describe it.
+const (
+	ManifestName  = "manifest.json" // the manifest
+	SignatureName = "manifest.sig"  // the base64 encoded ed25519 signature of the manifest, for signed bundles
+)
+
+// Manifest describes the contents of a template bundle. A bundle is a zip file, or a tar file which may be compressed
+// with gzip, holding a manifest.json file and the templates it lists.
+type Manifest struct {
+	Templates []BundleEntry `json:"templates"`
+}
+
+// BundleEntry describes one template in a bundle.
+type BundleEntry struct {
+	Name    string `json:"name"`              // the name the template is rendered and included by
+	Version string `json:"version,omitempty"` // the version label, for one of several versions of a template
+	File    string `json:"file,omitempty"`    // the path of the template in the bundle, which defaults to the name
+	Escape  string `json:"escape,omitempty"`  // the escape mode, which defaults to that of the file's extension or compiler
+	Partial bool   `json:"partial,omitempty"` // whether the template is only used as a partial
+	SHA256  string `json:"sha256,omitempty"`  // the hex encoded SHA-256 checksum of the file, checked if set
+	// Metadata is added to the template's metadata, for instance to record the commit and approval it was built from.
+	Metadata Metadata `json:"metadata,omitempty"`
+}
+
+// bundleOptions are the settings of a Compiler for loading bundles and directories.
+type bundleOptions struct {
+	keys      []ed25519.PublicKey
+	checksums bool
+	// extModes maps file extensions to the escape modes of templates, if they are inferred
+	extModes map[string]EscapeMode
+}
+
+// defaultExtensionEscapeModes are the escape modes inferred from file extensions by WithExtensionEscapeModes.
+var defaultExtensionEscapeModes = map[string]EscapeMode{
+	".html": EscapeHTML,
+	".htm":  EscapeHTML,
+	".json": EscapeJSON,
+	".txt":  Raw,
+}
+
+// WithBundleKeys requires bundles loaded with LoadBundle to be signed with the private key matching one of keys. The
+// signature of the manifest is read from manifest.sig, and every entry in a signed manifest must have a checksum, so
+// that the signature covers the templates as well as the manifest. Bundles which fail verification are refused with
+// a *VerificationError.
+func (r *Compiler) WithBundleKeys(keys ...ed25519.PublicKey) *Compiler {
+	r.bundle.keys = keys
+	return r
+}
+
+// WithBundleChecksums requires every entry in the manifest of bundles loaded with LoadBundle to have a checksum.
+// Checksums which are present are always checked.
+func (r *Compiler) WithBundleChecksums(b bool) *Compiler {
+	r.bundle.checksums = b
+	return r
+}
+
+// WithExtensionEscapeModes sets the escape mode of each template compiled from a bundle or from a TemplateSource by
+// the extension of its file, so that a directory holding templates for several kinds of output needs only one
+// compiler. Without a manifest, the extension of a template is found in its name, so page.html.mustache is rendered
+// with EscapeHTML; the extensions .html and .htm select EscapeHTML, .json selects EscapeJSON and .txt selects Raw. The
+// overrides add to or replace these, and may hold longer suffixes such as ".json.mustache"; the longest extension
+// which matches applies. A mode set by a manifest entry, or an ESCAPE pragma, takes precedence, and templates whose
+// extensions have no mode have the compiler's. Partials within the set are rendered with their own modes.
+func (r *Compiler) WithExtensionEscapeModes(overrides map[string]EscapeMode) *Compiler {
+	modes := make(map[string]EscapeMode, len(defaultExtensionEscapeModes)+len(overrides))
+	for ext, mode := range defaultExtensionEscapeModes {
+		modes[ext] = mode
+	}
+	for ext, mode := range overrides {
+		modes[ext] = mode
+	}
+	r.bundle.extModes = modes
+	return r
+}
+
+// extensionEscapeMode returns the escape mode of the longest extension set with WithExtensionEscapeModes which ends
+// any of names, and whether there is one.
+func (r *Compiler) extensionEscapeMode(names ...string) (EscapeMode, bool) {
+	best := ""
+	for ext := range r.bundle.extModes {
+		for _, name := range names {
+			if len(ext) > len(best) && strings.HasSuffix(name, ext) {
+				best = ext
+			}
+		}
+	}
+	mode, ok := r.bundle.extModes[best]
+	return mode, ok && best != ""
+}
+
+// SignManifest returns the contents of the manifest.sig file for a bundle with the given manifest.json contents.
+func SignManifest(key ed25519.PrivateKey, manifest []byte) []byte {
+	sig := ed25519.Sign(key, manifest)
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(sig)))
+	base64.StdEncoding.Encode(out, sig)
+	return out
+}
+
+// verifySignature checks the signature of a bundle's manifest against the keys set with WithBundleKeys.
+func (r *Compiler) verifySignature(files map[string][]byte) error {
+	encoded, ok := files[SignatureName]
+	if !ok {
+		return &VerificationError{File: SignatureName, Err: ErrUnverified}
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return &VerificationError{File: SignatureName, Err: ErrBadSignature}
+	}
+	for _, key := range r.bundle.keys {
+		if ed25519.Verify(key, files[ManifestName], sig) {
+			return nil
+		}
+	}
+	return &VerificationError{File: SignatureName, Err: ErrBadSignature}
+}
+
+// LoadBundle loads a template bundle, as described for Manifest, and compiles its templates with the default options.
+func LoadBundle(r io.Reader) (*TemplateSet, error) {
+	return New().LoadBundle(r)
+}
+
+// LoadBundle loads a template bundle, as described for Manifest, and compiles its templates into a TemplateSet. The
+// templates may include one another as partials; the compiler's own PartialProvider is not used.
+func (r *Compiler) LoadBundle(rd io.Reader) (*TemplateSet, error) {
+	files, err := readBundle(rd, r.maxTemplateBytes)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	if _, ok := files[ManifestName]; !ok {
+		return nil, fmt.Errorf("bundle: no %s", ManifestName)
+	}
+	set, err := r.compileFiles(files)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	return set, nil
+}
+
+// templateExtensions are the extensions of the files taken to be templates when there is no manifest.
+var templateExtensions = []string{".mustache", ".stache"}
+
+// compileFiles compiles the files of a bundle or directory, keyed by their paths, into a TemplateSet. The templates are
+// those listed in the manifest if there is one, and otherwise every file with one of the templateExtensions, named by
+// its path without the extension.
+func (r *Compiler) compileFiles(files map[string][]byte) (*TemplateSet, error) {
+	verify := r.bundle.checksums || len(r.bundle.keys) > 0
+	data, ok := files[ManifestName]
+	if !ok {
+		if verify {
+			return nil, &VerificationError{File: ManifestName, Err: ErrUnverified}
+		}
+		var sources []setSource
+		for file, data := range files {
+			for _, ext := range templateExtensions {
+				if strings.HasSuffix(file, ext) {
+					src := setSource{name: strings.TrimSuffix(file, ext), data: data}
+					src.mode, src.hasMode = r.extensionEscapeMode(file, src.name)
+					sources = append(sources, src)
+					break
+				}
+			}
+		}
+		sort.Slice(sources, func(i, j int) bool { return sources[i].name < sources[j].name })
+		return r.compileSet(sources)
+	}
+
+	if len(r.bundle.keys) > 0 {
+		if err := r.verifySignature(files); err != nil {
+			return nil, err
+		}
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", ManifestName, err)
+	}
+	sources := make([]setSource, 0, len(manifest.Templates))
+	for _, entry := range manifest.Templates {
+		src, err := entry.source(files, verify)
+		if err != nil {
+			return nil, err
+		}
+		if !src.hasMode {
+			file := entry.File
+			if file == "" {
+				file = entry.Name
+			}
+			src.mode, src.hasMode = r.extensionEscapeMode(file)
+		}
+		sources = append(sources, src)
+	}
+	return r.compileSet(sources)
+}
+
+// source finds the file for an entry among the files of a bundle, and checks its checksum, which must be present if
+// requireChecksum is set.
+func (e *BundleEntry) source(files map[string][]byte, requireChecksum bool) (setSource, error) {
+	if e.Name == "" {
+		return setSource{}, errors.New("template with no name")
+	}
+	file := e.File
+	if file == "" {
+		file = e.Name
+	}
+	data, ok := files[cleanBundlePath(file)]
+	if !ok {
+		return setSource{}, fmt.Errorf("%s: no file %q", e.Name, file)
+	}
+	if e.SHA256 != "" {
+		sum := sha256.Sum256(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), e.SHA256) {
+			return setSource{}, &VerificationError{File: file, Err: ErrChecksumMismatch}
+		}
+	} else if requireChecksum {
+		return setSource{}, &VerificationError{File: file, Err: ErrUnverified}
+	}
+	src := setSource{name: e.Name, version: e.Version, data: data, partial: e.Partial, metadata: e.Metadata}
+	if e.Escape != "" {
+		if src.mode, ok = parseEscapeMode(e.Escape); !ok {
+			return setSource{}, fmt.Errorf("%s: unknown escape mode %q", e.Name, e.Escape)
+		}
+		src.hasMode = true
+	}
+	return src, nil
+}
+
+// readBundle reads the regular files in a zip or tar archive, keyed by their cleaned paths. If limit is positive, no
+// more than one byte past the limit is read from each file.
+func readBundle(rd io.Reader, limit int) (map[string][]byte, error) {
+	br := bufio.NewReader(rd)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		return readZip(br, limit)
+	case bytes.HasPrefix(magic, []byte("\x1f\x8b")):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return readTar(zr, limit)
+	}
+	return readTar(br, limit)
+}
+
+func readZip(rd io.Reader, limit int) (map[string][]byte, error) {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		fr, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := readLimited(fr, limit)
+		fr.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		files[cleanBundlePath(f.Name)] = data
+	}
+	return files, nil
+}
+
+func readTar(rd io.Reader, limit int) (map[string][]byte, error) {
+	tr := tar.NewReader(rd)
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := readLimited(tr, limit)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		files[cleanBundlePath(hdr.Name)] = data
+	}
+}
+
+// cleanBundlePath normalizes a path within a bundle, so that "./a/b" and "a/b" refer to the same file.
+func cleanBundlePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
@@ -0,0 +1,327 @@
+//go:build !mustache_minimal
+
+package mustache
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// bundleFile is a file to be written to a test bundle.
+type bundleFile struct {
+	name string
+	data string
+}
+
+func zipBundle(t *testing.T, files ...bundleFile) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, f.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarBundle(t *testing.T, w io.Writer, files ...bundleFile) {
+	tw := tar.NewWriter(w)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, f.data)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func manifestFile(t *testing.T, entries ...BundleEntry) bundleFile {
+	data, err := json.Marshal(Manifest{Templates: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundleFile{ManifestName, string(data)}
+}
+
+func checksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLoadBundle(t *testing.T) {
+	files := []bundleFile{
+		manifestFile(t,
+			BundleEntry{Name: "page", File: "templates/page.mustache", SHA256: checksum("<p>{{title}}</p>{{>ld}}")},
+			BundleEntry{Name: "ld", File: "./partials/ld.mustache", Escape: "JSON", Partial: true},
+			BundleEntry{Name: "text", Escape: "RAW"},
+		),
+		{"templates/page.mustache", "<p>{{title}}</p>{{>ld}}"},
+		{"partials/ld.mustache", `{"title":"{{title}}"}`},
+		{"text", "{{title}}"},
+	}
+	var tgz bytes.Buffer
+	gw := gzip.NewWriter(&tgz)
+	tarBundle(t, gw, files...)
+	gw.Close()
+	var plainTar bytes.Buffer
+	tarBundle(t, &plainTar, files...)
+
+	for format, data := range map[string][]byte{"zip": zipBundle(t, files...), "tar": plainTar.Bytes(), "tgz": tgz.Bytes()} {
+		set, err := LoadBundle(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if names := strings.Join(set.Names(), ","); names != "page,text" {
+			t.Errorf("%s: expected templates page,text, got %s", format, names)
+		}
+		ctx := map[string]string{"title": `a "b" <c>`}
+		output, err := set.Render("page", ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if expected := `<p>a &#34;b&#34; &lt;c&gt;</p>{"title":"a \"b\" <c>"}`; output != expected {
+			t.Errorf("%s: expected %q, got %q", format, expected, output)
+		}
+		if output, _ := set.Render("text", ctx); output != ctx["title"] {
+			t.Errorf("%s: expected raw output, got %q", format, output)
+		}
+		if _, err := set.Render("ld", ctx); !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("%s: expected ErrTemplateNotFound rendering a partial, got %v", format, err)
+		}
+	}
+}
+
+func TestExtensionEscapeModes(t *testing.T) {
+	ctx := map[string]string{"v": `<"a">`}
+	// a directory without a manifest, as read by DirSource
+	files := map[string][]byte{
+		"page.html.mustache":  []byte("<p>{{v}}</p>{{>data.json}}"),
+		"data.json.mustache":  []byte(`{"v":"{{v}}"}`),
+		"notes.txt.mustache":  []byte("{{v}}"),
+		"feed.xml.stache":     []byte("{{v}}"),
+		"pragma.txt.mustache": []byte("{{%ESCAPE HTML}}{{v}}"),
+		"plain.mustache":      []byte("{{v}}"),
+	}
+	cmpl := New().WithEscapeMode(EscapeJSON).WithExtensionEscapeModes(map[string]EscapeMode{".xml.stache": Raw})
+	set, err := cmpl.compileFiles(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"page.html":  `<p>&lt;&#34;a&#34;&gt;</p>{"v":"<\"a\">"}`,
+		"data.json":  `{"v":"<\"a\">"}`,
+		"notes.txt":  `<"a">`,
+		"feed.xml":   `<"a">`,
+		"pragma.txt": `&lt;&#34;a&#34;&gt;`,
+		"plain":      `<\"a\">`,
+	}
+	for name, want := range expected {
+		if output, err := set.Render(name, ctx); err != nil || output != want {
+			t.Errorf("%s: expected %q, got %q, %v", name, want, output, err)
+		}
+	}
+
+	bundle := zipBundle(t,
+		manifestFile(t, BundleEntry{Name: "notes", File: "notes.txt"}, BundleEntry{Name: "escaped", File: "escaped.txt", Escape: "HTML"}),
+		bundleFile{"notes.txt", "{{v}}"},
+		bundleFile{"escaped.txt", "{{v}}"},
+	)
+	set, err = New().WithExtensionEscapeModes(nil).LoadBundle(bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := set.Render("notes", ctx); output != ctx["v"] {
+		t.Errorf("expected the mode of a manifest entry's file extension, got %q", output)
+	}
+	if output, _ := set.Render("escaped", ctx); output != "&lt;&#34;a&#34;&gt;" {
+		t.Errorf("expected the mode of the manifest entry to take precedence, got %q", output)
+	}
+
+	// constants are escaped for the mode of their template
+	set, err = New().WithExtensionEscapeModes(nil).WithConstants(map[string]string{"brand": "A&B"}).compileFiles(map[string][]byte{
+		"page.html.mustache": []byte("{{brand}}"), "notes.txt.mustache": []byte("{{brand}}")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"page.html": "A&amp;B", "notes.txt": "A&B"} {
+		if output, err := set.Render(name, nil); err != nil || output != want {
+			t.Errorf("%s: expected %q, got %q, %v", name, want, output, err)
+		}
+	}
+}
+
+func TestLoadBundleErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []bundleFile
+		err   string
+	}{
+		{"no manifest", []bundleFile{{"a", "a"}}, "no manifest.json"},
+		{"missing file", []bundleFile{manifestFile(t, BundleEntry{Name: "a"})}, `a: no file "a"`},
+		{"checksum", []bundleFile{manifestFile(t, BundleEntry{Name: "a", SHA256: checksum("b")}), {"a", "a"}}, "a: checksum mismatch"},
+		{"escape mode", []bundleFile{manifestFile(t, BundleEntry{Name: "a", Escape: "XML"}), {"a", "a"}}, `unknown escape mode "XML"`},
+		{"duplicate", []bundleFile{manifestFile(t, BundleEntry{Name: "a"}, BundleEntry{Name: "a"}), {"a", "a"}}, `duplicate template "a"`},
+		{"parse", []bundleFile{manifestFile(t, BundleEntry{Name: "a"}), {"a", "{{#a}}"}}, "a: line 1: Section a has no closing tag"},
+	}
+	for _, test := range tests {
+		_, err := LoadBundle(bytes.NewReader(zipBundle(t, test.files...)))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestBundleVerification(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := manifestFile(t, BundleEntry{Name: "a", SHA256: checksum("{{a}}")})
+	unchecked := manifestFile(t, BundleEntry{Name: "a"})
+	signature := bundleFile{SignatureName, string(SignManifest(priv, []byte(manifest.data)))}
+	template := bundleFile{"a", "{{a}}"}
+
+	tests := []struct {
+		name  string
+		cmpl  *Compiler
+		files []bundleFile
+		err   error
+	}{
+		{"signed", New().WithBundleKeys(pub), []bundleFile{manifest, signature, template}, nil},
+		{"rotated key", New().WithBundleKeys(otherPub, pub), []bundleFile{manifest, signature, template}, nil},
+		{"wrong key", New().WithBundleKeys(otherPub), []bundleFile{manifest, signature, template}, ErrBadSignature},
+		{"unsigned", New().WithBundleKeys(pub), []bundleFile{manifest, template}, ErrUnverified},
+		{"tampered manifest", New().WithBundleKeys(pub), []bundleFile{unchecked, signature, template}, ErrBadSignature},
+		{"tampered template", New().WithBundleKeys(pub), []bundleFile{manifest, signature, {"a", "{{b}}"}}, ErrChecksumMismatch},
+		{"signed without checksums", New().WithBundleKeys(pub), []bundleFile{unchecked, {SignatureName, string(SignManifest(priv, []byte(unchecked.data)))}, template}, ErrUnverified},
+		{"checksums required", New().WithBundleChecksums(true), []bundleFile{unchecked, template}, ErrUnverified},
+		{"checksums present", New().WithBundleChecksums(true), []bundleFile{manifest, template}, nil},
+	}
+	for _, test := range tests {
+		_, err := test.cmpl.LoadBundle(bytes.NewReader(zipBundle(t, test.files...)))
+		if test.err == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		var verr *VerificationError
+		if !errors.As(err, &verr) || !errors.Is(err, test.err) {
+			t.Errorf("%s: expected a verification error wrapping %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	// a template which fails to compile is not a verification error
+	broken := bundleFile{"a", "{{#a}}"}
+	brokenManifest := manifestFile(t, BundleEntry{Name: "a", SHA256: checksum(broken.data)})
+	_, err = New().WithBundleKeys(pub).LoadBundle(bytes.NewReader(zipBundle(t, brokenManifest,
+		bundleFile{SignatureName, string(SignManifest(priv, []byte(brokenManifest.data)))}, broken)))
+	var verr *VerificationError
+	if err == nil || errors.As(err, &verr) {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
+
+func TestTemplateSetVersions(t *testing.T) {
+	set, err := LoadBundle(bytes.NewReader(zipBundle(t,
+		manifestFile(t,
+			BundleEntry{Name: "greeting", File: "v1"},
+			BundleEntry{Name: "greeting", Version: "v2", File: "v2"},
+			BundleEntry{Name: "name", Partial: true},
+		),
+		bundleFile{"v1", "Hello {{>name}}"},
+		bundleFile{"v2", "Hi {{>name}}!"},
+		bundleFile{"name", "{{name}}"},
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := map[string]string{"name": "Jo"}
+	if versions := strings.Join(set.Versions("greeting"), ","); versions != "v2" {
+		t.Errorf("expected versions v2, got %s", versions)
+	}
+	if output, _ := set.Render("greeting", ctx); output != "Hello Jo" {
+		t.Errorf("expected the unversioned template without a selector, got %q", output)
+	}
+	if output, _ := set.RenderVersion("greeting", "v2", ctx); output != "Hi Jo!" {
+		t.Errorf("expected version v2, got %q", output)
+	}
+	if _, err := set.RenderVersion("greeting", "v3", ctx); !errors.Is(err, ErrTemplateNotFound) || !strings.Contains(err.Error(), "greeting@v3") {
+		t.Errorf("expected ErrTemplateNotFound for a missing version, got %v", err)
+	}
+
+	var selected []string
+	set.SetSelector(func(name string, versions []string, data []interface{}) string {
+		selected = append(selected, name+":"+strings.Join(versions, ","))
+		return data[0].(map[string]string)["version"]
+	})
+	if output, _ := set.Render("greeting", map[string]string{"name": "Jo", "version": "v2"}); output != "Hi Jo!" {
+		t.Errorf("expected the selected version, got %q", output)
+	}
+	if strings.Join(selected, " ") != "greeting:v2" {
+		t.Errorf("unexpected selector calls %v", selected)
+	}
+
+	tmpl, err := New().CompileString("Yo {{name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set.AddVersion("greeting", "v3", tmpl)
+	if output, _ := set.Render("greeting", map[string]string{"name": "Jo", "version": "v3"}); output != "Yo Jo" {
+		t.Errorf("expected the added version, got %q", output)
+	}
+	if versions := strings.Join(set.Versions("greeting"), ","); versions != "v2,v3" {
+		t.Errorf("expected versions v2,v3, got %s", versions)
+	}
+}
+
+func TestBundleMetadata(t *testing.T) {
+	bundle := zipBundle(t,
+		manifestFile(t, BundleEntry{Name: "a", Metadata: Metadata{MetaApproval: "A-1"}}, BundleEntry{Name: "b"}),
+		bundleFile{"a", "a"}, bundleFile{"b", "b"})
+	set, err := New().WithMetadata(Metadata{MetaCommit: "abc123"}).LoadBundle(bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md := set.Lookup("a").Metadata().String(); md != "approval=A-1 commit=abc123" {
+		t.Errorf("unexpected metadata for a: %q", md)
+	}
+	if md := set.Lookup("b").Metadata().String(); md != "commit=abc123" {
+		t.Errorf("unexpected metadata for b: %q", md)
+	}
+}
+
+func TestBundleRelativePartials(t *testing.T) {
+	// templates in a set resolve partials against their own names
+	bundle := zipBundle(t,
+		manifestFile(t, BundleEntry{Name: "mail/welcome"}, BundleEntry{Name: "mail/signature", Partial: true}),
+		bundleFile{"mail/welcome", "{{>./signature}}!"}, bundleFile{"mail/signature", "Jo"})
+	set, err := LoadBundle(bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := set.Render("mail/welcome"); err != nil || output != "Jo!" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+}
@@ -0,0 +1,172 @@
+package mustache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// CachedTemplate renders a template, keeping the output of each render for a time so that renders with identical data
+// return it rather than rendering again. Concurrent renders with identical data render once, and share the output. It
+// suits pages which are rendered many times over with the same data, such as marketing pages. A CachedTemplate is
+// safe for concurrent use.
+//
+// Data is identified by a hash of its JSON encoding, in which map keys are sorted, so maps and structs with the same
+// contents are identical however they were built. Data which cannot be encoded, such as lambdas, is rendered without
+// caching. The JSON encoding leaves out unexported fields and the results of methods, so data whose rendering depends
+// on them must not be rendered with a CachedTemplate. Renders which fail are not cached, and output from the cache
+// creates no spans and calls no render hooks.
+type CachedTemplate struct {
+	tmpl *Template
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*cacheEntry
+	// sweepAt is the number of entries at which expired entries are next removed
+	sweepAt int
+}
+
+// cacheEntry is the output of a render, or of a render in progress if done is not yet closed.
+type cacheEntry struct {
+	done    chan struct{}
+	output  []byte
+	err     error
+	expires time.Time
+}
+
+// minCacheSweep is the least number of entries at which a CachedTemplate removes expired entries.
+const minCacheSweep = 64
+
+// NewCachedTemplate returns a CachedTemplate which renders tmpl, and keeps each output for ttl.
+func NewCachedTemplate(tmpl *Template, ttl time.Duration) *CachedTemplate {
+	return &CachedTemplate{
+		tmpl:    tmpl,
+		ttl:     ttl,
+		entries: make(map[[sha256.Size]byte]*cacheEntry),
+		sweepAt: minCacheSweep,
+	}
+}
+
+// Template returns the template the CachedTemplate renders.
+func (c *CachedTemplate) Template() *Template {
+	return c.tmpl
+}
+
+// Render renders the template with data, or returns the output of an earlier render with identical data.
+func (c *CachedTemplate) Render(data ...interface{}) (string, error) {
+	return c.RenderContext(context.Background(), data...)
+}
+
+// RenderContext renders the template like Render. If an identical render is in progress, it waits for its output
+// until ctx is done. If that render is given up because the context of the caller which started it is done, the
+// render is started again with ctx.
+func (c *CachedTemplate) RenderContext(ctx context.Context, data ...interface{}) (string, error) {
+	var buf bytes.Buffer
+	err := c.FrenderContext(ctx, &buf, data...)
+	return buf.String(), err
+}
+
+// Frender renders the template to an io.Writer like Render.
+func (c *CachedTemplate) Frender(out io.Writer, data ...interface{}) error {
+	return c.FrenderContext(context.Background(), out, data...)
+}
+
+// FrenderContext renders the template to an io.Writer like RenderContext. Nothing is written if the render fails.
+func (c *CachedTemplate) FrenderContext(ctx context.Context, out io.Writer, data ...interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return c.tmpl.FrenderContext(ctx, out, data...)
+	}
+	key := sha256.Sum256(encoded)
+
+	for {
+		c.mu.Lock()
+		entry, ok := c.entries[key]
+		if ok && isClosed(entry.done) && !time.Now().Before(entry.expires) {
+			delete(c.entries, key)
+			ok = false
+		}
+		if !ok {
+			entry = &cacheEntry{done: make(chan struct{})}
+			c.add(key, entry)
+		}
+		c.mu.Unlock()
+
+		if ok {
+			select {
+			case <-entry.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if isContextError(entry.err) {
+				// the render was given up by the caller which started it, rather than failing, so render again with
+				// this caller's context
+				continue
+			}
+		} else {
+			var buf bytes.Buffer
+			entry.err = c.tmpl.FrenderContext(ctx, &buf, data...)
+			entry.output = buf.Bytes()
+			entry.expires = time.Now().Add(c.ttl)
+			if entry.err != nil {
+				c.mu.Lock()
+				if c.entries[key] == entry {
+					delete(c.entries, key)
+				}
+				c.mu.Unlock()
+			}
+			close(entry.done)
+		}
+		if entry.err != nil {
+			return entry.err
+		}
+		_, err = out.Write(entry.output)
+		return err
+	}
+}
+
+// isContextError reports whether err is that of a context which was cancelled or whose deadline passed.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// add adds an entry to the cache, first removing the expired entries if there are enough of them to be worth it.
+// c.mu must be held.
+func (c *CachedTemplate) add(key [sha256.Size]byte, entry *cacheEntry) {
+	if len(c.entries) >= c.sweepAt {
+		now := time.Now()
+		for k, e := range c.entries {
+			if isClosed(e.done) && !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.sweepAt = 2 * len(c.entries)
+		if c.sweepAt < minCacheSweep {
+			c.sweepAt = minCacheSweep
+		}
+	}
+	c.entries[key] = entry
+}
+
+// Purge removes every output from the cache, for instance once the data the template is rendered with has changed.
+// Renders in progress are not affected.
+func (c *CachedTemplate) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[[sha256.Size]byte]*cacheEntry)
+	c.sweepAt = minCacheSweep
+}
+
+func isClosed(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,140 @@
+package mustache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachedTemplate(t *testing.T) {
+	var renders int32
+	release := make(chan struct{})
+	cmpl := New().WithHelper("count", func(args ...interface{}) (string, error) {
+		atomic.AddInt32(&renders, 1)
+		<-release
+		return "", nil
+	})
+	tmpl, err := cmpl.CompileString("{{count 1}}Hello {{name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := NewCachedTemplate(tmpl, time.Hour)
+
+	// concurrent identical renders render once
+	var wg sync.WaitGroup
+	outputs := make([]string, 10)
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outputs[i], _ = cached.Render(map[string]interface{}{"name": "Mary", "n": 1})
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, output := range outputs {
+		if output != "Hello Mary" {
+			t.Errorf("unexpected output %q", output)
+		}
+	}
+	if n := atomic.LoadInt32(&renders); n != 1 {
+		t.Errorf("expected 1 render, got %d", n)
+	}
+
+	// the same data built differently is identical
+	type person struct {
+		Name string `json:"name"`
+	}
+	for _, data := range []interface{}{map[string]string{"name": "Mary"}, person{"Mary"}} {
+		if output, _ := cached.Render(data); output != "Hello Mary" {
+			t.Errorf("unexpected output %q", output)
+		}
+	}
+	if n := atomic.LoadInt32(&renders); n != 2 {
+		t.Errorf("expected 2 renders, got %d", n)
+	}
+	if output, _ := cached.Render(map[string]string{"name": "Jo"}); output != "Hello Jo" || atomic.LoadInt32(&renders) != 3 {
+		t.Errorf("expected a render of new data, got %q after %d renders", output, atomic.LoadInt32(&renders))
+	}
+
+	cached.Purge()
+	cached.Render(map[string]string{"name": "Jo"})
+	if n := atomic.LoadInt32(&renders); n != 4 {
+		t.Errorf("expected 4 renders after purging, got %d", n)
+	}
+
+	// data which cannot be encoded is rendered every time
+	unencodable := map[string]interface{}{"name": "Lambda", "updates": make(chan int)}
+	for i := 0; i < 2; i++ {
+		if output, _ := cached.Render(unencodable); output != "Hello Lambda" {
+			t.Errorf("unexpected output %q", output)
+		}
+	}
+	if n := atomic.LoadInt32(&renders); n != 6 {
+		t.Errorf("expected 6 renders, got %d", n)
+	}
+}
+
+func TestCachedTemplateCancel(t *testing.T) {
+	sp := &slowProvider{release: make(chan struct{})}
+	tmpl, err := New().WithPartials(sp).CompileString("{{>p}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := NewCachedTemplate(tmpl, time.Hour)
+
+	// a render given up by the caller which started it is rendered again for the callers waiting for it
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := cached.RenderContext(ctx)
+		first <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	second := make(chan string, 1)
+	go func() {
+		output, err := cached.Render()
+		if err != nil {
+			output = err.Error()
+		}
+		second <- output
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	close(sp.release)
+	if output := <-second; output != "slow" {
+		t.Errorf("expected %q, got %q", "slow", output)
+	}
+}
+
+func TestCachedTemplateExpiry(t *testing.T) {
+	var renders int
+	tmpl, err := New().WithRenderSummary(func(RenderSummary) { renders++ }).WithErrors(true).CompileString("{{name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := NewCachedTemplate(tmpl, time.Millisecond)
+	cached.Render(map[string]string{"name": "a"})
+	time.Sleep(2 * time.Millisecond)
+	cached.Render(map[string]string{"name": "a"})
+	if renders != 2 {
+		t.Errorf("expected the output to expire, got %d renders", renders)
+	}
+
+	// failed renders are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := cached.Render(nil); err == nil {
+			t.Error("expected an error")
+		}
+	}
+	if renders != 4 {
+		t.Errorf("expected failed renders to be repeated, got %d renders", renders)
+	}
+}
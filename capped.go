@@ -0,0 +1,180 @@
+package mustache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"unicode/utf8"
+)
+
+// WithCapClosingTags makes RenderCapped close the elements left open by the truncation of the output of templates in
+// the HTML escape mode, such as "<p><b>Hello, Wor" becoming "<p><b>Hello, </b></p>", within the budget.
+func (r *Compiler) WithCapClosingTags(b bool) *Compiler {
+	r.capClosingTags = b
+	return r
+}
+
+// RenderCapped renders the template like Render, but truncates the output to at most maxBytes bytes, for payloads with
+// a hard limit such as SMS and push notifications, and reports whether it was truncated. The rendering stops once the
+// budget is spent. The output is cut at a safe place: never within a UTF-8 encoded character, and in the HTML mode,
+// never within a tag, a comment, a character reference such as &amp; or the contents of a script or style element,
+// and in the JSON modes, never within an escape sequence such as \u00e9. The output of the JSON value mode is no
+// longer valid JSON once truncated.
+func (tmpl *Template) RenderCapped(maxBytes int, data ...interface{}) (string, bool, error) {
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	w := &capWriter{max: maxBytes + 1}
+	err := tmpl.FrenderContext(context.Background(), w, data...)
+	if err != nil && !errors.Is(err, errCapReached) {
+		return "", false, err
+	}
+	out := w.buf.Bytes()
+	if len(out) <= maxBytes {
+		return string(out), false, nil
+	}
+	return string(tmpl.capOutput(out, maxBytes)), true, nil
+}
+
+// errCapReached stops a render which has written all the output RenderCapped can use.
+var errCapReached = errors.New("mustache: byte budget reached")
+
+// capWriter keeps the first max bytes written to it, and fails writes past them.
+type capWriter struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (w *capWriter) Write(p []byte) (int, error) {
+	room := w.max - w.buf.Len()
+	if len(p) <= room {
+		return w.buf.Write(p)
+	}
+	w.buf.Write(p[:room])
+	return room, errCapReached
+}
+
+// capOutput truncates out, which is longer than maxBytes, at a safe place at or before maxBytes.
+func (tmpl *Template) capOutput(out []byte, maxBytes int) []byte {
+	budget := maxBytes
+	for {
+		for budget > 0 && !utf8.RuneStart(out[budget]) {
+			budget--
+		}
+		cut := budget
+		var closing []byte
+		switch tmpl.outputMode {
+		case EscapeHTML:
+			var open []string
+			cut, open = htmlCut(out[:budget])
+			if tmpl.parent.capClosingTags {
+				for i := len(open) - 1; i >= 0; i-- {
+					closing = append(closing, "</"+open[i]+">"...)
+				}
+			}
+		case EscapeJSON, EscapeJSONValue:
+			cut = jsonCut(out[:budget])
+		}
+		if cut+len(closing) <= maxBytes {
+			return append(out[:cut:cut], closing...)
+		}
+		// Make room for the closing tags, which may change with the cut.
+		budget = maxBytes - len(closing)
+		if budget <= 0 {
+			return nil
+		}
+	}
+}
+
+// voidElements are the HTML elements which have no end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlCut returns the length of the longest prefix of src which ends outside tags, comments, character references and
+// the contents of raw elements, and the names of the elements left open in it, outermost first.
+func htmlCut(src []byte) (int, []string) {
+	var open []string
+	for i := 0; i < len(src); {
+		switch src[i] {
+		case '&':
+			j := i + 1
+			for j < len(src) && (isASCIILetter(src[j]) || src[j] >= '0' && src[j] <= '9' || src[j] == '#') {
+				j++
+			}
+			if j == len(src) {
+				return i, open
+			}
+			i = j
+		case '<':
+			if i+1 == len(src) {
+				return i, open
+			}
+			if bytes.HasPrefix(src[i:], []byte("<!--")) {
+				end := bytes.Index(src[i+4:], []byte("-->"))
+				if end < 0 {
+					return i, open
+				}
+				i += 4 + end + 3
+				continue
+			}
+			if c := src[i+1]; !(isASCIILetter(c) || c == '/' || c == '!' || c == '?') {
+				i++
+				continue
+			}
+			end := tagEnd(src, i)
+			if end < 0 {
+				return i, open
+			}
+			tag := src[i:end]
+			name := htmlTagName(tag)
+			switch {
+			case name == "":
+			case tag[1] == '/':
+				for k := len(open) - 1; k >= 0; k-- {
+					if open[k] == name {
+						open = open[:k]
+						break
+					}
+				}
+			case voidElements[name] || bytes.HasSuffix(tag, []byte("/>")):
+			default:
+				open = append(open, name)
+				for _, raw := range rawElements {
+					if name != raw {
+						continue
+					}
+					closing := indexFold(src[end:], "</"+raw)
+					if closing < 0 {
+						return i, open[:len(open)-1]
+					}
+					end += closing
+					break
+				}
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	return len(src), open
+}
+
+// jsonCut returns the length of the longest prefix of src which does not end within a JSON escape sequence.
+func jsonCut(src []byte) int {
+	for i := 0; i < len(src); i++ {
+		if src[i] != '\\' {
+			continue
+		}
+		n := 2
+		if i+1 < len(src) && src[i+1] == 'u' {
+			n = 6
+		}
+		if i+n > len(src) {
+			return i
+		}
+		i += n - 1
+	}
+	return len(src)
+}
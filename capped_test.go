@@ -0,0 +1,51 @@
+package mustache
+
+import (
+	"testing"
+)
+
+func TestRenderCapped(t *testing.T) {
+	tests := []struct {
+		mode     EscapeMode
+		closing  bool
+		template string
+		max      int
+		expected string
+	}{
+		{Raw, false, "{{msg}}", 100, "héllo wörld"},
+		{Raw, false, "{{msg}}", 2, "h"},
+		{EscapeHTML, false, "{{msg}} & co", 100, "héllo wörld & co"},
+		{EscapeHTML, false, "<p>{{v}}</p>", 7, "<p>a"},
+		{EscapeHTML, false, "<p>{{v}}</p>", 6, "<p>a"},
+		{EscapeHTML, true, "<p><b>{{v}}{{v}}</b></p>", 16, "<p><b>a</b></p>"},
+		{EscapeHTML, true, "<p><b>{{v}}{{v}}</b></p>", 9, ""},
+		{EscapeHTML, true, "<p><br>{{msg}}</p>", 20, "<p><br>héllo w</p>"},
+		{EscapeHTML, true, `<p>x</p><script>var s = "</b>";</script>`, 30, "<p>x</p>"},
+		{EscapeHTML, true, `<a title="x>y">{{msg}}</a>`, 19, `<a title="x>y"></a>`},
+		{EscapeJSON, false, `"{{msg}}"`, 3, `"h`},
+		{EscapeJSON, false, `"{{q}}"`, 4, `"a\"`},
+	}
+	data := map[string]string{"msg": "héllo wörld", "v": "a<b", "q": `a"b`}
+	for _, test := range tests {
+		tmpl, err := New().WithEscapeMode(test.mode).WithCapClosingTags(test.closing).CompileString(test.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, truncated, err := tmpl.RenderCapped(test.max, data)
+		if err != nil || output != test.expected || len(output) > test.max {
+			t.Errorf("%q capped at %d: expected %q, got %q, %v", test.template, test.max, test.expected, output, err)
+		}
+		full, _ := tmpl.Render(data)
+		if truncated != (full != output) {
+			t.Errorf("%q capped at %d: truncated is %v for %q of %q", test.template, test.max, truncated, output, full)
+		}
+	}
+
+	tmpl, err := New().WithErrors(true).CompileString("{{missing}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tmpl.RenderCapped(10, nil); err == nil {
+		t.Error("expected the error of a failed render")
+	}
+}
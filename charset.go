@@ -0,0 +1,162 @@
+package mustache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ErrUnmappable indicates that output held a character which the charset set with WithCharset cannot encode.
+var ErrUnmappable = errors.New("character not in charset")
+
+// Charset is a character encoding which rendered output can be transcoded to with WithCharset, for consumers which do
+// not understand UTF-8, such as legacy email gateways and printers. Latin1 and ASCII are provided; EncoderCharset
+// adapts the encodings of golang.org/x/text/encoding, such as Shift_JIS.
+type Charset struct {
+	Name string // the name of the charset, as in the charset parameter of a Content-Type header
+	// NewEncoder returns an encoder for a single render, which need not be safe for concurrent use.
+	NewEncoder func() RuneEncoder
+}
+
+// RuneEncoder appends the encoding of r to dst, and reports whether r can be encoded.
+type RuneEncoder func(dst []byte, r rune) ([]byte, bool)
+
+// Latin1 is the ISO-8859-1 charset, which encodes the first 256 code points as single bytes.
+var Latin1 = Charset{Name: "ISO-8859-1", NewEncoder: func() RuneEncoder { return appendByteBelow(0x100) }}
+
+// ASCII is the US-ASCII charset.
+var ASCII = Charset{Name: "US-ASCII", NewEncoder: func() RuneEncoder { return appendByteBelow(0x80) }}
+
+func appendByteBelow(limit rune) RuneEncoder {
+	return func(dst []byte, r rune) ([]byte, bool) {
+		if r < 0 || r >= limit {
+			return dst, false
+		}
+		return append(dst, byte(r)), true
+	}
+}
+
+// EncoderCharset returns a Charset which encodes with the encoders made by newEncoder, such as the NewEncoder method
+// of an encoding in golang.org/x/text/encoding, which needs no adapting:
+//
+//	sjis := mustache.EncoderCharset("Shift_JIS", japanese.ShiftJIS.NewEncoder)
+//
+// A character which the encoder's String method fails to encode is unmappable.
+func EncoderCharset[E interface{ String(string) (string, error) }](name string, newEncoder func() E) Charset {
+	return Charset{Name: name, NewEncoder: func() RuneEncoder {
+		enc := newEncoder()
+		return func(dst []byte, r rune) ([]byte, bool) {
+			s, err := enc.String(string(r))
+			if err != nil {
+				return dst, false
+			}
+			return append(dst, s...), true
+		}
+	}}
+}
+
+// Unmappable is what WithCharset does with characters which the charset cannot encode.
+type Unmappable int
+
+const (
+	UnmappableError   Unmappable = iota // fail the render with an error wrapping ErrUnmappable
+	UnmappableReplace                   // write a question mark in place of the character
+	UnmappableCharRef                   // write an HTML numeric character reference, such as &#8364; for €
+)
+
+// WithCharset transcodes the output of the compiled templates from UTF-8 to cs as it is written, handling the
+// characters cs cannot encode as unmappable says. FrenderHTTP names the charset in the Content-Type header. A render
+// which fails on an unmappable character may have written the output before it.
+//
+// Character references are only understood by HTML and XML, so UnmappableCharRef suits HTML templates, and
+// UnmappableReplace or UnmappableError text templates.
+func (r *Compiler) WithCharset(cs Charset, unmappable Unmappable) *Compiler {
+	r.charset = cs
+	r.unmappable = unmappable
+	return r
+}
+
+// charsetWriter transcodes UTF-8 to a charset, holding back a character split between writes until the rest of it
+// is written. Bytes which are not valid UTF-8 are unmappable.
+type charsetWriter struct {
+	w          io.Writer
+	name       string
+	encode     RuneEncoder
+	unmappable Unmappable
+	pending    []byte
+	buf        []byte
+}
+
+func newCharsetWriter(w io.Writer, cs Charset, unmappable Unmappable) *charsetWriter {
+	return &charsetWriter{w: w, name: cs.Name, encode: cs.NewEncoder(), unmappable: unmappable}
+}
+
+func (cw *charsetWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(cw.pending) > 0 {
+		p = append(cw.pending, p...)
+		cw.pending = nil
+	}
+	cw.buf = cw.buf[:0]
+	for len(p) > 0 {
+		r, size := utf8.DecodeRune(p)
+		if r == utf8.RuneError && size <= 1 && !utf8.FullRune(p) {
+			cw.pending = append(cw.pending, p...)
+			break
+		}
+		if err := cw.encodeRune(r); err != nil {
+			return 0, err
+		}
+		p = p[size:]
+	}
+	if _, err := cw.w.Write(cw.buf); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (cw *charsetWriter) encodeRune(r rune) error {
+	var ok bool
+	if cw.buf, ok = cw.encode(cw.buf, r); ok {
+		return nil
+	}
+	var alt string
+	switch cw.unmappable {
+	case UnmappableReplace:
+		alt = "?"
+	case UnmappableCharRef:
+		alt = "&#" + strconv.Itoa(int(r)) + ";"
+	default:
+		return fmt.Errorf("%U cannot be encoded in %s: %w", r, cw.name, ErrUnmappable)
+	}
+	for _, c := range alt {
+		if cw.buf, ok = cw.encode(cw.buf, c); !ok {
+			return fmt.Errorf("%q cannot be encoded in %s: %w", c, cw.name, ErrUnmappable)
+		}
+	}
+	return nil
+}
+
+// Flush passes a flush on to the underlying writer, if it is a Flusher.
+func (cw *charsetWriter) Flush() error {
+	if f, ok := cw.w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// close handles the bytes of an incomplete character left at the end of the output.
+func (cw *charsetWriter) close() error {
+	if len(cw.pending) == 0 {
+		return nil
+	}
+	cw.pending = nil
+	cw.buf = cw.buf[:0]
+	if err := cw.encodeRune(utf8.RuneError); err != nil {
+		return err
+	}
+	_, err := cw.w.Write(cw.buf)
+	return err
+}
@@ -0,0 +1,76 @@
+package mustache
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCharset(t *testing.T) {
+	data := map[string]string{"name": "Zoë", "price": "5 €"}
+	tests := []struct {
+		unmappable Unmappable
+		expected   string
+		err        error
+	}{
+		{UnmappableReplace, "Zo\xeb: 5 ?", nil},
+		{UnmappableCharRef, "Zo\xeb: 5 &#8364;", nil},
+		{UnmappableError, "", ErrUnmappable},
+	}
+	for _, test := range tests {
+		tmpl, err := New().WithCharset(Latin1, test.unmappable).CompileString("{{{name}}}: {{{price}}}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(data)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%v: expected %v, got %v", test.unmappable, test.err, err)
+		} else if err == nil && output != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.unmappable, test.expected, output)
+		}
+	}
+}
+
+func TestCharsetWriter(t *testing.T) {
+	var buf bytes.Buffer
+	cw := newCharsetWriter(&buf, Latin1, UnmappableReplace)
+	// characters split between writes are held back until they are complete
+	for _, b := range []byte("né€") {
+		if _, err := cw.Write([]byte{b}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := cw.Write([]byte("\xc3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "n\xe9??" {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+// upperEncoder stands in for the encoders of golang.org/x/text/encoding.
+type upperEncoder struct{}
+
+func newUpperEncoder() *upperEncoder { return &upperEncoder{} }
+
+func (*upperEncoder) String(s string) (string, error) {
+	if s == "!" {
+		return "", errors.New("unsupported")
+	}
+	return strings.ToUpper(s), nil
+}
+
+func TestEncoderCharset(t *testing.T) {
+	cs := EncoderCharset("X-UPPER", newUpperEncoder)
+	tmpl, err := New().WithCharset(cs, UnmappableReplace).CompileString("hello {{name}}!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(map[string]string{"name": "world"}); err != nil || output != "HELLO WORLD?" {
+		t.Errorf("got %q, %v", output, err)
+	}
+}
@@ -0,0 +1,189 @@
+// Package compat compares the behavior of this package with that of other mustache engines, for migrating templates
+// from them. It renders a shared corpus of templates, Corpus, with this package, and reports where its results diverge
+// from the results recorded for the hoisie/mustache and cbroglie/mustache forks, or from engines rendered alongside it:
+//
+//	report := compat.Compare(compat.Corpus, nil)
+//	report.WriteTo(os.Stdout)
+//
+// Recorded results can be checked against the engine itself by passing an Engine for it, such as one calling
+// cbroglie/mustache from a module which depends on it:
+//
+//	cbroglie := compat.NewEngine(compat.Cbroglie, func(tmpl string, partials map[string]string, data interface{}) (string, error) {
+//		return cbmustache.RenderPartials(tmpl, &cbmustache.StaticProvider{Partials: partials}, data)
+//	})
+//	report := compat.Compare(compat.Corpus, nil, cbroglie)
+package compat
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hayeah/mustache/v2"
+)
+
+// Case is a template of the corpus, with the data it is rendered with.
+type Case struct {
+	Name     string            // the name of the case, such as "section/zero"
+	Note     string            // the behavior the case exercises
+	Template string            // the template
+	Data     interface{}       // the data the template is rendered with
+	Partials map[string]string // the partials available to the template, by name
+	// Recorded holds the results of other engines, by engine name, where they are known.
+	Recorded map[string]Result
+}
+
+// Result is the result of rendering a case: its output, or the message of the error it failed with.
+type Result struct {
+	Output string
+	Err    string
+}
+
+func (r Result) String() string {
+	if r.Err != "" {
+		return "error: " + r.Err
+	}
+	return fmt.Sprintf("%q", r.Output)
+}
+
+// Engine is a mustache engine to compare this package with.
+type Engine interface {
+	// Name names the engine. The results of an engine take the place of any recorded under its name.
+	Name() string
+	// Render renders tmpl with data and the given partials.
+	Render(tmpl string, partials map[string]string, data interface{}) (string, error)
+}
+
+// NewEngine returns an Engine which renders with the function render.
+func NewEngine(name string, render func(tmpl string, partials map[string]string, data interface{}) (string, error)) Engine {
+	return &engine{name, render}
+}
+
+type engine struct {
+	name   string
+	render func(string, map[string]string, interface{}) (string, error)
+}
+
+func (e *engine) Name() string {
+	return e.name
+}
+
+func (e *engine) Render(tmpl string, partials map[string]string, data interface{}) (string, error) {
+	return e.render(tmpl, partials, data)
+}
+
+// Ours returns the Engine of this package, rendering with the compilers returned by cmpl, or by mustache.New if cmpl
+// is nil, so that the corpus can be rendered with the options a migrated application will use. The partials of each
+// case are provided by a mustache.StaticProvider.
+func Ours(cmpl func() *mustache.Compiler) Engine {
+	if cmpl == nil {
+		cmpl = mustache.New
+	}
+	return NewEngine("hayeah/mustache", func(tmpl string, partials map[string]string, data interface{}) (string, error) {
+		t, err := cmpl().WithPartials(&mustache.StaticProvider{Partials: partials}).CompileString(tmpl)
+		if err != nil {
+			return "", err
+		}
+		return t.Render(data)
+	})
+}
+
+// Divergence is a case for which this package and another engine give different results.
+type Divergence struct {
+	Case   string // the name of the case
+	Note   string // the behavior the case exercises
+	Engine string // the name of the other engine
+	Theirs Result // the other engine's result
+	Ours   Result // this package's result
+}
+
+// Report is the outcome of a comparison.
+type Report struct {
+	Cases       int            // the number of cases compared
+	Engines     []string       // the names of the other engines, sorted
+	Compared    map[string]int // the number of cases compared with each engine, by name
+	Divergences []Divergence   // the divergences, by case and then engine
+}
+
+// Compare renders each case of corpus with this package, using the compilers returned by cmpl as described for Ours,
+// and compares the results with those of the given engines, and with the recorded results of any other engines. An
+// engine is compared only on the cases for which it has results. A panic while rendering a case is reported as its
+// error.
+func Compare(corpus []Case, cmpl func() *mustache.Compiler, engines ...Engine) *Report {
+	ours := Ours(cmpl)
+	report := &Report{Cases: len(corpus), Compared: make(map[string]int)}
+	live := make(map[string]Engine, len(engines))
+	for _, e := range engines {
+		live[e.Name()] = e
+	}
+	for _, c := range corpus {
+		got := run(ours, c)
+		theirs := make(map[string]Result, len(c.Recorded)+len(live))
+		for name, r := range c.Recorded {
+			theirs[name] = r
+		}
+		for name, e := range live {
+			theirs[name] = run(e, c)
+		}
+		names := make([]string, 0, len(theirs))
+		for name := range theirs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, ok := report.Compared[name]; !ok {
+				report.Engines = append(report.Engines, name)
+			}
+			report.Compared[name]++
+			if r := theirs[name]; r != got {
+				report.Divergences = append(report.Divergences, Divergence{c.Name, c.Note, name, r, got})
+			}
+		}
+	}
+	sort.Strings(report.Engines)
+	return report
+}
+
+// run renders c with e.
+func run(e Engine, c Case) (r Result) {
+	defer func() {
+		if v := recover(); v != nil {
+			r = Result{Err: fmt.Sprintf("panic: %v", v)}
+		}
+	}()
+	out, err := e.Render(c.Template, c.Partials, c.Data)
+	if err != nil {
+		return Result{Err: err.Error()}
+	}
+	return Result{Output: out}
+}
+
+// Diverged returns the names of the cases on which this package diverged from the named engine.
+func (r *Report) Diverged(engine string) []string {
+	var cases []string
+	for _, d := range r.Divergences {
+		if d.Engine == engine {
+			cases = append(cases, d.Case)
+		}
+	}
+	return cases
+}
+
+// WriteTo writes the report as text: a summary line for each engine, followed by each divergence with the results of
+// both engines.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, name := range r.Engines {
+		fmt.Fprintf(&b, "%s: %d of %d cases diverge\n", name, len(r.Diverged(name)), r.Compared[name])
+	}
+	for _, d := range r.Divergences {
+		fmt.Fprintf(&b, "\n%s (%s)\n", d.Case, d.Engine)
+		if d.Note != "" {
+			fmt.Fprintf(&b, "  %s\n", d.Note)
+		}
+		fmt.Fprintf(&b, "  %s: %s\n  ours: %s\n", d.Engine, d.Theirs, d.Ours)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
@@ -0,0 +1,78 @@
+package compat
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestCorpus pins down the cases on which this package diverges from the recorded engines, so that a change in
+// behavior which migrating applications would notice is made deliberately.
+func TestCorpus(t *testing.T) {
+	report := Compare(Corpus, nil)
+	want := map[string][]string{
+		Cbroglie: {
+			"lookup/unexported", "tag/dollar-name", "tag/less-than-name", "tag/percent-name", "partial/malformed",
+			"inheritance/parent", "error/interleaved",
+		},
+		Hoisie: {"lookup/dotted", "error/unclosed-section", "error/unclosed-tag", "error/stray-closing-tag"},
+	}
+	for engine, cases := range want {
+		if got := report.Diverged(engine); !reflect.DeepEqual(got, cases) {
+			t.Errorf("%s: diverged on %q, want %q", engine, got, cases)
+		}
+	}
+	if !reflect.DeepEqual(report.Engines, []string{Cbroglie, Hoisie}) {
+		t.Errorf("compared with %q", report.Engines)
+	}
+	names := make(map[string]bool)
+	for _, c := range Corpus {
+		if names[c.Name] {
+			t.Errorf("duplicate case %s", c.Name)
+		}
+		names[c.Name] = true
+		if _, ok := c.Recorded[Cbroglie]; !ok {
+			t.Errorf("%s: no result recorded for %s", c.Name, Cbroglie)
+		}
+	}
+}
+
+func TestCompareEngines(t *testing.T) {
+	corpus := []Case{
+		{Name: "same", Template: "{{a}}", Data: map[string]string{"a": "x"}},
+		{Name: "different", Template: "{{a}}", Data: map[string]string{"a": "y"}, Recorded: map[string]Result{"other": {Output: "z"}}},
+		{Name: "panics", Template: "{{a}}"},
+	}
+	other := NewEngine("other", func(tmpl string, partials map[string]string, data interface{}) (string, error) {
+		if data == nil {
+			panic("no data")
+		}
+		if data.(map[string]string)["a"] == "y" {
+			return "", errors.New("unsupported")
+		}
+		return "x", nil
+	})
+	report := Compare(corpus, nil, other)
+	if got := report.Diverged("other"); !reflect.DeepEqual(got, []string{"different", "panics"}) {
+		t.Fatalf("diverged on %q", got)
+	}
+	// the live engine's result takes the place of the recorded one
+	if d := report.Divergences[0]; d.Theirs != (Result{Err: "unsupported"}) || d.Ours != (Result{Output: "y"}) {
+		t.Errorf("got %+v", d)
+	}
+	if d := report.Divergences[1]; d.Theirs != (Result{Err: "panic: no data"}) || d.Ours != (Result{}) {
+		t.Errorf("got %+v", d)
+	}
+
+	var b strings.Builder
+	if _, err := report.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "other: 2 of 3 cases diverge\n\n" +
+		"different (other)\n  other: error: unsupported\n  ours: \"y\"\n\n" +
+		"panics (other)\n  other: error: panic: no data\n  ours: \"\"\n"
+	if b.String() != want {
+		t.Errorf("got report\n%s\nwant\n%s", b.String(), want)
+	}
+}
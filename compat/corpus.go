@@ -0,0 +1,396 @@
+package compat
+
+// The names of the engines whose results are recorded in Corpus.
+const (
+	// Cbroglie is github.com/cbroglie/mustache, which this package was forked from by way of runZeroInc/mustache. Its
+	// results were recorded by rendering the corpus with the runZeroInc/mustache code this package was forked from,
+	// which adds escape modes, lambdas and a fluent API to cbroglie/mustache.
+	Cbroglie = "cbroglie/mustache"
+	// Hoisie is github.com/hoisie/mustache, which cbroglie/mustache was forked from. It can't be built with current
+	// versions of Go, so its results were worked out from its source, and are recorded only for the cases where that is
+	// unambiguous. Its Render returns no error: a template which fails to parse renders as the error's message.
+	Hoisie = "hoisie/mustache"
+)
+
+type person struct {
+	First, Last string
+	secret      string
+}
+
+func (p person) Name() string {
+	return p.First + " " + p.Last
+}
+
+type employee struct {
+	person
+	Title string
+}
+
+type account struct {
+	Owner *person
+}
+
+// Corpus is the shared corpus of cases, covering the behaviors in which mustache engines commonly differ beyond those
+// the mustache spec pins down: escaping, truthiness, name lookup, value formatting, whitespace and errors.
+var Corpus = []Case{
+	{
+		Name:     "escape/html",
+		Note:     "the characters escaped in the HTML mode, and how",
+		Template: "{{s}}",
+		Data:     map[string]interface{}{"s": `"it's" <b>&`},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "&#34;it&#39;s&#34; &lt;b&gt;&amp;"},
+			Hoisie:   {Output: "&#34;it&#39;s&#34; &lt;b&gt;&amp;"},
+		},
+	},
+	{
+		Name:     "escape/triple",
+		Note:     "triple mustaches and ampersand tags write values unescaped",
+		Template: "{{{s}}} {{&s}}",
+		Data:     map[string]interface{}{"s": "<b>"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "<b> <b>"},
+			Hoisie:   {Output: "<b> <b>"},
+		},
+	},
+	{
+		Name:     "missing/variable",
+		Note:     "a name missing from the data renders nothing",
+		Template: "[{{missing}}]",
+		Data:     map[string]interface{}{},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[]"},
+			Hoisie:   {Output: "[]"},
+		},
+	},
+	{
+		Name:     "missing/nil",
+		Note:     "a name whose value is nil",
+		Template: "[{{v}}]",
+		Data:     map[string]interface{}{"v": nil},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[&lt;nil&gt;]"},
+			Hoisie:   {Output: "[&lt;nil&gt;]"},
+		},
+	},
+	{
+		Name:     "missing/nil-pointer",
+		Note:     "a field holding a nil pointer",
+		Template: "[{{Owner}}][{{Owner.First}}]",
+		Data:     account{},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[&lt;nil&gt;][]"},
+		},
+	},
+	{
+		Name:     "lookup/dotted",
+		Note:     "dotted names look into nested maps",
+		Template: "{{a.b.c}}",
+		Data:     map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": "deep"}}},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "deep"},
+			Hoisie:   {Output: ""},
+		},
+	},
+	{
+		Name:     "lookup/dotted-first-match",
+		Note:     "a dotted name is resolved only within the first context holding its first part",
+		Template: "{{#inner}}[{{a.b}}]{{/inner}}",
+		Data: map[string]interface{}{
+			"a":     map[string]interface{}{"b": "outer"},
+			"inner": map[string]interface{}{"a": map[string]interface{}{}},
+		},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[]"},
+		},
+	},
+	{
+		Name:     "lookup/method",
+		Note:     "methods of structs are called",
+		Template: "{{Name}} {{First}}",
+		Data:     person{First: "Ada", Last: "Lovelace"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "Ada Lovelace Ada"},
+			Hoisie:   {Output: "Ada Lovelace Ada"},
+		},
+	},
+	{
+		Name:     "lookup/unexported",
+		Note:     "unexported fields are not visible",
+		Template: "[{{secret}}]",
+		Data:     person{secret: "hidden"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[]"},
+		},
+	},
+	{
+		Name:     "lookup/embedded",
+		Note:     "the fields and methods of embedded structs are promoted",
+		Template: "{{First}} {{Name}} {{Title}}",
+		Data:     employee{person: person{First: "Grace", Last: "Hopper"}, Title: "Admiral"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "Grace Grace Hopper Admiral"},
+		},
+	},
+	{
+		Name:     "lookup/index",
+		Note:     "a number as the part of a dotted name",
+		Template: "[{{list.0}}]",
+		Data:     map[string]interface{}{"list": []string{"first", "second"}},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[]"},
+		},
+	},
+	{
+		Name:     "lookup/int-keys",
+		Note:     "maps whose keys are not strings",
+		Template: "[{{1}}]",
+		Data:     map[int]string{1: "one"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[]"},
+		},
+	},
+	{
+		Name:     "lookup/implicit-map",
+		Note:     "the implicit iterator writes a map as Go formats it",
+		Template: "{{.}}",
+		Data:     map[string]int{"a": 1},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "map[a:1]"},
+		},
+	},
+	{
+		Name:     "section/zero",
+		Note:     "whether zero is empty",
+		Template: "[{{#n}}yes{{/n}}][{{^n}}no{{/n}}]",
+		Data:     map[string]interface{}{"n": 0},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[][no]"},
+		},
+	},
+	{
+		Name:     "section/empty-string",
+		Note:     "whether the empty string is empty",
+		Template: "[{{#s}}yes{{/s}}][{{^s}}no{{/s}}]",
+		Data:     map[string]interface{}{"s": ""},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[][no]"},
+		},
+	},
+	{
+		Name:     "section/blank-string",
+		Note:     "whether a string of spaces is empty",
+		Template: "[{{#s}}yes{{/s}}][{{^s}}no{{/s}}]",
+		Data:     map[string]interface{}{"s": "  "},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[][no]"},
+		},
+	},
+	{
+		Name:     "section/empty-map",
+		Note:     "whether an empty map is empty",
+		Template: "[{{#m}}yes{{/m}}][{{^m}}no{{/m}}]",
+		Data:     map[string]interface{}{"m": map[string]interface{}{}},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[yes][]"},
+		},
+	},
+	{
+		Name:     "section/nil-pointer",
+		Note:     "whether a nil pointer is empty",
+		Template: "[{{#Owner}}yes{{/Owner}}][{{^Owner}}no{{/Owner}}]",
+		Data:     account{},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[][no]"},
+		},
+	},
+	{
+		Name:     "section/scalar",
+		Note:     "a section over a value which is neither a list nor a map pushes the value",
+		Template: "{{#s}}[{{.}}]{{/s}}",
+		Data:     map[string]interface{}{"s": "x"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[x]"},
+		},
+	},
+	{
+		Name:     "section/list",
+		Note:     "a section over a list renders once for each item",
+		Template: "{{#list}}{{.}},{{/list}}",
+		Data:     map[string]interface{}{"list": []int{1, 2, 3}},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "1,2,3,"},
+			Hoisie:   {Output: "1,2,3,"},
+		},
+	},
+	{
+		Name:     "section/nested-lists",
+		Note:     "the implicit iterator as a section name",
+		Template: "{{#rows}}[{{#.}}{{.}}{{/.}}]{{/rows}}",
+		Data:     map[string]interface{}{"rows": [][]int{{1, 2}, {3}}},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[12][3]"},
+		},
+	},
+	{
+		Name:     "format/float",
+		Note:     "how floating point numbers are written",
+		Template: "{{a}} {{b}} {{c}}",
+		Data:     map[string]interface{}{"a": 1.5, "b": 3.0, "c": 1e21},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "1.5 3 1e+21"},
+		},
+	},
+	{
+		Name:     "format/bytes",
+		Note:     "how byte slices are written",
+		Template: "{{b}}",
+		Data:     map[string]interface{}{"b": []byte("hi")},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[104 105]"},
+		},
+	},
+	{
+		Name:     "format/list",
+		Note:     "how a list is written by a variable tag",
+		Template: "{{list}}",
+		Data:     map[string]interface{}{"list": []string{"a", "b"}},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[a b]"},
+		},
+	},
+	{
+		Name:     "whitespace/standalone",
+		Note:     "lines holding only a section tag are removed",
+		Template: "{{#a}}\nx\n{{/a}}\n",
+		Data:     map[string]interface{}{"a": true},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "x\n"},
+		},
+	},
+	{
+		Name:     "whitespace/comment",
+		Note:     "lines holding only a comment are removed",
+		Template: "a\n  {{! comment }}\nb\n",
+		Data:     map[string]interface{}{},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "a\nb\n"},
+		},
+	},
+	{
+		Name:     "whitespace/partial-indent",
+		Note:     "a standalone partial tag indents each line of the partial",
+		Template: "  {{>p}}\n",
+		Partials: map[string]string{"p": "a\nb\n"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "  a\n  b\n"},
+		},
+	},
+	{
+		Name:     "delimiters/set",
+		Note:     "set delimiter tags",
+		Template: "{{=<% %>=}}<% a %> {{a}}",
+		Data:     map[string]interface{}{"a": "x"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "x {{a}}"},
+			Hoisie:   {Output: "x {{a}}"},
+		},
+	},
+	{
+		Name:     "tag/dollar-name",
+		Note:     "a name beginning with $, which opens a block tag in engines supporting template inheritance",
+		Template: "[{{$x}}]",
+		Data:     map[string]interface{}{"$x": "v"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[v]"},
+		},
+	},
+	{
+		Name:     "tag/less-than-name",
+		Note:     "a name beginning with <, which opens a parent tag in engines supporting template inheritance",
+		Template: "[{{<x}}]",
+		Data:     map[string]interface{}{"<x": "v"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[v]"},
+		},
+	},
+	{
+		Name:     "tag/percent-name",
+		Note:     "a name beginning with %, which some engines read as a pragma",
+		Template: "[{{%x}}]",
+		Data:     map[string]interface{}{"%x": "v"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[v]"},
+		},
+	},
+	{
+		Name:     "partial/missing",
+		Note:     "a partial the provider does not have renders nothing",
+		Template: "[{{>nope}}]",
+		Partials: map[string]string{},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[]"},
+		},
+	},
+	{
+		Name:     "partial/malformed",
+		Note:     "a partial which fails to parse",
+		Template: "[{{>p}}]",
+		Partials: map[string]string{"p": "{{#a}}"},
+		Recorded: map[string]Result{
+			Cbroglie: {Output: "[]"},
+		},
+	},
+	{
+		Name:     "inheritance/parent",
+		Note:     "parent and block tags, for template inheritance",
+		Template: "{{<layout}}{{$title}}Home{{/title}}{{/layout}}",
+		Partials: map[string]string{"layout": "<h1>{{$title}}Untitled{{/title}}</h1>"},
+		Recorded: map[string]Result{
+			Cbroglie: {Err: "line 1: unmatched close tag"},
+		},
+	},
+	{
+		Name:     "error/unclosed-section",
+		Note:     "a section with no closing tag",
+		Template: "{{#a}}x",
+		Recorded: map[string]Result{
+			Cbroglie: {Err: "line 1: Section a has no closing tag"},
+			Hoisie:   {Output: "line 1: Section a has no closing tag"},
+		},
+	},
+	{
+		Name:     "error/unclosed-tag",
+		Note:     "a tag with no closing delimiter",
+		Template: "a {{b",
+		Recorded: map[string]Result{
+			Cbroglie: {Err: "line 1: unmatched open tag"},
+			Hoisie:   {Output: "line 1: unmatched open tag"},
+		},
+	},
+	{
+		Name:     "error/empty-tag",
+		Note:     "a tag with no name",
+		Template: "a {{}} b",
+		Recorded: map[string]Result{
+			Cbroglie: {Err: "line 1: empty tag"},
+		},
+	},
+	{
+		Name:     "error/stray-closing-tag",
+		Note:     "a closing tag with no opening tag",
+		Template: "a {{/b}}",
+		Recorded: map[string]Result{
+			Cbroglie: {Err: "line 1: unmatched close tag"},
+			Hoisie:   {Output: "line 1: unmatched close tag"},
+		},
+	},
+	{
+		Name:     "error/interleaved",
+		Note:     "sections closed in the wrong order",
+		Template: "{{#a}}{{#b}}{{/a}}{{/b}}",
+		Recorded: map[string]Result{
+			Cbroglie: {Err: "line 1: interleaved closing tag: a"},
+		},
+	},
+}
@@ -0,0 +1,113 @@
+package mustache
+
+import (
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Flusher is implemented by writers which buffer what is written to them, such as a *gzip.Writer or a *bufio.Writer.
+// When a template is rendered to a Flusher, it is flushed as each of the template's top level sections ends, so that
+// the output streams to the reader in sensible pieces rather than waiting for the buffer to fill. Output which is
+// buffered for post processors or a post validator is not flushed.
+type Flusher interface {
+	Flush() error
+}
+
+// Encoding is a content coding in which FrenderHTTP can compress output, such as gzip or br.
+type Encoding struct {
+	Name string // the name of the coding in the Accept-Encoding and Content-Encoding headers
+	// NewWriter returns a writer which compresses what is written to it to w.
+	NewWriter func(w io.Writer) EncodingWriter
+}
+
+// EncodingWriter is the compressing writer of an Encoding. It is satisfied by *gzip.Writer, and by the writers of
+// most compression packages, such as *brotli.Writer.
+type EncodingWriter interface {
+	io.WriteCloser
+	Flusher
+}
+
+// Gzip is the gzip Encoding, which FrenderHTTP uses by default.
+var Gzip = Encoding{Name: "gzip", NewWriter: func(w io.Writer) EncodingWriter { return gzip.NewWriter(w) }}
+
+// WithEncodings sets the encodings FrenderHTTP may compress output in, in order of preference. The default is Gzip
+// alone. For example, to prefer brotli where the client accepts it:
+//
+//	br := mustache.Encoding{Name: "br", NewWriter: func(w io.Writer) mustache.EncodingWriter { return brotli.NewWriter(w) }}
+//	cmpl := mustache.New().WithEncodings(br, mustache.Gzip)
+//
+// With no encodings, FrenderHTTP never compresses output.
+func (r *Compiler) WithEncodings(encodings ...Encoding) *Compiler {
+	if encodings == nil {
+		encodings = []Encoding{}
+	}
+	r.encodings = encodings
+	return r
+}
+
+func (r *Compiler) encodingsOrDefault() []Encoding {
+	if r.encodings == nil {
+		return []Encoding{Gzip}
+	}
+	return r.encodings
+}
+
+// contentType returns the media type of output in the escape mode, in the named charset, or UTF-8 if it is empty.
+func (m EscapeMode) contentType(charset string) string {
+	switch m {
+	case EscapeHTML:
+		return "text/html; charset=" + charsetOrUTF8(charset)
+	case EscapeJSON, EscapeJSONValue:
+		if charset != "" {
+			return "application/json; charset=" + charset
+		}
+		return "application/json"
+	}
+	return "text/plain; charset=" + charsetOrUTF8(charset)
+}
+
+func charsetOrUTF8(charset string) string {
+	if charset == "" {
+		return "utf-8"
+	}
+	return charset
+}
+
+// negotiateEncoding returns the encoding with the highest quality in an Accept-Encoding header, preferring the
+// earlier of encodings with the same quality, and whether there is one with a quality above zero. An encoding the
+// header does not list is accepted with the quality of "*", if it is listed.
+func negotiateEncoding(accept string, encodings []Encoding) (Encoding, bool) {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(key) == "q" {
+				var err error
+				if q, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		qualities[name] = q
+	}
+	var best Encoding
+	bestQ := 0.0
+	for _, enc := range encodings {
+		q, ok := qualities[strings.ToLower(enc.Name)]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best, bestQ > 0
+}
@@ -0,0 +1,58 @@
+package mustache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// flushRecorder records the output written before each flush.
+type flushRecorder struct {
+	bytes.Buffer
+	flushes []string
+}
+
+func (fr *flushRecorder) Flush() error {
+	fr.flushes = append(fr.flushes, fr.String())
+	return nil
+}
+
+func TestFlushAtSections(t *testing.T) {
+	tmpl, err := New().WithPartials(&StaticProvider{Partials: map[string]string{"p": "{{#b}}p{{/b}}"}}).
+		CompileString("<h1>{{#a}}{{#b}}x{{/b}}{{/a}}</h1>{{>p}}{{#c}}{{.}}{{/c}}{{^c}}none{{/c}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out flushRecorder
+	if err := tmpl.Frender(&out, map[string]interface{}{"a": true, "b": true, "c": []int{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"<h1>x", "<h1>x</h1>p12"}
+	if strings.Join(out.flushes, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected flushes %q, got %q", expected, out.flushes)
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	br := Encoding{Name: "br"}
+	encodings := []Encoding{br, Gzip}
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, br", "br"},
+		{"GZIP;q=0.9, br;q=0.5", "gzip"},
+		{"*", "br"},
+		{"*;q=0.5, br;q=0", "gzip"},
+		{"identity", ""},
+		{"gzip;q=bad", ""},
+	}
+	for _, test := range tests {
+		enc, ok := negotiateEncoding(test.accept, encodings)
+		if ok != (test.expected != "") || enc.Name != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.accept, test.expected, enc.Name)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package mustache
+
+// WithConstants sets variables whose values are known at compile time, such as a brand name or a base URL. A variable
+// tag with the name of a constant, {{name}}, {{{name}}} or {{&name}}, is replaced when the template is compiled by the
+// value, escaped for the escape mode of the template unless the tag is raw, so rendering it costs no lookup and no
+// context can override it. Partials and the templates of a TemplateSet are compiled in the escape mode they are given
+// by their provider or source, so their constants are escaped for it. Constants are not reported by Tags. A tag naming the data explicitly, {{.name}}, is still
+// looked up in the data.
+//
+// The value of a constant becomes part of the text of the template, so in a partial included with indentation, lines
+// after the first are indented like the rest of the text.
+func (r *Compiler) WithConstants(constants map[string]string) *Compiler {
+	r.constants = constants
+	return r
+}
+
+// expandConstants replaces the variables naming constants in the template by their values.
+func (tmpl *Template) expandConstants() {
+	if len(tmpl.parent.constants) > 0 {
+		tmpl.elems = tmpl.expandConstantElems(tmpl.elems)
+	}
+}
+
+func (tmpl *Template) expandConstantElems(elems []interface{}) []interface{} {
+	out := make([]interface{}, 0, len(elems))
+	for _, elem := range elems {
+		switch elem := elem.(type) {
+		case *varElement:
+			if value, ok := tmpl.parent.constants[elem.name]; ok {
+				if !elem.raw {
+					value = EscapeString(tmpl.outputMode, value)
+				}
+				out = append(out, &textElement{text: []byte(value)})
+				continue
+			}
+		case *sectionElement:
+			copied := *elem
+			copied.elems = tmpl.expandConstantElems(elem.elems)
+			out = append(out, &copied)
+			continue
+		}
+		out = append(out, elem)
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package mustache
+
+import (
+	"testing"
+)
+
+func TestConstants(t *testing.T) {
+	constants := map[string]string{"brand": "Tom & Jerry", "baseURL": "https://example.com"}
+	tests := []struct {
+		mode     EscapeMode
+		template string
+		expected string
+	}{
+		{EscapeHTML, "{{brand}} {{{brand}}} {{&brand}}", "Tom &amp; Jerry Tom & Jerry Tom & Jerry"},
+		{EscapeHTML, "{{#items}}<a href=\"{{baseURL}}/{{.}}\">{{/items}}", `<a href="https://example.com/a"><a href="https://example.com/b">`},
+		// the data can neither override a constant nor hide the data from an explicit reference
+		{EscapeHTML, "{{brand}}|{{.brand}}", "Tom &amp; Jerry|data"},
+		{EscapeJSON, `"{{brand}}"`, `"Tom & Jerry"`},
+		{EscapeJSONValue, `{{brand}}`, `"Tom \u0026 Jerry"`},
+		{EscapeHTML, "{{%ESCAPE RAW}}{{brand}}", "Tom & Jerry"},
+	}
+	data := map[string]interface{}{"items": []string{"a", "b"}, "brand": "data"}
+	for _, test := range tests {
+		tmpl, err := New().WithEscapeMode(test.mode).WithConstants(constants).CompileString(test.template)
+		if err != nil {
+			t.Errorf("%q: %v", test.template, err)
+			continue
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Errorf("%q: %v", test.template, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.template, test.expected, output)
+		}
+	}
+
+	tmpl, err := New().WithConstants(constants).CompileString("{{brand}}{{#items}}{{baseURL}}{{name}}{{/items}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := tmpl.Tags()
+	if len(tags) != 1 || tags[0].Name() != "items" || len(tags[0].Tags()) != 1 || tags[0].Tags()[0].Name() != "name" {
+		t.Errorf("expected constants to be left out of the tags, got %v", tags)
+	}
+
+	// partials given their own escape mode by their provider escape constants for it
+	partials := &StaticProvider{Partials: map[string]string{"p": "{{brand}}|{{x}}"}, EscapeModes: map[string]EscapeMode{"p": Raw}}
+	tmpl, err = New().WithPartials(partials).WithConstants(constants).CompileString("{{>p}} {{brand}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Tom & Jerry|<b> Tom &amp; Jerry"
+	if output, err := tmpl.Render(map[string]string{"x": "<b>"}); err != nil || output != expected {
+		t.Errorf("expected %q, got %q, %v", expected, output, err)
+	}
+}
@@ -0,0 +1,72 @@
+package mustache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Coverage reports which sections of a template, and of the partials it includes, a set of example contexts rendered,
+// like code coverage for templates. It is returned by Template.Coverage.
+type Coverage struct {
+	// Sections holds the usage of every section and inverted section, sorted by path as in UsageReport.Tags. A
+	// section with a Produced count of zero was never entered.
+	Sections []TagUsage
+}
+
+// Coverage renders the template once with each of examples, discarding the output, and reports which of its sections
+// were entered, so that tests can require a corpus of example contexts to exercise every branch of a template:
+//
+//	cov, err := tmpl.Coverage(guest, member, admin)
+//	if err == nil && cov.Percent() < 100 {
+//		t.Errorf("sections never entered: %v", cov.Uncovered())
+//	}
+//
+// Sections of partials are included once the partial has been rendered; partials which no example includes are
+// missing from the report, and reported as uncovered through the sections which include them. A render which fails
+// stops the report with an error naming the example.
+func (tmpl *Template) Coverage(examples ...interface{}) (*Coverage, error) {
+	report := NewUsageReport()
+	for i, example := range examples {
+		var tags *tagCounts
+		err := tmpl.frenderPrepared(context.Background(), io.Discard, []interface{}{example}, func(t *Template, st *renderState) {
+			if st.tags == nil {
+				st.tags = newTagCounts()
+				st.tags.rendered(t)
+			}
+			tags = st.tags
+		})
+		if err != nil {
+			return nil, fmt.Errorf("example %d: %w", i, err)
+		}
+		report.merge(tags)
+	}
+	cov := &Coverage{}
+	for _, tag := range report.Tags() {
+		key := tag.Path[strings.LastIndexByte(tag.Path, '/')+1:]
+		if strings.HasPrefix(key, "#") || strings.HasPrefix(key, "^") {
+			cov.Sections = append(cov.Sections, tag)
+		}
+	}
+	return cov, nil
+}
+
+// Percent returns the percentage of the sections which were entered, or 100 if there are none.
+func (c *Coverage) Percent() float64 {
+	if len(c.Sections) == 0 {
+		return 100
+	}
+	return 100 * float64(len(c.Sections)-len(c.Uncovered())) / float64(len(c.Sections))
+}
+
+// Uncovered returns the paths of the sections which were never entered.
+func (c *Coverage) Uncovered() []string {
+	var paths []string
+	for _, section := range c.Sections {
+		if section.Produced == 0 {
+			paths = append(paths, section.Path)
+		}
+	}
+	return paths
+}
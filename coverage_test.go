@@ -0,0 +1,47 @@
+package mustache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	partials := &StaticProvider{Partials: map[string]string{"badge": "{{#admin}}admin{{/admin}}{{^admin}}user{{/admin}}"}}
+	tmpl, err := New().WithPartials(partials).CompileString(
+		"{{#user}}{{name}}{{>badge}}{{#items}}{{.}}{{/items}}{{^items}}none{{/items}}{{/user}}{{^user}}guest{{/user}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cov, err := tmpl.Coverage(map[string]interface{}{"user": map[string]interface{}{"name": "Mary", "items": []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"#user/^items", "^user", "badge/#admin"}
+	if uncovered := cov.Uncovered(); strings.Join(uncovered, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v uncovered, got %v", expected, uncovered)
+	}
+	if p := cov.Percent(); p != 50 {
+		t.Errorf("expected 50%% coverage, got %v", p)
+	}
+
+	cov, err = tmpl.Coverage(
+		map[string]interface{}{"user": map[string]interface{}{"name": "Mary", "items": []int{1}}},
+		map[string]interface{}{"user": map[string]interface{}{"name": "Bob", "admin": true}},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := cov.Percent(); p != 100 || len(cov.Sections) != 6 {
+		t.Errorf("expected full coverage of 6 sections, got %v%% of %v", p, cov.Sections)
+	}
+
+	strict, err := New().WithErrors(true).CompileString("{{#a}}{{b}}{{/a}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strict.Coverage(map[string]interface{}{"a": true, "b": 1}, map[string]interface{}{"a": true}); err == nil || !strings.HasPrefix(err.Error(), "example 1:") {
+		t.Errorf("expected an error for the second example, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package mustache
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// TagHandler compiles a custom tag, such as {{@flag new-checkout}}, for a sigil registered with WithTagHandler. It is
+// called as the template is compiled, with the body of the tag following its sigil, trimmed of spaces, and returns the
+// function which renders the tag, or an error which fails the compile.
+type TagHandler func(body string) (TagRenderer, error)
+
+// TagRenderer renders a custom tag. Its lookup function looks names up in the context of the tag like a variable,
+// reporting whether they were found, and the text it returns is escaped like the value of a variable.
+type TagRenderer func(lookup func(name string) (interface{}, bool)) (string, error)
+
+// reservedSigils are the sigils of the tags of the mustache syntax, which custom tags can't use.
+const reservedSigils = "!%#^/<>={&$"
+
+// WithTagHandler registers a handler for tags beginning with sigil, so that organizations can add their own tags,
+// such as feature flags and experiments, without forking the parser. Tags beginning with the sigil are then custom
+// tags rather than variables, so a handler for '@' takes the place of Handlebars data variables such as @index. The
+// sigils of the mustache syntax itself, ! % # ^ / < > = { & and $, can't be used, and registering one has no effect.
+// Templates holding custom tags can't be exported to JavaScript.
+func (r *Compiler) WithTagHandler(sigil rune, h TagHandler) *Compiler {
+	if strings.ContainsRune(reservedSigils, sigil) {
+		return r
+	}
+	if r.tagHandlers == nil {
+		r.tagHandlers = make(map[rune]TagHandler)
+	}
+	r.tagHandlers[sigil] = h
+	return r
+}
+
+type customTagElement struct {
+	sigil  rune
+	body   string
+	render TagRenderer
+	line   int // the line of the tag, or 0 if it was not compiled from source
+	col    int // the column of the tag, counting bytes from 1
+	offset int // the offset of the tag in the source
+}
+
+// String returns the text of the tag, including its sigil.
+func (elem *customTagElement) String() string {
+	return string(elem.sigil) + elem.body
+}
+
+// parseCustomTag compiles the text of a tag with its registered handler, returning nil if its sigil has none.
+func (tmpl *Template) parseCustomTag(tag string) (*customTagElement, error) {
+	sigil, size := utf8.DecodeRuneInString(tag)
+	h, ok := tmpl.parent.tagHandlers[sigil]
+	if !ok {
+		return nil, nil
+	}
+	elem := &customTagElement{sigil: sigil, body: strings.TrimSpace(tag[size:]), line: tmpl.tagLine, col: tmpl.tagColumn,
+		offset: tmpl.tagOffset}
+	render, err := h(elem.body)
+	if err != nil {
+		return nil, tmpl.parseError(fmt.Sprintf("tag %s: %s", elem, err))
+	}
+	if render == nil {
+		return nil, tmpl.parseError(fmt.Sprintf("tag %s: no renderer", elem))
+	}
+	elem.render = render
+	return elem, nil
+}
+
+// renderCustomTag calls the renderer of a custom tag and writes the result.
+func (tmpl *Template) renderCustomTag(st *renderState, elem *customTagElement, contextChain []reflect.Value, buf io.Writer) error {
+	var lookupErr error
+	lookup := func(name string) (interface{}, bool) {
+		v, frame, err := tmpl.lookup(st, contextChain, name)
+		if err != nil {
+			if lookupErr == nil {
+				lookupErr = err
+			}
+			return nil, false
+		}
+		if st.usage != nil {
+			st.usage.use(contextChain, frame, name, true)
+		}
+		if !v.IsValid() {
+			return nil, false
+		}
+		if !indirect(v).IsValid() {
+			return nil, true
+		}
+		return indirectValue(v.Interface()), true
+	}
+	s, err := elem.render(lookup)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	if err != nil {
+		return fmt.Errorf("tag %s: %w", elem, err)
+	}
+	if tmpl.outputMode == EscapeJSONValue {
+		if s, err = toJSONString(s); err != nil {
+			return err
+		}
+	}
+	return tmpl.writeEscaped(st, buf, s, false, false)
+}
@@ -0,0 +1,84 @@
+package mustache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// flagTag is a TagHandler for tags such as {{@flag beta}}, which write the name of the user's variant of a flag.
+func flagTag(body string) (TagRenderer, error) {
+	words := strings.Fields(body)
+	if len(words) != 2 || words[0] != "flag" {
+		return nil, fmt.Errorf("expected flag and its name, got %q", body)
+	}
+	return func(lookup func(string) (interface{}, bool)) (string, error) {
+		flags, ok := lookup("flags")
+		if !ok {
+			return "off", nil
+		}
+		if v, ok := flags.(map[string]string)[words[1]]; ok {
+			return v, nil
+		}
+		return "off", nil
+	}, nil
+}
+
+func TestTagHandler(t *testing.T) {
+	cmpl := New().WithTagHandler('@', flagTag)
+	tmpl, err := cmpl.CompileString(`<p class="{{@flag beta}}">{{#users}}{{ @ flag beta }},{{/users}}{{@flag other}}</p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{
+		"flags": map[string]string{"beta": "<b>"},
+		"users": []interface{}{map[string]interface{}{}, map[string]interface{}{"flags": map[string]string{"beta": "v2"}}},
+	}
+	expected := `<p class="&lt;b&gt;">&lt;b&gt;,v2,off</p>`
+	if output, err := tmpl.Render(data); err != nil || output != expected {
+		t.Errorf("expected %q, got %q, %v", expected, output, err)
+	}
+
+	if _, err := cmpl.CompileString("line\n{{@flag}}"); err == nil || !strings.Contains(err.Error(), "line 2: tag @flag: expected flag") {
+		t.Errorf("expected the handler's error at line 2, got %v", err)
+	}
+
+	failing := errors.New("flag service down")
+	tmpl, err = New().WithTagHandler('@', func(string) (TagRenderer, error) {
+		return func(func(string) (interface{}, bool)) (string, error) { return "", failing }, nil
+	}).CompileString("{{@flag beta}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(nil); !errors.Is(err, failing) {
+		t.Errorf("expected the renderer's error, got %v", err)
+	}
+
+	tmpl, err = New().WithTagHandler('#', flagTag).CompileString("{{#flags}}x{{/flags}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(data); err != nil || output != "x" {
+		t.Errorf("expected a handler for a reserved sigil to be ignored, got %q, %v", output, err)
+	}
+
+	tmpl, err = cmpl.CompileString("{{@flag beta}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := tmpl.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := cmpl.CompileJSON(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := loaded.Render(data); err != nil || output != "&lt;b&gt;" {
+		t.Errorf("unexpected output %q, error %v after an AST round trip", output, err)
+	}
+	if _, err := New().CompileJSON(ast); err == nil {
+		t.Error("expected an AST with a custom tag to need its handler")
+	}
+}
@@ -0,0 +1,130 @@
+// Package debugserver serves a web page for debugging mustache templates while they are written: a template, picked
+// from a set or pasted in, is rendered with a JSON context as it is edited, and each tag of the output is annotated
+// with how its names were resolved, including which context of the chain each was found in.
+//
+// It is meant for development only. It compiles and renders whatever it is sent, so it must not be exposed to
+// untrusted networks:
+//
+//	cmpl := mustache.New().WithPartials(&mustache.FileProvider{Paths: []string{"templates"}})
+//	log.Fatal(http.ListenAndServe("localhost:8080", debugserver.New(cmpl, templates)))
+package debugserver
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/hayeah/mustache/v2"
+)
+
+// maxRequestBytes bounds the size of a render request.
+const maxRequestBytes = 4 << 20
+
+//go:embed index.html
+var indexHTML []byte
+
+// RenderRequest is the body of a request to render a template.
+type RenderRequest struct {
+	Template string          `json:"template"`
+	Data     json.RawMessage `json:"data"` // the context, or nothing to render with no context
+}
+
+// RenderResponse is the result of rendering a template.
+type RenderResponse struct {
+	Output      string       `json:"output"`
+	Error       string       `json:"error,omitempty"`
+	Resolutions []Resolution `json:"resolutions"`
+}
+
+// Resolution is a mustache.Resolution, as sent to the page.
+type Resolution struct {
+	Template string `json:"template,omitempty"`
+	Tag      string `json:"tag"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Offset   int    `json:"offset"`
+	Name     string `json:"name"`
+	Found    bool   `json:"found"`
+	Frame    int    `json:"frame"`
+	Path     string `json:"path,omitempty"`
+}
+
+// Template is a template the page offers to pick.
+type Template struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// New returns a handler serving the page at /, with templates, keyed by name, offered to pick, rendered by cmpl, or
+// by a new compiler if cmpl is nil. Options such as partials and the escape mode are taken from cmpl.
+func New(cmpl *mustache.Compiler, templates map[string]string) http.Handler {
+	if cmpl == nil {
+		cmpl = mustache.New()
+	}
+	list := make([]Template, 0, len(templates))
+	for name, source := range templates {
+		list = append(list, Template{name, source})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(indexHTML)
+	})
+	mux.HandleFunc("/templates", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, list)
+	})
+	mux.HandleFunc("/render", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var rr RenderRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxRequestBytes)).Decode(&rr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, render(cmpl, rr))
+	})
+	return mux
+}
+
+// render compiles and renders a template, reporting any error in the response.
+func render(cmpl *mustache.Compiler, rr RenderRequest) RenderResponse {
+	resp := RenderResponse{Resolutions: []Resolution{}}
+	var data []interface{}
+	if len(rr.Data) > 0 {
+		var v interface{}
+		if err := json.Unmarshal(rr.Data, &v); err != nil {
+			resp.Error = "data: " + err.Error()
+			return resp
+		}
+		data = append(data, v)
+	}
+	tmpl, err := cmpl.CompileString(rr.Template)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	output, resolutions, err := tmpl.RenderResolutions(data...)
+	resp.Output = output
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	for _, r := range resolutions {
+		resp.Resolutions = append(resp.Resolutions, Resolution(r))
+	}
+	return resp
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
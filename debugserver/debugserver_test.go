@@ -0,0 +1,59 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	srv := httptest.NewServer(New(nil, map[string]string{"b": "{{y}}", "a": "{{x}}"}))
+	defer srv.Close()
+
+	body := `{"template": "{{#items}}{{label}}/{{name}} {{/items}}", "data": {"name": "Jo", "items": [{"label": "a"}]}}`
+	resp, err := http.Post(srv.URL+"/render", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var rr RenderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Output != "a/Jo " || rr.Error != "" || len(rr.Resolutions) != 3 {
+		t.Fatalf("unexpected response %+v", rr)
+	}
+	if r := rr.Resolutions[2]; r.Name != "name" || r.Frame != 1 || r.Path != "name" || r.Offset != 2 {
+		t.Errorf("unexpected resolution %+v", r)
+	}
+
+	resp, err = http.Post(srv.URL+"/render", "application/json", strings.NewReader(`{"template": "{{#a}}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil || !strings.Contains(rr.Error, "no closing tag") {
+		t.Errorf("expected a compile error, got %+v, %v", rr, err)
+	}
+
+	resp, err = http.Get(srv.URL + "/templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var templates []Template
+	if err := json.NewDecoder(resp.Body).Decode(&templates); err != nil || len(templates) != 2 || templates[0].Name != "a" {
+		t.Errorf("unexpected templates %+v, %v", templates, err)
+	}
+
+	resp, err = http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("unexpected page response %s", resp.Status)
+	}
+}
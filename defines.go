@@ -0,0 +1,44 @@
+package mustache
+
+// WithDefines sets names which are constant at compile time, such as feature flags. A section over a defined name,
+// {{#name}} or {{^name}}, or a Handlebars {{#if name}} or {{#unless name}} block, is replaced when the template is
+// compiled by its contents if it would render, and removed if it would not, so that each combination of flags
+// compiles to a template holding only what it renders:
+//
+//	cmpl := mustache.New().WithDefines(map[string]bool{"newHeader": true})
+//
+// The contents of a folded section are rendered in the enclosing context, rather than with the value of the name
+// pushed onto it, so {{.}} within them refers to the enclosing context. Variables with a defined name are still looked
+// up in the data.
+func (r *Compiler) WithDefines(defines map[string]bool) *Compiler {
+	r.defines = defines
+	return r
+}
+
+// foldDefines replaces the sections over defined names in the template by their contents, or removes them.
+func (tmpl *Template) foldDefines() {
+	if len(tmpl.parent.defines) > 0 {
+		tmpl.elems = foldDefines(tmpl.elems, tmpl.parent.defines)
+	}
+}
+
+func foldDefines(elems []interface{}, defines map[string]bool) []interface{} {
+	out := make([]interface{}, 0, len(elems))
+	for _, elem := range elems {
+		section, ok := elem.(*sectionElement)
+		if !ok {
+			out = append(out, elem)
+			continue
+		}
+		folded := foldDefines(section.elems, defines)
+		value, defined := defines[section.name]
+		if !defined || section.sigil != 0 || section.closer == "each" || section.closer == "with" {
+			copied := *section
+			copied.elems = folded
+			out = append(out, &copied)
+		} else if value != section.inverted {
+			out = append(out, folded...)
+		}
+	}
+	return out
+}
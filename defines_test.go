@@ -0,0 +1,68 @@
+package mustache
+
+import (
+	"testing"
+)
+
+func TestDefines(t *testing.T) {
+	defines := map[string]bool{"newHeader": true, "beta": false}
+	tests := []struct {
+		syntax   Syntax
+		template string
+		expected string
+		sections int
+	}{
+		{Mustache, "{{#newHeader}}new{{/newHeader}}{{^newHeader}}old{{/newHeader}}", "new", 0},
+		{Mustache, "{{#beta}}beta{{/beta}}{{^beta}}stable{{/beta}}", "stable", 0},
+		{Mustache, "{{#newHeader}}{{#items}}{{.}}{{/items}}{{/newHeader}}", "ab", 1},
+		{Mustache, "{{#items}}{{#beta}}x{{/beta}}{{.}}{{/items}}", "ab", 1},
+		// the contents of a folded section are rendered in the enclosing context
+		{Mustache, "{{#newHeader}}{{name}}{{/newHeader}}", "Mary", 0},
+		{Mustache, "{{newHeader}}", "", 0},
+		{Handlebars, "{{#if newHeader}}new{{else}}old{{/if}}", "new", 0},
+		{Handlebars, "{{#unless beta}}stable{{else}}beta{{/unless}}", "stable", 0},
+		{Handlebars, "{{#each newHeader}}x{{/each}}", "", 1},
+	}
+	data := map[string]interface{}{"items": []string{"a", "b"}, "name": "Mary"}
+	for _, test := range tests {
+		tmpl, err := New().WithSyntax(test.syntax).WithDefines(defines).CompileString(test.template)
+		if err != nil {
+			t.Errorf("%q: %v", test.template, err)
+			continue
+		}
+		if sections := countSections(tmpl.elems); sections != test.sections {
+			t.Errorf("%q: expected %d sections, got %d", test.template, test.sections, sections)
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Errorf("%q: %v", test.template, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.template, test.expected, output)
+		}
+	}
+}
+
+func TestDefinesReparse(t *testing.T) {
+	cmpl := New().WithDefines(map[string]bool{"flag": false})
+	tmpl, err := cmpl.CompileString("a\n{{#flag}}\nb\n{{/flag}}\nc\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited, err := tmpl.Reparse(Edit{Start: 0, End: 1, Text: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := edited.Render(nil); output != "x\nc\n" {
+		t.Errorf("expected %q, got %q", "x\nc\n", output)
+	}
+}
+
+func countSections(elems []interface{}) int {
+	n := 0
+	for _, elem := range elems {
+		if section, ok := elem.(*sectionElement); ok {
+			n += 1 + countSections(section.elems)
+		}
+	}
+	return n
+}
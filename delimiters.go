@@ -0,0 +1,57 @@
+package mustache
+
+import (
+	"bytes"
+	"sort"
+)
+
+// delimiterChange records that a set delimiter tag ending at offset at changed the delimiters.
+type delimiterChange struct {
+	at   int
+	otag string
+	ctag string
+}
+
+// DelimiterRange is a range of the source of a template within which a pair of delimiters is in effect.
+type DelimiterRange struct {
+	Open  string // the opening delimiter, such as "{{"
+	Close string // the closing delimiter, such as "}}"
+	Start int    // the offset of the first byte of the range in the source
+	End   int    // the offset just past the last byte of the range
+	Line  int    // the line of the first byte of the range
+}
+
+// Delimiters returns the ranges of the template's source within which each pair of delimiters is in effect, in order,
+// so that tools such as scanners and formatters can find the tags within regions of alternate delimiters. The first
+// range begins with the source, with {{ and }}, and each of the others begins just past the set delimiter tag which
+// changed the delimiters, such as {{=<% %>=}}; together they cover the whole source. Set delimiter tags which leave
+// the delimiters as they are don't begin a range. It returns nil for templates which were not compiled from source,
+// such as those compiled with CompileJSON.
+func (tmpl *Template) Delimiters() []DelimiterRange {
+	if len(tmpl.checkpoints) == 0 {
+		return nil
+	}
+	ranges := []DelimiterRange{{Open: "{{", Close: "}}", Start: 0, Line: 1}}
+	for _, c := range tmpl.delimiterChanges {
+		last := &ranges[len(ranges)-1]
+		last.End = c.at
+		line := last.Line + bytes.Count(tmpl.data[last.Start:c.at], []byte("\n"))
+		ranges = append(ranges, DelimiterRange{Open: c.otag, Close: c.ctag, Start: c.at, Line: line})
+	}
+	ranges[len(ranges)-1].End = len(tmpl.data)
+	return ranges
+}
+
+// DelimitersAt returns the delimiters in effect at an offset in the template's source, as found by Delimiters, or
+// empty strings if the offset is out of range or the template was not compiled from source.
+func (tmpl *Template) DelimitersAt(offset int) (open, close string) {
+	if len(tmpl.checkpoints) == 0 || offset < 0 || offset > len(tmpl.data) {
+		return "", ""
+	}
+	open, close = "{{", "}}"
+	i := sort.Search(len(tmpl.delimiterChanges), func(i int) bool { return tmpl.delimiterChanges[i].at > offset })
+	if i > 0 {
+		open, close = tmpl.delimiterChanges[i-1].otag, tmpl.delimiterChanges[i-1].ctag
+	}
+	return open, close
+}
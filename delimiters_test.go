@@ -0,0 +1,55 @@
+package mustache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDelimiters(t *testing.T) {
+	src := "{{a}}\n{{=<% %>=}}\n<%b%> {{c}}\n<%={{ }}=%>\n{{d}}\n"
+	tmpl, err := New().CompileString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []DelimiterRange{
+		{Open: "{{", Close: "}}", Start: 0, End: 17, Line: 1},
+		{Open: "<%", Close: "%>", Start: 17, End: 41, Line: 2},
+		{Open: "{{", Close: "}}", Start: 41, End: len(src), Line: 4},
+	}
+	if ranges := tmpl.Delimiters(); !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("expected %+v, got %+v", expected, ranges)
+	}
+	for _, test := range []struct {
+		offset      int
+		open, close string
+	}{{0, "{{", "}}"}, {16, "{{", "}}"}, {17, "<%", "%>"}, {40, "<%", "%>"}, {41, "{{", "}}"}, {len(src), "{{", "}}"}, {-1, "", ""}} {
+		if open, close := tmpl.DelimitersAt(test.offset); open != test.open || close != test.close {
+			t.Errorf("%d: expected %s %s, got %s %s", test.offset, test.open, test.close, open, close)
+		}
+	}
+
+	// an edit on a line of its own is parsed alone, and moves the ranges after it
+	edited, err := tmpl.Reparse(Edit{Start: 0, End: 5, Text: "{{alpha}}\n{{beta}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := New().CompileString(string(edited.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(edited.Delimiters(), compiled.Delimiters()) {
+		t.Errorf("expected the ranges of the edited template to be %+v, got %+v", compiled.Delimiters(), edited.Delimiters())
+	}
+
+	data, err := tmpl.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := New().CompileJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranges := loaded.Delimiters(); ranges != nil {
+		t.Errorf("expected no ranges for a template compiled from JSON, got %+v", ranges)
+	}
+}
@@ -0,0 +1,113 @@
+package mustache
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// WithDeterministic guarantees that rendering the compiled templates with the same data produces byte-identical output
+// across runs and Go versions, for uses such as hashing rendered configuration to detect drift. In deterministic mode:
+//
+//   - Floating point numbers are written in decimal notation with as few digits as represent them exactly, as by
+//     strconv.FormatFloat(f, 'f', -1, 64), never with an exponent.
+//   - Times are written in the RFC 3339 format with nanoseconds, without the monotonic clock reading.
+//   - Values with no stable text, such as functions, channels and pointers within other values, which would be
+//     written as memory addresses, fail the render with an error.
+//   - Watermarks set with WithWatermark carry no render time.
+//
+// Maps are written with their keys sorted, in every mode. Stringers, ValueStringers and helpers are trusted to be
+// deterministic.
+func (r *Compiler) WithDeterministic(b bool) *Compiler {
+	r.deterministic = b
+	return r
+}
+
+// maxStableDepth bounds the nesting checked by checkStable, as values may refer to themselves through interfaces.
+const maxStableDepth = 64
+
+// stableString returns the text of a value for the deterministic mode.
+func stableString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case fmt.Stringer, error:
+		return fmt.Sprint(v), nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	}
+	if err := checkStable(rv, 0); err != nil {
+		return "", err
+	}
+	return fmt.Sprint(value), nil
+}
+
+// checkStable returns an error if fmt would write any part of v differently from one run to the next.
+func checkStable(v reflect.Value, depth int) error {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > maxStableDepth {
+		return fmt.Errorf("mustache: value nested too deeply to be written deterministically")
+	}
+	if v.CanInterface() {
+		switch i := v.Interface().(type) {
+		case time.Time:
+			if i != i.Round(0) {
+				return fmt.Errorf("mustache: time %s has a monotonic clock reading, and cannot be written deterministically", i.Round(0))
+			}
+			return nil
+		case fmt.Stringer, error:
+			return nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return fmt.Errorf("mustache: %s value cannot be written deterministically", v.Kind())
+	case reflect.Pointer:
+		// fmt writes the address of pointers, other than top level pointers to composite values
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+			if depth == 0 && !v.IsNil() {
+				return checkStable(v.Elem(), depth+1)
+			}
+		}
+		if v.IsNil() {
+			return nil
+		}
+		return fmt.Errorf("mustache: pointer to %s cannot be written deterministically", v.Type().Elem())
+	case reflect.Interface:
+		return checkStable(v.Elem(), depth+1)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := checkStable(v.Field(i), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkStable(v.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := checkStable(iter.Key(), depth+1); err != nil {
+				return err
+			}
+			if err := checkStable(iter.Value(), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
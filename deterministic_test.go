@@ -0,0 +1,51 @@
+package mustache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeterministic(t *testing.T) {
+	n := 3
+	ok := []struct {
+		value    interface{}
+		expected string
+	}{
+		{1e21, "1000000000000000000000"},
+		{float32(0.1), "0.1"},
+		{2.5, "2.5"},
+		{time.Date(2024, 5, 1, 12, 0, 0, 5, time.UTC), "2024-05-01T12:00:00.000000005Z"},
+		{map[string]int{"b": 2, "a": 1}, "map[a:1 b:2]"},
+		{[]interface{}{1, "x", nil}, "[1 x &lt;nil&gt;]"},
+		// pointers are written as what they point to
+		{&struct{ A []int }{[]int{1}}, "{[1]}"},
+		{&n, "3"},
+		{`<a href="x">'&'</a>` + "\x00", "&lt;a href=&#34;x&#34;&gt;&#39;&amp;&#39;&lt;/a&gt;�"},
+	}
+	cmpl := New().WithDeterministic(true)
+	tmpl, err := cmpl.CompileString("{{v}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range ok {
+		output, err := tmpl.Render(map[string]interface{}{"v": test.value})
+		if err != nil {
+			t.Errorf("%v: %v", test.value, err)
+		} else if output != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.value, test.expected, output)
+		}
+	}
+
+	unstable := []interface{}{
+		func() {},
+		make(chan int),
+		struct{ P *int }{&n},
+		[]interface{}{time.Now()},
+	}
+	for _, value := range unstable {
+		if _, err := tmpl.Render(map[string]interface{}{"v": value}); err == nil || !strings.Contains(err.Error(), "deterministically") {
+			t.Errorf("%T: expected an error, got %v", value, err)
+		}
+	}
+}
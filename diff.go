@@ -0,0 +1,143 @@
+package mustache
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffOp is the kind of a DiffLine.
+type DiffOp int
+
+// The kinds of lines in a Diff.
+const (
+	DiffEqual  DiffOp = iota // the line is in both outputs
+	DiffDelete               // the line is only in the old output
+	DiffInsert               // the line is only in the new output
+)
+
+// DiffLine is one line of a Diff.
+type DiffLine struct {
+	Op      DiffOp
+	Text    string // the line, without its line ending
+	OldLine int    // the line number in the old output, counting from 1, or 0 for inserted lines
+	NewLine int    // the line number in the new output, counting from 1, or 0 for deleted lines
+}
+
+// Diff is the line by line difference between the outputs of two templates rendered with the same data.
+type Diff struct {
+	Old   string     // the output of the old template
+	New   string     // the output of the new template
+	Lines []DiffLine // the lines of both outputs, in order, as a shortest edit script
+}
+
+// DiffRender renders two templates with the same data, and returns the difference between their outputs, for
+// instance to review the effect of migrating a template to a new version.
+func DiffRender(t1, t2 *Template, data ...interface{}) (Diff, error) {
+	old, err := t1.Render(data...)
+	if err != nil {
+		return Diff{}, fmt.Errorf("old template: %w", err)
+	}
+	new, err := t2.Render(data...)
+	if err != nil {
+		return Diff{}, fmt.Errorf("new template: %w", err)
+	}
+	return Diff{Old: old, New: new, Lines: diffLines(splitLines(old), splitLines(new))}, nil
+}
+
+// Equal reports whether the outputs are identical.
+func (d Diff) Equal() bool {
+	return d.Old == d.New
+}
+
+// String formats the diff with each line prefixed by ' ', '-' or '+', in the manner of a unified diff, but including
+// every line.
+func (d Diff) String() string {
+	var sb strings.Builder
+	for _, line := range d.Lines {
+		switch line.Op {
+		case DiffEqual:
+			sb.WriteByte(' ')
+		case DiffDelete:
+			sb.WriteByte('-')
+		case DiffInsert:
+			sb.WriteByte('+')
+		}
+		sb.WriteString(line.Text)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns a shortest edit script turning a into b, using Myers' algorithm.
+func diffLines(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset, d)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the trace of diffLines back from the end of both inputs to build the edit script.
+func backtrack(trace [][]int, a, b []string, offset, d int) []DiffLine {
+	x, y := len(a), len(b)
+	var lines []DiffLine
+	for ; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, DiffLine{Op: DiffEqual, Text: a[x], OldLine: x + 1, NewLine: y + 1})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: b[y], NewLine: y + 1})
+		} else {
+			x--
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: a[x], OldLine: x + 1})
+		}
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
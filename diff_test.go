@@ -0,0 +1,88 @@
+package mustache
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDiffRender(t *testing.T) {
+	t1, err := New().CompileString("<ul>\n{{#items}}<li>{{.}}</li>\n{{/items}}</ul>\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := New().CompileString("<ul class=\"list\">\n{{#items}}<li>{{.}}</li>\n{{/items}}<li>more</li>\n</ul>\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string][]string{"items": {"a", "b"}}
+	diff, err := DiffRender(t1, t2, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Equal() {
+		t.Error("expected the outputs to differ")
+	}
+	expected := "-<ul>\n+<ul class=\"list\">\n <li>a</li>\n <li>b</li>\n+<li>more</li>\n </ul>\n"
+	if diff.String() != expected {
+		t.Errorf("expected diff\n%s\ngot\n%s", expected, diff.String())
+	}
+	last := diff.Lines[len(diff.Lines)-1]
+	if last.Op != DiffEqual || last.OldLine != 4 || last.NewLine != 5 {
+		t.Errorf("unexpected line numbers %+v", last)
+	}
+
+	diff, err = DiffRender(t1, t1, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Equal() || strings.ContainsAny(diff.String(), "+-") {
+		t.Errorf("expected no differences, got\n%s", diff)
+	}
+
+	empty, err := New().CompileString("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err = DiffRender(empty, t1, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Lines) != 4 || diff.Lines[0].Op != DiffInsert {
+		t.Errorf("expected every line to be inserted, got\n%s", diff)
+	}
+
+	strict, err := New().WithErrors(true).CompileString("{{missing}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DiffRender(t1, strict, data); err == nil || !strings.HasPrefix(err.Error(), "new template:") {
+		t.Errorf("expected an error from the new template, got %v", err)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, rnd.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+		var old, new []string
+		for _, line := range diffLines(a, b) {
+			if line.Op != DiffInsert {
+				old = append(old, line.Text)
+			}
+			if line.Op != DiffDelete {
+				new = append(new, line.Text)
+			}
+		}
+		if strings.Join(old, "") != strings.Join(a, "") || strings.Join(new, "") != strings.Join(b, "") {
+			t.Fatalf("diff of %v and %v does not reproduce them", a, b)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package mustache
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+var (
+	// ErrTemplateTooLarge indicates that a template was larger than the limit set with WithMaxTemplateBytes.
+	ErrTemplateTooLarge = errors.New("template too large")
+	// ErrSectionTooDeep indicates that a template nested sections deeper than the limit set with WithMaxSectionDepth.
+	ErrSectionTooDeep = errors.New("sections nested too deeply")
+	// ErrPartialNotFound indicates that a PartialProvider has no partial with the requested name.
+	ErrPartialNotFound = errors.New("partial not found")
+	// ErrTemplateNotFound indicates that a TemplateSet has no template with the requested name.
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrSectionNotFound indicates that a template has no section with the name passed to RenderSection.
+	ErrSectionNotFound = errors.New("section not found")
+	// ErrChecksumMismatch indicates that a file in a bundle does not match the checksum in the bundle's manifest.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrBadSignature indicates that the signature of a bundle's manifest is invalid, or was not made with any of the
+	// keys set with WithBundleKeys.
+	ErrBadSignature = errors.New("bad signature")
+	// ErrBundleTooLarge indicates that a bundle downloaded by a BundleURL was larger than its MaxBytes.
+	ErrBundleTooLarge = errors.New("bundle too large")
+	// ErrUnverified indicates that a bundle is missing the checksum or signature needed to verify it.
+	ErrUnverified = errors.New("unverified")
+	// ErrInternal indicates that compiling or rendering a template panicked, whether in the package or in code it
+	// called, such as a method of the data, a lambda or a PartialProvider. The error is an *InternalError.
+	ErrInternal = errors.New("internal error")
+	// ErrLambdaTimeout indicates that a lambda did not return within the limit set with WithLambdaTimeout.
+	ErrLambdaTimeout = errors.New("lambda timed out")
+	// ErrCircuitOpen indicates that a ResilientProvider refused to load a partial, as its provider has been failing.
+	ErrCircuitOpen = errors.New("circuit open")
+	// ErrTooManyLambdaCalls indicates that a render called more lambdas than the limit set with WithMaxLambdaCalls.
+	ErrTooManyLambdaCalls = errors.New("too many lambda calls")
+	// ErrTooManyPartialFetches indicates that a render fetched more partials than the limit set with
+	// WithMaxPartialFetches.
+	ErrTooManyPartialFetches = errors.New("too many partial fetches")
+	// ErrTooManyLookups indicates that a render looked up more names than the limit set with WithMaxLookups.
+	ErrTooManyLookups = errors.New("too many lookups")
+	// ErrMissingVariable indicates that a name looked up by a tag was not found, when missing names are errors, as set
+	// with WithErrors.
+	ErrMissingVariable = errors.New("missing variable")
+	// ErrContextMutated indicates that the data of a render with frozen contexts, set with WithFrozenContext, changed
+	// during the render. The error is a *MutationError.
+	ErrContextMutated = errors.New("context mutated")
+)
+
+// errNoPartialProvider is returned when a template includes a partial, but no PartialProvider was configured. This is
+// treated in the same way as a missing partial.
+var errNoPartialProvider = fmt.Errorf("no partial provider specified: %w", ErrPartialNotFound)
+
+// missingPartialError reports a partial which could not be loaded because of Err as missing, so that errors.Is reports
+// it both as ErrPartialNotFound and as Err.
+type missingPartialError struct {
+	Err error
+}
+
+func (e *missingPartialError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrPartialNotFound, e.Err)
+}
+
+func (e *missingPartialError) Is(target error) bool {
+	return target == ErrPartialNotFound
+}
+
+func (e *missingPartialError) Unwrap() error {
+	return e.Err
+}
+
+// LimitError is returned when a template exceeds one of the limits configured on the Compiler. Use errors.Is with
+// the wrapped error to find out which limit was exceeded.
+type LimitError struct {
+	Err  error // the limit which was exceeded, such as ErrTemplateTooLarge
+	Max  int   // the configured limit
+	Line int   // the line at which the limit was exceeded, or 0 if it applies to the whole template
+}
+
+func (e *LimitError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s (limit %d)", e.Line, e.Err, e.Max)
+	}
+	return fmt.Sprintf("%s (limit %d)", e.Err, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// VerificationError is returned when a template bundle fails verification, as opposed to being malformed or holding
+// templates which fail to compile. Use errors.Is with the wrapped error to find out why.
+type VerificationError struct {
+	File string // the file in the bundle which failed verification
+	Err  error  // the reason, such as ErrChecksumMismatch
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.File, e.Err)
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// LambdaError is returned when the lambda of a section returns an error or times out, unless a fallback set with
+// WithLambdaFallback takes its place. Render returns it along with the output written before the section. errors.Is
+// and errors.As see through it to the lambda's own error, or to ErrLambdaTimeout.
+type LambdaError struct {
+	Name string // the name of the lambda's section
+	Line int    // the line of the section's opening tag
+	Err  error  // the error returned by the lambda
+}
+
+func (e *LambdaError) Error() string {
+	return fmt.Sprintf("line %d: lambda %q: %s", e.Line, e.Name, e.Err)
+}
+
+func (e *LambdaError) Unwrap() error {
+	return e.Err
+}
+
+// RenderError is returned when a tag names a variable or partial which is missing, when missing names are errors, as
+// set with WithErrors, or exceeds a render budget, such as that set with WithMaxLookups. It locates the tag, so that
+// the failure can be found in large templates. errors.Is and errors.As see through it to ErrMissingVariable or
+// ErrPartialNotFound, or to the *LimitError.
+type RenderError struct {
+	Template string // the name of the template or partial holding the tag, such as its file name, if it has one
+	Tag      string // the tag, such as "{{name}}"
+	Line     int    // the line of the tag in its template
+	Column   int    // the column of the tag, counting bytes from 1
+	Err      error  // the error, such as that returned for a missing variable
+}
+
+func (e *RenderError) Error() string {
+	where := fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+	if e.Template != "" {
+		where = e.Template + " " + where
+	}
+	return fmt.Sprintf("%s: %s", where, e.Err)
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// positionedErrors are the errors which positionError locates.
+var positionedErrors = []error{
+	ErrMissingVariable, ErrPartialNotFound, ErrTooManyLambdaCalls, ErrTooManyPartialFetches, ErrTooManyLookups,
+}
+
+// positionError attaches the position of elem to err, if err is for a missing name or an exceeded render budget and
+// has no position yet.
+func (tmpl *Template) positionError(err error, elem interface{}) error {
+	var re *RenderError
+	if err == nil || errors.As(err, &re) {
+		return err
+	}
+	found := false
+	for _, target := range positionedErrors {
+		found = found || errors.Is(err, target)
+	}
+	if !found {
+		return err
+	}
+	line, col := elementPosition(elem)
+	if line == 0 {
+		return err
+	}
+	return &RenderError{Template: tmpl.name, Tag: "{{" + tagKey(elem) + "}}", Line: line, Column: col, Err: err}
+}
+
+// elementPosition returns the line and column of a tag, or a line of 0 if it was not compiled from source.
+func elementPosition(elem interface{}) (line, col int) {
+	switch elem := elem.(type) {
+	case *varElement:
+		return elem.line, elem.col
+	case *helperElement:
+		return elem.line, elem.col
+	case *exprElement:
+		return elem.line, elem.col
+	case *customTagElement:
+		return elem.line, elem.col
+	case *partialElement:
+		return elem.line, elem.col
+	case *sectionElement:
+		return elem.startline, elem.startcol
+	}
+	return 0, 0
+}
+
+// InternalError is returned in place of a panic while compiling or rendering a template, so that no input template or
+// data makes the package panic. errors.Is reports it as ErrInternal, and as the value of the panic if that is an error.
+type InternalError struct {
+	Op    string      // what was being done, such as looking up a name, if known
+	Value interface{} // the value passed to panic
+	Stack []byte      // the stack of the goroutine which panicked, as formatted by runtime/debug.Stack
+}
+
+func (e *InternalError) Error() string {
+	if e.Op != "" {
+		return fmt.Sprintf("mustache: %s while %s: %v", ErrInternal, e.Op, e.Value)
+	}
+	return fmt.Sprintf("mustache: %s: %v", ErrInternal, e.Value)
+}
+
+func (e *InternalError) Is(target error) bool {
+	return target == ErrInternal
+}
+
+func (e *InternalError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverInternal turns a panic into an *InternalError, which it stores in *err. It must be deferred directly, as in
+// defer recoverInternal(&err, "").
+func recoverInternal(err *error, op string) {
+	if r := recover(); r != nil {
+		*err = &InternalError{Op: op, Value: r, Stack: debug.Stack()}
+	}
+}
+
+// joinedError joins several errors, as errors.Join does from Go 1.20. Its Is and As methods match any of the errors, so
+// that errors.Is and errors.As see all of them in older versions of Go too.
+type joinedError struct {
+	errs []error
+}
+
+// joinErrors returns an error which joins the non-nil errs, or nil if there are none.
+func joinErrors(errs ...error) error {
+	e := &joinedError{}
+	for _, err := range errs {
+		if err != nil {
+			e.errs = append(e.errs, err)
+		}
+	}
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *joinedError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *joinedError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *joinedError) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *joinedError) Unwrap() []error {
+	return e.errs
+}
@@ -0,0 +1,129 @@
+package mustache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type panicky struct{}
+
+func (panicky) Boom() string {
+	panic("method panicked")
+}
+
+func (panicky) Fail() string {
+	panic(io.ErrUnexpectedEOF)
+}
+
+// panicProvider is a PartialProvider which panics.
+type panicProvider struct{}
+
+func (panicProvider) Get(name string) (string, error) {
+	panic("provider panicked")
+}
+
+func TestInternalError(t *testing.T) {
+	tests := []struct {
+		template string
+		data     interface{}
+		message  string
+	}{
+		{"{{Boom}}", panicky{}, `mustache: internal error while looking up "Boom": method panicked`},
+		{"{{x.Boom}}", map[string]interface{}{"x": panicky{}}, `mustache: internal error while looking up "Boom": method panicked`},
+		{"{{Fail}}", panicky{}, `mustache: internal error while looking up "Fail": unexpected EOF`},
+		{"{{#f}}x{{/f}}", map[string]interface{}{"f": func(string, RenderFn) (string, error) { panic("lambda panicked") }},
+			"mustache: internal error while rendering: lambda panicked"},
+	}
+	for _, test := range tests {
+		if lightBuild && needsMethods(test.data) {
+			continue
+		}
+		tmpl, err := New().CompileString(test.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tmpl.Render(test.data)
+		var ie *InternalError
+		if !errors.Is(err, ErrInternal) || !errors.As(err, &ie) || err.Error() != test.message {
+			t.Errorf("%q: expected %q, got %v", test.template, test.message, err)
+		} else if !strings.Contains(string(ie.Stack), "panic") {
+			t.Errorf("%q: expected the stack of the panic, got %s", test.template, ie.Stack)
+		}
+	}
+
+	// the value of the panic can be matched, if it is an error
+	tmpl, _ := New().CompileString("{{Fail}}")
+	if _, err := tmpl.Render(panicky{}); !lightBuild && !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected the error passed to panic, got %v", err)
+	}
+
+	// panics in providers are internal errors, whether or not the render has a deadline
+	tmpl, _ = New().WithPartials(panicProvider{}).CompileString("{{>p}}")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := tmpl.RenderContext(ctx); !errors.Is(err, ErrInternal) || !strings.Contains(err.Error(), "provider panicked") {
+		t.Errorf("expected an internal error from the provider, got %v", err)
+	}
+	if _, err := tmpl.Render(); !errors.Is(err, ErrInternal) {
+		t.Errorf("expected an internal error from the provider, got %v", err)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"page.mustache": {Data: []byte("Hi\n  {{#user}}{{name}}{{/user}}{{>card}}")},
+		"card.mustache": {Data: []byte("<b>\n {{title}}</b>")},
+	}
+	tmpl, err := New().WithErrors(true).CompileFS(fsys, "page.mustache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		data     interface{}
+		expected RenderError
+	}{
+		{map[string]interface{}{"user": map[string]string{}}, RenderError{Template: "page.mustache", Tag: "{{name}}", Line: 2, Column: 12}},
+		{map[string]interface{}{"user": false}, RenderError{Template: "card", Tag: "{{title}}", Line: 2, Column: 2}},
+	}
+	for _, test := range tests {
+		_, err := tmpl.Render(test.data)
+		var re *RenderError
+		if !errors.As(err, &re) || !errors.Is(err, ErrMissingVariable) {
+			t.Errorf("expected a RenderError for a missing variable, got %v", err)
+			continue
+		}
+		got := *re
+		got.Err = nil
+		if got != test.expected {
+			t.Errorf("expected %+v, got %+v", test.expected, got)
+		}
+	}
+	_, err = tmpl.Render(map[string]interface{}{"user": map[string]string{}})
+	if expected := `page.mustache line 2, column 12: missing variable "name"`; err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err)
+	}
+
+	// missing partials, and templates without names
+	tmpl, err = New().WithErrors(true).WithPartials(&StaticProvider{ReportMissing: true}).CompileString("a\n{{>nope}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tmpl.Render(nil)
+	var re *RenderError
+	if !errors.As(err, &re) || !errors.Is(err, ErrPartialNotFound) || re.Tag != "{{>nope}}" || re.Line != 2 || re.Column != 1 ||
+		!strings.HasPrefix(err.Error(), "line 2, column 1: ") {
+		t.Errorf("expected a RenderError for the partial, got %v", err)
+	}
+
+	// without WithErrors, missing names render as empty
+	if output, err := New().CompileString("{{name}}"); err != nil {
+		t.Fatal(err)
+	} else if s, err := output.Render(nil); err != nil || s != "" {
+		t.Errorf("expected no error, got %q, %v", s, err)
+	}
+}
@@ -0,0 +1,67 @@
+package mustache
+
+import (
+	"io"
+	"strings"
+)
+
+// The escapers below escape strings exactly as templates in the matching escape mode escape the values of variables,
+// so that snippets assembled by hand are escaped consistently with rendered output.
+
+// HTMLEscape writes s to w escaped as in the EscapeHTML mode: & < > " ' become &amp; &lt; &gt; &#34; &#39;, and NUL
+// becomes U+FFFD.
+func HTMLEscape(w io.Writer, s string) error {
+	return htmlEscape(w, s)
+}
+
+// HTMLEscapeString returns s escaped as in the EscapeHTML mode.
+func HTMLEscapeString(s string) string {
+	var b strings.Builder
+	_ = htmlEscape(&b, s)
+	return b.String()
+}
+
+// JSONEscapeString returns s escaped as in the EscapeJSON mode, for use within the quotes of a JSON string.
+func JSONEscapeString(s string) string {
+	var b strings.Builder
+	_ = JSONEscape(&b, s)
+	return b.String()
+}
+
+// JSONValueEscape writes s to w as in the EscapeJSONValue mode: as a complete, quoted JSON string, in which < > and &
+// are also escaped so that the JSON can be embedded in HTML.
+func JSONValueEscape(w io.Writer, s string) error {
+	_, err := io.WriteString(w, JSONValueEscapeString(s))
+	return err
+}
+
+// JSONValueEscapeString returns s as in the EscapeJSONValue mode: as a complete, quoted JSON string.
+func JSONValueEscapeString(s string) string {
+	// a string always marshals
+	out, _ := toJSONString(s)
+	return out
+}
+
+// Escape writes s to w escaped as in the escape mode m. In the Raw mode s is written as it is; as in templates,
+// nothing is written in modes unknown to the package.
+func Escape(w io.Writer, m EscapeMode, s string) error {
+	switch m {
+	case EscapeHTML:
+		return HTMLEscape(w, s)
+	case EscapeJSON:
+		return JSONEscape(w, s)
+	case EscapeJSONValue:
+		return JSONValueEscape(w, s)
+	case Raw:
+		_, err := io.WriteString(w, s)
+		return err
+	}
+	return nil
+}
+
+// EscapeString returns s escaped as in the escape mode m, like Escape.
+func EscapeString(m EscapeMode, s string) string {
+	var b strings.Builder
+	_ = Escape(&b, m, s)
+	return b.String()
+}
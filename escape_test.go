@@ -0,0 +1,39 @@
+package mustache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEscapeMatchesRender(t *testing.T) {
+	inputs := []string{"", "plain", `<a href="x">'&'</a>`, "line\nbreak\t\"quoted\"\\", "\x00\x1f ünï", "\xff"}
+	for _, mode := range []EscapeMode{EscapeHTML, EscapeJSON, Raw, EscapeJSONValue} {
+		tmpl, err := New().WithEscapeMode(mode).CompileString("{{v}}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range inputs {
+			expected, err := tmpl.Render(map[string]string{"v": s})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output := EscapeString(mode, s); output != expected {
+				t.Errorf("%s %q: expected %q, got %q", mode, s, expected, output)
+			}
+			var b strings.Builder
+			if err := Escape(&b, mode, s); err != nil || b.String() != expected {
+				t.Errorf("%s %q: expected %q, got %q (%v)", mode, s, expected, b.String(), err)
+			}
+		}
+	}
+
+	if s := HTMLEscapeString(`<"'&>`); s != "&lt;&#34;&#39;&amp;&gt;" {
+		t.Errorf("unexpected HTML escaping %q", s)
+	}
+	if s := JSONEscapeString("a\"\n"); s != `a\"\n` {
+		t.Errorf("unexpected JSON escaping %q", s)
+	}
+	if s := JSONValueEscapeString("<a>"); s != `"\u003ca\u003e"` {
+		t.Errorf("unexpected JSON value escaping %q", s)
+	}
+}
@@ -0,0 +1,249 @@
+package mustache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Explanation describes the tree of partials a template includes, as returned by Template.Explain.
+type Explanation struct {
+	Size     int                  // the size of the template's source, in bytes
+	Expanded int                  // the size of the source with every partial tag replaced by the partial, recursively
+	Partials []PartialExplanation // the partials included, directly or not, by decreasing contribution to Expanded
+}
+
+// PartialExplanation describes one partial of an Explanation.
+type PartialExplanation struct {
+	Name     string
+	Size     int // the size of the partial's source, in bytes
+	Expanded int // the size of the partial with its own partials expanded
+	Depth    int // the least depth at which the partial is included, 1 for the partials of the template itself
+	Includes int // the number of times the partial appears in the expanded template
+	// Recursive is set if the partial includes itself, directly or not. The recursive inclusions are not counted in
+	// Expanded and Includes, as they must be conditional for the template to render at all.
+	Recursive bool
+	Missing   bool // whether the partial was not found
+}
+
+// Expansion returns the expansion factor of the template, the size of the expanded template over the size of the
+// template itself.
+func (e *Explanation) Expansion() float64 {
+	return expansion(e.Expanded, e.Size)
+}
+
+// Expansion returns the expansion factor of the partial, the size of the partial with its own partials expanded over
+// the size of the partial itself.
+func (p *PartialExplanation) Expansion() float64 {
+	return expansion(p.Expanded, p.Size)
+}
+
+func expansion(expanded, size int) float64 {
+	if size == 0 {
+		return 1
+	}
+	return float64(expanded) / float64(size)
+}
+
+// String formats the explanation as a table, with a row for each partial.
+func (e *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "template: %d bytes, expanded %d bytes (x%.1f)\n", e.Size, e.Expanded, e.Expansion())
+	w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "partial\tsize\tdepth\tincludes\texpanded\texpansion\t")
+	for _, p := range e.Partials {
+		name := p.Name
+		switch {
+		case p.Missing:
+			name += " (missing)"
+		case p.Recursive:
+			name += " (recursive)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\tx%.1f\t\n", name, p.Size, p.Depth, p.Includes, p.Expanded, p.Expansion())
+	}
+	w.Flush()
+	return b.String()
+}
+
+// Explain loads every partial the template includes, directly or through other partials, from provider, or the
+// template's own PartialProvider if provider is nil, and reports the size of each, the depth at which it is first
+// included, how many times it is included, and how much larger the template and each partial become when their
+// partials are expanded. It is meant for finding templates which pull in large partials many times over, and for
+// checking a template against a size budget before deploying it:
+//
+//	explanation, err := tmpl.Explain(nil)
+//	if err == nil && explanation.Expanded > 1<<20 {
+//		log.Printf("template is too large:\n%s", explanation)
+//	}
+//
+// The sizes are of the template sources, counting each partial tag once, whether it is in a section or not; they are
+// not the size of any particular output. Missing partials, and all partials if there is no provider, are reported with
+// a size of zero. Other errors loading or compiling partials are returned.
+func (tmpl *Template) Explain(provider PartialProvider) (*Explanation, error) {
+	if provider == nil {
+		provider = tmpl.partial
+	}
+	x := &explainer{tmpl: tmpl, provider: provider, nodes: make(map[string]*explainNode), raw: make(map[string]bool)}
+	root := &explainNode{size: len(tmpl.data), depth: 0, partials: includedPartials(tmpl.elems, nil, x.raw)}
+	if err := x.load(root); err != nil {
+		return nil, err
+	}
+
+	// count the inclusions of each partial, parents first, ignoring the inclusions which close a cycle
+	x.expand(root)
+	root.includes = 1
+	for i := len(x.order) - 1; i >= 0; i-- {
+		node := x.order[i]
+		for _, child := range node.children {
+			if !node.back[child] {
+				child.includes += node.includes
+			}
+		}
+	}
+
+	e := &Explanation{Size: root.size, Expanded: root.expanded}
+	for name, node := range x.nodes {
+		e.Partials = append(e.Partials, PartialExplanation{
+			Name:      name,
+			Size:      node.size,
+			Expanded:  node.expanded,
+			Depth:     node.depth,
+			Includes:  node.includes,
+			Recursive: node.recursive,
+			Missing:   node.missing,
+		})
+	}
+	sort.Slice(e.Partials, func(i, j int) bool {
+		pi, pj := e.Partials[i], e.Partials[j]
+		if ci, cj := pi.Size*pi.Includes, pj.Size*pj.Includes; ci != cj {
+			return ci > cj
+		}
+		return pi.Name < pj.Name
+	})
+	return e, nil
+}
+
+// explainer loads the partial tree of a template for Explain.
+type explainer struct {
+	tmpl     *Template
+	provider PartialProvider
+	nodes    map[string]*explainNode
+	// raw holds the names of the partials included verbatim by some tag
+	raw map[string]bool
+	// order holds the nodes in the order their expansion finished, children before parents
+	order []*explainNode
+}
+
+// explainNode is the template or a partial in the tree loaded by explainer.
+type explainNode struct {
+	size     int
+	depth    int
+	partials []string // the names of the partials included, once for each tag
+	children []*explainNode
+	// back marks the children whose inclusion closes a cycle
+	back      map[*explainNode]bool
+	expanded  int
+	includes  int
+	state     int // 0 before expand visits the node, 1 while it is being expanded, 2 once it has been
+	recursive bool
+	missing   bool
+}
+
+// load loads the partials of node and of its partials, breadth first so that each records the least depth at which
+// it is included.
+func (x *explainer) load(root *explainNode) error {
+	queue := []*explainNode{root}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, name := range node.partials {
+			child, ok := x.nodes[name]
+			if !ok {
+				child = &explainNode{depth: node.depth + 1}
+				if x.raw[name] || isRawPartial(x.provider, name) {
+					if err := x.loadRaw(child, name); err != nil {
+						return err
+					}
+					x.nodes[name] = child
+					node.children = append(node.children, child)
+					continue
+				}
+				partial, err := x.tmpl.getPartials(context.Background(), x.provider, name)
+				switch {
+				case errors.Is(err, ErrPartialNotFound) || errors.Is(err, errNoPartialProvider):
+					child.missing = true
+				case err != nil:
+					return fmt.Errorf("partial %s: %w", name, err)
+				default:
+					child.size = len(partial.data)
+					child.partials = includedPartials(partial.elems, nil, x.raw)
+					queue = append(queue, child)
+				}
+				x.nodes[name] = child
+			}
+			node.children = append(node.children, child)
+		}
+	}
+	return nil
+}
+
+// loadRaw loads a partial which is included verbatim, and so includes no partials.
+func (x *explainer) loadRaw(node *explainNode, name string) error {
+	data, err := "", errNoPartialProvider
+	if x.provider != nil {
+		data, err = getPartialSource(context.Background(), x.provider, name)
+	}
+	switch {
+	case errors.Is(err, ErrPartialNotFound):
+		node.missing = true
+	case err != nil:
+		return fmt.Errorf("partial %s: %w", name, err)
+	}
+	node.size = len(data)
+	return nil
+}
+
+// expand computes the expanded size of node, depth first, marking the inclusions which close a cycle.
+func (x *explainer) expand(node *explainNode) {
+	node.state = 1
+	node.expanded = node.size
+	for _, child := range node.children {
+		switch child.state {
+		case 0:
+			x.expand(child)
+		case 1:
+			if node.back == nil {
+				node.back = make(map[*explainNode]bool)
+			}
+			node.back[child] = true
+			child.recursive = true
+			continue
+		}
+		node.expanded += child.expanded
+	}
+	node.state = 2
+	x.order = append(x.order, node)
+}
+
+// includedPartials appends the name of each partial tag in elems, including those in sections, to names, and adds the
+// names of those included verbatim to raw.
+func includedPartials(elems []interface{}, names []string, raw map[string]bool) []string {
+	for _, elem := range elems {
+		switch elem := elem.(type) {
+		case *partialElement:
+			names = append(names, elem.name)
+			if elem.raw {
+				raw[elem.name] = true
+			}
+		case *sectionElement:
+			if elem.sigil == parentSigil {
+				names = append(names, elem.name)
+			}
+			names = includedPartials(elem.elems, names, raw)
+		}
+	}
+	return names
+}
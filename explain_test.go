@@ -0,0 +1,101 @@
+package mustache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	big := strings.Repeat("x", 1000)
+	partials := &StaticProvider{ReportMissing: true, Partials: map[string]string{
+		"layout": "<html>{{>header}}{{#items}}{{>item}}{{/items}}{{>footer}}</html>",
+		"header": "<h1>{{title}}</h1>{{>logo}}",
+		"footer": "{{>logo}}{{>nav}}",
+		"item":   "<li>{{>logo}}{{>logo}}{{>big}}</li>",
+		"logo":   "<img>",
+		"big":    big,
+		"nav":    "{{#children}}{{>nav}}{{/children}}",
+	}}
+	tmpl, err := New().WithPartials(partials).CompileString("{{>layout}}{{>missing}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := tmpl.Explain(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string]PartialExplanation)
+	for _, p := range e.Partials {
+		byName[p.Name] = p
+	}
+	size := func(name string) int { return len(partials.Partials[name]) }
+	logo := size("logo")
+	header := size("header") + logo
+	item := size("item") + 2*logo + size("big")
+	footer := size("footer") + logo + size("nav")
+	layout := size("layout") + header + item + footer
+	expected := map[string]PartialExplanation{
+		"layout":  {Name: "layout", Size: size("layout"), Expanded: layout, Depth: 1, Includes: 1},
+		"header":  {Name: "header", Size: size("header"), Expanded: header, Depth: 2, Includes: 1},
+		"item":    {Name: "item", Size: size("item"), Expanded: item, Depth: 2, Includes: 1},
+		"footer":  {Name: "footer", Size: size("footer"), Expanded: footer, Depth: 2, Includes: 1},
+		"logo":    {Name: "logo", Size: logo, Expanded: logo, Depth: 3, Includes: 4},
+		"big":     {Name: "big", Size: 1000, Expanded: 1000, Depth: 3, Includes: 1},
+		"nav":     {Name: "nav", Size: size("nav"), Expanded: size("nav"), Depth: 3, Includes: 1, Recursive: true},
+		"missing": {Name: "missing", Depth: 1, Includes: 1, Missing: true},
+	}
+	if len(byName) != len(expected) {
+		t.Errorf("expected %d partials, got %+v", len(expected), e.Partials)
+	}
+	for name, want := range expected {
+		if got := byName[name]; got != want {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+	}
+	if e.Size != 23 || e.Expanded != 23+layout {
+		t.Errorf("unexpected template sizes %d, %d", e.Size, e.Expanded)
+	}
+	if e.Partials[0].Name != "big" {
+		t.Errorf("expected the largest contribution first, got %s", e.Partials[0].Name)
+	}
+	report := e.String()
+	for _, s := range []string{"template: 23 bytes, expanded 1220 bytes (x53.0)", "nav (recursive)", "missing (missing)"} {
+		if !strings.Contains(report, s) {
+			t.Errorf("expected %q in report:\n%s", s, report)
+		}
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	tmpl, err := New().CompileString("{{>a}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := tmpl.Explain(nil)
+	if err != nil || len(e.Partials) != 1 || !e.Partials[0].Missing {
+		t.Errorf("expected a missing partial without a provider, got %+v, %v", e, err)
+	}
+
+	_, err = tmpl.Explain(&StaticProvider{Partials: map[string]string{"a": "{{#b}}"}})
+	if err == nil || !strings.Contains(err.Error(), "partial a") {
+		t.Errorf("expected a parse error in partial a, got %v", err)
+	}
+	e, err = tmpl.Explain(&StaticProvider{Partials: map[string]string{"a": "{{#b}}"}, RawPartials: map[string]bool{"a": true}})
+	if err != nil || len(e.Partials) != 1 || e.Partials[0].Size != 6 {
+		t.Errorf("expected a raw partial to be measured without parsing, got %+v, %v", e, err)
+	}
+
+	failing := errors.New("unavailable")
+	_, err = tmpl.Explain(providerFunc(func(string) (string, error) { return "", failing }))
+	if !errors.Is(err, failing) {
+		t.Errorf("expected the provider error, got %v", err)
+	}
+}
+
+type providerFunc func(name string) (string, error)
+
+func (f providerFunc) Get(name string) (string, error) {
+	return f(name)
+}
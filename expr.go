@@ -0,0 +1,372 @@
+package mustache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// WithExpressions enables simple expressions in variable tags, which are not part of the Mustache spec: arithmetic
+// over numbers, such as {{price * quantity}}, and pipelines which pass a value to printf or to a helper, such as
+// {{total | printf "%.2f"}}. Templates can then derive values themselves rather than relying on the code building
+// their contexts to compute them.
+//
+// The operators are +, -, *, / and %, with the usual precedence, and parentheses. They must be separated from their
+// operands by spaces, as names may contain dashes. Operands are numbers, names, which are looked up like variables and
+// must hold numbers or strings of numbers, and parenthesized expressions. Integers stay integers, except under /,
+// which always divides exactly, and % takes integers alone. An expression with a missing operand is missing itself.
+//
+// A pipeline passes the value before each | to the call after it as its last argument, as in Go templates. A call is
+// of printf, which formats with fmt.Sprintf, or of a helper. Helpers which take the value first are called with it
+// in parentheses instead, as in {{currency (price * 1.2) "EUR"}}.
+//
+// Expressions call no methods other than those of the values they look up, and evaluate in time proportional to
+// their length.
+func (r *Compiler) WithExpressions(b bool) *Compiler {
+	r.expressions = b
+	return r
+}
+
+// exprElement is a variable tag holding an expression.
+type exprElement struct {
+	src    string // the text of the tag
+	root   *exprNode
+	raw    bool
+	line   int // the line of the tag, or 0 if it was not compiled from source
+	col    int // the column of the tag, counting bytes from 1
+	offset int // the offset of the tag in the source
+}
+
+// exprNode is a node of the tree of an expression: a literal, a name, an arithmetic operation or a call.
+type exprNode struct {
+	op    string // one of the operators, "call", "name" or "" for a literal
+	name  string // the name looked up, or the function called
+	value interface{}
+	args  []*exprNode // the operands of an operator, or the arguments of a call
+}
+
+// isExpression reports whether a tag holds an expression: whether it calls printf, or any of its words outside quotes
+// is an operator, a pipe or an opening parenthesis.
+func isExpression(tag string) bool {
+	tokens := exprTokens(tag)
+	if len(tokens) > 1 && tokens[0] == "printf" {
+		return true
+	}
+	for _, tok := range tokens {
+		if tok == "|" || tok == "(" || isOperator(tok) {
+			return true
+		}
+	}
+	return false
+}
+
+// exprTokens splits the text of an expression into words, quoted strings and parentheses. An unterminated string
+// runs to the end of the text.
+func exprTokens(text string) []string {
+	var tokens []string
+	for {
+		text = strings.TrimLeft(text, " \t\r\n")
+		if text == "" {
+			return tokens
+		}
+		end := 1
+		switch text[0] {
+		case '(', ')':
+		case '"', '\'':
+			if close := strings.IndexByte(text[1:], text[0]); close >= 0 {
+				end = close + 2
+			} else {
+				end = len(text)
+			}
+		default:
+			for end < len(text) && !strings.ContainsRune(" \t\r\n()", rune(text[end])) {
+				end++
+			}
+		}
+		tokens = append(tokens, text[:end])
+		text = text[end:]
+	}
+}
+
+// exprParser parses the tokens of an expression by recursive descent.
+type exprParser struct {
+	tmpl   *Template
+	tokens []string
+	pos    int
+}
+
+func (tmpl *Template) parseExpression(tag string, raw bool) (*exprElement, error) {
+	p := &exprParser{tmpl: tmpl, tokens: exprTokens(tag)}
+	root, err := p.pipeline()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, tmpl.parseError(fmt.Sprintf("expression %q: %s", tag, err))
+	}
+	return &exprElement{src: tag, root: root, raw: raw, line: tmpl.tagLine, col: tmpl.tagColumn, offset: tmpl.tagOffset}, nil
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) pipeline() (*exprNode, error) {
+	node, err := p.stage()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "|" {
+		p.pos++
+		call, err := p.call()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, node)
+		node = call
+	}
+	return node, nil
+}
+
+// stage is the first stage of a pipeline: a call of a helper or printf with arguments, or arithmetic.
+func (p *exprParser) stage() (*exprNode, error) {
+	if p.pos+1 < len(p.tokens) && p.isFunc(p.peek()) && !isOperator(p.tokens[p.pos+1]) && p.tokens[p.pos+1] != "|" {
+		return p.call()
+	}
+	return p.sum()
+}
+
+func (p *exprParser) isFunc(name string) bool {
+	if name == "printf" {
+		return true
+	}
+	_, ok := p.tmpl.parent.helper(name)
+	return ok
+}
+
+func (p *exprParser) call() (*exprNode, error) {
+	name := p.peek()
+	if !p.isFunc(name) {
+		return nil, fmt.Errorf("%q is not printf or a helper", name)
+	}
+	p.pos++
+	node := &exprNode{op: "call", name: name}
+	for p.pos < len(p.tokens) && p.peek() != "|" && p.peek() != ")" {
+		arg, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		node.args = append(node.args, arg)
+	}
+	return node, nil
+}
+
+func (p *exprParser) sum() (*exprNode, error) {
+	return p.binary(p.product, "+", "-")
+}
+
+func (p *exprParser) product() (*exprNode, error) {
+	return p.binary(p.operand, "*", "/", "%")
+}
+
+func (p *exprParser) binary(next func() (*exprNode, error), ops ...string) (*exprNode, error) {
+	node, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		found := false
+		for _, o := range ops {
+			found = found || op == o
+		}
+		if !found {
+			return node, nil
+		}
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		node = &exprNode{op: op, args: []*exprNode{node, right}}
+	}
+}
+
+func (p *exprParser) operand() (*exprNode, error) {
+	tok := p.peek()
+	p.pos++
+	switch {
+	case tok == "":
+		return nil, errors.New("unexpected end")
+	case tok == "(":
+		node, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return node, nil
+	case tok == ")" || tok == "|" || isOperator(tok):
+		return nil, fmt.Errorf("unexpected %q", tok)
+	case (tok[0] == '"' || tok[0] == '\'') && (len(tok) < 2 || tok[len(tok)-1] != tok[0]):
+		return nil, errors.New("unterminated string")
+	}
+	if v, ok := handlebarsLiteral(tok); ok {
+		return &exprNode{value: v}, nil
+	}
+	return &exprNode{op: "name", name: tok}, nil
+}
+
+func isOperator(tok string) bool {
+	switch tok {
+	case "+", "-", "*", "/", "%":
+		return true
+	}
+	return false
+}
+
+// renderExpression evaluates an expression and writes its value like that of a variable.
+func (tmpl *Template) renderExpression(st *renderState, elem *exprElement, contextChain []reflect.Value, buf io.Writer) error {
+	value, err := tmpl.evalExpression(st, elem.root, contextChain)
+	if err != nil {
+		return fmt.Errorf("expression %q: %w", elem.src, err)
+	}
+	if value == nil {
+		if tmpl.outputMode == EscapeJSONValue && !elem.raw {
+			err = tmpl.writeEscaped(st, buf, "null", false, false)
+		}
+		return err
+	}
+	s, err := tmpl.valueString(&varElement{name: elem.src, raw: elem.raw}, value)
+	if err != nil {
+		return err
+	}
+	if elem.raw {
+		s = tmpl.sanitizeRaw(value, s)
+	}
+	return tmpl.writeEscaped(st, buf, s, elem.raw, tmpl.isStructured(elem.raw, value) || tmpl.isAttrs(value))
+}
+
+// evalExpression returns the value of a node of an expression, or nil if it is missing.
+func (tmpl *Template) evalExpression(st *renderState, node *exprNode, contextChain []reflect.Value) (interface{}, error) {
+	switch node.op {
+	case "":
+		return node.value, nil
+	case "name":
+		v, frame, err := tmpl.lookup(st, contextChain, node.name)
+		if err != nil {
+			return nil, err
+		}
+		if st.usage != nil {
+			st.usage.use(contextChain, frame, node.name, true)
+		}
+		if !indirect(v).IsValid() {
+			return nil, nil
+		}
+		return indirectValue(v.Interface()), nil
+	}
+	args := make([]interface{}, len(node.args))
+	for i, arg := range node.args {
+		var err error
+		if args[i], err = tmpl.evalExpression(st, arg, contextChain); err != nil {
+			return nil, err
+		}
+	}
+	if node.op == "call" {
+		if node.name == "printf" {
+			if len(args) == 0 {
+				return nil, errors.New("printf: expected a format")
+			}
+			return fmt.Sprintf(fmt.Sprint(args[0]), args[1:]...), nil
+		}
+		h, _ := tmpl.parent.helper(node.name)
+		s, err := h(args...)
+		if err != nil {
+			return nil, fmt.Errorf("helper %s: %w", node.name, err)
+		}
+		return s, nil
+	}
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	return arithmetic(node.op, args[0], args[1])
+}
+
+// arithmetic applies an operator to two numbers, or strings holding numbers.
+func arithmetic(op string, a, b interface{}) (interface{}, error) {
+	x, xInt := toInt(a)
+	y, yInt := toInt(b)
+	if xInt && yInt && op != "/" {
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "%":
+			if y == 0 {
+				return nil, errors.New("division by zero")
+			}
+			return x % y, nil
+		}
+	}
+	if op == "%" {
+		return nil, fmt.Errorf("%% takes integers, got %v and %v", a, b)
+	}
+	f, err := toFloat(a)
+	if err != nil {
+		return nil, fmt.Errorf("expected a number, got %q", fmt.Sprint(a))
+	}
+	g, err := toFloat(b)
+	if err != nil {
+		return nil, fmt.Errorf("expected a number, got %q", fmt.Sprint(b))
+	}
+	switch op {
+	case "+":
+		return f + g, nil
+	case "-":
+		return f - g, nil
+	case "*":
+		return f * g, nil
+	}
+	if g == 0 {
+		return nil, errors.New("division by zero")
+	}
+	return f / g, nil
+}
+
+// toInt converts an integer, of any integer type, to an int64, and reports whether it is one.
+func toInt(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+// names returns the names the expression looks up.
+func (elem *exprElement) names() []string {
+	var names []string
+	var walk func(node *exprNode)
+	walk = func(node *exprNode) {
+		if node.op == "name" {
+			names = append(names, node.name)
+		}
+		for _, arg := range node.args {
+			walk(arg)
+		}
+	}
+	walk(elem.root)
+	return names
+}
@@ -0,0 +1,101 @@
+package mustache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExpressions(t *testing.T) {
+	data := map[string]interface{}{
+		"price":      2.5,
+		"quantity":   4,
+		"count":      7,
+		"total":      10.126,
+		"name":       "<Ann>",
+		"first-name": "Ann",
+		"items":      []map[string]interface{}{{"n": 2, "each": 3}, {"n": 1, "each": 5}},
+	}
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{"{{price * quantity}} {{count / 2}} {{count % 4}} {{count - 1 - 2}} {{1 + 2 * 3}} {{(1 + 2) * 3}}", "10 3.5 3 4 7 9"},
+		{`{{total | printf "%.2f"}} {{printf "%05d" count}} {{price * quantity | printf "$%.2f"}}`, "10.13 00007 $10.00"},
+		{`{{currency (price * quantity) "EUR"}} {{plural (count - 6) "item" "items"}} {{count | label "count"}}`, "€10.00 item count: 7"},
+		{"{{#items}}{{n * each}},{{/items}} {{missing + 1}}|{{first-name}}", "6,5, |Ann"},
+		{`{{name | printf "%s!"}} {{{name | printf "%s!"}}}`, "&lt;Ann&gt;! <Ann>!"},
+	}
+	cmpl := New().WithExpressions(true).WithHelper("label", func(args ...interface{}) (string, error) {
+		return fmt.Sprintf("%v: %v", args[0], args[1]), nil
+	})
+	for _, test := range tests {
+		tmpl, err := cmpl.CompileString(test.tmpl)
+		if err != nil {
+			t.Fatalf("%q: %v", test.tmpl, err)
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.tmpl, test.expected, output)
+		}
+	}
+
+	// without the option, the tags are names
+	tmpl, err := New().CompileString("{{price * quantity}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(map[string]string{"price * quantity": "x"}); err != nil || output != "x" {
+		t.Errorf("expected a plain variable, got %q, %v", output, err)
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	for _, src := range []string{"{{price *}}", "{{(price * 2}}", "{{price | upper}}", `{{price | printf "%d}}`} {
+		if _, err := New().WithExpressions(true).CompileString(src); err == nil || !strings.Contains(err.Error(), "expression") {
+			t.Errorf("%q: expected an expression error, got %v", src, err)
+		}
+	}
+	for src, msg := range map[string]string{
+		"{{count / 0}}":    "division by zero",
+		"{{name * 2}}":     "expected a number",
+		"{{price % 2}}":    "% takes integers",
+		"{{count % zero}}": "division by zero",
+	} {
+		tmpl, err := New().WithExpressions(true).CompileString(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tmpl.Render(map[string]interface{}{"count": 3, "zero": 0, "name": "x", "price": 1.5})
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%q: expected %q, got %v", src, msg, err)
+		}
+	}
+}
+
+func TestExpressionAST(t *testing.T) {
+	cmpl := New().WithExpressions(true)
+	tmpl, err := cmpl.CompileString("{{a + b}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := tmpl.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(ast), `{"type":"expression","name":"a + b"}`) {
+		t.Errorf("unexpected AST %s", ast)
+	}
+	compiled, err := cmpl.CompileJSON(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := compiled.Render(map[string]int{"a": 1, "b": 2}); err != nil || output != "3" {
+		t.Errorf("got %q, %v", output, err)
+	}
+	if _, err := New().CompileJSON(ast); err == nil {
+		t.Error("expected an error compiling an expression without the option")
+	}
+}
@@ -0,0 +1,42 @@
+package mustache
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// WithFallback returns a copy of the template which, when a render fails, for instance with a missing variable under
+// WithErrors(true), renders fallback with the same data instead, such as a generic error message, so that users are
+// not sent a blank page or e-mail. onError, if not nil, is called with the error of the failed render, and the render
+// succeeds if the fallback does; if it does not, both errors are returned. Output is buffered until the template or
+// the fallback has rendered successfully, so nothing of a failed render is written. Renders which fail because their context is done are not retried with the fallback. The template itself
+// is not modified.
+func (tmpl *Template) WithFallback(fallback *Template, onError func(err error)) *Template {
+	out := *tmpl
+	out.fallback = fallback
+	out.onFallback = onError
+	return &out
+}
+
+// frenderWithFallback renders the template to out, or its fallback if the render fails.
+func (tmpl *Template) frenderWithFallback(ctx context.Context, out io.Writer, data []interface{}, prepare func(*Template, *renderState)) error {
+	var buf bytes.Buffer
+	err := tmpl.frenderContext(ctx, &buf, tmpl.elems, data, prepare)
+	if err == nil {
+		_, err = buf.WriteTo(out)
+		return err
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+	if tmpl.onFallback != nil {
+		tmpl.onFallback(err)
+	}
+	buf.Reset()
+	if fallbackErr := tmpl.fallback.frenderPrepared(ctx, &buf, data, prepare); fallbackErr != nil {
+		return joinErrors(err, fallbackErr)
+	}
+	_, err = buf.WriteTo(out)
+	return err
+}
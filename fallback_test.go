@@ -0,0 +1,43 @@
+package mustache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	cmpl := New().WithErrors(true)
+	page, err := cmpl.CompileString("<h1>{{title}}</h1><p>{{body}}</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	card, err := cmpl.CompileString("<p>Sorry, {{title}} is unavailable.</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failures []error
+	safe := page.WithFallback(card, func(err error) { failures = append(failures, err) })
+
+	output, err := safe.Render(map[string]string{"title": "News", "body": "Hello"})
+	if err != nil || output != "<h1>News</h1><p>Hello</p>" || len(failures) != 0 {
+		t.Errorf("unexpected output %q, error %v, failures %v", output, err, failures)
+	}
+	output, err = safe.Render(map[string]string{"title": "News"})
+	if err != nil || output != "<p>Sorry, News is unavailable.</p>" {
+		t.Errorf("expected the fallback, got %q, error %v", output, err)
+	}
+	if len(failures) != 1 || !strings.Contains(failures[0].Error(), `missing variable "body"`) {
+		t.Errorf("expected the error of the failed render, got %v", failures)
+	}
+	if _, err := page.Render(map[string]string{"title": "News"}); err == nil {
+		t.Error("expected the original template to fail without a fallback")
+	}
+
+	// both errors are returned if the fallback fails too
+	output, err = safe.Render(nil)
+	var joined interface{ Unwrap() []error }
+	if output != "" || !errors.As(err, &joined) || len(joined.Unwrap()) != 2 {
+		t.Errorf("expected both errors and no output, got %q, error %v", output, err)
+	}
+}
@@ -0,0 +1,133 @@
+//go:build !mustache_minimal
+
+package mustache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CompileFile compiles a Mustache template from a file. A byte order mark at the start of the file is dropped, and files
+// with a UTF-16 byte order mark are transcoded to UTF-8.
+func (r *Compiler) CompileFile(filename string) (*Template, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := r.readTemplate(f)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = decodeFile(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return r.compile(context.Background(), filename, data)
+}
+
+// FileProvider implements the PartialProvider interface by providing partials drawn from a filesystem. When a partial
+// named `NAME`  is requested, FileProvider searches each listed path for a file named as `NAME` followed by any of the
+// listed extensions. The default for `Paths` is to search the current working directory. The default for `Extensions`
+// is to examine, in order, no extension; then ".mustache"; then ".stache". If Unsafe is set, partial names are allowed
+// to begin with '.' or '..' after cleaning, meaning they can potentially refer to files outside any of the listed
+// directory paths. Byte order marks are handled as by CompileFile.
+//
+// Unsafe names, and files which can't be opened or read, for instance for lack of permission, are reported as missing
+// partials with an error wrapping both ErrPartialNotFound and the cause, so that they render as empty strings unless
+// WithErrors(true) is set.
+type FileProvider struct {
+	Paths      []string
+	Extensions []string
+	Unsafe     bool
+	// Timeout optionally limits how long loading a partial may take, for instance from a network filesystem.
+	Timeout time.Duration
+	// RawExtensions optionally lists the extensions, such as ".css" and ".js", of partials which are included verbatim,
+	// as described for RawPartialProvider. They are matched against the names of partials.
+	RawExtensions []string
+}
+
+// Get accepts the name of a partial and returns the parsed partial.
+func (fp *FileProvider) Get(name string) (string, error) {
+	return fp.GetContext(context.Background(), name)
+}
+
+// GetContext returns a partial like Get, giving up once ctx is done or the provider's Timeout has passed.
+func (fp *FileProvider) GetContext(ctx context.Context, name string) (string, error) {
+	clean := name
+	if !fp.Unsafe {
+		// Use a '/' prefix so filepath.Clean can prevent a directory traversal
+		cname := "/" + strings.Trim(name, "/\\")
+		cname = strings.ReplaceAll(filepath.Clean(cname), "\\", "/")
+		cname = strings.TrimLeft(cname, "/")
+		if cname != name || cname == "" {
+			return "", fmt.Errorf("unsafe partial name passed to FileProvider: %s: %w", name, ErrPartialNotFound)
+		}
+		clean = cname
+	}
+
+	var paths []string
+	if fp.Paths != nil {
+		paths = fp.Paths
+	} else {
+		paths = []string{""}
+	}
+
+	var exts []string
+	if fp.Extensions != nil {
+		exts = fp.Extensions
+	} else {
+		exts = []string{"", ".mustache", ".stache"}
+	}
+
+	if fp.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fp.Timeout)
+		defer cancel()
+	}
+	return withContext(ctx, func() (string, error) {
+		// the first file found which could not be opened, other than for not existing
+		var unreadable error
+		for _, p := range paths {
+			for _, e := range exts {
+				if err := ctx.Err(); err != nil {
+					return "", err
+				}
+				f, err := os.Open(filepath.Join(p, clean+e))
+				if err != nil {
+					if !errors.Is(err, fs.ErrNotExist) && unreadable == nil {
+						unreadable = err
+					}
+					continue
+				}
+				defer f.Close()
+				data, err := io.ReadAll(f)
+				if err == nil {
+					data, err = decodeFile(data)
+				}
+				if err != nil {
+					return "", fmt.Errorf("%s: %w", name, &missingPartialError{err})
+				}
+				return string(data), nil
+			}
+		}
+		if unreadable != nil {
+			return "", fmt.Errorf("%s: %w", name, &missingPartialError{unreadable})
+		}
+		return "", fmt.Errorf("%s: %w", name, ErrPartialNotFound)
+	})
+}
+
+// RawPartial implements RawPartialProvider.
+func (fp *FileProvider) RawPartial(name string) bool {
+	return hasRawExtension(name, fp.RawExtensions)
+}
+
+var _ ContextPartialProvider = (*FileProvider)(nil)
+var _ RawPartialProvider = (*FileProvider)(nil)
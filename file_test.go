@@ -0,0 +1,185 @@
+//go:build !mustache_minimal
+
+package mustache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	filename := path.Join(path.Join(os.Getenv("PWD"), "tests"), "test1.mustache")
+	expected := "hello world"
+	cmpl, err := New().CompileFile(filename)
+	if err != nil {
+		t.Error(err)
+	}
+	output, err := cmpl.Render(map[string]string{"name": "world"})
+	if err != nil {
+		t.Error(err)
+	} else if output != expected {
+		t.Errorf("testfile expected %q got %q", expected, output)
+	}
+}
+
+func TestFRender(t *testing.T) {
+	filename := path.Join(path.Join(os.Getenv("PWD"), "tests"), "test1.mustache")
+	expected := "hello world"
+	tmpl, err := New().CompileFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Frender(&buf, map[string]string{"name": "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+	if output != expected {
+		t.Fatalf("testfile expected %q got %q", expected, output)
+	}
+}
+
+func TestPartial(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Error(err)
+	}
+	testdir := path.Join(cwd, "tests")
+	filename := path.Join(testdir, "test2.mustache")
+	expected := "hello world"
+	tmpl, err := New().WithErrors(true).
+		WithPartials(&FileProvider{Paths: []string{testdir}, Extensions: []string{".mustache"}}).
+		CompileFile(filename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	output, err := tmpl.Render(map[string]string{"Name": "world"})
+	if err != nil {
+		t.Error(err)
+		return
+	} else if output != expected {
+		t.Errorf("testpartial expected %q got %q", expected, output)
+		return
+	}
+
+	expectedTags := []tag{
+		{
+			Type: Partial,
+			Name: "partial",
+		},
+	}
+	compareTags(t, tmpl.Tags(), expectedTags)
+}
+
+func TestMissingPartial(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	providers := []struct {
+		name     string
+		provider PartialProvider
+		reports  bool
+	}{
+		{"static", &StaticProvider{Partials: map[string]string{}}, false},
+		{"static reporting missing", &StaticProvider{Partials: map[string]string{}, ReportMissing: true}, true},
+		{"file", &FileProvider{Paths: []string{path.Join(cwd, "tests")}}, true},
+		{"none", nil, true},
+	}
+	for _, p := range providers {
+		for _, withErrors := range []bool{false, true} {
+			tmpl, err := New().WithPartials(p.provider).WithErrors(withErrors).CompileString("a{{>missing}}b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			output, err := tmpl.Render(nil)
+			if withErrors && p.reports {
+				if !errors.Is(err, ErrPartialNotFound) {
+					t.Errorf("%s: expected ErrPartialNotFound, got %v", p.name, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", p.name, err)
+			} else if output != "ab" {
+				t.Errorf("%s: expected %q got %q", p.name, "ab", output)
+			}
+		}
+	}
+}
+
+func TestUnreadablePartial(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// partials which can't be read, or whose names are unsafe, are missing, rendering empty unless errors are enabled
+	for _, name := range []string{"dir", "../unsafe"} {
+		for _, withErrors := range []bool{false, true} {
+			tmpl, err := New().WithErrors(withErrors).WithPartials(&FileProvider{Paths: []string{dir}, Extensions: []string{""}}).
+				CompileString("a{{>" + name + "}}b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			output, err := tmpl.Render(nil)
+			if withErrors {
+				if !errors.Is(err, ErrPartialNotFound) {
+					t.Errorf("%s: expected ErrPartialNotFound, got %v", name, err)
+				}
+			} else if err != nil || output != "ab" {
+				t.Errorf("%s: expected %q, got %q, %v", name, "ab", output, err)
+			}
+		}
+	}
+}
+
+func TestPartialSafety(t *testing.T) {
+	tmpl, err := New().WithErrors(true).WithPartials(&FileProvider{}).CompileString("{{>../unsafe}}")
+	if err != nil {
+		t.Error(err)
+	}
+	txt, err := tmpl.Render(nil)
+	if err == nil {
+		t.Errorf("expected error for unsafe partial")
+	}
+	if txt != "" {
+		t.Errorf("expected unsafe partial to fail")
+	}
+}
+
+func TestPartialSafetyWindows(t *testing.T) {
+	tmpl, err := New().WithErrors(true).WithPartials(&FileProvider{}).CompileString("{{>spec/..\\..\\test.txt}}")
+	if err != nil {
+		t.Error(err)
+	}
+	txt, err := tmpl.Render(nil)
+	if err == nil {
+		t.Errorf("expected error for unsafe partial")
+	}
+	if txt != "" {
+		t.Errorf("expected unsafe partial to fail")
+	}
+}
+
+func TestFileProviderContext(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "p.mustache"), []byte("file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fp := &FileProvider{Paths: []string{dir}}
+	if data, err := fp.GetContext(context.Background(), "p"); err != nil || data != "file" {
+		t.Errorf("unexpected partial %q, %v", data, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fp.GetContext(ctx, "p"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+}
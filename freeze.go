@@ -0,0 +1,222 @@
+package mustache
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// WithFrozenContext makes renders fail when the data they are rendered with changes during the render, as when a
+// method, a lambda or a ValueStringer called for a tag modifies the shared structures it was called on. It is a
+// debugging aid: the data is recorded before the render, following pointers, interfaces, maps, slices and struct
+// fields, unexported ones included, and is compared with the record after each tag, which makes renders many times
+// slower. A render whose data changes fails with a *MutationError naming the first tag after which it changed.
+//
+// Functions and channels are recorded by identity alone, and values reached through them or through unsafe pointers
+// are not recorded. Methods which legitimately change the state of their receiver, such as those caching a result or
+// counting calls, are reported as mutations as well.
+func (r *Compiler) WithFrozenContext(b bool) *Compiler {
+	r.frozenContext = b
+	return r
+}
+
+// MutationError is returned by renders with frozen contexts when the data they are rendered with changes. errors.Is
+// reports it as ErrContextMutated.
+type MutationError struct {
+	Tag     string // the tag after which the change was found, such as "{{#items}}"
+	Line    int    // the line of the tag, or 0 if it was not compiled from source
+	Context int    // the position of the changed context among those passed to the render
+	Path    string // the path to the changed value within the context, such as "Items[2].Name", or "." for the context
+	Before  string // the value before the change, or "missing" if it was added
+	After   string // the value after the change, or "missing" if it was removed
+}
+
+func (e *MutationError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s at %s: %s became %s", e.Line, e.Tag, ErrContextMutated, e.Path, e.Before, e.After)
+}
+
+func (e *MutationError) Unwrap() error {
+	return ErrContextMutated
+}
+
+// frozenContext holds the record of the contexts of a render, for WithFrozenContext.
+type frozenContext struct {
+	contexts []reflect.Value
+	record   []map[string]string
+}
+
+func newFrozenContext(contexts []reflect.Value) *frozenContext {
+	fc := &frozenContext{contexts: contexts, record: make([]map[string]string, len(contexts))}
+	for i, c := range contexts {
+		fc.record[i] = recordValue(c)
+	}
+	return fc
+}
+
+// check compares the contexts with their record after elem is rendered.
+func (fc *frozenContext) check(elem interface{}) error {
+	for i, c := range fc.contexts {
+		now := recordValue(c)
+		path, ok := firstChange(fc.record[i], now)
+		if !ok {
+			continue
+		}
+		line, _ := elementPosition(elem)
+		err := &MutationError{Tag: "{{" + tagKey(elem) + "}}", Line: line, Context: i, Path: path,
+			Before: "missing", After: "missing"}
+		if v, ok := fc.record[i][path]; ok {
+			err.Before = v
+		}
+		if v, ok := now[path]; ok {
+			err.After = v
+		}
+		fc.record[i] = now
+		return err
+	}
+	return nil
+}
+
+// firstChange returns the first path, in order, whose value differs between before and after.
+func firstChange(before, after map[string]string) (string, bool) {
+	var changed []string
+	for path, v := range before {
+		if w, ok := after[path]; !ok || w != v {
+			changed = append(changed, path)
+		}
+	}
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	if len(changed) == 0 {
+		return "", false
+	}
+	sort.Strings(changed)
+	return changed[0], true
+}
+
+// recordValue records the values reachable from v by their paths.
+func recordValue(v reflect.Value) map[string]string {
+	rec := make(map[string]string)
+	recordPath(rec, make(map[recordedRef]string), v, ".")
+	return rec
+}
+
+// recordedRef identifies the target of a pointer, as pointers to a struct and to its first field share an address.
+type recordedRef struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// recordPath records v and the values reachable from it at path. seen holds the paths at which the targets of
+// pointers were recorded, so that a value which refers to itself, or is shared, is recorded only once.
+func recordPath(rec map[string]string, seen map[recordedRef]string, v reflect.Value, path string) {
+	join := func(sep, name string) string {
+		if path == "." {
+			return name
+		}
+		return path + sep + name
+	}
+	if !v.IsValid() {
+		rec[path] = "nil"
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			rec[path] = "nil"
+			return
+		}
+		ref := recordedRef{v.Pointer(), v.Type()}
+		if at, ok := seen[ref]; ok {
+			rec[path] = "same as " + at
+			return
+		}
+		seen[ref] = path
+		recordPath(rec, seen, v.Elem(), path)
+	case reflect.Interface:
+		if v.IsNil() {
+			rec[path] = "nil"
+			return
+		}
+		recordPath(rec, seen, v.Elem(), path)
+	case reflect.Struct:
+		rec[path] = v.Type().String()
+		for i := 0; i < v.NumField(); i++ {
+			recordPath(rec, seen, v.Field(i), join(".", v.Type().Field(i).Name))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			rec[path] = "nil"
+			return
+		}
+		ref := recordedRef{v.Pointer(), v.Type()}
+		if at, ok := seen[ref]; ok {
+			rec[path] = "same as " + at
+			return
+		}
+		seen[ref] = path
+		rec[path] = fmt.Sprintf("%s of %d", v.Type(), v.Len())
+		// in order of key, so that the paths at which shared values are recorded do not vary
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := recordKey(iter.Key())
+			keys = append(keys, key)
+			values[key] = iter.Value()
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			recordPath(rec, seen, values[key], join("", "["+key+"]"))
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			rec[path] = "nil"
+			return
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			rec[path] = strconv.Quote(string(v.Bytes()))
+			return
+		}
+		rec[path] = fmt.Sprintf("%s of %d", v.Type(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			recordPath(rec, seen, v.Index(i), join("", "["+strconv.Itoa(i)+"]"))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			recordPath(rec, seen, v.Index(i), join("", "["+strconv.Itoa(i)+"]"))
+		}
+	default:
+		rec[path] = recordKey(v)
+	}
+}
+
+// recordKey returns the text of a value which holds no others, without calling its methods, which may not be called
+// on unexported fields.
+func recordKey(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(v.Complex(), 'g', -1, 128)
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return fmt.Sprintf("%s %#x", v.Type(), v.Pointer())
+	case reflect.Interface:
+		if v.IsNil() {
+			return "nil"
+		}
+		return recordKey(v.Elem())
+	}
+	return v.Type().String()
+}
@@ -0,0 +1,100 @@
+package mustache
+
+import (
+	"errors"
+	"testing"
+)
+
+type frozenCart struct {
+	Items []string
+	total int
+	calls int
+}
+
+// Total counts its calls, changing the cart it is called on.
+func (c *frozenCart) Total() int {
+	c.calls++
+	return c.total
+}
+
+func (c *frozenCart) Last() string {
+	return c.Items[len(c.Items)-1]
+}
+
+func TestFrozenContext(t *testing.T) {
+	skipLight(t)
+	tmpl, err := New().WithFrozenContext(true).CompileString("{{#Items}}{{.}},{{/Items}} {{Last}}\n{{Total}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cart := &frozenCart{Items: []string{"a", "b"}, total: 3}
+	output, err := tmpl.Render(cart)
+	var merr *MutationError
+	if !errors.As(err, &merr) || !errors.Is(err, ErrContextMutated) {
+		t.Fatalf("expected a MutationError, got %v", err)
+	}
+	expected := MutationError{Tag: "{{Total}}", Line: 2, Path: "calls", Before: "0", After: "1"}
+	if *merr != expected {
+		t.Errorf("expected %+v, got %+v", expected, *merr)
+	}
+	if output != "a,b, b\n3" {
+		t.Errorf("expected the output up to the tag, got %q", output)
+	}
+
+	// lambdas which change the data are found, at any depth
+	data := map[string]interface{}{
+		"rows": []map[string]interface{}{{"n": 1}, {"n": 2}},
+		"keep": func(text string, render RenderFn) (string, error) {
+			return render(text)
+		},
+	}
+	data["shrink"] = func(text string, render RenderFn) (string, error) {
+		data["rows"].([]map[string]interface{})[1]["n"] = 5
+		return render(text)
+	}
+	tmpl, err = New().WithFrozenContext(true).CompileString("{{#keep}}x{{/keep}}{{#shrink}}y{{/shrink}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(data); !errors.As(err, &merr) || merr.Path != `["rows"][1]["n"]` || merr.Tag != "{{#shrink}}" ||
+		merr.Before != "2" || merr.After != "5" {
+		t.Errorf("expected the lambda's change to be found, got %v", err)
+	}
+
+	// data which refers to itself, and the second of several contexts
+	type node struct {
+		Name string
+		Next *node
+	}
+	loop := &node{Name: "a"}
+	loop.Next = loop
+	rename := func(text string, render RenderFn) (string, error) {
+		loop.Name = "b"
+		return "", nil
+	}
+	tmpl, err = New().WithFrozenContext(true).CompileString("{{Name}}{{#Next}}{{Name}}{{/Next}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err = tmpl.Render(loop)
+	if err != nil || output != "aa" {
+		t.Errorf("expected %q, got %q, %v", "aa", output, err)
+	}
+	tmpl, err = New().WithFrozenContext(true).CompileString("{{Name}}{{#rename}}{{/rename}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(map[string]interface{}{"rename": rename}, loop); !errors.As(err, &merr) || merr.Context != 1 ||
+		merr.Path != "Name" {
+		t.Errorf("expected the rename to be found, got %v", err)
+	}
+
+	// without the option, changes are not checked
+	tmpl, err = New().CompileString("{{Total}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(cart); err != nil || output != "3" {
+		t.Errorf("expected %q, got %q, %v", "3", output, err)
+	}
+}
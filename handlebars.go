@@ -0,0 +1,315 @@
+package mustache
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Syntax selects the template syntax a Compiler accepts.
+type Syntax int
+
+// The syntaxes a Compiler can accept.
+const (
+	// Mustache is the standard Mustache syntax.
+	Mustache Syntax = iota
+	// Handlebars accepts the common Handlebars constructs which can be mapped onto Mustache, to ease migrating
+	// Handlebars templates: the if, unless, each and with block helpers, {{else}}, {{!-- --}} comments, this and @first,
+	// @last and @index, and partials with a context and hash parameters. Block parameters are ignored. Other
+	// constructs, such as helper calls and parent paths, are reported as compile errors.
+	Handlebars
+)
+
+// WithSyntax sets the syntax of the templates the compiler accepts. The default is Mustache.
+func (r *Compiler) WithSyntax(s Syntax) *Compiler {
+	r.syntax = s
+	return r
+}
+
+// partialParam is a hash parameter of a Handlebars partial tag, such as title="Home" or user=author.
+type partialParam struct {
+	key   string
+	name  string      // the name the value is looked up by, if it is not a literal
+	value interface{} // the literal value
+}
+
+// parseHandlebars handles the Handlebars tags which differ from Mustache tags, and reports whether it did so.
+func (tmpl *Template) parseHandlebars(tag string, indent []byte, stack *[]*sectionElement, elems **[]interface{}) (bool, error) {
+	if tag == "else" || tag == "^" {
+		return true, tmpl.parseElse(stack, elems)
+	}
+	switch tag[0] {
+	case '!', '%', '=':
+		return false, nil
+	case '~':
+		return true, tmpl.unsupported("whitespace control")
+	case '#':
+		return true, tmpl.parseBlock(tag[1:], stack, elems)
+	case '^':
+		name, err := tmpl.handlebarsName(strings.TrimSpace(tag[1:]))
+		if err != nil {
+			return true, err
+		}
+		se := &sectionElement{name: name, inverted: true, elems: []interface{}{}, closer: strings.TrimSpace(tag[1:])}
+		return true, tmpl.openSection(se, stack, elems)
+	case '/':
+		return true, tmpl.closeSection(strings.TrimSpace(tag[1:]), stack, elems)
+	case '>':
+		partial, err := tmpl.parseHandlebarsPartial(tag[1:], indent)
+		if err != nil {
+			return true, err
+		}
+		**elems = append(**elems, partial)
+		return true, nil
+	case '{':
+		if tag[len(tag)-1] != '}' {
+			return false, nil
+		}
+		tag = tag[1 : len(tag)-1]
+		if strings.HasPrefix(tag, "{") {
+			return true, tmpl.unsupported("raw blocks")
+		}
+		return true, tmpl.addVariable(strings.TrimSpace(tag), true, elems)
+	case '&':
+		return true, tmpl.addVariable(strings.TrimSpace(tag[1:]), true, elems)
+	case '*':
+		return true, tmpl.unsupported("decorators")
+	}
+	return true, tmpl.addVariable(tag, tmpl.forceRaw, elems)
+}
+
+// parseBlock handles an opening block tag: a Mustache style section, or one of the if, unless, each and with helpers.
+func (tmpl *Template) parseBlock(text string, stack *[]*sectionElement, elems **[]interface{}) error {
+	if strings.HasPrefix(text, ">") {
+		return tmpl.unsupported("partial blocks")
+	}
+	if strings.HasPrefix(text, "*") {
+		return tmpl.unsupported("inline partials")
+	}
+	words, err := tmpl.handlebarsFields(text)
+	if err != nil {
+		return err
+	}
+	// block parameters, as in {{#each items as |item|}}, are ignored
+	for i, word := range words {
+		if word == "as" && i+1 < len(words) && strings.HasPrefix(words[i+1], "|") {
+			words = words[:i]
+			break
+		}
+	}
+	if len(words) == 0 {
+		return tmpl.parseError("empty section name")
+	}
+
+	se := &sectionElement{elems: []interface{}{}, closer: words[0]}
+	arg := words[0]
+	if len(words) > 1 {
+		switch words[0] {
+		case "if":
+			se.cond = true
+		case "unless":
+			se.inverted = true
+		case "each", "with":
+		default:
+			return tmpl.unsupported("block helper " + words[0])
+		}
+		if len(words) > 2 {
+			return tmpl.unsupported(words[0] + " with more than one argument")
+		}
+		arg = words[1]
+	}
+	if se.name, err = tmpl.handlebarsName(arg); err != nil {
+		return err
+	}
+	return tmpl.openSection(se, stack, elems)
+}
+
+// parseElse handles {{else}}, which ends the innermost section and begins one which renders when it would not.
+func (tmpl *Template) parseElse(stack *[]*sectionElement, elems **[]interface{}) error {
+	if len(*stack) == 0 {
+		return tmpl.parseError("else outside a section")
+	}
+	section := (*stack)[len(*stack)-1]
+	if section.elseBranch {
+		return tmpl.parseError("more than one else in a section")
+	}
+	// the else branch of an unless section renders once, like an if section
+	se := &sectionElement{
+		name:       section.name,
+		inverted:   !section.inverted,
+		elems:      []interface{}{},
+		cond:       section.inverted,
+		closer:     section.closer,
+		elseBranch: true,
+	}
+	*stack = (*stack)[:len(*stack)-1]
+	*elems = tmpl.sectionElems(*stack)
+	return tmpl.openSection(se, stack, elems)
+}
+
+// parseHandlebarsPartial handles a partial tag, which may have a context and hash parameters.
+func (tmpl *Template) parseHandlebarsPartial(text string, indent []byte) (*partialElement, error) {
+	words, err := tmpl.handlebarsFields(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, tmpl.parseError("empty partial name")
+	}
+	if strings.HasPrefix(words[0], "(") {
+		return nil, tmpl.unsupported("dynamic partials")
+	}
+	partial, err := tmpl.parsePartial(words[0], indent)
+	if err != nil {
+		return nil, err
+	}
+	partial.line, partial.col, partial.offset = tmpl.tagLine, tmpl.tagColumn, tmpl.tagOffset
+	for _, word := range words[1:] {
+		key, value, ok := strings.Cut(word, "=")
+		if !ok {
+			if partial.context != "" || len(partial.params) > 0 {
+				return nil, tmpl.parseError("partial context must come before hash parameters: " + word)
+			}
+			if partial.context, err = tmpl.handlebarsName(word); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		param := partialParam{key: key}
+		if param.value, ok = handlebarsLiteral(value); !ok {
+			if param.name, err = tmpl.handlebarsName(value); err != nil {
+				return nil, err
+			}
+		}
+		partial.params = append(partial.params, param)
+	}
+	return partial, nil
+}
+
+// handlebarsLiteral parses a string, number or boolean literal.
+func handlebarsLiteral(s string) (interface{}, bool) {
+	switch {
+	case len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0]:
+		return s[1 : len(s)-1], true
+	case s == "true" || s == "false":
+		return s == "true", true
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, true
+	}
+	return nil, false
+}
+
+// handlebarsFields splits the text of a tag into words, keeping quoted strings together.
+func (tmpl *Template) handlebarsFields(text string) ([]string, error) {
+	var words []string
+	for {
+		text = strings.TrimLeft(text, " \t\r\n")
+		if text == "" {
+			return words, nil
+		}
+		end := 0
+		for end < len(text) && !strings.ContainsRune(" \t\r\n", rune(text[end])) {
+			if q := text[end]; q == '"' || q == '\'' {
+				close := strings.IndexByte(text[end+1:], q)
+				if close < 0 {
+					return nil, tmpl.parseError("unterminated string in tag")
+				}
+				end += close + 1
+			}
+			end++
+		}
+		words = append(words, text[:end])
+		text = text[end:]
+	}
+}
+
+// handlebarsName maps a Handlebars path onto a Mustache name.
+func (tmpl *Template) handlebarsName(path string) (string, error) {
+	switch {
+	case path == "":
+		return "", tmpl.parseError("empty variable name")
+	case strings.ContainsAny(path, " \t\r\n"):
+		return "", tmpl.unsupported("helper calls")
+	case strings.HasPrefix(path, "("):
+		return "", tmpl.unsupported("subexpressions")
+	case strings.HasPrefix(path, "../"):
+		return "", tmpl.unsupported("parent paths")
+	case strings.HasPrefix(path, "~") || strings.HasSuffix(path, "~"):
+		return "", tmpl.unsupported("whitespace control")
+	}
+	switch path {
+	case "this", ".", "./":
+		return ".", nil
+	case "@first":
+		return iterFirst, nil
+	case "@last":
+		return iterLast, nil
+	case "@index":
+		return iterIndex0, nil
+	}
+	if strings.HasPrefix(path, "@") {
+		return "", tmpl.unsupported("data variable " + path)
+	}
+	path = strings.TrimPrefix(path, "this.")
+	path = strings.TrimPrefix(path, "this/")
+	path = strings.TrimPrefix(path, "./")
+	return strings.ReplaceAll(path, "/", "."), nil
+}
+
+func (tmpl *Template) unsupported(construct string) error {
+	return tmpl.parseError("unsupported Handlebars construct: " + construct)
+}
+
+// partialContexts returns the context chain a Handlebars partial with a context or hash parameters is rendered with.
+func (tmpl *Template) partialContexts(st *renderState, elem *partialElement, contextChain []reflect.Value) ([]reflect.Value, error) {
+	var pushed []reflect.Value
+	var paths []string
+	if elem.context != "" {
+		v, frame, err := tmpl.lookup(st, contextChain, elem.context)
+		if err != nil {
+			return nil, err
+		}
+		pushed = append(pushed, v)
+		if st.usage != nil {
+			paths = append(paths, st.usage.use(contextChain, frame, elem.context, false))
+		}
+	}
+	if len(elem.params) > 0 {
+		params := make(map[string]interface{}, len(elem.params))
+		for _, param := range elem.params {
+			if param.name == "" {
+				params[param.key] = param.value
+				continue
+			}
+			v, frame, err := tmpl.lookup(st, contextChain, param.name)
+			if err != nil {
+				return nil, err
+			}
+			if v.IsValid() {
+				params[param.key] = v.Interface()
+			}
+			if st.usage != nil {
+				st.usage.use(contextChain, frame, param.name, true)
+			}
+		}
+		pushed = append(pushed, reflect.ValueOf(params))
+		// the parameters are not part of the data passed to the render
+		paths = append(paths, fmt.Sprintf("\x00%p", elem))
+	}
+
+	chain := make([]reflect.Value, 0, len(pushed)+len(contextChain))
+	for i := len(pushed) - 1; i >= 0; i-- {
+		chain = append(chain, pushed[i])
+	}
+	chain = append(chain, contextChain...)
+	st.iterations = append(st.iterations[:len(contextChain)], make([]iteration, len(pushed))...)
+	if st.usage != nil {
+		st.usage.paths = append(st.usage.paths[:len(contextChain)], paths...)
+	}
+	return chain, nil
+}
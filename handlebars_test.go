@@ -0,0 +1,95 @@
+package mustache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandlebars(t *testing.T) {
+	data := map[string]interface{}{
+		"title":  "Hi",
+		"people": []map[string]string{{"name": "Ann"}, {"name": "Bob"}},
+		"none":   []string{},
+		"author": map[string]string{"name": "Cy"},
+		"admin":  true,
+		"list":   []int{1, 2},
+	}
+	partials := &StaticProvider{Partials: map[string]string{
+		"card":  "[{{name}}:{{label}}:{{size}}:{{title}}]",
+		"title": "<{{title}}>",
+	}}
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{"{{#if admin}}yes{{else}}no{{/if}}", "yes"},
+		{"{{#if missing}}yes{{else}}no{{/if}}", "no"},
+		{"{{#if list}}{{title}}{{/if}}", "Hi"},
+		{"{{#unless admin}}no{{else}}yes{{/unless}}", "yes"},
+		{"{{#unless list}}no{{else}}{{#each list}}{{this}}{{/each}}{{/unless}}", "12"},
+		{"{{#each people}}{{@index}}={{name}}{{#unless @last}},{{/unless}}{{/each}}", "0=Ann,1=Bob"},
+		{"{{#each people as |person|}}{{#if @first}}{{this.name}}{{/if}}{{/each}}", "Ann"},
+		{"{{#each none}}x{{else}}empty{{/each}}", "empty"},
+		{"{{#each none}}x{{^}}empty{{/each}}", "empty"},
+		{"{{#with author}}{{name}}{{/with}}", "Cy"},
+		{"{{#author}}{{./name}}{{/author}}", "Cy"},
+		{"{{author/name}}", "Cy"},
+		{"{{!-- a comment with }} inside --}}{{title}}", "Hi"},
+		{"{{! short comment }}{{title}}", "Hi"},
+		{"{{> title}}", "<Hi>"},
+		{"{{> card author label='x' size=3}}", "[Cy:x:3:Hi]"},
+		{"{{> card name=title label=\"a b\"}}", "[Hi:a b::Hi]"},
+		{"{{#each people}}{{> card label=@index}}{{/each}}", "[Ann:0::Hi][Bob:1::Hi]"},
+		{"{{{title}}}{{&title}}", "HiHi"},
+		{"<ul>\n  {{#each people}}\n  <li>{{name}}</li>\n  {{else}}\n  <li>none</li>\n  {{/each}}\n</ul>\n", "<ul>\n  <li>Ann</li>\n  <li>Bob</li>\n</ul>\n"},
+	}
+	for _, test := range tests {
+		tmpl, err := New().WithSyntax(Handlebars).WithPartials(partials).CompileString(test.tmpl)
+		if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+			continue
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.tmpl, test.expected, output)
+		}
+	}
+}
+
+func TestHandlebarsErrors(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		err  string
+	}{
+		{"{{formatDate date 'short'}}", "unsupported Handlebars construct: helper calls"},
+		{"{{#each items}}{{../title}}{{/each}}", "unsupported Handlebars construct: parent paths"},
+		{"{{#custom a}}{{/custom}}", "unsupported Handlebars construct: block helper custom"},
+		{"{{#if a}}x{{else if b}}y{{/if}}", "unsupported Handlebars construct: helper calls"},
+		{"{{~title~}}", "unsupported Handlebars construct: whitespace control"},
+		{"{{@key}}", "unsupported Handlebars construct: data variable @key"},
+		{"{{> (lookup . 'p')}}", "unsupported Handlebars construct: dynamic partials"},
+		{"{{#> layout}}{{/layout}}", "unsupported Handlebars construct: partial blocks"},
+		{"{{#if a}}{{/a}}", "line 1: interleaved closing tag: a (expected if, opened at line 1 column 1"},
+		{"{{#if a}}x{{else}}y{{else}}z{{/if}}", "more than one else in a section"},
+		{"{{else}}", "else outside a section"},
+		{"{{> p a=1 ctx}}", "partial context must come before hash parameters"},
+		{"{{> p a='x}}", "unterminated string in tag"},
+	}
+	for _, test := range tests {
+		_, err := New().WithSyntax(Handlebars).CompileString(test.tmpl)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected error containing %q, got %v", test.tmpl, test.err, err)
+		}
+	}
+
+	// in the default syntax, Handlebars constructs are treated as plain names
+	tmpl, err := New().CompileString("{{#if a}}x{{/if a}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := tmpl.Render(map[string]interface{}{"if a": true}); output != "x" {
+		t.Errorf("expected a Mustache section, got %q", output)
+	}
+}
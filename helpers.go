@@ -0,0 +1,314 @@
+package mustache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Helper is a function which templates can call from a variable tag, such as {{plural count "item" "items"}}. Its
+// arguments are string, number and boolean literals, and names, which are looked up like variables, with nil for
+// missing values. The text it returns is escaped like the value of a variable.
+type Helper func(args ...interface{}) (string, error)
+
+// builtinHelpers are the helpers available to every template, unless replaced by one registered with WithHelper.
+var builtinHelpers = map[string]Helper{
+	"plural":        Plural,
+	"currency":      Currency,
+	"truncate":      Truncate,
+	"truncateWords": TruncateWords,
+}
+
+// WithHelper registers a helper which templates can call by name. A tag calls a helper if its first word is the name
+// of a helper and it has arguments, so a tag holding only the name is still a variable. The built-in helpers, plural,
+// currency, truncate and truncateWords, can be replaced by registering a helper with the same name.
+func (r *Compiler) WithHelper(name string, h Helper) *Compiler {
+	if r.helpers == nil {
+		r.helpers = make(map[string]Helper)
+	}
+	r.helpers[name] = h
+	return r
+}
+
+func (r *Compiler) helper(name string) (Helper, bool) {
+	if h, ok := r.helpers[name]; ok {
+		return h, true
+	}
+	h, ok := builtinHelpers[name]
+	return h, ok
+}
+
+// helperArg is an argument of a helper call: a name to look up, or if name is empty, a literal value.
+type helperArg struct {
+	name  string
+	value interface{}
+}
+
+type helperElement struct {
+	name   string
+	helper Helper
+	args   []helperArg
+	raw    bool
+	line   int // the line of the tag, or 0 if it was not compiled from source
+	col    int // the column of the tag, counting bytes from 1
+	offset int // the offset of the tag in the source
+}
+
+// String returns the text of the helper call, as it would appear in a tag.
+func (elem *helperElement) String() string {
+	words := []string{elem.name}
+	for _, arg := range elem.args {
+		if s, ok := arg.value.(string); ok {
+			words = append(words, strconv.Quote(s))
+		} else if arg.name != "" {
+			words = append(words, arg.name)
+		} else {
+			words = append(words, fmt.Sprint(arg.value))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// addVariable appends the element for a variable tag to elems, which is an expression if they are enabled and the tag
+// holds one, or a helper call if the tag begins with the name of a helper and has arguments.
+func (tmpl *Template) addVariable(tag string, raw bool, elems **[]interface{}) error {
+	if tmpl.parent.expressions && isExpression(tag) {
+		elem, err := tmpl.parseExpression(tag, raw)
+		if err != nil {
+			return err
+		}
+		**elems = append(**elems, elem)
+		return nil
+	}
+	if i := strings.IndexAny(tag, " \t\r\n"); i > 0 {
+		if h, ok := tmpl.parent.helper(tag[:i]); ok {
+			elem, err := tmpl.parseHelper(tag, h, raw)
+			if err != nil {
+				return err
+			}
+			**elems = append(**elems, elem)
+			return nil
+		}
+	}
+	name := tag
+	if tmpl.parent.syntax == Handlebars {
+		var err error
+		if name, err = tmpl.handlebarsName(tag); err != nil {
+			return err
+		}
+	}
+	**elems = append(**elems, &varElement{name: name, raw: raw, line: tmpl.tagLine, col: tmpl.tagColumn, offset: tmpl.tagOffset})
+	return nil
+}
+
+// parseHelper parses a helper call from the text of its tag.
+func (tmpl *Template) parseHelper(tag string, h Helper, raw bool) (*helperElement, error) {
+	words, err := tmpl.handlebarsFields(tag)
+	if err != nil {
+		return nil, err
+	}
+	elem := &helperElement{name: words[0], helper: h, raw: raw, line: tmpl.tagLine, col: tmpl.tagColumn, offset: tmpl.tagOffset}
+	for _, word := range words[1:] {
+		var arg helperArg
+		var ok bool
+		if arg.value, ok = handlebarsLiteral(word); !ok {
+			arg.name = word
+			if tmpl.parent.syntax == Handlebars {
+				if arg.name, err = tmpl.handlebarsName(word); err != nil {
+					return nil, err
+				}
+			}
+		}
+		elem.args = append(elem.args, arg)
+	}
+	return elem, nil
+}
+
+// renderHelper calls a helper with the values of its arguments and writes the result.
+func (tmpl *Template) renderHelper(st *renderState, elem *helperElement, contextChain []reflect.Value, buf io.Writer) error {
+	args := make([]interface{}, len(elem.args))
+	for i, arg := range elem.args {
+		if arg.name == "" {
+			args[i] = arg.value
+			continue
+		}
+		v, frame, err := tmpl.lookup(st, contextChain, arg.name)
+		if err != nil {
+			return err
+		}
+		if st.usage != nil {
+			st.usage.use(contextChain, frame, arg.name, true)
+		}
+		if indirect(v).IsValid() {
+			args[i] = indirectValue(v.Interface())
+		}
+	}
+	s, err := elem.helper(args...)
+	if err != nil {
+		return fmt.Errorf("helper %s: %w", elem.name, err)
+	}
+	if tmpl.outputMode == EscapeJSONValue && !elem.raw {
+		if s, err = toJSONString(s); err != nil {
+			return err
+		}
+	}
+	if elem.raw {
+		s = tmpl.sanitizeRaw(nil, s)
+	}
+	return tmpl.writeEscaped(st, buf, s, elem.raw, false)
+}
+
+// toFloat converts a number, or a string holding one, to a float64.
+func toFloat(v interface{}) (float64, error) {
+	if s, ok := v.(string); ok {
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	}
+	rv := indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return 0, fmt.Errorf("expected a number, got %T", v)
+}
+
+// Plural is the built-in plural helper: {{plural count "item" "items"}} returns the singular form if count is 1 or
+// -1, and the plural form otherwise.
+func Plural(args ...interface{}) (string, error) {
+	if len(args) != 3 {
+		return "", errors.New("expected a count and the singular and plural forms")
+	}
+	n, err := toFloat(args[0])
+	if err != nil {
+		return "", err
+	}
+	if math.Abs(n) == 1 {
+		return fmt.Sprint(args[1]), nil
+	}
+	return fmt.Sprint(args[2]), nil
+}
+
+// currencies holds the symbol and number of decimal places of common currencies, by ISO 4217 code.
+var currencies = map[string]struct {
+	symbol   string
+	decimals int
+}{
+	"AUD": {"A$", 2},
+	"BRL": {"R$", 2},
+	"CAD": {"CA$", 2},
+	"CHF": {"CHF ", 2},
+	"CNY": {"CN¥", 2},
+	"EUR": {"€", 2},
+	"GBP": {"£", 2},
+	"INR": {"₹", 2},
+	"JPY": {"¥", 0},
+	"KRW": {"₩", 0},
+	"MXN": {"MX$", 2},
+	"USD": {"$", 2},
+}
+
+// Currency is the built-in currency helper: {{currency amount "EUR"}} formats amount with the symbol of the currency,
+// a comma between thousands and the currency's usual number of decimal places, as in €1,234.50. Currencies without a
+// known symbol are formatted with their code after the amount, with two decimal places.
+func Currency(args ...interface{}) (string, error) {
+	if len(args) != 2 {
+		return "", errors.New("expected an amount and a currency code")
+	}
+	amount, err := toFloat(args[0])
+	if err != nil {
+		return "", err
+	}
+	code := strings.ToUpper(fmt.Sprint(args[1]))
+	c, known := currencies[code]
+	if !known {
+		c.decimals = 2
+	}
+
+	digits := strconv.FormatFloat(math.Abs(amount), 'f', c.decimals, 64)
+	whole, frac, _ := strings.Cut(digits, ".")
+	var sb strings.Builder
+	if amount < 0 && strings.Trim(digits, "0.") != "" {
+		sb.WriteByte('-')
+	}
+	sb.WriteString(c.symbol)
+	for i, d := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(d)
+	}
+	if frac != "" {
+		sb.WriteByte('.')
+		sb.WriteString(frac)
+	}
+	if !known {
+		sb.WriteString(" " + code)
+	}
+	return sb.String(), nil
+}
+
+// Truncate is the built-in truncate helper: {{truncate summary 140}} cuts summary to at most 140 characters, counting
+// runes rather than bytes so that no character is split. An optional suffix, as in {{truncate summary 140 "…"}}, is
+// added to text which is cut, within the length. The text is cut before it is escaped, so escaping never splits an
+// entity and the length counts the characters the reader sees.
+func Truncate(args ...interface{}) (string, error) {
+	return truncate(args, false)
+}
+
+// TruncateWords is the built-in truncateWords helper, which is like truncate but cuts text at the end of a word, unless
+// the first word alone is longer than the length.
+func TruncateWords(args ...interface{}) (string, error) {
+	return truncate(args, true)
+}
+
+func truncate(args []interface{}, words bool) (string, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return "", errors.New("expected a text, a length and an optional suffix")
+	}
+	var text, suffix string
+	if args[0] != nil {
+		text = fmt.Sprint(args[0])
+	}
+	if len(args) == 3 {
+		suffix = fmt.Sprint(args[2])
+	}
+	n, err := toFloat(args[1])
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || n != math.Trunc(n) {
+		return "", fmt.Errorf("expected a whole length, got %v", args[1])
+	}
+	if float64(utf8.RuneCountInString(text)) <= n {
+		return text, nil
+	}
+	keep := int(n) - utf8.RuneCountInString(suffix)
+	if keep < 0 {
+		return "", nil
+	}
+	cut := 0
+	for i := range text {
+		if keep == 0 {
+			cut = i
+			break
+		}
+		keep--
+	}
+	next, _ := utf8.DecodeRuneInString(text[cut:])
+	text = text[:cut]
+	if words && !unicode.IsSpace(next) {
+		if i := strings.LastIndexFunc(text, unicode.IsSpace); i > 0 {
+			text = text[:i]
+		}
+	}
+	return strings.TrimRightFunc(text, unicode.IsSpace) + suffix, nil
+}
@@ -0,0 +1,114 @@
+package mustache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHelpers(t *testing.T) {
+	data := map[string]interface{}{
+		"one":   1,
+		"many":  3,
+		"price": 1234.5,
+		"debt":  -0.004,
+		"yen":   "98765",
+		"tag":   "<b>",
+		"text":  "Ünïcödé & more words",
+	}
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{`{{one}} {{plural one "item" "items"}}, {{many}} {{plural many "item" "items"}}`, "1 item, 3 items"},
+		{`{{plural 0 'child' 'children'}}`, "children"},
+		{`{{currency price "EUR"}} {{currency price "usd"}} {{currency yen "JPY"}} {{currency 1234567.891 "XYZ"}}`, "€1,234.50 $1,234.50 ¥98,765 1,234,567.89 XYZ"},
+		{`{{currency debt "GBP"}} {{currency -12 "GBP"}}`, "£0.00 -£12.00"},
+		{`{{shout tag}} {{{shout tag}}} {{&shout "a"}}`, "&lt;B&gt;! <B>! A!"},
+		{`{{plural}}{{shout}}`, "yell"},
+		{`{{truncate text 9}}|{{truncate text 100}}|{{truncate missing 3}}`, "Ünïcödé &amp;|Ünïcödé &amp; more words|"},
+		{`{{truncate text 12 "…"}}|{{truncateWords text 12 "…"}}|{{truncateWords text 17}}`, "Ünïcödé &amp; m…|Ünïcödé &amp;…|Ünïcödé &amp; more"},
+		{`{{truncateWords "unbroken" 4}}|{{truncate text 1 "..."}}|{{truncate text 3 "..."}}`, "unbr||..."},
+	}
+	cmpl := New().WithHelper("shout", func(args ...interface{}) (string, error) {
+		return strings.ToUpper(args[0].(string)) + "!", nil
+	})
+	for _, test := range tests {
+		tmpl, err := cmpl.CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(data, map[string]string{"shout": "yell"})
+		if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.tmpl, test.expected, output)
+		}
+	}
+}
+
+func TestHelperErrors(t *testing.T) {
+	tmpl, err := New().CompileString(`{{plural tag "a" "b"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(map[string]string{"tag": "x"}); err == nil || !strings.HasPrefix(err.Error(), "helper plural: ") {
+		t.Errorf("expected a helper error, got %v", err)
+	}
+
+	failure := errors.New("failed")
+	tmpl, err = New().WithHelper("plural", func(args ...interface{}) (string, error) { return "", failure }).CompileString(`{{plural 1 "a" "b"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(nil); !errors.Is(err, failure) {
+		t.Errorf("expected the replaced helper's error, got %v", err)
+	}
+
+	tmpl, err = New().CompileString(`{{truncate tag 1.5}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(map[string]string{"tag": "x"}); err == nil || !strings.Contains(err.Error(), "expected a whole length") {
+		t.Errorf("expected a length error, got %v", err)
+	}
+
+	if _, err := New().CompileString(`{{plural n "unterminated}}`); err == nil || !strings.Contains(err.Error(), "unterminated string") {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
+
+func TestHelpersInOtherModes(t *testing.T) {
+	data := map[string]interface{}{"author": map[string]interface{}{"count": 2}}
+
+	tmpl, err := New().WithSyntax(Handlebars).CompileString(`{{#with author}}{{plural this.count "post" "posts"}}{{/with}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(data); err != nil || output != "posts" {
+		t.Errorf("expected a helper call in Handlebars syntax, got %q, %v", output, err)
+	}
+
+	tmpl, err = New().WithEscapeMode(EscapeJSONValue).CompileString(`{"n": {{plural author.count "post" "posts"}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(data); err != nil || output != `{"n": "posts"}` {
+		t.Errorf("expected a JSON string, got %q, %v", output, err)
+	}
+
+	ast, err := tmpl.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(ast), `{"type":"helper","name":"plural","args":[{"name":"author.count","value":null},{"value":"post"},{"value":"posts"}]}`) {
+		t.Errorf("unexpected AST %s", ast)
+	}
+	loaded, err := New().WithEscapeMode(EscapeJSONValue).CompileJSON(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := loaded.Render(data); err != nil || output != `{"n": "posts"}` {
+		t.Errorf("expected the loaded template to call the helper, got %q, %v", output, err)
+	}
+}
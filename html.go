@@ -0,0 +1,297 @@
+package mustache
+
+import (
+	"bytes"
+	"html"
+	"io"
+)
+
+// htmlToken is the kind of a piece of HTML passed to the callback of splitHTML.
+type htmlToken int
+
+const (
+	htmlText    htmlToken = iota // text between tags
+	htmlTag                      // a start or end tag, doctype or processing instruction, from < to >
+	htmlComment                  // a comment, from <!-- to -->
+	htmlRaw                      // the contents of a script, style, pre or textarea element
+)
+
+// rawElements are the elements whose contents are passed through unchanged by the HTML post processors.
+var rawElements = []string{"script", "style", "pre", "textarea"}
+
+// splitHTML splits src into text, tags, comments and the contents of raw elements, and calls fn with each in order.
+// It is not a full HTML parser, but handles quoted attribute values containing '>' and text containing a bare '<'.
+func splitHTML(src []byte, fn func(kind htmlToken, b []byte)) {
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			fn(htmlText, src[start:end])
+		}
+	}
+	for i := 0; i < len(src); {
+		if src[i] != '<' || i+1 == len(src) {
+			i++
+			continue
+		}
+		if bytes.HasPrefix(src[i:], []byte("<!--")) {
+			end := bytes.Index(src[i+4:], []byte("-->"))
+			if end < 0 {
+				break
+			}
+			flush(i)
+			fn(htmlComment, src[i:i+4+end+3])
+			i += 4 + end + 3
+			start = i
+			continue
+		}
+		if c := src[i+1]; !(isASCIILetter(c) || c == '/' || c == '!' || c == '?') {
+			i++
+			continue
+		}
+		end := tagEnd(src, i)
+		if end < 0 {
+			break
+		}
+		flush(i)
+		tag := src[i:end]
+		fn(htmlTag, tag)
+		i, start = end, end
+		if name := htmlTagName(tag); name != "" && tag[1] != '/' {
+			for _, raw := range rawElements {
+				if name != raw {
+					continue
+				}
+				closing := indexFold(src[i:], "</"+raw)
+				if closing < 0 {
+					closing = len(src) - i
+				}
+				if closing > 0 {
+					fn(htmlRaw, src[i:i+closing])
+				}
+				i += closing
+				start = i
+				break
+			}
+		}
+	}
+	if start < len(src) {
+		fn(htmlText, src[start:])
+	}
+}
+
+// tagEnd returns the offset just past the '>' ending the tag which starts at i, skipping quoted attribute values, or
+// -1 if the tag is not ended.
+func tagEnd(src []byte, i int) int {
+	var quote byte
+	for j := i + 1; j < len(src); j++ {
+		switch c := src[j]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// htmlTagName returns the lower case name of a start or end tag, or an empty string for other tags.
+func htmlTagName(tag []byte) string {
+	i := 1
+	if tag[i] == '/' {
+		i++
+	}
+	j := i
+	for j < len(tag) && (isASCIILetter(tag[j]) || tag[j] >= '0' && tag[j] <= '9' || tag[j] == '-') {
+		j++
+	}
+	return string(bytes.ToLower(tag[i:j]))
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// indexFold returns the index of the first instance of the ASCII string s in b, ignoring case, or -1.
+func indexFold(b []byte, s string) int {
+	for i := 0; i+len(s) <= len(b); i++ {
+		if bytes.EqualFold(b[i:i+len(s)], []byte(s)) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// MinifyHTML is a post processor which removes comments from HTML output, other than conditional comments, and
+// collapses each run of whitespace to a single space, outside quoted attribute values and the contents of script,
+// style, pre and textarea elements. Whitespace is never removed entirely, as it may separate inline elements.
+func MinifyHTML(src []byte) ([]byte, error) {
+	out := make([]byte, 0, len(src))
+	splitHTML(src, func(kind htmlToken, b []byte) {
+		switch kind {
+		case htmlComment:
+			if bytes.HasPrefix(b, []byte("<!--[if")) || bytes.HasPrefix(b, []byte("<!--<![endif]")) {
+				out = append(out, b...)
+			}
+		case htmlRaw:
+			out = append(out, b...)
+		default:
+			var quote byte
+			for i := 0; i < len(b); i++ {
+				c := b[i]
+				switch {
+				case quote != 0:
+					if c == quote {
+						quote = 0
+					}
+				case kind == htmlTag && (c == '"' || c == '\''):
+					quote = c
+				case isHTMLSpace(c):
+					for i+1 < len(b) && isHTMLSpace(b[i+1]) {
+						i++
+					}
+					if len(out) == 0 || out[len(out)-1] != ' ' {
+						out = append(out, ' ')
+					}
+					continue
+				}
+				out = append(out, c)
+			}
+		}
+	})
+	return out, nil
+}
+
+// linkAttributes are the attributes whose values RewriteLinks rewrites.
+var linkAttributes = []string{"href", "src", "action", "poster"}
+
+// RewriteLinks returns a post processor which replaces the URLs in the href, src, action and poster attributes of
+// HTML output with the result of rewrite, for instance to point assets at fingerprinted file names or a CDN. rewrite
+// receives and returns URLs without HTML escaping.
+func RewriteLinks(rewrite func(url string) string) func([]byte) ([]byte, error) {
+	return func(src []byte) ([]byte, error) {
+		out := make([]byte, 0, len(src))
+		splitHTML(src, func(kind htmlToken, b []byte) {
+			if kind == htmlTag && b[1] != '/' && b[1] != '!' && b[1] != '?' {
+				out = rewriteTag(out, b, rewrite)
+			} else {
+				out = append(out, b...)
+			}
+		})
+		return out, nil
+	}
+}
+
+// rewriteTag appends tag to out, with the values of its link attributes rewritten.
+func rewriteTag(out, tag []byte, rewrite func(string) string) []byte {
+	i := 1
+	for i < len(tag) && !isHTMLSpace(tag[i]) && tag[i] != '>' && tag[i] != '/' {
+		i++
+	}
+	out = append(out, tag[:i]...)
+	for i < len(tag) {
+		// copy up to the attribute name
+		start := i
+		for i < len(tag) && (isHTMLSpace(tag[i]) || tag[i] == '/') {
+			i++
+		}
+		nameStart := i
+		for i < len(tag) && !isHTMLSpace(tag[i]) && tag[i] != '=' && tag[i] != '>' && tag[i] != '/' {
+			i++
+		}
+		name := string(bytes.ToLower(tag[nameStart:i]))
+		for i < len(tag) && isHTMLSpace(tag[i]) {
+			i++
+		}
+		if i == nameStart || i >= len(tag) || tag[i] != '=' {
+			if i == start {
+				// the closing '>'
+				out = append(out, tag[i:]...)
+				break
+			}
+			out = append(out, tag[start:i]...)
+			continue
+		}
+		i++
+		for i < len(tag) && isHTMLSpace(tag[i]) {
+			i++
+		}
+		out = append(out, tag[start:i]...)
+
+		// the value, which may be quoted
+		var quote byte
+		if i < len(tag) && (tag[i] == '"' || tag[i] == '\'') {
+			quote = tag[i]
+			i++
+		}
+		valueStart := i
+		for i < len(tag) && (quote != 0 && tag[i] != quote || quote == 0 && !isHTMLSpace(tag[i]) && tag[i] != '>') {
+			i++
+		}
+		value := tag[valueStart:i]
+		if isLinkAttribute(name) {
+			value = []byte(html.EscapeString(rewrite(html.UnescapeString(string(value)))))
+		}
+		if quote != 0 {
+			out = append(out, quote)
+			out = append(out, value...)
+			if i < len(tag) {
+				out = append(out, quote)
+				i++
+			}
+		} else {
+			out = append(out, value...)
+		}
+	}
+	return out
+}
+
+func isLinkAttribute(name string) bool {
+	for _, attr := range linkAttributes {
+		if name == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlEscape writes s to w, HTML escaped by a fixed table: & < > " ' become &amp; &lt; &gt; &#34; &#39;, and NUL
+// becomes U+FFFD. The table is that of html/template, which is not imported as it brings in the regexp package.
+func htmlEscape(w io.Writer, s string) error {
+	last := 0
+	for i := 0; i < len(s); i++ {
+		var esc string
+		switch s[i] {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '"':
+			esc = "&#34;"
+		case '\'':
+			esc = "&#39;"
+		case 0:
+			esc = "\uFFFD"
+		default:
+			continue
+		}
+		if _, err := io.WriteString(w, s[last:i]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, esc); err != nil {
+			return err
+		}
+		last = i + 1
+	}
+	_, err := io.WriteString(w, s[last:])
+	return err
+}
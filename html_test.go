@@ -0,0 +1,164 @@
+package mustache
+
+import (
+	"errors"
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestMinifyHTML(t *testing.T) {
+	tests := []struct {
+		html     string
+		expected string
+	}{
+		{"<ul>\n  <li>a</li>\n\n  <li>b</li>\n</ul>\n", "<ul> <li>a</li> <li>b</li> </ul> "},
+		{"<p title=\"a  b\"   class='x'>one <!-- note -->  two</p>", "<p title=\"a  b\" class='x'>one two</p>"},
+		{"<pre>\n  keep  this\n</pre>\n<SCRIPT>\nif (a  <  b) {}\n</SCRIPT>", "<pre>\n  keep  this\n</pre> <SCRIPT>\nif (a  <  b) {}\n</SCRIPT>"},
+		{"<!--[if IE]>old<![endif]-->  a < b", "<!--[if IE]>old<![endif]--> a < b"},
+		{"<a href=\"x>y\">  <!-- unterminated", "<a href=\"x>y\"> <!-- unterminated"},
+	}
+	for _, test := range tests {
+		output, err := MinifyHTML([]byte(test.html))
+		if err != nil {
+			t.Error(err)
+		} else if string(output) != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.html, test.expected, output)
+		}
+	}
+}
+
+func TestRewriteLinks(t *testing.T) {
+	rewrite := RewriteLinks(func(url string) string {
+		if strings.HasPrefix(url, "/static/") {
+			return "https://cdn.example.com" + url + "?v=1&x=2"
+		}
+		return url
+	})
+	tests := []struct {
+		html     string
+		expected string
+	}{
+		{`<link rel="stylesheet" href="/static/app.css">`, `<link rel="stylesheet" href="https://cdn.example.com/static/app.css?v=1&amp;x=2">`},
+		{`<img src=/static/a.png alt='/static/a.png'/>`, `<img src=https://cdn.example.com/static/a.png?v=1&amp;x=2 alt='/static/a.png'/>`},
+		{`<a HREF = '/about' download>About</a> src="/static/x"`, `<a HREF = '/about' download>About</a> src="/static/x"`},
+		{`<script src="/static/a.js">var s = "<img src='/static/b.png'>";</script>`, `<script src="https://cdn.example.com/static/a.js?v=1&amp;x=2">var s = "<img src='/static/b.png'>";</script>`},
+	}
+	for _, test := range tests {
+		output, err := rewrite([]byte(test.html))
+		if err != nil {
+			t.Error(err)
+		} else if string(output) != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.html, test.expected, output)
+		}
+	}
+}
+
+func TestIndentHTML(t *testing.T) {
+	tests := []struct {
+		html     string
+		expected string
+	}{
+		{"<ul>\n<li>a</li>\n        <li>b</li>  \n    </ul>\n", "<ul>\n  <li>a</li>\n  <li>b</li>\n</ul>\n"},
+		{"<!DOCTYPE html>\n<html>\n<body>\n<p>Hello <b>world</b>,\nand <i>all</i></p>\n</body>\n</html>",
+			"<!DOCTYPE html>\n<html>\n  <body>\n    <p>Hello <b>world</b>,\n      and <i>all</i></p>\n  </body>\n</html>"},
+		{"<div>\n<pre>\n  keep\n    this\n</pre>\n<br>\n<img src=x />\n</div>", "<div>\n  <pre>\n  keep\n    this\n</pre>\n  <br>\n  <img src=x />\n</div>"},
+		{"<ul>\n<li>a\n<li>b\n</ul>\n<table><tr><td>1\n<tr><td>2\n</table>", "<ul>\n  <li>a\n  <li>b\n</ul>\n<table><tr><td>1\n    <tr><td>2\n</table>"},
+		{"<div>\r\n  \r\n<!-- a\n  note -->\r\n<span\n    class=x>y</span>\r\n</div>", "<div>\r\n\r\n  <!-- a\n  note -->\r\n  <span\n    class=x>y</span>\r\n</div>"},
+		{"<section>\n<script>\n  if (a < b) {}\n</script>\n</section>", "<section>\n\t<script>\n  if (a < b) {}\n</script>\n</section>"},
+	}
+	for i, test := range tests {
+		indent := "  "
+		if i == len(tests)-1 {
+			indent = "\t"
+		}
+		output, err := IndentHTML(indent)([]byte(test.html))
+		if err != nil {
+			t.Error(err)
+		} else if string(output) != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.html, test.expected, output)
+		}
+	}
+
+	// partials come out indented by the structure of the page, not by the tags including them
+	partials := &StaticProvider{Partials: map[string]string{"nav": "<nav>\n<a href=/>Home</a>\n</nav>\n"}}
+	tmpl, err := New().WithPartials(partials).WithPostProcessor(IndentHTML("  ")).CompileString("<body>\n<header>\n        {{>nav}}\n</header>\n</body>\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "<body>\n  <header>\n    <nav>\n      <a href=/>Home</a>\n    </nav>\n  </header>\n</body>\n"
+	if output, err := tmpl.Render(nil); err != nil || output != expected {
+		t.Errorf("expected %q, got %q, %v", expected, output, err)
+	}
+}
+
+func TestPostProcessor(t *testing.T) {
+	tmpl, err := New().
+		WithPostProcessor(MinifyHTML).
+		WithPostProcessor(func(b []byte) ([]byte, error) { return append(b, '!'), nil }).
+		CompileString("<p>\n  {{name}}\n</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(map[string]string{"name": "Jo"}); err != nil || output != "<p> Jo </p>!" {
+		t.Errorf("expected processed output, got %q, %v", output, err)
+	}
+
+	failure := errors.New("failed")
+	tmpl, err = New().WithPostProcessor(func(b []byte) ([]byte, error) { return nil, failure }).CompileString("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Frender(&sb, nil); !errors.Is(err, failure) || sb.Len() > 0 {
+		t.Errorf("expected the processor's error and no output, got %v, %q", err, sb.String())
+	}
+}
+
+func TestRawSanitizer(t *testing.T) {
+	stripScripts := SanitizerFunc(func(s string) string {
+		return strings.ReplaceAll(s, "<script>", "")
+	})
+	cmpl := New().WithRawSanitizer(stripScripts).WithHelper("echo", func(args ...interface{}) (string, error) {
+		return args[0].(string), nil
+	})
+	data := map[string]interface{}{
+		"comment": "<b>hi</b><script>",
+		"banner":  TrustedHTML("<script>"),
+	}
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{"{{{comment}}}", "<b>hi</b>"},
+		{"{{&comment}}", "<b>hi</b>"},
+		{"{{comment}}", "&lt;b&gt;hi&lt;/b&gt;&lt;script&gt;"},
+		{"{{{banner}}}", "<script>"},
+		{"{{{echo comment}}}", "<b>hi</b>"},
+		{"{{%ESCAPE RAW}}{{{comment}}}", "<b>hi</b><script>"},
+	}
+	for _, test := range tests {
+		tmpl, err := cmpl.CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.tmpl, test.expected, output)
+		}
+	}
+}
+
+func TestHTMLEscape(t *testing.T) {
+	for _, s := range []string{"", "plain", `<>&"'`, "\x00a\x00", "ünïcødé <b>", "\xff\xfe<"} {
+		var b strings.Builder
+		if err := htmlEscape(&b, s); err != nil {
+			t.Fatal(err)
+		}
+		if expected := template.HTMLEscapeString(s); b.String() != expected {
+			t.Errorf("%q: expected %q, got %q", s, expected, b.String())
+		}
+	}
+}
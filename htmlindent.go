@@ -0,0 +1,108 @@
+package mustache
+
+import "bytes"
+
+// impliedEnds lists, for elements whose end tags may be left out, the open elements their start tags end, so that
+// IndentHTML can follow the nesting of <li> items and table cells without end tags.
+var impliedEnds = map[string][]string{
+	"li":     {"li"},
+	"p":      {"p"},
+	"dt":     {"dt", "dd"},
+	"dd":     {"dt", "dd"},
+	"tr":     {"tr", "td", "th"},
+	"td":     {"td", "th"},
+	"th":     {"td", "th"},
+	"option": {"option"},
+}
+
+// IndentHTML returns a post processor which re-indents HTML output, so that the output of templates assembled from
+// many partials reads, and diffs, as if it were written by hand. Each line is indented with indent, such as "  " or
+// "\t", once for each element open at its start; a line beginning with an end tag is indented as its start tag's line
+// was. Line breaks are neither added nor removed, so the text and the inline elements of a line stay as they were,
+// and trailing whitespace and the whitespace of blank lines are dropped. The contents of script, style, pre and
+// textarea elements are passed through unchanged, as are the lines within comments and tags which span lines.
+func IndentHTML(indent string) func([]byte) ([]byte, error) {
+	return func(src []byte) ([]byte, error) {
+		out := make([]byte, 0, len(src))
+		var open []string
+		lineStart := true
+		// startLine indents the line begun by the content which follows
+		startLine := func() {
+			if lineStart {
+				for range open {
+					out = append(out, indent...)
+				}
+				lineStart = false
+			}
+		}
+		splitHTML(src, func(kind htmlToken, b []byte) {
+			switch kind {
+			case htmlText:
+				for i := 0; i < len(b); i++ {
+					c := b[i]
+					switch {
+					case c == '\n':
+						out = trimLineEnd(out)
+						out = append(out, c)
+						lineStart = true
+						continue
+					case lineStart && (c == ' ' || c == '\t'):
+						continue
+					case lineStart && c == '\r' && i+1 < len(b) && b[i+1] == '\n':
+						out = append(out, c)
+						continue
+					}
+					startLine()
+					out = append(out, c)
+				}
+			case htmlTag:
+				name := htmlTagName(b)
+				switch {
+				case name == "":
+				case b[1] == '/':
+					for k := len(open) - 1; k >= 0; k-- {
+						if open[k] == name {
+							open = open[:k]
+							break
+						}
+					}
+				default:
+					if ends := impliedEnds[name]; len(open) > 0 {
+						for _, end := range ends {
+							if open[len(open)-1] == end {
+								open = open[:len(open)-1]
+								break
+							}
+						}
+					}
+				}
+				startLine()
+				out = append(out, b...)
+				if name != "" && b[1] != '/' && !voidElements[name] && !bytes.HasSuffix(b, []byte("/>")) {
+					open = append(open, name)
+				}
+			case htmlComment:
+				startLine()
+				out = append(out, b...)
+			case htmlRaw:
+				// the contents end where the end tag begins, which must follow them directly
+				out = append(out, b...)
+				lineStart = false
+			}
+		})
+		return out, nil
+	}
+}
+
+// trimLineEnd removes the spaces and tabs ending out, before any carriage return.
+func trimLineEnd(out []byte) []byte {
+	cr := len(out) > 0 && out[len(out)-1] == '\r'
+	if cr {
+		out = out[:len(out)-1]
+	}
+	out = bytes.TrimRight(out, " \t")
+	if cr {
+		out = append(out, '\r')
+	}
+	return out
+}
@@ -0,0 +1,139 @@
+//go:build !mustache_minimal
+
+package mustache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPProvider implements the PartialProvider interface by fetching partials from a web server. A partial named
+// `NAME` is fetched from BaseURL followed by `NAME` and Extension, so with a BaseURL of
+// "https://example.com/partials/" and an Extension of ".mustache", the partial "user/card" is fetched from
+// "https://example.com/partials/user/card.mustache". A 404 Not Found response means the partial is missing. Partial
+// names with empty, "." or ".." path segments are refused.
+type HTTPProvider struct {
+	BaseURL   string
+	Extension string
+	Client    *http.Client // the client to use, or nil for http.DefaultClient
+	// Timeout optionally limits how long fetching a partial may take, including reading the response.
+	Timeout time.Duration
+}
+
+// Get accepts the name of a partial and returns the parsed partial.
+func (hp *HTTPProvider) Get(name string) (string, error) {
+	return hp.GetContext(context.Background(), name)
+}
+
+// GetContext returns a partial like Get, giving up once ctx is done or the provider's Timeout has passed.
+func (hp *HTTPProvider) GetContext(ctx context.Context, name string) (string, error) {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("unsafe partial name passed to HTTPProvider: %s", name)
+		}
+		segments[i] = url.PathEscape(segment)
+	}
+	if hp.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hp.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hp.BaseURL+strings.Join(segments, "/")+hp.Extension, nil)
+	if err != nil {
+		return "", err
+	}
+	client := hp.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%s: %w", name, ErrPartialNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+var _ ContextPartialProvider = (*HTTPProvider)(nil)
+
+// FrenderHTTP renders the template as the response to req, compressed in the encoding the request accepts best of
+// those set with WithEncodings, and flushed to the client as each of the template's top level sections ends, so that
+// large pages stream compressed without being buffered. The render has the request's context. If the response has no
+// Content-Type, it is set from the escape mode of the template, since the compressed output cannot be sniffed.
+//
+// Once the render has begun, the status can no longer be changed, so an error from the render leaves the response
+// truncated; it is returned for the handler to log.
+func (tmpl *Template) FrenderHTTP(w http.ResponseWriter, req *http.Request, data ...interface{}) error {
+	header := w.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", tmpl.outputMode.contentType(tmpl.parent.charset.Name))
+	}
+	out := &responseWriter{w}
+	enc, ok := negotiateEncoding(req.Header.Get("Accept-Encoding"), tmpl.parent.encodingsOrDefault())
+	if !ok {
+		return tmpl.FrenderContext(req.Context(), out, data...)
+	}
+	header.Set("Content-Encoding", enc.Name)
+	header.Del("Content-Length")
+	ew := enc.NewWriter(w)
+	err := tmpl.FrenderContext(req.Context(), &encodingFlusher{ew, out}, data...)
+	if closeErr := ew.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// responseWriter flushes an http.ResponseWriter if it, or a writer it wraps, is an http.Flusher, as an
+// http.ResponseController does from Go 1.20.
+type responseWriter struct {
+	w http.ResponseWriter
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	return rw.w.Write(p)
+}
+
+func (rw *responseWriter) Flush() error {
+	w := rw.w
+	for {
+		switch t := w.(type) {
+		case http.Flusher:
+			t.Flush()
+			return nil
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// encodingFlusher writes to a compressing writer, and flushes it and then the response.
+type encodingFlusher struct {
+	EncodingWriter
+	response Flusher
+}
+
+func (ef *encodingFlusher) Flush() error {
+	if err := ef.EncodingWriter.Flush(); err != nil {
+		return err
+	}
+	return ef.response.Flush()
+}
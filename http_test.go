@@ -0,0 +1,119 @@
+//go:build !mustache_minimal
+
+package mustache
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/partials/user/card.mustache":
+			w.Write([]byte("<{{name}}>"))
+		case "/partials/slow.mustache":
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		case "/partials/broken.mustache":
+			http.Error(w, "broken", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	hp := &HTTPProvider{BaseURL: server.URL + "/partials/", Extension: ".mustache"}
+
+	tmpl, err := New().WithPartials(hp).CompileString("{{>user/card}}{{>missing}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(map[string]string{"name": "x"}); err != nil || output != "<x>" {
+		t.Errorf("unexpected output %q, %v", output, err)
+	}
+	if _, err := hp.Get("missing"); !errors.Is(err, ErrPartialNotFound) {
+		t.Errorf("expected a missing partial, got %v", err)
+	}
+	if _, err := hp.Get("broken"); err == nil {
+		t.Error("expected an error for a server error")
+	}
+	for _, name := range []string{"../secret", "a//b", "/abs"} {
+		if _, err := hp.Get(name); err == nil {
+			t.Errorf("expected an error for the unsafe name %q", name)
+		}
+	}
+
+	tmpl, err = New().WithPartials(hp).CompileString("{{>slow}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = tmpl.RenderContext(ctx)
+	assertPrompt(t, start, err, context.DeadlineExceeded)
+
+	hp.Timeout = 20 * time.Millisecond
+	start = time.Now()
+	_, err = tmpl.Render()
+	assertPrompt(t, start, err, context.DeadlineExceeded)
+}
+
+func TestFrenderHTTP(t *testing.T) {
+	tmpl, err := New().CompileString("{{#items}}<p>{{.}}</p>{{/items}}<footer>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{"items": []string{"a", "b"}}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "deflate, gzip;q=0.8")
+	rec := httptest.NewRecorder()
+	if err := tmpl.FrenderHTTP(rec, req, data); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" ||
+		rec.Header().Get("Vary") != "Accept-Encoding" || !rec.Flushed {
+		t.Errorf("unexpected headers %v, flushed %v", rec.Header(), rec.Flushed)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := io.ReadAll(zr); err != nil || string(output) != "<p>a</p><p>b</p><footer>" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+
+	req.Header.Set("Accept-Encoding", "gzip;q=0, br")
+	rec = httptest.NewRecorder()
+	if err := tmpl.FrenderHTTP(rec, req, data); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "<p>a</p><p>b</p><footer>" {
+		t.Errorf("expected uncompressed output, got %v %q", rec.Header(), rec.Body.String())
+	}
+}
+
+func TestFrenderHTTPCharset(t *testing.T) {
+	tmpl, err := New().WithCharset(EncoderCharset("X-UPPER", newUpperEncoder), UnmappableReplace).CompileString("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	if err := tmpl.FrenderHTTP(rec, httptest.NewRequest("GET", "/", nil), nil); err != nil {
+		t.Fatal(err)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=X-UPPER" {
+		t.Errorf("got Content-Type %q", ct)
+	}
+}
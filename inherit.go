@@ -0,0 +1,201 @@
+package mustache
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+)
+
+// The sigils of the tags of template inheritance, which set sectionElement.sigil. A parent tag, {{<name}}...{{/name}},
+// renders the partial name with the blocks defined in its body in place of the partial's blocks of the same names; the
+// rest of its body is ignored. A block tag, {{$name}}...{{/name}}, renders its contents unless a parent tag including
+// the template holding it overrides the block.
+const (
+	parentSigil = '<'
+	blockSigil  = '$'
+)
+
+// blockOverride is the content a parent tag gives a block, and the template it belongs to, which renders it.
+type blockOverride struct {
+	block *sectionElement
+	tmpl  *Template
+}
+
+// inheritParse is the state of the parse of an open parent or block tag, for deciding how the construct as a whole
+// stands on its lines.
+type inheritParse struct {
+	// clear is set if only tags and whitespace precede the opening tag on its line
+	clear bool
+	// padding holds the whitespace before the opening tag, if the tag was not standalone but began its line
+	padding *textElement
+	// standalone is set if the opening tag was standalone
+	standalone bool
+}
+
+// inheritStandalone reports whether a tag which is not standalone under the usual rule may still stand alone because
+// it belongs to template inheritance: parent and block tags, and the tags closing them, stand alone when only other
+// tags precede them on their line, and a tag closing a parent tag, whose body is not rendered, when its opening tag
+// began a line. clear is set if only tags and whitespace precede the tag on its line.
+func inheritStandalone(tag string, stack []*sectionElement, open map[*sectionElement]inheritParse, clear bool) bool {
+	switch tag[0] {
+	case parentSigil, blockSigil:
+		return clear
+	case '/':
+		if len(stack) == 0 {
+			return false
+		}
+		switch top := stack[len(stack)-1]; top.sigil {
+		case parentSigil:
+			return open[top].clear
+		case blockSigil:
+			return clear
+		}
+	}
+	return false
+}
+
+// skipLineEnd moves past the spaces and tabs following a tag and the line break ending its line, reporting whether
+// there was nothing else before the end of the line.
+func (tmpl *Template) skipLineEnd() bool {
+	eow := tmpl.p
+	for eow < len(tmpl.data) && (tmpl.data[eow] == ' ' || tmpl.data[eow] == '\t') {
+		eow++
+	}
+	switch {
+	case eow == len(tmpl.data):
+		tmpl.p = eow
+	case tmpl.data[eow] == '\n':
+		tmpl.p = eow + 1
+		tmpl.curline++
+	case eow+1 < len(tmpl.data) && tmpl.data[eow] == '\r' && tmpl.data[eow+1] == '\n':
+		tmpl.p = eow + 2
+		tmpl.curline++
+	default:
+		return false
+	}
+	return true
+}
+
+// closeInherited finishes a parent or block tag once its closing tag has been parsed. If the construct stands alone,
+// with its opening tag beginning a line and its closing tag ending one, the whitespace before the opening tag becomes
+// its indentation rather than text. A block defined in the body of a parent tag whose opening tag is standalone has
+// the indentation of its first line removed from each of its lines, to be replaced by that of the block it overrides.
+func closeInherited(se *sectionElement, state inheritParse, closedStandalone bool, stack []*sectionElement) {
+	if closedStandalone && state.padding != nil {
+		se.indent = string(state.padding.text)
+		state.padding.text = nil
+	}
+	if se.sigil == blockSigil && state.standalone && len(stack) > 0 && stack[len(stack)-1].sigil == parentSigil {
+		dedentElems(se.elems, leadingIndent(se.elems), true)
+	}
+}
+
+// leadingIndent returns the spaces and tabs beginning the first line of elems.
+func leadingIndent(elems []interface{}) []byte {
+	if len(elems) == 0 {
+		return nil
+	}
+	text, ok := elems[0].(*textElement)
+	if !ok {
+		return nil
+	}
+	n := 0
+	for n < len(text.text) && (text.text[n] == ' ' || text.text[n] == '\t') {
+		n++
+	}
+	return text.text[:n]
+}
+
+// dedentElems removes indent from the start of each line of the text of elems and the sections within them, given
+// whether elems begin a line. It returns whether they end at the start of a line.
+func dedentElems(elems []interface{}, indent []byte, lineStart bool) bool {
+	if len(indent) == 0 {
+		return lineStart
+	}
+	for _, elem := range elems {
+		switch elem := elem.(type) {
+		case *textElement:
+			var out []byte
+			for text := elem.text; len(text) > 0; {
+				if lineStart && bytes.HasPrefix(text, indent) {
+					text = text[len(indent):]
+				}
+				line := text
+				if i := bytes.IndexByte(text, '\n'); i >= 0 {
+					line = text[:i+1]
+				}
+				out = append(out, line...)
+				if len(line) > 0 {
+					lineStart = line[len(line)-1] == '\n'
+				}
+				text = text[len(line):]
+			}
+			elem.text = out
+		case *sectionElement:
+			lineStart = dedentElems(elem.elems, indent, lineStart)
+		default:
+			lineStart = false
+		}
+	}
+	return lineStart
+}
+
+// renderInherited renders a parent or block tag.
+func (tmpl *Template) renderInherited(st *renderState, elem *sectionElement, contextChain []reflect.Value, buf io.Writer) error {
+	if elem.sigil == blockSigil {
+		return tmpl.renderBlock(st, elem, contextChain, buf)
+	}
+	return tmpl.renderParent(st, elem, contextChain, buf)
+}
+
+// renderBlock renders the content a parent tag gives a block, or else the block's own content. Content from a parent
+// tag is indented as a standalone block tag is.
+func (tmpl *Template) renderBlock(st *renderState, elem *sectionElement, contextChain []reflect.Value, buf io.Writer) error {
+	o, ok := st.blocks[elem.name]
+	if !ok {
+		return tmpl.renderElements(st, elem.elems, contextChain, buf)
+	}
+	outer := st.indent
+	if elem.indent != "" {
+		st.indentation = indentation{outer + elem.indent, true}
+	}
+	defer func() { st.indent = outer }()
+	return o.tmpl.renderElements(st, o.block.elems, contextChain, buf)
+}
+
+// renderParent renders the partial of a parent tag with the blocks defined in the tag's body. The blocks given by the
+// parent tags which led to this one take precedence, so that the most derived template has the last word.
+func (tmpl *Template) renderParent(st *renderState, elem *sectionElement, contextChain []reflect.Value, buf io.Writer) error {
+	blocks := make(map[string]blockOverride, len(st.blocks))
+	for _, e := range elem.elems {
+		if block, ok := e.(*sectionElement); ok && block.sigil == blockSigil {
+			blocks[block.name] = blockOverride{block, tmpl}
+		}
+	}
+	for name, o := range st.blocks {
+		blocks[name] = o
+	}
+	outerBlocks, outer := st.blocks, st.indent
+	st.blocks = blocks
+	if elem.indent != "" {
+		st.indentation = indentation{outer + elem.indent, true}
+	}
+	defer func() { st.blocks, st.indent = outerBlocks, outer }()
+
+	if err := spend(&st.spent.partialFetches, tmpl.parent.budget.partialFetches, ErrTooManyPartialFetches); err != nil {
+		return err
+	}
+	ctx, span := tmpl.parent.startSpan(st.ctx, SpanPartial, Attribute{AttrPartial, elem.name})
+	partial, err := tmpl.getPartials(ctx, elem.prov, elem.name)
+	span.End(err)
+	if err != nil {
+		return tmpl.partialMissing(elem.name, err)
+	}
+	if st.summary != nil {
+		st.summary.Partials++
+	}
+	if st.tags != nil {
+		st.tags.rendered(partial)
+	}
+	return partial.renderTemplate(st, contextChain, buf)
+}
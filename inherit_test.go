@@ -0,0 +1,57 @@
+package mustache
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestInheritance(t *testing.T) {
+	tests := []struct {
+		name     string
+		tmpl     string
+		partials map[string]string
+		data     interface{}
+		expected string
+	}{
+		{"default", "{{$title}}Default {{bar}}{{/title}}\n", nil, map[string]string{"bar": "<b>"}, "Default &lt;b&gt;\n"},
+		{"inherit", "{{<include}}{{/include}}\n", map[string]string{"include": "{{$foo}}default content{{/foo}}"}, nil, "default content"},
+		{"override", "{{<super}}{{$title}}sub template title{{/title}}{{/super}}\n", map[string]string{"super": "...{{$title}}Default title{{/title}}..."}, nil, "...sub template title..."},
+		{"data does not override", "{{<include}}{{/include}}", map[string]string{"include": "{{$var}}var in include{{/var}}"}, map[string]string{"var": "var in data"}, "var in include"},
+		{"two parents", "test {{<partial}}{{$stuff}}1{{/stuff}}{{/partial}} {{<partial}}{{$stuff}}2{{/stuff}}{{/partial}}\n", map[string]string{"partial": "|{{$stuff}}...{{/stuff}}{{$other}}-{{/other}}|"}, nil, "test |1-| |2-|\n"},
+		{"newlines", "{{<parent}}{{$ballmer}}\npeaked\n\n:(\n{{/ballmer}}{{/parent}}", map[string]string{"parent": "{{$ballmer}}peaking{{/ballmer}}"}, nil, "peaked\n\n:(\n"},
+		{"text inside parent", "{{<parent}} ignored {{$foo}}hmm{{/foo}} ignored {{/parent}}", map[string]string{"parent": "{{$foo}}default content{{/foo}}"}, nil, "hmm"},
+		{"multi-level", "{{<parent}}{{$a}}c{{/a}}{{/parent}}", map[string]string{"parent": "{{<older}}{{$a}}p{{/a}}{{$b}}p{{/b}}{{/older}}", "older": "{{<grandParent}}{{$a}}o{{/a}}{{/grandParent}}", "grandParent": "{{$a}}g{{/a}}{{$b}}g{{/b}}"}, nil, "cp"},
+		{"recursion", "{{<parent}}{{$foo}}override{{/foo}}{{/parent}}", map[string]string{"parent": "{{$foo}}default content{{/foo}} {{$bar}}{{<parent2}}{{/parent2}}{{/bar}}", "parent2": "{{$foo}}parent2 default content{{/foo}} {{<parent}}{{$bar}}don't recurse{{/bar}}{{/parent}}"}, nil, "override override override don't recurse"},
+		{"block scope", "{{<parent}}{{$block}}I say {{fruit}}.{{/block}}{{/parent}}", map[string]string{"parent": "{{#nested}}{{$block}}You say {{fruit}}.{{/block}}{{/nested}}"}, map[string]interface{}{"fruit": "apples", "nested": map[string]string{"fruit": "bananas"}}, "I say bananas."},
+		{"standalone parent", "Hi,\n  {{<parent}}{{/parent}}\n", map[string]string{"parent": "one\ntwo\n"}, nil, "Hi,\n  one\n  two\n"},
+		{"standalone block", "{{<parent}}{{$block}}\none\ntwo\n{{/block}}{{/parent}}\n", map[string]string{"parent": "Hi,\n  {{$block}}{{/block}}\n"}, nil, "Hi,\n  one\n  two\n"},
+		{"block reindentation", "{{<parent}}\n  {{$block}}\n    one\n      two\n  {{/block}}\n{{/parent}}\n", map[string]string{"parent": "Hi,\n  {{$block}}\n  default\n  {{/block}}\n"}, nil, "Hi,\n  one\n    two\n"},
+		{"indented default", "Hi,\n  {{$block}}\n  default\n  {{/block}}\n", nil, nil, "Hi,\n  default\n"},
+		{"relative parent", "{{<./base}}{{$x}}y{{/x}}{{/./base}}", map[string]string{"base": "[{{$x}}{{/x}}]"}, nil, "[y]"},
+	}
+	for _, test := range tests {
+		tmpl, err := New().WithPartials(&StaticProvider{Partials: test.partials}).CompileString(test.tmpl)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if output, err := tmpl.Render(test.data); err != nil || output != test.expected {
+			t.Errorf("%s: expected %q, got %q, %v", test.name, test.expected, output, err)
+		}
+	}
+
+	if _, err := New().CompileString("{{$block}}never closed"); err == nil || !strings.Contains(err.Error(), "no closing tag") {
+		t.Errorf("expected an unclosed block to fail, got %v", err)
+	}
+	tmpl, err := New().CompileString("{{<layout}}{{$body}}x{{/body}}{{/layout}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.ExportJS(io.Discard); err == nil {
+		t.Error("expected a template using inheritance not to be exported to JavaScript")
+	}
+	if tags := tmpl.Tags(); len(tags) != 1 || tags[0].Type() != Parent || tags[0].Tags()[0].Type() != Block {
+		t.Errorf("expected a parent tag holding a block, got %v", tags)
+	}
+}
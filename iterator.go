@@ -0,0 +1,28 @@
+package mustache
+
+import "reflect"
+
+// WithSectionIterator adds a hook which lets sections iterate over collection types other than slices and arrays, such
+// as ordered maps, paginated cursors and generic containers, without converting them to slices first. Given the value
+// of a section, the hook returns its elements and true, or false if it doesn't handle the value's type, in which case
+// the next hook is tried, in the order they were added, and then the usual rules apply. A section over a value a hook
+// handles renders once for each element, with -first, -last and -index as for lists, and an inverted section renders
+// if there are no elements. Hooks aren't called for missing values.
+func (r *Compiler) WithSectionIterator(hook func(v any) ([]any, bool)) *Compiler {
+	r.sectionIterators = append(r.sectionIterators, hook)
+	return r
+}
+
+// iterate returns the elements of a section value from the first section iterator which handles it.
+func (tmpl *Template) iterate(value reflect.Value) ([]any, bool) {
+	if len(tmpl.parent.sectionIterators) == 0 || !value.IsValid() || !value.CanInterface() {
+		return nil, false
+	}
+	v := value.Interface()
+	for _, hook := range tmpl.parent.sectionIterators {
+		if items, ok := hook(v); ok {
+			return items, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,36 @@
+package mustache
+
+import "testing"
+
+// orderedMap keeps its entries in the order they were added.
+type orderedMap struct {
+	keys   []string
+	values map[string]string
+}
+
+func TestSectionIterator(t *testing.T) {
+	cmpl := New().WithSectionIterator(func(v any) ([]any, bool) {
+		m, ok := v.(*orderedMap)
+		if !ok {
+			return nil, false
+		}
+		entries := make([]any, len(m.keys))
+		for i, k := range m.keys {
+			entries[i] = map[string]string{"key": k, "value": m.values[k]}
+		}
+		return entries, true
+	})
+	tmpl, err := cmpl.CompileString("{{#env}}{{-index}}.{{key}}={{value}}{{^-last}},{{/-last}}{{/env}}{{^env}}none{{/env}}|{{#list}}{{.}}{{/list}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &orderedMap{keys: []string{"b", "a"}, values: map[string]string{"a": "1", "b": "2"}}
+	data := map[string]interface{}{"env": env, "list": []int{1, 2}}
+	if output, err := tmpl.Render(data); err != nil || output != "1.b=2,2.a=1|12" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+	data["env"] = &orderedMap{}
+	if output, err := tmpl.Render(data); err != nil || output != "none|12" {
+		t.Errorf("expected an empty collection to render the inverted section, got %q, %v", output, err)
+	}
+}
@@ -0,0 +1,330 @@
+package mustache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxExportedPartials limits the number of distinct partials, by name and indentation, an exported template may
+// include. It is only reached by partials which include themselves with growing indentation.
+const maxExportedPartials = 1000
+
+// ExportJS writes the template as a self-contained JavaScript expression which evaluates to a render function, so that
+// the same template can be rendered in a browser without shipping its source or a template parser:
+//
+//	const page = <output of ExportJS>;
+//	element.innerHTML = page(data);
+//
+// Like Render, the function accepts any number of contexts, the first taking precedence. It renders data decoded
+// from JSON as the template does in Go, including the escape modes, missing value and reserved name errors, and -first,
+// -last and -index. Numbers are formatted as Go formats float64 values, which JSON numbers decode to. Functions in the
+// data are called with no arguments when interpolated, and as lambdas, with the section text and a render function
+// which throws, in sections.
+//
+// Partials are loaded from the template's PartialProvider when the template is exported, and included in the output.
+// Templates compiled with value stringers, or which call helpers, can't be exported, since those are Go functions, nor
+// can templates compiled with numeric sections.
+func (tmpl *Template) ExportJS(w io.Writer) error {
+	if tmpl.valueStringer != nil || len(tmpl.parent.tagStringers) > 0 || len(tmpl.parent.modeStringers) > 0 {
+		return errors.New("mustache: templates using value stringers can't be exported to JavaScript")
+	}
+	if tmpl.parent.numericSections {
+		return errors.New("mustache: templates with numeric sections can't be exported to JavaScript")
+	}
+	e := &jsExporter{partials: make(map[string]int)}
+	e.add(tmpl)
+	for i := 0; i < len(e.templates); i++ {
+		if err := e.function(i); err != nil {
+			return err
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("(function () {\n\"use strict\";\n")
+	fmt.Fprintf(&out, "var reserved = %s;\n", jsLiteral(tmpl.parent.strictReserved))
+	out.WriteString(jsRuntime)
+	out.WriteString("var t = [];\n")
+	for _, body := range e.bodies {
+		out.WriteString(body)
+	}
+	out.WriteString("return function () {\nvar s = [];\nfor (var i = arguments.length - 1; i >= 0; i--) s.push({v: arguments[i]});\nreturn t[0](s);\n};\n})()\n")
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// jsExporter generates a JavaScript function for a template and each of the partials it includes.
+type jsExporter struct {
+	templates []*Template
+	bodies    []string
+	// partials maps the name and indentation of each partial to the index of its function, or -1 if it is missing
+	partials map[string]int
+}
+
+// add adds a template to be exported, returning the index of its function.
+func (e *jsExporter) add(tmpl *Template) int {
+	e.templates = append(e.templates, tmpl)
+	e.bodies = append(e.bodies, "")
+	return len(e.templates) - 1
+}
+
+// function generates the function for the template at index i.
+func (e *jsExporter) function(i int) error {
+	tmpl := e.templates[i]
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "t[%d] = ", i)
+	if err := e.elements(&buf, tmpl, tmpl.elems); err != nil {
+		return err
+	}
+	buf.WriteString(";\n")
+	e.bodies[i] = buf.String()
+	return nil
+}
+
+// elements generates a function which renders elems with a context stack, and returns the output.
+func (e *jsExporter) elements(buf *bytes.Buffer, tmpl *Template, elems []interface{}) error {
+	buf.WriteString("function (s) {\nvar o = \"\";\n")
+	strict := jsLiteral(tmpl.errorOnMissing)
+	for _, elem := range elems {
+		switch elem := elem.(type) {
+		case *textElement:
+			if len(elem.text) > 0 {
+				fmt.Fprintf(buf, "o += %s;\n", jsLiteral(string(elem.text)))
+			}
+		case *varElement:
+			fmt.Fprintf(buf, "o += v(s, %s, %s, %s, %s);\n", jsLiteral(elem.name), jsLiteral(tmpl.outputMode.String()), jsLiteral(elem.raw), strict)
+		case *sectionElement:
+			if elem.sigil != 0 {
+				return fmt.Errorf("mustache: templates using inheritance can't be exported to JavaScript: %s", tagKey(elem))
+			}
+			var text bytes.Buffer
+			getSectionText(elem.elems, &text)
+			fmt.Fprintf(buf, "o += sec(s, %s, %s, %s, %s, %s, ", jsLiteral(elem.name), jsLiteral(elem.inverted), jsLiteral(elem.cond), jsLiteral(text.String()), strict)
+			if err := e.elements(buf, tmpl, elem.elems); err != nil {
+				return err
+			}
+			buf.WriteString(");\n")
+		case *partialElement:
+			if err := e.partial(buf, tmpl, elem); err != nil {
+				return err
+			}
+		case *helperElement:
+			return fmt.Errorf("mustache: templates calling helpers can't be exported to JavaScript: %s", elem.name)
+		case *exprElement:
+			return fmt.Errorf("mustache: templates with expressions can't be exported to JavaScript: %s", elem.src)
+		case *customTagElement:
+			return fmt.Errorf("mustache: templates with custom tags can't be exported to JavaScript: %s", elem)
+		}
+	}
+	buf.WriteString("return o;\n}")
+	return nil
+}
+
+// partial generates a call to the function for a partial, loading and compiling the partial if it hasn't been seen.
+func (e *jsExporter) partial(buf *bytes.Buffer, tmpl *Template, elem *partialElement) error {
+	if elem.raw || isRawPartial(elem.prov, elem.name) {
+		return e.rawPartial(buf, tmpl, elem)
+	}
+	key := elem.name + "\x00" + elem.indent
+	index, seen := e.partials[key]
+	if !seen {
+		partial, err := tmpl.getIndentedPartial(context.Background(), elem.prov, elem.name, elem.indent)
+		switch {
+		case errors.Is(err, ErrPartialNotFound):
+			if tmpl.errorOnMissing {
+				return err
+			}
+			index = -1
+		case err != nil:
+			return err
+		case len(e.partials) >= maxExportedPartials:
+			return fmt.Errorf("mustache: more than %d partials to export", maxExportedPartials)
+		default:
+			index = e.add(partial)
+		}
+		e.partials[key] = index
+	}
+	if index < 0 {
+		return nil
+	}
+
+	stack := "s"
+	if elem.context != "" || len(elem.params) > 0 {
+		ctx := "null"
+		if elem.context != "" {
+			ctx = jsLiteral(elem.context)
+		}
+		params := make([]string, len(elem.params))
+		for i, param := range elem.params {
+			if param.name != "" {
+				params[i] = fmt.Sprintf("[%s, %s, null]", jsLiteral(param.key), jsLiteral(param.name))
+			} else {
+				params[i] = fmt.Sprintf("[%s, null, %s]", jsLiteral(param.key), jsLiteral(param.value))
+			}
+		}
+		stack = fmt.Sprintf("hp(s, %s, [%s], %s)", ctx, strings.Join(params, ", "), jsLiteral(tmpl.errorOnMissing))
+	}
+	fmt.Fprintf(buf, "o += t[%d](%s);\n", index, stack)
+	return nil
+}
+
+// rawPartial writes a partial which is included verbatim into the generated function as a literal.
+func (e *jsExporter) rawPartial(buf *bytes.Buffer, tmpl *Template, elem *partialElement) error {
+	data, err := "", errNoPartialProvider
+	if elem.prov != nil {
+		data, err = getPartialSource(context.Background(), elem.prov, elem.name)
+	}
+	if err != nil {
+		if tmpl.errorOnMissing || !errors.Is(err, ErrPartialNotFound) {
+			return err
+		}
+		return nil
+	}
+	if data != "" {
+		fmt.Fprintf(buf, "o += %s;\n", jsLiteral(string(indentLines(data, elem.indent))))
+	}
+	return nil
+}
+
+// jsLiteral returns the JavaScript literal for a string, bool or number.
+func jsLiteral(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+// jsRuntime holds the functions used by exported templates. A context stack is an array of frames, innermost last,
+// each holding a context v, and for list elements, the index i of the element and the length n of the list.
+const jsRuntime = `var hasOwn = Object.prototype.hasOwnProperty;
+var SPACE = /^[\t\n\v\f\r \u0085\u00a0\u1680\u2000-\u200a\u2028\u2029\u202f\u205f\u3000]*$/;
+var HTML = {"&": "&amp;", "<": "&lt;", ">": "&gt;", "\"": "&#34;", "'": "&#39;", "\u0000": "\ufffd"};
+var JSONESC = {"\"": "\\\"", "\\": "\\\\", "\n": "\\n", "\b": "\\b", "\f": "\\f", "\r": "\\r", "\t": "\\t"};
+function u(c) {
+  return "\\u" + ("000" + c.charCodeAt(0).toString(16)).slice(-4);
+}
+function find(s, name, strict) {
+  var i;
+  if (name.length > 1 && name.charAt(0) === ".") {
+    name = name.slice(1);
+  } else if (name.length > 1 && name.charAt(0) === "-") {
+    if (reserved && /^-(first|last|index0?)$/.test(name) && find(s, "." + name, false)) {
+      throw new Error("data shadows the reserved name " + JSON.stringify(name) + "; use " + JSON.stringify("." + name) + " to refer to it");
+    }
+    for (i = s.length - 1; i >= 0; i--) {
+      var f = s[i];
+      if (!f.n) continue;
+      if (name === "-first") return {v: f.i === 0};
+      if (name === "-last") return {v: f.i === f.n - 1};
+      if (name === "-index") return {v: f.i + 1};
+      if (name === "-index0") return {v: f.i};
+      break;
+    }
+  }
+  if (name !== "." && name.indexOf(".") >= 0) {
+    var dot = name.indexOf(".");
+    var head = find(s, name.slice(0, dot), strict);
+    return find(head ? [{v: head.v}] : [], name.slice(dot + 1), strict);
+  }
+  for (i = s.length - 1; i >= 0; i--) {
+    var c = s[i].v;
+    if (c === null || c === undefined) continue;
+    if (name === ".") return {v: c};
+    if (typeof c === "object" && !Array.isArray(c) && hasOwn.call(c, name)) return {v: c[name]};
+  }
+  if (strict) throw new Error("missing variable " + JSON.stringify(name));
+  return null;
+}
+function empty(x) {
+  if (x === null || x === undefined) return true;
+  if (Array.isArray(x)) return x.length === 0;
+  switch (typeof x) {
+  case "string": return SPACE.test(x);
+  case "number": return x === 0;
+  case "boolean": return !x;
+  }
+  return false;
+}
+function num(x) {
+  if (isNaN(x)) return "NaN";
+  if (!isFinite(x)) return x > 0 ? "+Inf" : "-Inf";
+  var e = x.toExponential(), at = e.indexOf("e"), exp = parseInt(e.slice(at + 1), 10);
+  if (exp >= -4 && exp < 6) return String(x);
+  var digits = String(Math.abs(exp));
+  return e.slice(0, at) + "e" + (exp < 0 ? "-" : "+") + (digits.length < 2 ? "0" : "") + digits;
+}
+function str(x) {
+  if (x === null || x === undefined) return "<nil>";
+  switch (typeof x) {
+  case "string": return x;
+  case "boolean": return x ? "true" : "false";
+  case "number": return num(x);
+  }
+  if (Array.isArray(x)) return "[" + x.map(str).join(" ") + "]";
+  return "map[" + Object.keys(x).sort().map(function (k) { return k + ":" + str(x[k]); }).join(" ") + "]";
+}
+function jv(x) {
+  if (x === null || x === undefined) return "null";
+  switch (typeof x) {
+  case "string": return JSON.stringify(x).replace(/[<>&\u2028\u2029]/g, u);
+  case "boolean": return x ? "true" : "false";
+  case "number":
+    if (!isFinite(x)) throw new Error("json: unsupported value: " + num(x));
+    return JSON.stringify(x);
+  case "function": throw new Error("json: unsupported type: function");
+  }
+  if (Array.isArray(x)) return "[" + x.map(jv).join(",") + "]";
+  return "{" + Object.keys(x).sort().map(function (k) { return jv(k) + ":" + jv(x[k]); }).join(",") + "}";
+}
+function v(s, name, mode, raw, strict) {
+  var r = find(s, name, strict);
+  if (!r) return mode === "JSONVALUE" && !raw ? "null" : "";
+  var x = r.v;
+  if (typeof x === "function") x = x();
+  if (raw) return str(x);
+  switch (mode) {
+  case "HTML": return str(x).replace(/[&<>"'\u0000]/g, function (c) { return HTML[c]; });
+  case "JSON": return str(x).replace(/[\\"\u0000-\u001f\u007f-\u009f]/g, function (c) { return JSONESC[c] || u(c); });
+  case "JSONVALUE": return jv(x);
+  }
+  return str(x);
+}
+function sec(s, name, inverted, cond, text, strict, body) {
+  var r = find(s, name, strict), x = r ? r.v : undefined;
+  if (empty(x) !== inverted) return "";
+  if (inverted || cond) return body(s.concat([{v: s.length ? s[s.length - 1].v : undefined}]));
+  if (Array.isArray(x)) {
+    var o = "";
+    for (var i = 0; i < x.length; i++) o += body(s.concat([{v: x[i], i: i, n: x.length}]));
+    return o;
+  }
+  if (typeof x === "function") {
+    return String(x(text, function () { throw new Error("render is not available in exported templates"); }));
+  }
+  return body(s.concat([{v: x}]));
+}
+function hp(s, ctx, params, strict) {
+  var pushed = [], r;
+  if (ctx !== null) {
+    r = find(s, ctx, strict);
+    pushed.push({v: r ? r.v : undefined});
+  }
+  if (params.length) {
+    var m = {};
+    for (var i = 0; i < params.length; i++) {
+      if (params[i][1] === null) {
+        m[params[i][0]] = params[i][2];
+      } else if ((r = find(s, params[i][1], strict))) {
+        m[params[i][0]] = r.v;
+      }
+    }
+    pushed.push({v: m});
+  }
+  return s.concat(pushed);
+}
+`
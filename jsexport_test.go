@@ -0,0 +1,116 @@
+package mustache
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportJS(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	const data = `{
+		"name": "<Jo & \"Al\">",
+		"empty": "  ",
+		"zero": 0,
+		"price": 1234567.5,
+		"ratio": 0.25,
+		"tiny": 1.5e-7,
+		"big": 1000000,
+		"none": null,
+		"ok": true,
+		"items": [{"n": "a"}, {"n": "b"}, {"n": "c"}],
+		"nested": {"list": [1, 2], "obj": {"x": "y"}},
+		"text": "line\nbreak\t\u0001"
+	}`
+	partials := &StaticProvider{Partials: map[string]string{
+		"item": "<li>{{n}}</li>\n",
+		"list": "{{#items}}\n{{>item}}\n{{/items}}\n",
+		"card": "[{{name}}:{{label}}:{{size}}]",
+	}}
+	tests := []struct {
+		tmpl string
+		cmpl *Compiler
+	}{
+		{"Hello {{name}}! {{{name}}} {{&name}}", New()},
+		{"{{#items}}{{n}}{{^-last}}, {{/-last}}{{/items}}", New()},
+		{"{{#items}}{{-index}}:{{#-first}}first{{/-first}}{{/items}}", New()},
+		{"{{#items}}{{.-index}}{{.n}}{{-last}}{{/items}}", New().WithReservedNames(true)},
+		{"{{^empty}}empty{{/empty}}{{#zero}}x{{/zero}}{{^missing}}missing{{/missing}}", New()},
+		{"{{price}} {{ratio}} {{tiny}} {{big}} {{zero}} {{ok}} {{none}} {{missing}}", New()},
+		{"{{nested.obj.x}} {{nested.list}} {{nested.obj}} {{#nested}}{{obj.x}}{{/nested}}", New()},
+		{"{{#nested.list}}{{.}}{{/nested.list}}", New()},
+		{"<ul>\n  {{>list}}\n</ul>\n", New().WithPartials(partials)},
+		{"{{>missing}}", New()},
+		{"{\"name\": \"{{name}}\", \"text\": \"{{text}}\"}", New().WithEscapeMode(EscapeJSON)},
+		{"{\"name\": {{name}}, \"nested\": {{nested}}, \"missing\": {{missing}}}", New().WithEscapeMode(EscapeJSONValue)},
+		{"{{#each items}}{{@index}}{{this.n}}{{else}}none{{/each}}{{#if ok}}yes{{/if}}", New().WithSyntax(Handlebars)},
+		{"{{> card nested.obj label='x' size=3}} {{> card name=name}}", New().WithSyntax(Handlebars).WithPartials(partials)},
+	}
+
+	dir := t.TempDir()
+	for _, test := range tests {
+		tmpl, err := test.cmpl.CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ctx interface{}
+		if err := json.Unmarshal([]byte(data), &ctx); err != nil {
+			t.Fatal(err)
+		}
+		expected, err := tmpl.Render(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var script bytes.Buffer
+		script.WriteString("const render = ")
+		if err := tmpl.ExportJS(&script); err != nil {
+			t.Fatal(err)
+		}
+		script.WriteString(";\nprocess.stdout.write(render(" + data + "));\n")
+		file := filepath.Join(dir, "render.js")
+		if err := os.WriteFile(file, script.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		output, err := exec.Command(node, file).CombinedOutput()
+		if err != nil {
+			t.Errorf("%q: %v: %s", test.tmpl, err, output)
+		} else if string(output) != expected {
+			t.Errorf("%q: expected %q, got %q", test.tmpl, expected, output)
+		}
+	}
+}
+
+func TestExportJSErrors(t *testing.T) {
+	tmpl, err := New().WithValueStringer(func(v interface{}) (string, error) { return "", nil }).CompileString("{{a}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.ExportJS(&bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "value stringers") {
+		t.Errorf("expected a value stringer error, got %v", err)
+	}
+
+	tmpl, err = New().WithErrors(true).CompileString("{{>missing}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.ExportJS(&bytes.Buffer{}); err == nil {
+		t.Error("expected an error for a missing partial")
+	}
+
+	partials := &StaticProvider{Partials: map[string]string{"p": "x\n  {{>p}}\n"}}
+	tmpl, err = New().WithPartials(partials).CompileString("{{>p}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.ExportJS(&bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "partials to export") {
+		t.Errorf("expected an error for recursive indentation, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package mustache
+
+import (
+	"bytes"
+	"io"
+)
+
+// WithJSONArrays makes sections over lists separate their elements with commas in the JSON escape modes, when the
+// section is the only content of a JSON array in the text of its template, so that
+//
+//	{"users": [{{#users}}{"name": {{name}}, "admin": {{admin}}}{{/users}}]}
+//
+// renders valid JSON without {{^-last}},{{/-last}} after each element. Elements which write nothing but whitespace,
+// such as those skipped by a section within them, are left out along with their commas. Each element is written as it
+// is rendered, so arrays of millions of records are streamed to the writer rather than held in memory; with
+// JSONTemplate, an element written by {{.}} is the record marshaled by encoding/json.
+//
+// Only the brackets in the text around the section count, so a section beginning or ending a partial, or sharing its
+// array with other tags, is not separated.
+func (r *Compiler) WithJSONArrays(b bool) *Compiler {
+	r.jsonArrays = b
+	return r
+}
+
+// jsonSpace is the whitespace allowed between JSON values.
+const jsonSpace = " \t\r\n"
+
+// inJSONArray reports whether the section at elems[i] separates its elements with commas, as set with WithJSONArrays.
+func (tmpl *Template) inJSONArray(elems []interface{}, i int) bool {
+	if !tmpl.parent.jsonArrays || tmpl.outputMode != EscapeJSON && tmpl.outputMode != EscapeJSONValue {
+		return false
+	}
+	return adjacentByte(elems[:i], true) == '[' && adjacentByte(elems[i+1:], false) == ']'
+}
+
+// adjacentByte returns the last byte other than whitespace of the text ending elems, if last is set, or the first of
+// the text beginning them otherwise. It returns 0 if a tag other than a comment comes first.
+func adjacentByte(elems []interface{}, last bool) byte {
+	for k := range elems {
+		j := k
+		if last {
+			j = len(elems) - 1 - k
+		}
+		switch elem := elems[j].(type) {
+		case *commentElement:
+		case *textElement:
+			text := bytes.Trim(elem.text, jsonSpace)
+			if len(text) == 0 {
+				continue
+			}
+			if last {
+				return text[len(text)-1]
+			}
+			return text[0]
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// jsonArrayWriter separates the elements a section writes into a JSON array with commas. Whitespace is held back
+// until something else is written, so that a comma follows the element before it directly, and is written only once
+// the next element writes anything but whitespace.
+type jsonArrayWriter struct {
+	w      io.Writer
+	values bool   // whether an element has written a value
+	due    bool   // whether a comma is due before the next value
+	held   []byte // the whitespace written since the last value
+}
+
+// next begins the next element.
+func (aw *jsonArrayWriter) next() {
+	aw.due = aw.values
+}
+
+func (aw *jsonArrayWriter) Write(p []byte) (int, error) {
+	value := bytes.TrimRight(p, jsonSpace)
+	if len(value) == 0 {
+		aw.held = append(aw.held, p...)
+		return len(p), nil
+	}
+	var out []byte
+	if aw.due {
+		out = append(out, ',')
+	}
+	out = append(append(out, aw.held...), value...)
+	aw.due, aw.values = false, true
+	aw.held = append(aw.held[:0], p[len(value):]...)
+	if _, err := aw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// close writes the whitespace held back after the last value.
+func (aw *jsonArrayWriter) close() error {
+	if len(aw.held) == 0 {
+		return nil
+	}
+	_, err := aw.w.Write(aw.held)
+	aw.held = nil
+	return err
+}
@@ -0,0 +1,69 @@
+package mustache
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONArrays(t *testing.T) {
+	data := map[string]interface{}{
+		"users": []map[string]interface{}{{"name": "Ann", "admin": true}, {"name": "Bob", "admin": false}, {"name": "Cy", "admin": true}},
+		"none":  []interface{}{},
+		"tags":  []string{"a", "b"},
+	}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`[{{#users}}{"name": {{name}}}{{/users}}]`, `[{"name": "Ann"},{"name": "Bob"},{"name": "Cy"}]`},
+		// whitespace is kept, and the comma follows the element before it
+		{"[\n{{#users}}\n  {{name}}\n{{/users}}\n]", "[\n  \"Ann\",\n  \"Bob\",\n  \"Cy\"\n]"},
+		// elements which write nothing are left out
+		{`[{{#users}}{{#admin}}{{name}}{{/admin}}{{/users}}]`, `["Ann","Cy"]`},
+		{`[{{#none}}{{name}}{{/none}}]`, `[]`},
+		// nested arrays, and elements marshaled whole
+		{`{"users": [{{#users}}[{{#tags}}{{.}}{{/tags}}]{{/users}}]}`, `{"users": [["a","b"],["a","b"],["a","b"]]}`},
+		{`[{{#users}}{{.}}{{/users}}]`, `[{"admin":true,"name":"Ann"},{"admin":false,"name":"Bob"},{"admin":true,"name":"Cy"}]`},
+		// sections which do not fill an array, and sections in strings, are not separated
+		{`[{{#tags}}{{.}}{{/tags}}, "c"]`, `["a""b", "c"]`},
+		{`"{{#tags}}{{.}}{{/tags}}"`, `"ab"`},
+	}
+	for _, test := range tests {
+		tmpl, err := New().WithEscapeMode(EscapeJSONValue).WithStructuredValues(true).WithJSONArrays(true).CompileString(test.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Errorf("%q: %v", test.template, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %s, got %s", test.template, test.expected, output)
+		}
+	}
+
+	// the EscapeJSON mode, with streaming output
+	tmpl, err := New().WithEscapeMode(EscapeJSON).WithJSONArrays(true).CompileString(`[{{#users}}"{{name}}"{{/users}}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := tmpl.Frender(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(b.String()), &names); err != nil || len(names) != 3 {
+		t.Errorf("expected a JSON array of names, got %s, %v", b.String(), err)
+	}
+
+	// other modes, and compilers without the option, are unchanged
+	for _, cmpl := range []*Compiler{New().WithJSONArrays(true), New().WithEscapeMode(EscapeJSON)} {
+		tmpl, err := cmpl.CompileString(`[{{#tags}}{{.}}{{/tags}}]`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output, err := tmpl.Render(data); err != nil || output != "[ab]" {
+			t.Errorf("expected %q, got %q, %v", "[ab]", output, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package mustache
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// The rules of Template.JSONLint.
+const (
+	// RuleJSONUnquoted reports an escaped tag outside the string literals of a JSON template in a mode which escapes
+	// values for strings, such as EscapeJSON, where a value like `1, "admin": true` adds to the structure.
+	RuleJSONUnquoted = "json-unquoted"
+	// RuleJSONRaw reports a raw tag in a JSON template, or any tag in the Raw mode, which can add to the structure
+	// or end a string wherever it is.
+	RuleJSONRaw = "json-raw"
+	// RuleJSONEscapeMode reports a tag in a JSON string of a template in the HTML escape mode, which does not escape
+	// backslashes or control characters, so that a value ending in a backslash runs the string on.
+	RuleJSONEscapeMode = "json-escape-mode"
+	// RuleJSONQuotedValue reports a tag in a JSON string of a template in the EscapeJSONValue mode, which writes
+	// strings with their own quotes, so that the output is not valid JSON.
+	RuleJSONQuotedValue = "json-quoted-value"
+)
+
+// JSONLint checks the variable and helper tags of a template which produces JSON for uses which can produce invalid
+// JSON or let values change its structure, so that CI can reject such templates: tags outside string literals, unless
+// the template is in the EscapeJSONValue mode, raw tags, tags in strings of templates in the HTML mode, and tags in
+// strings of templates in the EscapeJSONValue mode, unless WithStructuredValues is set. Unlike SecurityLint, it checks
+// templates in every escape mode. The issues are returned in the order of the template.
+//
+// Strings are found from the text of the template alone, taking the contents of sections as if they were always
+// rendered once, and partials as if they were empty, so issues in partials are reported by linting the partials
+// themselves.
+func (tmpl *Template) JSONLint() []SecurityIssue {
+	skeleton, tags := tmpl.skeleton()
+	var issues []SecurityIssue
+	scanJSONStrings(skeleton, func(i int, inString bool) {
+		issue, raw := tagIssue(tags[i])
+		switch {
+		case raw || tmpl.outputMode == Raw:
+			issue.Rule, issue.Severity = RuleJSONRaw, SeverityHigh
+			issue.Message = "the value is written without escaping, so it can change the structure of the JSON"
+		case inString && tmpl.outputMode == EscapeHTML:
+			issue.Rule, issue.Severity = RuleJSONEscapeMode, SeverityHigh
+			issue.Message = "HTML escaping does not escape backslashes or control characters in JSON strings"
+		case inString && tmpl.outputMode == EscapeJSONValue && !tmpl.parent.structuredValues:
+			issue.Rule, issue.Severity = RuleJSONQuotedValue, SeverityMedium
+			issue.Message = "the value is written as a JSON value, with its own quotes, inside a string"
+		case !inString && tmpl.outputMode != EscapeJSONValue:
+			issue.Rule, issue.Severity = RuleJSONUnquoted, SeverityHigh
+			issue.Message = "the value is not in a string, so it can change the structure of the JSON"
+		default:
+			return
+		}
+		issues = append(issues, issue)
+	})
+	return issues
+}
+
+// scanJSONStrings scans src, in which each tag is replaced by its index between NUL bytes, and calls fn with the index
+// of each tag in order, and whether it is in a JSON string literal.
+func scanJSONStrings(src []byte, fn func(i int, inString bool)) {
+	inString := false
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == 0:
+			end := bytes.IndexByte(src[i+1:], 0)
+			if end < 0 {
+				return
+			}
+			n, _ := strconv.Atoi(string(src[i+1 : i+1+end]))
+			fn(n, inString)
+			i += end + 1
+		case c == '"':
+			inString = !inString
+		case c == '\\' && inString && i+1 < len(src) && src[i+1] != 0:
+			i++
+		}
+	}
+}
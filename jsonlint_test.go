@@ -0,0 +1,58 @@
+package mustache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONLint(t *testing.T) {
+	source := `{
+  "name": "{{name}}",
+  "age": {{age}},
+  "note": "say \"{{greeting}}\"",
+  {{#items}}"item": "{{{item}}}",{{/items}}
+  "count": {{plural n "x" "xs"}}
+}`
+	type issue struct {
+		Rule     string
+		Severity Severity
+		Tag      string
+		Line     int
+		Column   int
+	}
+	tests := []struct {
+		mode     EscapeMode
+		expected []issue
+	}{
+		{EscapeJSON, []issue{
+			{RuleJSONUnquoted, SeverityHigh, "age", 3, 10},
+			{RuleJSONRaw, SeverityHigh, "item", 5, 22},
+			{RuleJSONUnquoted, SeverityHigh, `plural n "x" "xs"`, 6, 12},
+		}},
+		{EscapeHTML, []issue{
+			{RuleJSONEscapeMode, SeverityHigh, "name", 2, 12},
+			{RuleJSONUnquoted, SeverityHigh, "age", 3, 10},
+			{RuleJSONEscapeMode, SeverityHigh, "greeting", 4, 18},
+			{RuleJSONRaw, SeverityHigh, "item", 5, 22},
+			{RuleJSONUnquoted, SeverityHigh, `plural n "x" "xs"`, 6, 12},
+		}},
+		{EscapeJSONValue, []issue{
+			{RuleJSONQuotedValue, SeverityMedium, "name", 2, 12},
+			{RuleJSONQuotedValue, SeverityMedium, "greeting", 4, 18},
+			{RuleJSONRaw, SeverityHigh, "item", 5, 22},
+		}},
+	}
+	for _, test := range tests {
+		tmpl, err := New().WithEscapeMode(test.mode).CompileString(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []issue
+		for _, i := range tmpl.JSONLint() {
+			actual = append(actual, issue{i.Rule, i.Severity, i.Tag, i.Line, i.Column})
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: expected\n%v\ngot\n%v", test.mode, test.expected, actual)
+		}
+	}
+}
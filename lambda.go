@@ -0,0 +1,160 @@
+package mustache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// WithLambdaTimeout limits the time each call of a lambda may take, so that a misbehaving lambda, such as one which
+// calls a slow service, cannot hang the whole render. A lambda which takes a context.Context as its first argument,
+//
+//	func(ctx context.Context, text string, render mustache.RenderFn) (string, error)
+//
+// is passed a context which is done once the timeout passes, and should return promptly. Other lambdas are left
+// running and their results discarded. A lambda which times out is treated as a missing value: its section renders
+// nothing, or the render fails with a *LambdaError wrapping ErrLambdaTimeout if errors are enabled with WithErrors.
+// A fallback set with WithLambdaFallback takes precedence over both. Either way the lambda's span records the timeout.
+// The default, zero, sets no limit.
+func (r *Compiler) WithLambdaTimeout(d time.Duration) *Compiler {
+	r.lambdaTimeout = d
+	return r
+}
+
+// WithLambdaFallback sets a function which gives the content written in place of a lambda section whose lambda fails,
+// by returning an error or by timing out, instead of the failure ending the render. The content is written as it is,
+// like the result of a lambda, so returning an empty string skips the section. Returning an error fails the render
+// with that error, which may be err itself, so that only some failures are recovered from. Without a fallback, a
+// lambda's error fails the render: Render returns a *LambdaError wrapping it, along with the output written before the
+// section. A lambda which times out is treated as WithLambdaTimeout describes.
+//
+// Failures while the render's own context is done, as when FrenderContext is cancelled, are not passed to the fallback.
+func (r *Compiler) WithLambdaFallback(fallback func(err *LambdaError) (string, error)) *Compiler {
+	r.lambdaFallback = fallback
+	return r
+}
+
+// callLambda calls the lambda of a section, writing its result to buf.
+func (tmpl *Template) callLambda(st *renderState, section *sectionElement, fn reflect.Value, contextChain []reflect.Value, buf io.Writer) error {
+	if err := spend(&st.spent.lambdaCalls, tmpl.parent.budget.lambdaCalls, ErrTooManyLambdaCalls); err != nil {
+		return err
+	}
+	var text bytes.Buffer
+	getSectionText(section.elems, &text)
+	ctx, span := tmpl.parent.startSpan(st.ctx, SpanLambda, Attribute{AttrLambda, section.name})
+	timeout := tmpl.parent.lambdaTimeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	// a lambda which times out is left running, and must not touch st once the render has moved on
+	var mu sync.Mutex
+	abandoned := false
+	render := func(text string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if abandoned {
+			return "", ErrLambdaTimeout
+		}
+		templ, err := tmpl.parent.compile(ctx, "", []byte(text))
+		if err != nil {
+			return "", err
+		}
+		// the lambda's result is indented as it is written, like the section text it replaces
+		var buf bytes.Buffer
+		// nor are the tags of the text it renders those of the template
+		saved, tags := st.indentation, st.tags
+		st.indentation, st.tags = indentation{}, nil
+		err = templ.renderTemplate(st, contextChain, &buf)
+		st.indentation, st.tags = saved, tags
+		if err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	in := []reflect.Value{reflect.ValueOf(text.String()), reflect.ValueOf(render)}
+	if t := fn.Type(); t.NumIn() > 0 && t.In(0) == contextType {
+		in = append([]reflect.Value{reflect.ValueOf(ctx)}, in...)
+	}
+
+	var res string
+	var err error
+	if timeout > 0 {
+		res, err = callWithTimeout(ctx, fn, in)
+		mu.Lock()
+		abandoned = true
+		mu.Unlock()
+		// the lambda may notice the timeout and return its error before callWithTimeout does
+		if err != nil && ctx.Err() != nil && st.ctx.Err() == nil {
+			err = fmt.Errorf("did not return within %s: %w", timeout, ErrLambdaTimeout)
+			span.SetAttributes(Attribute{AttrTimedOut, true})
+			span.End(err)
+			lerr := &LambdaError{Name: section.name, Line: section.startline, Err: err}
+			if tmpl.parent.lambdaFallback != nil {
+				return tmpl.lambdaFallback(st, lerr, buf)
+			}
+			if tmpl.errorOnMissing {
+				return lerr
+			}
+			return nil
+		}
+	} else {
+		res, err = lambdaResult(fn.Call(in))
+	}
+	span.End(err)
+	if err != nil {
+		lerr := &LambdaError{Name: section.name, Line: section.startline, Err: err}
+		// a cancelled render fails however its lambdas fail
+		if tmpl.parent.lambdaFallback != nil && st.ctx.Err() == nil {
+			return tmpl.lambdaFallback(st, lerr, buf)
+		}
+		return lerr
+	}
+	return st.writeText(buf, []byte(res))
+}
+
+// lambdaFallback writes the content the fallback set with WithLambdaFallback gives in place of a failed lambda.
+func (tmpl *Template) lambdaFallback(st *renderState, lerr *LambdaError, buf io.Writer) error {
+	res, err := tmpl.parent.lambdaFallback(lerr)
+	if err != nil {
+		return err
+	}
+	return st.writeText(buf, []byte(res))
+}
+
+// callWithTimeout calls a lambda, returning early with the context's error if ctx is done first.
+func callWithTimeout(ctx context.Context, fn reflect.Value, in []reflect.Value) (string, error) {
+	type result struct {
+		data string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		defer func() { done <- res }()
+		// a panic here could not be recovered by the caller
+		defer recoverInternal(&res.err, "calling a lambda")
+		res.data, res.err = lambdaResult(fn.Call(in))
+	}()
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// lambdaResult returns the results of a call of a lambda.
+func lambdaResult(res []reflect.Value) (string, error) {
+	if !res[1].IsNil() {
+		return res[0].String(), res[1].Interface().(error)
+	}
+	return res[0].String(), nil
+}
@@ -0,0 +1,127 @@
+package mustache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLambdaTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	data := map[string]interface{}{
+		"slow": func(text string, render RenderFn) (string, error) {
+			<-release
+			return "late", nil
+		},
+		"aware": func(ctx context.Context, text string, render RenderFn) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+		"fast": func(ctx context.Context, text string, render RenderFn) (string, error) {
+			return render(text)
+		},
+		"name": "world",
+	}
+
+	tracer := &recordingTracer{}
+	cmpl := New().WithLambdaTimeout(20 * time.Millisecond).WithTracer(tracer)
+	tmpl, err := cmpl.CompileString("[{{#slow}}x{{/slow}}][{{#aware}}y{{/aware}}][{{#fast}}hello {{name}}{{/fast}}]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tmpl.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "[][][hello world]" {
+		t.Errorf("got %q", out)
+	}
+	var timedOut int
+	for _, span := range tracer.spans {
+		if span.attrs[AttrTimedOut] == true {
+			timedOut++
+			if !errors.Is(span.err, ErrLambdaTimeout) {
+				t.Errorf("span of %v ended with %v", span.attrs[AttrLambda], span.err)
+			}
+		}
+	}
+	if timedOut != 2 {
+		t.Errorf("%d spans record a timeout, want 2", timedOut)
+	}
+
+	tmpl, err = New().WithLambdaTimeout(20 * time.Millisecond).WithErrors(true).CompileString("{{#slow}}x{{/slow}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(data); !errors.Is(err, ErrLambdaTimeout) {
+		t.Errorf("got %v, want ErrLambdaTimeout", err)
+	}
+
+	// a render which is canceled fails with its own error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tmpl.RenderContext(ctx, data); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestLambdaFallback(t *testing.T) {
+	failure := errors.New("service unavailable")
+	data := map[string]interface{}{
+		"broken": func(text string, render RenderFn) (string, error) {
+			return "", failure
+		},
+		"fatal": func(text string, render RenderFn) (string, error) {
+			return "", errors.New("fatal")
+		},
+		"slow": func(ctx context.Context, text string, render RenderFn) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	}
+	var failed []string
+	cmpl := New().WithLambdaTimeout(20 * time.Millisecond).WithLambdaFallback(func(err *LambdaError) (string, error) {
+		failed = append(failed, err.Name)
+		switch {
+		case errors.Is(err, failure):
+			return "", nil
+		case errors.Is(err, ErrLambdaTimeout):
+			return "(pending)", nil
+		}
+		return "", err
+	})
+
+	tmpl, err := cmpl.CompileString("a[{{#broken}}x{{/broken}}]b[{{#slow}}y{{/slow}}]c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tmpl.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "a[]b[(pending)]c" {
+		t.Errorf("got %q", out)
+	}
+	if got := strings.Join(failed, ","); got != "broken,slow" {
+		t.Errorf("fallback called for %s", got)
+	}
+
+	tmpl, err = cmpl.CompileString("a\n{{#fatal}}x{{/fatal}}b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = tmpl.Render(data)
+	var lerr *LambdaError
+	if !errors.As(err, &lerr) || lerr.Name != "fatal" || lerr.Line != 2 {
+		t.Fatalf("got %v, want a *LambdaError for fatal on line 2", err)
+	}
+	if err.Error() != `line 2: lambda "fatal": fatal` {
+		t.Errorf("got error %q", err)
+	}
+	if out != "a\n" {
+		t.Errorf("got %q", out)
+	}
+}
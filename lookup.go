@@ -0,0 +1,54 @@
+//go:build !mustache_light
+
+package mustache
+
+import "reflect"
+
+// lookupValue resolves a name against one context, as a method, struct field or map key, evaluating any chain of
+// interfaces and pointers on the way, and reports whether it was found.
+func lookupValue(v reflect.Value, name string) (reflect.Value, bool) {
+	for v.IsValid() {
+		if isNil(v) {
+			// methods are not called on nil receivers, which mostly can't handle them
+			if name == "." {
+				return v, true
+			}
+			return reflect.Value{}, false
+		}
+		if value, found, ok := lookupName(v, name); ok {
+			return value, found
+		}
+		typ := v.Type()
+		if n := v.Type().NumMethod(); n > 0 {
+			for j := 0; j < n; j++ {
+				m := typ.Method(j)
+				mtyp := m.Type
+				if m.Name == name && mtyp.NumIn() == 1 {
+					return v.Method(j).Call(nil)[0], true
+				}
+			}
+		}
+		if name == "." {
+			return v, true
+		}
+		switch av := v; av.Kind() {
+		case reflect.Ptr:
+			v = av.Elem()
+		case reflect.Interface:
+			v = av.Elem()
+		case reflect.Struct:
+			ret := av.FieldByName(name)
+			return ret, ret.IsValid()
+		case reflect.Map:
+			key, ok := mapKey(av.Type().Key(), name)
+			if !ok {
+				return reflect.Value{}, false
+			}
+			ret := av.MapIndex(key)
+			return ret, ret.IsValid()
+		default:
+			return reflect.Value{}, false
+		}
+	}
+	return reflect.Value{}, false
+}
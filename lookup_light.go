@@ -0,0 +1,37 @@
+//go:build mustache_light
+
+package mustache
+
+import "reflect"
+
+// The mustache_light build tag restricts the contexts templates are rendered with to maps with string keys, such as
+// map[string]interface{}, slices, and values of basic types, as decoded from JSON. Names are not resolved to methods
+// or struct fields, so rendering neither scans method sets nor calls methods, which TinyGo and small runtimes support
+// poorly or not at all. Functions in maps are still called as lambdas.
+
+// lookupValue resolves a name against one context, as a map key, evaluating interfaces and pointers on the way, and
+// reports whether it was found.
+func lookupValue(v reflect.Value, name string) (reflect.Value, bool) {
+	for v.IsValid() {
+		if value, found, ok := lookupName(v, name); ok {
+			return value, found
+		}
+		if name == "." {
+			return v, true
+		}
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			v = v.Elem()
+		case reflect.Map:
+			key, ok := mapKey(v.Type().Key(), name)
+			if !ok {
+				return reflect.Value{}, false
+			}
+			ret := v.MapIndex(key)
+			return ret, ret.IsValid()
+		default:
+			return reflect.Value{}, false
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -0,0 +1,45 @@
+//go:build mustache_light
+
+package mustache
+
+import "testing"
+
+const lightBuild = true
+
+type lightKey string
+
+type lightStruct struct {
+	Name string
+}
+
+func (lightStruct) Title() string {
+	return "method"
+}
+
+func TestLightLookup(t *testing.T) {
+	tests := []struct {
+		tmpl     string
+		data     interface{}
+		expected string
+	}{
+		{"{{a.b}} {{#list}}{{.}},{{/list}}", map[string]interface{}{"a": map[string]interface{}{"b": 1.5}, "list": []interface{}{"x", true}}, "1.5 x,true,"},
+		{"{{a}}", map[lightKey]string{"a": "named keys"}, "named keys"},
+		{"{{a}}", &map[string]int{"a": 1}, "1"},
+		{"[{{a}}]", map[int]string{1: "x"}, "[]"},
+		{"[{{Name}}{{Title}}]", lightStruct{Name: "field"}, "[]"},
+		{"{{#s}}[{{Name}}]{{/s}}", map[string]interface{}{"s": lightStruct{Name: "field"}}, "[]"},
+		{"{{#f}}x{{/f}}", map[string]interface{}{"f": func(text string, render RenderFn) (string, error) { return "<" + text + ">", nil }}, "<x>"},
+	}
+	for _, test := range tests {
+		tmpl, err := New().CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(test.data)
+		if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.tmpl, test.expected, output)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !mustache_light
+
+package mustache
+
+// lightBuild is set in the mustache_light build, which doesn't resolve names to struct fields or methods.
+const lightBuild = false
@@ -0,0 +1,116 @@
+package mustache
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Conflict is a name which more than one of the contexts given to MergeContexts hold, with values which could not be
+// merged.
+type Conflict struct {
+	Path    string      // the path of the name, such as "user.name"
+	Context int         // the position among the arguments of the context whose value was dropped
+	Kept    interface{} // the value of the merged context, from an earlier argument
+	Dropped interface{} // the value which was dropped
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s: context %d has %#v, kept %#v", c.Path, c.Context, c.Dropped, c.Kept)
+}
+
+// MergeContexts merges contexts into a single map, for logging or inspecting the data a template sees when it is
+// rendered with several contexts, as in tmpl.Render(page, user, site). Earlier contexts take precedence, as they do
+// in Render. Maps and structs are merged deeply: the exported fields of structs and the keys of maps become keys of
+// the merged maps, and when two contexts both hold a map or struct under a name, their contents are merged in turn.
+// So the merged map may hold more than Render finds, as Render looks user.theme up only in the first context holding
+// user.
+// Any other value under a name which an earlier context also holds is dropped and reported as a conflict, unless the
+// two values are equal. The conflicts are sorted by path.
+//
+// Pointers and interfaces are followed. Contexts which are neither maps nor structs, such as NameLookupers, whose
+// names can't be listed, are left out, as are the methods of structs. Values which are not merged are not copied, so
+// the merged map refers to the same slices and maps as the contexts.
+func MergeContexts(contexts ...interface{}) (map[string]interface{}, []Conflict) {
+	merged := make(map[string]interface{})
+	var conflicts []Conflict
+	for i, c := range contexts {
+		mergeInto(merged, reflect.ValueOf(c), "", i, &conflicts, 0)
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool { return conflicts[i].Path < conflicts[j].Path })
+	return merged, conflicts
+}
+
+// maxMergeDepth bounds the nesting merged by MergeContexts, as values may refer to themselves.
+const maxMergeDepth = 64
+
+// mergeInto adds the names held by v, a map or struct from the context at position i, to merged.
+func mergeInto(merged map[string]interface{}, v reflect.Value, path string, i int, conflicts *[]Conflict, depth int) {
+	forEachName(v, func(name string, value reflect.Value) {
+		p := joinPath(path, name)
+		existing, ok := merged[name]
+		if !ok {
+			if depth < maxMergeDepth && isMergeable(value) {
+				m := make(map[string]interface{})
+				mergeInto(m, value, p, i, conflicts, depth+1)
+				merged[name] = m
+				return
+			}
+			merged[name] = valueInterface(value)
+			return
+		}
+		if m, ok := existing.(map[string]interface{}); ok && depth < maxMergeDepth && isMergeable(value) {
+			mergeInto(m, value, p, i, conflicts, depth+1)
+			return
+		}
+		dropped := valueInterface(value)
+		if !reflect.DeepEqual(existing, dropped) {
+			*conflicts = append(*conflicts, Conflict{Path: p, Context: i, Kept: existing, Dropped: dropped})
+		}
+	})
+}
+
+// isMergeable reports whether v is a map or struct, once pointers and interfaces are followed.
+func isMergeable(v reflect.Value) bool {
+	for _, v := range []reflect.Value{v, indirect(v)} {
+		if !v.IsValid() || !v.CanInterface() {
+			return false
+		}
+		if _, ok := v.Interface().(NameLookuper); ok {
+			return false
+		}
+	}
+	v = indirect(v)
+	return v.Kind() == reflect.Map || v.Kind() == reflect.Struct
+}
+
+// forEachName calls fn with each key of a map, or each exported field of a struct, in v.
+func forEachName(v reflect.Value, fn func(name string, value reflect.Value)) {
+	if !isMergeable(v) {
+		return
+	}
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			fn(fmt.Sprint(key.Interface()), v.MapIndex(key))
+		}
+	case reflect.Struct:
+		for _, f := range reflect.VisibleFields(v.Type()) {
+			if !f.IsExported() {
+				continue
+			}
+			if field, err := v.FieldByIndexErr(f.Index); err == nil {
+				fn(f.Name, field)
+			}
+		}
+	}
+}
+
+// valueInterface returns the value held by v, or nil if it is not valid.
+func valueInterface(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
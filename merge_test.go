@@ -0,0 +1,53 @@
+package mustache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeContexts(t *testing.T) {
+	type user struct {
+		Name  string
+		Admin bool
+		Tags  []string
+		note  string
+	}
+	page := map[string]interface{}{
+		"title": "Home",
+		"user":  map[string]interface{}{"Name": "Jo", "theme": "dark"},
+	}
+	site := map[string]interface{}{
+		"title": "Example",
+		"lang":  "en",
+		"user":  &user{Name: "Anonymous", Tags: []string{"a"}, note: "hidden"},
+	}
+	merged, conflicts := MergeContexts(page, nil, site, map[string]string{"lang": "en"}, Values{"q": {"x"}})
+	expected := map[string]interface{}{
+		"title": "Home",
+		"lang":  "en",
+		"user":  map[string]interface{}{"Name": "Jo", "theme": "dark", "Admin": false, "Tags": []string{"a"}},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %v, got %v", expected, merged)
+	}
+	expectedConflicts := []Conflict{
+		{Path: "title", Context: 2, Kept: "Home", Dropped: "Example"},
+		{Path: "user.Name", Context: 2, Kept: "Jo", Dropped: "Anonymous"},
+	}
+	if !reflect.DeepEqual(conflicts, expectedConflicts) {
+		t.Errorf("expected conflicts %v, got %v", expectedConflicts, conflicts)
+	}
+	if _, ok := page["user"].(map[string]interface{})["Admin"]; ok {
+		t.Error("expected the contexts not to be modified")
+	}
+
+	tmpl, err := New().CompileString("{{title}} {{lang}} {{user.Name}} {{user.theme}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromMerged, _ := tmpl.Render(merged)
+	fromChain, _ := tmpl.Render(page, site)
+	if fromMerged != fromChain {
+		t.Errorf("expected the merged context to render as the contexts do, got %q and %q", fromMerged, fromChain)
+	}
+}
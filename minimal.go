@@ -0,0 +1,11 @@
+//go:build mustache_minimal
+
+package mustache
+
+// The mustache_minimal build tag leaves out the parts of the package which read templates from files, the network or
+// archives: CompileFile, FileProvider, HTTPProvider, FrenderHTTP, bundles and watchers. It suits WebAssembly builds
+// for the browser, which only need to compile templates from strings and render them, and are smaller without the
+// file, net/http, archive and regexp packages.
+
+// bundleOptions is empty, as bundles are left out.
+type bundleOptions struct{}
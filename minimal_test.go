@@ -0,0 +1,74 @@
+//go:build go1.21
+
+package mustache
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestMinimalBuild checks that the mustache_minimal build, and the core of the package for WebAssembly, stay free of
+// the packages they are meant to leave out, and runs the tests of the minimal build. The spec tests, which need the
+// spec submodule and use nothing the minimal build leaves out, are left to the default one. Building for wasip1 needs Go
+// 1.21, as do the tests in this file.
+func TestMinimalBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is not available")
+	}
+	excluded := []string{"archive/tar", "archive/zip", "crypto/ed25519", "html/template", "net/http", "net/url",
+		"path/filepath", "regexp"}
+	cmd := exec.Command(gobin, "list", "-tags", "mustache_minimal", "-deps", ".")
+	cmd.Env = append(cmd.Environ(), "GOOS=js", "GOARCH=wasm")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	deps := make(map[string]bool)
+	for _, dep := range strings.Fields(string(output)) {
+		deps[dep] = true
+	}
+	for _, pkg := range excluded {
+		if deps[pkg] {
+			t.Errorf("the minimal build imports %s", pkg)
+		}
+	}
+
+	cmd = exec.Command(gobin, "test", "-short", "-tags", "mustache_minimal", "-skip", "^TestSpec$", ".")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("%v: %s", err, output)
+	}
+
+	for _, goos := range []string{"js", "wasip1"} {
+		cmd := exec.Command(gobin, "build", ".")
+		cmd.Env = append(cmd.Environ(), "GOOS="+goos, "GOARCH=wasm")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("GOOS=%s: %v: %s", goos, err, output)
+		}
+	}
+}
+
+// TestLightBuild runs the tests in the mustache_light build, in which those which render structs or call methods are
+// skipped, and those of its lookups, which are left out of the default one, are run.
+func TestLightBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is not available")
+	}
+	cmd := exec.Command(gobin, "test", "-short", "-tags", "mustache_light", "-skip", "^TestSpec$", ".")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("%v: %s", err, output)
+	}
+	cmd = exec.Command(gobin, "build", "-tags", "mustache_minimal,mustache_light", ".")
+	cmd.Env = append(cmd.Environ(), "GOOS=js", "GOARCH=wasm")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("%v: %s", err, output)
+	}
+}
@@ -2,14 +2,15 @@ package mustache
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"io"
-	"os"
+	"path"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -21,22 +22,67 @@ func toJSONString(data any) (string, error) {
 	return string(out), nil
 }
 
+// JSONTemplate compiles a template which produces JSON, using the EscapeJSONValue escape mode so that each variable
+// is emitted as a JSON value of the appropriate type, WithStructuredValues so that variables in strings are emitted as
+// their contents, and ValidateJSON to check the rendered output.
 func JSONTemplate(template string) (*Template, error) {
-	return New().WithEscapeMode(Raw).WithValueStringer(toJSONString).CompileString(template)
+	return New().WithEscapeMode(EscapeJSONValue).WithStructuredValues(true).WithPostValidator(ValidateJSON).CompileString(template)
 }
 
 // RenderFn is the signature of a function which can be called from a lambda section
 type RenderFn func(text string) (string, error)
 
 type Compiler struct {
-	partial        PartialProvider
-	outputMode     EscapeMode
-	valueStringer  ValueStringer
-	errorOnMissing bool
+	partial          PartialProvider
+	outputMode       EscapeMode
+	valueStringer    ValueStringer
+	errorOnMissing   bool
+	maxTemplateBytes int
+	maxSectionDepth  int
+	partialCache     bool
+	partialMiss      func(name string)
+	tagStringers     map[StringerTarget]ValueStringer
+	modeStringers    map[EscapeMode]ValueStringer
+	postValidator    func([]byte) error
+	postProcessors   []func([]byte) ([]byte, error)
+	parseTrace       io.Writer
+	transforms       []func([]ASTNode) []ASTNode
+	sectionIterators []func(any) ([]any, bool)
+	helpers          map[string]Helper
+	tagHandlers      map[rune]TagHandler
+	compiledPartials *compiledPartials
+	usageReport      *UsageReport
+	defines          map[string]bool
+	constants        map[string]string
+	numericSections  bool
+	structuredValues bool
+	expressions      bool
+	progress         progressOptions
+	snapshots        snapshotOptions
+	lambdaTimeout    time.Duration
+	lambdaFallback   func(*LambdaError) (string, error)
+	rawSanitizer     Sanitizer
+	metadata         Metadata
+	auditHook        func(context.Context, AuditEvent)
+	deterministic    bool
+	encodings        []Encoding
+	charset          Charset
+	unmappable       Unmappable
+	strictReserved   bool
+	strictParsing    bool
+	renderSummary    func(RenderSummary)
+	tracer           Tracer
+	bundle           bundleOptions
+	syntax           Syntax
+	watermark        func(WatermarkInfo) string
+	capClosingTags   bool
+	frozenContext    bool
+	budget           renderBudget
+	jsonArrays       bool
 }
 
 func New() *Compiler {
-	return &Compiler{}
+	return &Compiler{compiledPartials: newCompiledPartials()}
 }
 
 // WithPartials adds a partial provider and enables support for partials.
@@ -52,6 +98,28 @@ func (r *Compiler) WithValueStringer(vs ValueStringer) *Compiler {
 	return r
 }
 
+// WithTagValueStringer sets a function to convert values to strings for one kind of tag only. It takes precedence over
+// stringers set with WithValueStringer and WithEscapeModeValueStringer. Unlike those, a stringer for RawVariable tags
+// applies to triple mustache and ampersand tags, which otherwise always use fmt.
+func (r *Compiler) WithTagValueStringer(target StringerTarget, vs ValueStringer) *Compiler {
+	if r.tagStringers == nil {
+		r.tagStringers = make(map[StringerTarget]ValueStringer)
+	}
+	r.tagStringers[target] = vs
+	return r
+}
+
+// WithEscapeModeValueStringer sets a function to convert values to strings in escaped variable tags, used only when
+// templates are escaped with the given mode. It takes precedence over the stringer set with WithValueStringer, so the
+// same compiler configuration can, for instance, marshal values to JSON in JSON templates and use fmt in HTML ones.
+func (r *Compiler) WithEscapeModeValueStringer(m EscapeMode, vs ValueStringer) *Compiler {
+	if r.modeStringers == nil {
+		r.modeStringers = make(map[EscapeMode]ValueStringer)
+	}
+	r.modeStringers[m] = vs
+	return r
+}
+
 // WithEscapeMode sets the output mode to either HTML, JSON or raw (plain text).
 // The default is HTML.
 func (r *Compiler) WithEscapeMode(m EscapeMode) *Compiler {
@@ -60,30 +128,167 @@ func (r *Compiler) WithEscapeMode(m EscapeMode) *Compiler {
 }
 
 // WithErrors enables errors when there is a missing data object referred to by the template, a missing partial,
-// or a missing partial provider to handle a partial. Otherwise, errors are ignored and result in empty strings in the
-// output.
+// or a missing partial provider to handle a partial. Otherwise, these are ignored and result in empty strings in the
+// output. Other errors from a PartialProvider, and errors compiling partials, are always returned. Missing variables
+// and partials are reported as a *RenderError, which locates the tag.
 func (r *Compiler) WithErrors(b bool) *Compiler {
 	r.errorOnMissing = b
 	return r
 }
 
+// WithReservedNames makes the names -first, -last, -index and -index0 strictly reserved for the position of the current
+// list element. Rendering a template which refers to one of them then fails if the data also has a field, method or
+// map key of that name, rather than the data being silently shadowed inside lists and used outside them. Either way,
+// prefixing a name with a dot, as in {{.-index}}, refers to the data explicitly.
+func (r *Compiler) WithReservedNames(strict bool) *Compiler {
+	r.strictReserved = strict
+	return r
+}
+
+// WithStrictParsing makes malformed tags which are otherwise ignored parse errors: set delimiter tags, such as
+// {{=<% %>=}}, which do not hold exactly two delimiters separated by whitespace, or whose delimiters contain '='.
+// Without it, such a tag is skipped and the template goes on being parsed with the delimiters it had.
+func (r *Compiler) WithStrictParsing(b bool) *Compiler {
+	r.strictParsing = b
+	return r
+}
+
+// WithMaxTemplateBytes limits the size of the templates and partials the compiler will accept. Compiling a larger
+// template returns a *LimitError wrapping ErrTemplateTooLarge. A value of zero or less means no limit.
+func (r *Compiler) WithMaxTemplateBytes(n int) *Compiler {
+	r.maxTemplateBytes = n
+	return r
+}
+
+// WithMaxSectionDepth limits how deeply sections may be nested in the templates and partials the compiler will
+// accept. Compiling a template with deeper nesting returns a *LimitError wrapping ErrSectionTooDeep. A value of zero
+// or less means no limit.
+func (r *Compiler) WithMaxSectionDepth(n int) *Compiler {
+	r.maxSectionDepth = n
+	return r
+}
+
+// WithPartialCache enables caching of rendered partials for the duration of a single render. A partial is rendered
+// once for each distinct combination of values of the names it refers to, and its output is reused when it is
+// included again, for instance in each iteration of a section. Since lambdas and methods are assumed to return the
+// same result each time they are called, only enable this when that holds. Partials which include other partials are
+// never cached.
+func (r *Compiler) WithPartialCache(b bool) *Compiler {
+	r.partialCache = b
+	return r
+}
+
+// WithPartialMissHandler sets a function which is called with the name of each partial found missing while rendering
+// without WithErrors, where the partial is otherwise left out of the output without a trace, so that production
+// systems can count and alert on partial misses without failing renders. It is also called when there is no partial
+// provider, and may be called from concurrent renders. Providers which treat missing partials as empty, such as
+// StaticProvider without ReportMissing, report no misses.
+func (r *Compiler) WithPartialMissHandler(h func(name string)) *Compiler {
+	r.partialMiss = h
+	return r
+}
+
 // CompileString compiles a Mustache template from a string.
 func (r *Compiler) CompileString(data string) (*Template, error) {
-	tmpl := Template{data, "{{", "}}", 0, 1, []interface{}{}, false, r.partial, r.outputMode, r.valueStringer, r.errorOnMissing, r}
-	err := tmpl.parse()
+	return r.CompileBytes([]byte(data))
+}
+
+// CompileBytes compiles a Mustache template from a byte slice. The compiled template refers to the text in data
+// directly rather than copying it, so data must not be modified after it has been passed to CompileBytes.
+func (r *Compiler) CompileBytes(data []byte) (*Template, error) {
+	return r.compile(context.Background(), "", data)
+}
+
+// CompileReader compiles a Mustache template read from an io.Reader, such as a network connection.
+func (r *Compiler) CompileReader(rd io.Reader) (*Template, error) {
+	data, err := r.readTemplate(rd)
 	if err != nil {
 		return nil, err
 	}
-	return &tmpl, nil
+	return r.CompileBytes(data)
+}
+
+func (r *Compiler) readTemplate(rd io.Reader) ([]byte, error) {
+	return readLimited(rd, r.maxTemplateBytes)
+}
+
+// readLimited reads all of rd, or if limit is positive, at most one byte more than limit, so that oversized input is
+// detected without reading all of it.
+func readLimited(rd io.Reader, limit int) ([]byte, error) {
+	if limit > 0 {
+		rd = io.LimitReader(rd, int64(limit)+1)
+	}
+	return io.ReadAll(rd)
+}
+
+// compile compiles a template with the given name, within a span which is a child of any span held by ctx.
+func (r *Compiler) compile(ctx context.Context, name string, data []byte) (*Template, error) {
+	_, span := r.startSpan(ctx, SpanCompile, Attribute{AttrTemplate, name}, Attribute{AttrSize, len(data)})
+	tmpl, err := r.parse(name, data)
+	if err == nil {
+		span.SetAttributes(Attribute{AttrPartials, countPartials(tmpl.elems)}, Attribute{AttrEscapeMode, tmpl.outputMode.String()})
+	}
+	span.End(err)
+	return tmpl, err
 }
 
-// CompileFile compiles a Mustache template from a file.
-func (r *Compiler) CompileFile(filename string) (*Template, error) {
-	data, err := os.ReadFile(filename)
+// CompileStringLenient compiles a template as CompileString does, but rather than stopping at the first parse error, it
+// records the error and continues after the tag which caused it, for tools such as editors which need the structure
+// of templates while they are being written. Sections which are not closed are closed at the end of the template,
+// and a closing tag for an outer section closes the sections opened within it. It returns the best effort template
+// and the parse errors, in order; the template is nil only if the source exceeds the size limit.
+func (r *Compiler) CompileStringLenient(data string) (*Template, []error) {
+	tmpl, err := r.newTemplate("", []byte(data))
 	if err != nil {
+		return nil, []error{err}
+	}
+	tmpl.lenient = true
+	if err := tmpl.parse(); err != nil {
+		tmpl.parseErrors = append(tmpl.parseErrors, err)
+	}
+	tmpl.lenient = false
+	tmpl.foldDefines()
+	tmpl.expandConstants()
+	errs := tmpl.parseErrors
+	tmpl.parseErrors = nil
+	return tmpl, errs
+}
+
+func (r *Compiler) parse(name string, data []byte) (*Template, error) {
+	tmpl, err := r.newTemplate(name, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.parse(); err != nil {
+		return nil, err
+	}
+	tmpl.foldDefines()
+	tmpl.expandConstants()
+	if err := tmpl.transform(); err != nil {
 		return nil, err
 	}
-	return r.CompileString(string(data))
+	return tmpl, nil
+}
+
+// newTemplate returns a template for the source in data, ready to be parsed.
+func (r *Compiler) newTemplate(name string, data []byte) (*Template, error) {
+	if r.maxTemplateBytes > 0 && len(data) > r.maxTemplateBytes {
+		return nil, &LimitError{Err: ErrTemplateTooLarge, Max: r.maxTemplateBytes}
+	}
+	return &Template{
+		data:           data,
+		otag:           "{{",
+		ctag:           "}}",
+		p:              0,
+		curline:        1,
+		elems:          []interface{}{},
+		partial:        r.partial,
+		outputMode:     r.outputMode,
+		valueStringer:  r.valueStringer,
+		errorOnMissing: r.errorOnMissing,
+		parent:         r,
+		name:           name,
+	}, nil
 }
 
 // A TagType represents the specific type of mustache tag that a Tag
@@ -97,12 +302,20 @@ const (
 	Section
 	InvertedSection
 	Partial
+	Parent
+	Block
+	// The types below are reported only by Template.Walk.
+	Text
+	Comment
+	HelperTag
+	Expression
+	CustomTag
 )
 
 // Skip all whitespaces apeared after these types of tags until end of line
 // if the line only contains a tag and whitespaces.
 const (
-	SkipWhitespaceTagTypes = "#^/<>=!"
+	SkipWhitespaceTagTypes = "#^/<>=!%$"
 )
 
 func (t TagType) String() string {
@@ -118,6 +331,13 @@ var tagNames = []string{
 	Section:         "Section",
 	InvertedSection: "InvertedSection",
 	Partial:         "Partial",
+	Parent:          "Parent",
+	Block:           "Block",
+	Text:            "Text",
+	Comment:         "Comment",
+	HelperTag:       "HelperTag",
+	Expression:      "Expression",
+	CustomTag:       "CustomTag",
 }
 
 // Tag represents the different mustache tag types.
@@ -134,6 +354,13 @@ type Tag interface {
 	// Tags returns any child tags. It panics for tag types which cannot contain
 	// child tags (i.e. variable tags).
 	Tags() []Tag
+	// Line returns the line of the tag, counting from 1, or 0 if it is not known, as for tags built from an AST by
+	// CompileJSON or WithTransform.
+	Line() int
+	// Column returns the column of the tag within its line, counting bytes from 1.
+	Column() int
+	// ByteOffset returns the offset of the tag's opening delimiter in the template's source, or 0 if it is not known.
+	ByteOffset() int
 }
 
 type textElement struct {
@@ -141,40 +368,103 @@ type textElement struct {
 }
 
 type varElement struct {
-	name string
-	raw  bool
+	name   string
+	raw    bool
+	line   int // the line of the tag, or 0 if it was not compiled from source
+	col    int // the column of the tag, counting bytes from 1
+	offset int // the offset of the tag in the source
 }
 
 type sectionElement struct {
-	name      string
-	inverted  bool
-	startline int
-	elems     []interface{}
+	name        string
+	inverted    bool
+	startline   int // the line of the opening tag
+	startcol    int // the column of the opening tag, counting bytes from 1
+	startoffset int // the offset of the opening tag in the source
+	elems       []interface{}
+	// cond sections render their elements once with the current context if their value is not empty, rather than
+	// pushing the value, as for Handlebars' if helper
+	cond bool
+	// closer is the name expected in the closing tag, if it differs from name, as for Handlebars' block helpers
+	closer string
+	// elseBranch is set for the section begun by a Handlebars {{else}} tag
+	elseBranch bool
+	// sigil is set for parent and block tags, which are parsed as sections, to parentSigil or blockSigil
+	sigil byte
+	// indent is the indentation of a standalone parent or block tag
+	indent string
+	// prov provides the partial of a parent tag
+	prov PartialProvider
 }
 
 type partialElement struct {
 	name   string
 	indent string
 	prov   PartialProvider
+	// context and params are the context and hash parameters of a Handlebars partial tag, which are pushed onto the
+	// context chain while the partial is rendered
+	context string
+	params  []partialParam
+	// raw is set for a partial tag such as {{>site.css raw}}, which includes the partial verbatim
+	raw    bool
+	line   int // the line of the tag, or 0 if it was not compiled from source
+	col    int // the column of the tag, counting bytes from 1
+	offset int // the offset of the tag in the source
 }
 
 type ValueStringer func(any any) (string, error)
 
+// StringerTarget identifies a kind of tag for WithTagValueStringer.
+type StringerTarget int
+
+const (
+	EscapedVariable  StringerTarget = iota // Escaped variable tags, such as {{name}}
+	RawVariable                            // Unescaped variable tags, such as {{{name}}} and {{&name}}
+	ImplicitIterator                       // The implicit iterator {{.}}, escaped or not, typically a scalar in a section
+)
+
 // EscapeMode indicates what sort of escaping to perform in template output.
 // EscapeHTML is the default, and assumes the template is producing HTML.
 // EscapeJSON switches to JSON escaping, for use cases such as generating Slack messages.
 // Raw turns off escaping, for situations where you are absolutely sure you want plain text.
+// EscapeJSONValue emits each escaped variable as a complete JSON value, so strings are quoted while numbers and
+// booleans are bare, and missing values are emitted as null; template authors don't need to place quotes by type.
 type EscapeMode int
 
 const (
-	EscapeHTML EscapeMode = iota // Escape output as HTML (default)
-	EscapeJSON                   // Escape output as JSON
-	Raw                          // Do not escape output (plain text mode)
+	EscapeHTML      EscapeMode = iota // Escape output as HTML (default)
+	EscapeJSON                        // Escape output as JSON
+	Raw                               // Do not escape output (plain text mode)
+	EscapeJSONValue                   // Marshal output as JSON values
 )
 
+var escapeModeNames = []string{
+	EscapeHTML:      "HTML",
+	EscapeJSON:      "JSON",
+	Raw:             "RAW",
+	EscapeJSONValue: "JSONVALUE",
+}
+
+func (m EscapeMode) String() string {
+	if int(m) < len(escapeModeNames) {
+		return escapeModeNames[m]
+	}
+	return "mode" + strconv.Itoa(int(m))
+}
+
+// parseEscapeMode returns the escape mode with the given name, as returned by EscapeMode.String, ignoring case.
+func parseEscapeMode(name string) (EscapeMode, bool) {
+	for m, n := range escapeModeNames {
+		if strings.EqualFold(n, name) {
+			return EscapeMode(m), true
+		}
+	}
+	return 0, false
+}
+
 // Template represents a compiled mustache template which can be used to render data.
 type Template struct {
-	data           string
+	data           []byte
 	otag           string
 	ctag           string
 	p              int
@@ -183,14 +473,48 @@ type Template struct {
 	forceRaw       bool
 	partial        PartialProvider
 	outputMode     EscapeMode
+	escapePragma   bool
 	valueStringer  ValueStringer
 	errorOnMissing bool
 	parent         *Compiler
+	name           string
+	// metadata is set by WithMetadata, replacing the compiler's
+	metadata Metadata
+	// fallback is rendered if a render fails, as set by WithFallback
+	fallback   *Template
+	onFallback func(error)
+	// checkpoints are the positions at which Reparse can resume parsing, in order
+	checkpoints []checkpoint
+	// delimiterChanges are the changes of delimiters made by set delimiter tags, in order
+	delimiterChanges []delimiterChange
+	// tagLine, tagColumn and tagOffset are the position of the tag being parsed
+	tagLine   int
+	tagColumn int
+	tagOffset int
+	// lenient is set to continue parsing after errors, which are recorded in parseErrors
+	lenient     bool
+	parseErrors []error
+}
+
+// ParseError is returned when a template fails to parse, giving the position of the tag at fault so that tools can
+// point to it. Errors which wrap it, such as those of TemplateSet, can be examined with errors.As.
+type ParseError struct {
+	Line    int    // the line of the tag, counting from 1
+	Column  int    // the column of the tag, counting bytes from 1
+	Offset  int    // the offset of the tag in the template's source, in bytes from 0
+	Message string // the problem, such as "unmatched close tag"
 }
 
-type parseError struct {
-	line    int
-	message string
+// Name returns the name of the template: the file name for templates compiled with CompileFile, and otherwise an
+// empty string.
+func (tmpl *Template) Name() string {
+	return tmpl.name
+}
+
+// EscapeMode returns the escape mode of the template: that of its ESCAPE pragma, if it has one, and otherwise that of
+// the compiler.
+func (tmpl *Template) EscapeMode() EscapeMode {
+	return tmpl.outputMode
 }
 
 // Tags returns the mustache tags for the given template.
@@ -225,7 +549,25 @@ func (e *varElement) Tags() []Tag {
 	panic("mustache: Tags on Variable type")
 }
 
+func (e *varElement) Line() int {
+	return e.line
+}
+
+func (e *varElement) Column() int {
+	return e.col
+}
+
+func (e *varElement) ByteOffset() int {
+	return e.offset
+}
+
 func (e *sectionElement) Type() TagType {
+	switch e.sigil {
+	case parentSigil:
+		return Parent
+	case blockSigil:
+		return Block
+	}
 	if e.inverted {
 		return InvertedSection
 	}
@@ -240,6 +582,18 @@ func (e *sectionElement) Tags() []Tag {
 	return extractTags(e.elems)
 }
 
+func (e *sectionElement) Line() int {
+	return e.startline
+}
+
+func (e *sectionElement) Column() int {
+	return e.startcol
+}
+
+func (e *sectionElement) ByteOffset() int {
+	return e.startoffset
+}
+
 func (e *partialElement) Type() TagType {
 	return Partial
 }
@@ -252,11 +606,44 @@ func (e *partialElement) Tags() []Tag {
 	return nil
 }
 
-func (p parseError) Error() string {
-	return fmt.Sprintf("line %d: %s", p.line, p.message)
+func (e *partialElement) Line() int {
+	return e.line
+}
+
+func (e *partialElement) Column() int {
+	return e.col
+}
+
+func (e *partialElement) ByteOffset() int {
+	return e.offset
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// parseError returns a *ParseError for a problem with the tag being parsed.
+func (tmpl *Template) parseError(message string) *ParseError {
+	return tmpl.errorAt(tmpl.tagLine, tmpl.tagColumn, message)
+}
+
+// errorAt returns a *ParseError for a problem at a line and column of the template's source.
+func (tmpl *Template) errorAt(line, column int, message string) *ParseError {
+	offset := 0
+	for l := 1; l < line && offset < len(tmpl.data); l++ {
+		i := bytes.IndexByte(tmpl.data[offset:], '\n')
+		if i < 0 {
+			break
+		}
+		offset += i + 1
+	}
+	if column > 1 {
+		offset += column - 1
+	}
+	return &ParseError{Line: line, Column: column, Offset: offset, Message: message}
 }
 
-func (tmpl *Template) readString(s string) (string, error) {
+func (tmpl *Template) readString(s string) ([]byte, error) {
 	newlines := 0
 	for i := tmpl.p; ; i++ {
 		// are we at the end of the string?
@@ -292,8 +679,8 @@ func (tmpl *Template) readString(s string) (string, error) {
 }
 
 type textReadingResult struct {
-	text          string
-	padding       string
+	text          []byte
+	padding       []byte
 	mayStandalone bool
 }
 
@@ -303,7 +690,7 @@ func (tmpl *Template) readText() (*textReadingResult, error) {
 	if err == io.EOF {
 		return &textReadingResult{
 			text:          text,
-			padding:       "",
+			padding:       nil,
 			mayStandalone: false,
 		}, err
 	}
@@ -327,7 +714,7 @@ func (tmpl *Template) readText() (*textReadingResult, error) {
 
 	return &textReadingResult{
 		text:          tmpl.data[pPrev : tmpl.p-len(tmpl.otag)],
-		padding:       "",
+		padding:       nil,
 		mayStandalone: false,
 	}, nil
 }
@@ -335,28 +722,36 @@ func (tmpl *Template) readText() (*textReadingResult, error) {
 type tagReadingResult struct {
 	tag        string
 	standalone bool
+	// why explains whether the tag stands alone, for the parse trace
+	why string
+	// end is the offset just past the tag's closing delimiter
+	end int
 }
 
 func (tmpl *Template) readTag(mayStandalone bool) (*tagReadingResult, error) {
-	var text string
+	var text []byte
 	var err error
 	if tmpl.p < len(tmpl.data) && tmpl.data[tmpl.p] == '{' {
 		text, err = tmpl.readString("}" + tmpl.ctag)
+	} else if tmpl.parent.syntax == Handlebars && bytes.HasPrefix(tmpl.data[tmpl.p:], []byte("!--")) {
+		// Handlebars comments of this form may contain the closing delimiter
+		text, err = tmpl.readString("--" + tmpl.ctag)
 	} else {
 		text, err = tmpl.readString(tmpl.ctag)
 	}
 
 	if err == io.EOF {
 		// put the remaining text in a block
-		return nil, parseError{tmpl.curline, "unmatched open tag"}
+		return nil, tmpl.parseError("unmatched open tag")
 	}
 
 	text = text[:len(text)-len(tmpl.ctag)]
+	end := tmpl.p
 
 	// trim the close tag off the text
-	tag := strings.TrimSpace(text)
+	tag := string(bytes.TrimSpace(text))
 	if len(tag) == 0 {
-		return nil, parseError{tmpl.curline, "empty tag"}
+		return nil, tmpl.parseError("empty tag")
 	}
 
 	eow := tmpl.p
@@ -368,9 +763,11 @@ func (tmpl *Template) readTag(mayStandalone bool) (*tagReadingResult, error) {
 	}
 
 	standalone := true
+	why := "not standalone: text precedes it on its line"
 	if mayStandalone {
-		if !strings.Contains(SkipWhitespaceTagTypes, tag[0:1]) {
+		if !strings.Contains(SkipWhitespaceTagTypes, tag[0:1]) && !(tmpl.parent.syntax == Handlebars && tag == "else") {
 			standalone = false
+			why = "not standalone: tags of its kind never are"
 		} else {
 			if eow == len(tmpl.data) {
 				standalone = true
@@ -385,6 +782,10 @@ func (tmpl *Template) readTag(mayStandalone bool) (*tagReadingResult, error) {
 				tmpl.curline++
 			} else {
 				standalone = false
+				why = "not standalone: text follows it on its line"
+			}
+			if standalone {
+				why = "standalone: its line is removed"
 			}
 		}
 	}
@@ -392,236 +793,410 @@ func (tmpl *Template) readTag(mayStandalone bool) (*tagReadingResult, error) {
 	return &tagReadingResult{
 		tag:        tag,
 		standalone: standalone,
+		why:        why,
+		end:        end,
 	}, nil
 }
 
-func (tmpl *Template) parsePartial(name, indent string) (*partialElement, error) {
+// tagName trims the name of a tag following its sigil, returning a parse error if the name is empty.
+func (tmpl *Template) tagName(text, kind string) (string, error) {
+	name := strings.TrimSpace(text)
+	if name == "" {
+		return "", tmpl.parseError("empty " + kind + " name")
+	}
+	return name, nil
+}
+
+// parsePragma handles a pragma tag such as {{%ESCAPE JSON}}, which sets the escape mode of the template it appears in,
+// overriding the compiler's mode and any mode set by the partial provider.
+func (tmpl *Template) parsePragma(text string) error {
+	pragma := strings.Fields(text)
+	if len(pragma) == 0 {
+		return tmpl.parseError("empty pragma")
+	}
+	if pragma[0] != "ESCAPE" {
+		return tmpl.parseError("unknown pragma: " + pragma[0])
+	}
+	if len(pragma) != 2 {
+		return tmpl.parseError("ESCAPE pragma requires one escape mode")
+	}
+	mode, ok := parseEscapeMode(pragma[1])
+	if !ok {
+		return tmpl.parseError("unknown escape mode: " + pragma[1])
+	}
+	tmpl.outputMode = mode
+	tmpl.escapePragma = true
+	return nil
+}
+
+// setDelimiters sets the delimiters from the contents of a set delimiter tag, checking them for strict parsing.
+func (tmpl *Template) setDelimiters(tag string) error {
+	delims := strings.Fields(tag)
+	if len(delims) != 2 {
+		return tmpl.parseError(fmt.Sprintf("set delimiter tag at column %d has %d delimiters rather than 2", tmpl.tagColumn, len(delims)))
+	}
+	if strings.Contains(tag, "=") {
+		return tmpl.parseError(fmt.Sprintf("set delimiter tag at column %d has a delimiter containing '='", tmpl.tagColumn))
+	}
+	tmpl.otag, tmpl.ctag = delims[0], delims[1]
+	return nil
+}
+
+// parsePartial returns the element for a partial tag. A name beginning with "./" or "../" is relative to the directory
+// of the template's own name, and is resolved as it is parsed, so that {{>../shared/footer}} in the template
+// "cards/product" includes "shared/footer". A name which would lead out of the namespace, by beginning with ".." once
+// resolved, is left as it is for the provider to refuse, as FileProvider and FSProvider do.
+func (tmpl *Template) parsePartial(name string, indent []byte) (*partialElement, error) {
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") {
+		resolved := path.Join(path.Dir(tmpl.name), name)
+		if resolved != ".." && !strings.HasPrefix(resolved, "../") {
+			name = resolved
+		}
+	}
 	return &partialElement{
 		name:   name,
-		indent: indent,
+		indent: string(indent),
 		prov:   tmpl.partial,
 	}, nil
 }
 
-func (tmpl *Template) parseSection(section *sectionElement) error {
+// openSection appends a section to elems and pushes it onto the stack of open sections, so that the elements which
+// follow are added to the section.
+func (tmpl *Template) openSection(se *sectionElement, stack *[]*sectionElement, elems **[]interface{}) error {
+	if limit := tmpl.parent.maxSectionDepth; limit > 0 && len(*stack) >= limit {
+		return &LimitError{Err: ErrSectionTooDeep, Max: limit, Line: tmpl.curline}
+	}
+	se.startline, se.startcol, se.startoffset = tmpl.tagLine, tmpl.tagColumn, tmpl.tagOffset
+	**elems = append(**elems, se)
+	*stack = append(*stack, se)
+	*elems = &se.elems
+	return nil
+}
+
+// closeSection pops the innermost open section, which must be closed by name.
+func (tmpl *Template) closeSection(name string, stack *[]*sectionElement, elems **[]interface{}) error {
+	if len(*stack) == 0 {
+		return tmpl.parseError("unmatched close tag")
+	}
+	section := (*stack)[len(*stack)-1]
+	expected := section.name
+	if section.closer != "" {
+		expected = section.closer
+	}
+	if name != expected {
+		err := tmpl.parseError(fmt.Sprintf("interleaved closing tag: %s (expected %s, opened at line %d column %d; closed at line %d column %d)",
+			name, expected, section.startline, section.startcol, tmpl.tagLine, tmpl.tagColumn))
+		if tmpl.lenient {
+			// close the sections opened within the one which is closed, if there is one
+			for i := len(*stack) - 2; i >= 0; i-- {
+				if (*stack)[i].name == name && (*stack)[i].closer == "" || (*stack)[i].closer == name {
+					*stack = (*stack)[:i]
+					*elems = tmpl.sectionElems(*stack)
+					break
+				}
+			}
+		}
+		return err
+	}
+	*stack = (*stack)[:len(*stack)-1]
+	*elems = tmpl.sectionElems(*stack)
+	return nil
+}
+
+// sectionElems returns the elements of the innermost open section, or of the template if there is none.
+func (tmpl *Template) sectionElems(stack []*sectionElement) *[]interface{} {
+	if len(stack) > 0 {
+		return &stack[len(stack)-1].elems
+	}
+	return &tmpl.elems
+}
+
+func (tmpl *Template) parse() (err error) {
+	defer recoverInternal(&err, "parsing")
+	// sections which have been opened but not yet closed, innermost last
+	var stack []*sectionElement
+	elems := &tmpl.elems
+	// inherited holds the state of the open parent and block tags
+	inherited := make(map[*sectionElement]inheritParse)
+	// lineClear is set when only tags and whitespace precede the position of the parse on its line
+	lineClear := true
 	for {
+		if len(stack) == 0 && (tmpl.p == 0 || tmpl.data[tmpl.p-1] == '\n') {
+			tmpl.checkpoints = append(tmpl.checkpoints, checkpoint{tmpl.p, len(tmpl.elems), tmpl.curline, tmpl.otag, tmpl.ctag})
+		}
+		textStart, textLine := tmpl.p, tmpl.curline
 		textResult, err := tmpl.readText()
 		text := textResult.text
 		padding := textResult.padding
 		mayStandalone := textResult.mayStandalone
+		if mayStandalone {
+			lineClear = true
+		} else if len(bytes.Trim(text, " \t")) > 0 {
+			lineClear = false
+		}
+		if tmpl.parent.parseTrace != nil && len(text) > 0 {
+			tmpl.trace(textLine, textStart, "text %q", text)
+		}
 
 		if err == io.EOF {
 			// put the remaining text in a block
-			return parseError{section.startline, "Section " + section.name + " has no closing tag"}
+			*elems = append(*elems, &textElement{text})
+			if tmpl.parent.parseTrace != nil {
+				tmpl.trace(tmpl.curline, tmpl.p, "end of template, open sections: %d", len(stack))
+			}
+			for len(stack) > 0 {
+				section := stack[len(stack)-1]
+				if err := tmpl.parseFailed(tmpl.errorAt(section.startline, section.startcol, "Section "+section.name+" has no closing tag")); err != nil {
+					return err
+				}
+				stack = stack[:len(stack)-1]
+			}
+			return nil
 		}
 
 		// put text into an item
-		section.elems = append(section.elems, &textElement{[]byte(text)})
+		*elems = append(*elems, &textElement{text})
+
+		tagStart := tmpl.p - len(tmpl.otag)
+		tmpl.tagLine = tmpl.curline
+		tmpl.tagColumn = tagStart - bytes.LastIndexByte(tmpl.data[:tagStart], '\n')
+		tmpl.tagOffset = tagStart
 
 		tagResult, err := tmpl.readTag(mayStandalone)
 		if err != nil {
-			return err
+			if tmpl.parent.parseTrace != nil {
+				tmpl.trace(tmpl.tagLine, tagStart, "error: %v", err)
+			}
+			if err := tmpl.parseFailed(err); err != nil {
+				return err
+			}
+			*elems = append(*elems, &textElement{padding})
+			if tmpl.p == tagStart+len(tmpl.otag) {
+				// the tag is not closed, so the rest of the template is text
+				*elems = append(*elems, &textElement{tmpl.data[tagStart:]})
+				tmpl.p = len(tmpl.data)
+			}
+			continue
 		}
 
+		// readTag only decides whether tags which begin their line stand alone
+		standalone := mayStandalone && tagResult.standalone
+		if !standalone && inheritStandalone(tagResult.tag, stack, inherited, lineClear) && tmpl.skipLineEnd() {
+			standalone, tagResult.standalone = true, true
+			tagResult.why = "standalone: only tags precede it on its line"
+		}
+		var paddingElem *textElement
 		if !tagResult.standalone {
-			section.elems = append(section.elems, &textElement{[]byte(padding)})
+			// the indentation of a tag is only that of a standalone tag
+			paddingElem = &textElement{padding}
+			*elems = append(*elems, paddingElem)
+			padding = nil
+		}
+		var closing *sectionElement
+		if tagResult.tag[0] == '/' && len(stack) > 0 {
+			closing = stack[len(stack)-1]
 		}
 
-		tag := tagResult.tag
-		switch tag[0] {
-		case '!':
-			// ignore comment
-			break
-		case '#', '^':
-			name := strings.TrimSpace(tag[1:])
-			se := sectionElement{name, tag[0] == '^', tmpl.curline, []interface{}{}}
-			err := tmpl.parseSection(&se)
-			if err != nil {
+		if tmpl.parent.parseTrace != nil {
+			tmpl.traceTag(tagResult, padding, tagStart, len(stack))
+		}
+		otag, ctag, depth := tmpl.otag, tmpl.ctag, len(stack)
+		if err := tmpl.parseTag(tagResult.tag, padding, &stack, &elems); err != nil {
+			if tmpl.parent.parseTrace != nil {
+				tmpl.trace(tmpl.tagLine, tagStart, "error: %v", err)
+			}
+			if err := tmpl.parseFailed(err); err != nil {
 				return err
 			}
-			section.elems = append(section.elems, &se)
-		case '/':
-			name := strings.TrimSpace(tag[1:])
-			if name != section.name {
-				return parseError{tmpl.curline, "interleaved closing tag: " + name}
+		}
+		if n := len(stack); n > depth && stack[n-1].sigil != 0 {
+			state := inheritParse{clear: lineClear, standalone: standalone}
+			if mayStandalone && !standalone {
+				state.padding = paddingElem
 			}
-			return nil
-		case '>':
-			name := strings.TrimSpace(tag[1:])
-			partial, err := tmpl.parsePartial(name, textResult.padding)
-			if err != nil {
-				return err
+			inherited[stack[n-1]] = state
+		} else if closing != nil && closing.sigil != 0 && len(stack) < depth {
+			closeInherited(closing, inherited[closing], standalone, stack)
+			delete(inherited, closing)
+		}
+		lineClear = lineClear && strings.Contains(SkipWhitespaceTagTypes, tagResult.tag[:1])
+		if tagResult.tag[0] == '=' {
+			if otag != tmpl.otag || ctag != tmpl.ctag {
+				tmpl.delimiterChanges = append(tmpl.delimiterChanges, delimiterChange{tagResult.end, tmpl.otag, tmpl.ctag})
+			}
+			if tmpl.parent.parseTrace != nil {
+				tmpl.traceDelimiters(tagStart, otag, ctag)
 			}
-			section.elems = append(section.elems, partial)
-		case '=':
-			if len(tag) < 2 || tag[len(tag)-1] != '=' {
-				return parseError{tmpl.curline, "invalid meta tag"}
-			}
-			tag = strings.TrimSpace(tag[1 : len(tag)-1])
-			newtags := strings.SplitN(tag, " ", 2)
-			if len(newtags) == 2 {
-				tmpl.otag = newtags[0]
-				tmpl.ctag = newtags[1]
-			}
-		case '{':
-			if tag[len(tag)-1] == '}' {
-				// use a raw tag
-				name := strings.TrimSpace(tag[1 : len(tag)-1])
-				section.elems = append(section.elems, &varElement{name, true})
-			}
-		case '&':
-			name := strings.TrimSpace(tag[1:])
-			section.elems = append(section.elems, &varElement{name, true})
-		default:
-			section.elems = append(section.elems, &varElement{tag, tmpl.forceRaw})
 		}
 	}
 }
 
-func (tmpl *Template) parse() error {
-	for {
-		textResult, err := tmpl.readText()
-		text := textResult.text
-		padding := textResult.padding
-		mayStandalone := textResult.mayStandalone
+// parseFailed returns err, or in lenient mode, records it so that parsing continues after the tag which caused it.
+func (tmpl *Template) parseFailed(err error) error {
+	if !tmpl.lenient {
+		return err
+	}
+	tmpl.parseErrors = append(tmpl.parseErrors, err)
+	return nil
+}
 
-		if err == io.EOF {
-			// put the remaining text in a block
-			tmpl.elems = append(tmpl.elems, &textElement{[]byte(text)})
-			return nil
+// parseTag adds the element for a tag to the innermost open section, or opens or closes a section.
+func (tmpl *Template) parseTag(tag string, padding []byte, stack *[]*sectionElement, elems **[]interface{}) error {
+	if tmpl.parent.syntax == Handlebars {
+		if handled, err := tmpl.parseHandlebars(tag, padding, stack, elems); err != nil || handled {
+			return err
 		}
-
-		// put text into an item
-		tmpl.elems = append(tmpl.elems, &textElement{[]byte(text)})
-
-		tagResult, err := tmpl.readTag(mayStandalone)
+	}
+	switch tag[0] {
+	case '!':
+		// comments render nothing, but are kept for Walk
+		**elems = append(**elems, &commentElement{text: tag[1:], line: tmpl.tagLine, col: tmpl.tagColumn, offset: tmpl.tagOffset})
+	case '%':
+		if err := tmpl.parsePragma(tag[1:]); err != nil {
+			return err
+		}
+	case '#', '^':
+		name, err := tmpl.tagName(tag[1:], "section")
 		if err != nil {
 			return err
 		}
-
-		if !tagResult.standalone {
-			tmpl.elems = append(tmpl.elems, &textElement{[]byte(padding)})
+		se := &sectionElement{name: name, inverted: tag[0] == '^', elems: []interface{}{}}
+		if err := tmpl.openSection(se, stack, elems); err != nil {
+			return err
 		}
-
-		tag := tagResult.tag
-		switch tag[0] {
-		case '!':
-			// ignore comment
-			break
-		case '#', '^':
-			name := strings.TrimSpace(tag[1:])
-			se := sectionElement{name, tag[0] == '^', tmpl.curline, []interface{}{}}
-			err := tmpl.parseSection(&se)
+	case parentSigil, blockSigil:
+		kind := "parent"
+		if tag[0] == blockSigil {
+			kind = "block"
+		}
+		name, err := tmpl.tagName(tag[1:], kind)
+		if err != nil {
+			return err
+		}
+		se := &sectionElement{name: name, sigil: tag[0], indent: string(padding), elems: []interface{}{}}
+		if tag[0] == parentSigil {
+			// the name of the parent is resolved as that of a partial
+			partial, err := tmpl.parsePartial(name, padding)
 			if err != nil {
 				return err
 			}
-			tmpl.elems = append(tmpl.elems, &se)
-		case '/':
-			return parseError{tmpl.curline, "unmatched close tag"}
-		case '>':
-			name := strings.TrimSpace(tag[1:])
-			partial, err := tmpl.parsePartial(name, textResult.padding)
+			se.prov = partial.prov
+			se.closer, se.name = name, partial.name
+			if se.closer == se.name {
+				se.closer = ""
+			}
+		}
+		if err := tmpl.openSection(se, stack, elems); err != nil {
+			return err
+		}
+	case '/':
+		if len(*stack) == 0 {
+			return tmpl.parseError("unmatched close tag")
+		}
+		name, err := tmpl.tagName(tag[1:], "closing tag")
+		if err != nil {
+			return err
+		}
+		if err := tmpl.closeSection(name, stack, elems); err != nil {
+			return err
+		}
+	case '>':
+		name, err := tmpl.tagName(tag[1:], "partial")
+		if err != nil {
+			return err
+		}
+		raw := false
+		if fields := strings.Fields(name); len(fields) == 2 && fields[1] == "raw" {
+			name, raw = fields[0], true
+		}
+		partial, err := tmpl.parsePartial(name, padding)
+		if err != nil {
+			return err
+		}
+		partial.raw = raw
+		partial.line, partial.col, partial.offset = tmpl.tagLine, tmpl.tagColumn, tmpl.tagOffset
+		**elems = append(**elems, partial)
+	case '=':
+		if len(tag) < 2 || tag[len(tag)-1] != '=' {
+			return tmpl.parseError("invalid meta tag")
+		}
+		tag = strings.TrimSpace(tag[1 : len(tag)-1])
+		if tmpl.parent.strictParsing {
+			return tmpl.setDelimiters(tag)
+		}
+		newtags := strings.SplitN(tag, " ", 2)
+		if len(newtags) == 2 {
+			tmpl.otag = newtags[0]
+			tmpl.ctag = newtags[1]
+		}
+	case '{':
+		// use a raw tag
+		if tag[len(tag)-1] == '}' {
+			name, err := tmpl.tagName(tag[1:len(tag)-1], "variable")
 			if err != nil {
 				return err
 			}
-			tmpl.elems = append(tmpl.elems, partial)
-		case '=':
-			if tag[len(tag)-1] != '=' || len(tag) < 2 {
-				return parseError{tmpl.curline, "Invalid meta tag"}
-			}
-			tag = strings.TrimSpace(tag[1 : len(tag)-1])
-			newtags := strings.SplitN(tag, " ", 2)
-			if len(newtags) == 2 {
-				tmpl.otag = newtags[0]
-				tmpl.ctag = newtags[1]
-			}
-		case '{':
-			// use a raw tag
-			if tag[len(tag)-1] == '}' {
-				name := strings.TrimSpace(tag[1 : len(tag)-1])
-				tmpl.elems = append(tmpl.elems, &varElement{name, true})
-			}
-		case '&':
-			name := strings.TrimSpace(tag[1:])
-			tmpl.elems = append(tmpl.elems, &varElement{name, true})
-		default:
-			tmpl.elems = append(tmpl.elems, &varElement{tag, tmpl.forceRaw})
+			return tmpl.addVariable(name, true, elems)
 		}
+	case '&':
+		name, err := tmpl.tagName(tag[1:], "variable")
+		if err != nil {
+			return err
+		}
+		return tmpl.addVariable(name, true, elems)
+	default:
+		elem, err := tmpl.parseCustomTag(tag)
+		if err != nil || elem != nil {
+			if elem != nil {
+				**elems = append(**elems, elem)
+			}
+			return err
+		}
+		return tmpl.addVariable(tag, tmpl.forceRaw, elems)
 	}
+	return nil
 }
 
 // Evaluate interfaces and pointers looking for a value that can look up the name, via a
 // struct field, method, or map key, and return the result of the lookup.
-func lookup(contextChain []interface{}, name string, errorOnMissing bool) (reflect.Value, error) {
+func lookup(contextChain []reflect.Value, name string, errorOnMissing bool) (reflect.Value, error) {
+	v, _, err := lookupFrame(contextChain, name, errorOnMissing)
+	return v, err
+}
+
+// lookupFrame is like lookup, but also returns the index in contextChain of the context the name (or the first part
+// of a dotted name) was found in, or -1 if it wasn't found.
+func lookupFrame(contextChain []reflect.Value, name string, errorOnMissing bool) (_ reflect.Value, _ int, err error) {
 	// dot notation
 	if name != "." && strings.Contains(name, ".") {
 		parts := strings.SplitN(name, ".", 2)
 
-		v, err := lookup(contextChain, parts[0], errorOnMissing)
+		v, i, err := lookupFrame(contextChain, parts[0], errorOnMissing)
 		if err != nil {
-			return v, err
+			return v, i, err
 		}
-		return lookup([]interface{}{v}, parts[1], errorOnMissing)
+		v, _, err = lookupFrame([]reflect.Value{v}, parts[1], errorOnMissing)
+		return v, i, err
 	}
 
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Printf("Panic while looking up %q: %s\n", name, r)
-		}
-	}()
+	defer recoverInternal(&err, fmt.Sprintf("looking up %q", name))
 
-Outer:
-	for _, ctx := range contextChain {
-		v := ctx.(reflect.Value)
-		for v.IsValid() {
-			typ := v.Type()
-			if n := v.Type().NumMethod(); n > 0 {
-				for i := 0; i < n; i++ {
-					m := typ.Method(i)
-					mtyp := m.Type
-					if m.Name == name && mtyp.NumIn() == 1 {
-						return v.Method(i).Call(nil)[0], nil
-					}
-				}
-			}
-			if name == "." {
-				return v, nil
-			}
-			switch av := v; av.Kind() {
-			case reflect.Ptr:
-				v = av.Elem()
-			case reflect.Interface:
-				v = av.Elem()
-			case reflect.Struct:
-				ret := av.FieldByName(name)
-				if ret.IsValid() {
-					return ret, nil
-				}
-				continue Outer
-			case reflect.Map:
-				ret := av.MapIndex(reflect.ValueOf(name))
-				if ret.IsValid() {
-					return ret, nil
-				}
-				continue Outer
-			default:
-				continue Outer
-			}
+	for i, v := range contextChain {
+		if ret, ok := lookupValue(v, name); ok {
+			return ret, i, nil
 		}
 	}
 	if !errorOnMissing {
-		return reflect.Value{}, nil
+		return reflect.Value{}, -1, nil
 	}
-	return reflect.Value{}, fmt.Errorf("missing variable %q", name)
+	return reflect.Value{}, -1, fmt.Errorf("%w %q", ErrMissingVariable, name)
 }
 
+// isEmpty reports whether v is falsy in a section: missing, nil at the end of any chain of pointers and interfaces, an
+// empty list, a blank string or a zero value.
 func isEmpty(v reflect.Value) bool {
-	if !v.IsValid() || v.Interface() == nil {
-		return true
-	}
-
 	valueInd := indirect(v)
-	if !valueInd.IsValid() {
+	if isNil(valueInd) {
 		return true
 	}
 	switch val := valueInd; val.Kind() {
@@ -634,6 +1209,18 @@ func isEmpty(v reflect.Value) bool {
 	}
 }
 
+// isNil reports whether v is missing, or a nil pointer or interface. Such values are falsy and have no names to look
+// up, wherever they appear.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
 func indirect(v reflect.Value) reflect.Value {
 loop:
 	for v.IsValid() {
@@ -649,77 +1236,350 @@ loop:
 	return v
 }
 
-func (tmpl *Template) renderSection(section *sectionElement, contextChain []interface{}, buf io.Writer) error {
-	value, err := lookup(contextChain, section.name, tmpl.errorOnMissing)
-	if err != nil {
-		return err
+// sectionContexts describes the contexts a section's elements are rendered with: each element of list, if the section
+// iterates over a list, or else context, once. The contexts of a list are drawn from it as they are rendered, rather
+// than collected up front, so rendering a long list does not allocate a slice of them.
+type sectionContexts struct {
+	list    reflect.Value
+	context reflect.Value
+	count   int
+	// repeat is set for numeric sections, whose contexts are the numbers from 1 to count
+	repeat bool
+}
+
+// at returns the context for the i'th iteration.
+func (sc *sectionContexts) at(i int) reflect.Value {
+	if sc.repeat {
+		return reflect.ValueOf(i + 1)
+	}
+	if sc.list.IsValid() {
+		return sc.list.Index(i)
+	}
+	return sc.context
+}
+
+// sectionContexts looks up the value of a section and returns the contexts the section's elements should be rendered
+// with. Lambda sections are rendered directly to buf, and return no contexts.
+func (tmpl *Template) sectionContexts(st *renderState, section *sectionElement, contextChain []reflect.Value, buf io.Writer) (sectionContexts, error) {
+	var value reflect.Value
+	if n, ok := tmpl.literalRepeat(section.name); ok {
+		value = reflect.ValueOf(n)
+		if st.usage != nil {
+			st.usage.section = ""
+		}
+	} else {
+		var frame int
+		var err error
+		if value, frame, err = tmpl.lookup(st, contextChain, section.name); err != nil {
+			return sectionContexts{}, err
+		}
+		if st.usage != nil {
+			st.usage.section = st.usage.use(contextChain, frame, section.name, false)
+		}
 	}
-	context := contextChain[0].(reflect.Value)
-	contexts := []interface{}{}
 	// if the value is nil, check if it's an inverted section
+	items, iterated := tmpl.iterate(value)
 	isEmpty := isEmpty(value)
+	if iterated {
+		isEmpty = len(items) == 0
+	}
 	if isEmpty && !section.inverted || !isEmpty && section.inverted {
-		return nil
-	} else if !section.inverted {
+		return sectionContexts{}, nil
+	} else if !section.inverted && !section.cond {
+		if iterated {
+			list := reflect.ValueOf(items)
+			return sectionContexts{list: list, count: list.Len()}, nil
+		}
 		valueInd := indirect(value)
+		if contexts, ok, err := tmpl.repeatContexts(section, valueInd); ok {
+			return contexts, err
+		}
 		switch val := valueInd; val.Kind() {
-		case reflect.Slice:
-			for i := 0; i < val.Len(); i++ {
-				contexts = append(contexts, val.Index(i))
-			}
-		case reflect.Array:
-			for i := 0; i < val.Len(); i++ {
-				contexts = append(contexts, val.Index(i))
-			}
+		case reflect.Slice, reflect.Array:
+			return sectionContexts{list: val, count: val.Len()}, nil
 		case reflect.Map, reflect.Struct:
-			contexts = append(contexts, value)
+			return sectionContexts{context: value, count: 1}, nil
 		case reflect.Func:
-			var text bytes.Buffer
-			getSectionText(section.elems, &text)
-			render := func(text string) (string, error) {
-				templ, err := tmpl.parent.CompileString(text)
-				if err != nil {
-					return "", err
-				}
-				var buf bytes.Buffer
-				err = templ.renderTemplate(contextChain, &buf)
-				if err != nil {
-					return "", err
-				}
-				return buf.String(), nil
-			}
-			in := []reflect.Value{reflect.ValueOf(text.String()), reflect.ValueOf(render)}
-			res := val.Call(in)
-			res_str := res[0].String()
-			if !res[1].IsNil() {
-				return res[1].Interface().(error)
-			}
-			fmt.Fprintf(buf, "%s", res_str)
-			return nil
+			return sectionContexts{}, tmpl.callLambda(st, section, val, contextChain, buf)
 		default:
 			// Spec: Non-false sections have their value at the top of context,
 			// accessible as {{.}} or through the parent context. This gives
 			// a simple way to display content conditionally if a variable exists.
-			contexts = append(contexts, value)
+			return sectionContexts{context: value, count: 1}, nil
+		}
+	}
+	if st.usage != nil {
+		st.usage.section = st.usage.paths[len(contextChain)-1]
+	}
+	return sectionContexts{context: contextChain[0], count: 1}, nil
+}
+
+// renderState holds the state of a single call to Frender, shared by the template and any partials and lambdas it
+// renders.
+type renderState struct {
+	ctx      context.Context
+	partials *partialCache
+	usage    *usageTracker
+	summary  *RenderSummary
+	tags     *tagCounts
+	origins  *originRecorder
+	progress *progressTracker
+	// resolutions collects the resolutions of names for RenderResolutions
+	resolutions *[]Resolution
+	// jsonStrings is set by WithStructuredValues in the JSON escape modes
+	jsonStrings *jsonStrings
+	// flush flushes the output at the end of each top level section, if it is a Flusher
+	flush func() error
+	// iterations holds the position of each context in the context chain within the list it was drawn from,
+	// outermost first
+	iterations []iteration
+	indentation
+	// blocks holds the content given to blocks by the parent tags being rendered
+	blocks map[string]blockOverride
+	// frozen is set by WithFrozenContext
+	frozen *frozenContext
+	// spent counts the uses of the limits set with WithMaxLambdaCalls, WithMaxPartialFetches and WithMaxLookups
+	spent renderBudget
+}
+
+// iteration records the position of a context within the list a section is iterating over. A zero count means the
+// context was not drawn from a list.
+type iteration struct {
+	index int
+	count int
+}
+
+// Names which refer to the position of the innermost list iteration, rather than being looked up in the context.
+const (
+	iterFirst = "-first" // true for the first element of a list
+	iterLast  = "-last"  // true for the last element of a list
+	iterIndex = "-index" // the position of the element in the list, starting at 1
+	// the position of the element in the list, starting at 0, which Handlebars' @index is mapped to
+	iterIndex0 = "-index0"
+)
+
+func isReservedName(name string) bool {
+	switch name {
+	case iterFirst, iterLast, iterIndex, iterIndex0:
+		return true
+	}
+	return false
+}
+
+// lookup resolves a name against the context chain, like lookupFrame, but also resolves names such as -first which
+// depend on the state of the render.
+func (tmpl *Template) lookup(st *renderState, contextChain []reflect.Value, name string) (v reflect.Value, frame int, err error) {
+	if st.summary != nil {
+		st.summary.Tags++
+	}
+	if err := spend(&st.spent.lookups, tmpl.parent.budget.lookups, ErrTooManyLookups); err != nil {
+		return reflect.Value{}, -1, err
+	}
+	if st.resolutions != nil {
+		defer func(name string) { st.resolved(contextChain, name, v, frame) }(name)
+	}
+	if len(name) > 1 && name[0] == '.' {
+		// an explicit reference to the data, which may be shadowed by a reserved name
+		name = name[1:]
+	} else if len(name) > 1 && name[0] == '-' {
+		if tmpl.parent.strictReserved && isReservedName(name) {
+			if v, _, _ := lookupFrame(contextChain, name, false); v.IsValid() {
+				return reflect.Value{}, -1, fmt.Errorf("data shadows the reserved name %q; use %q to refer to it", name, "."+name)
+			}
 		}
-	} else if section.inverted {
-		contexts = append(contexts, context)
+		// search from the innermost context outwards
+		for i := len(contextChain) - 1; i >= 0; i-- {
+			it := st.iterations[i]
+			if it.count == 0 {
+				continue
+			}
+			switch name {
+			case iterFirst:
+				return reflect.ValueOf(it.index == 0), -1, nil
+			case iterLast:
+				return reflect.ValueOf(it.index == it.count-1), -1, nil
+			case iterIndex:
+				return reflect.ValueOf(it.index + 1), -1, nil
+			case iterIndex0:
+				return reflect.ValueOf(it.index), -1, nil
+			}
+			break
+		}
+	}
+	v, frame, err = lookupFrame(contextChain, name, tmpl.errorOnMissing)
+	v = nullValue(v)
+	if st.summary != nil && !v.IsValid() {
+		st.summary.Misses++
+	}
+	return v, frame, err
+}
+
+func (tmpl *Template) newRenderState() *renderState {
+	st := &renderState{ctx: context.Background()}
+	if tmpl.parent.partialCache {
+		st.partials = newPartialCache()
+	}
+	if tmpl.parent.renderSummary != nil {
+		st.summary = &RenderSummary{Template: tmpl.name}
+	}
+	if tmpl.parent.structuredValues && (tmpl.outputMode == EscapeJSON || tmpl.outputMode == EscapeJSONValue) {
+		st.jsonStrings = &jsonStrings{}
+	}
+	if tmpl.parent.progress.fn != nil {
+		st.progress = &progressTracker{progressOptions: tmpl.parent.progress}
+	}
+	if tmpl.parent.usageReport != nil {
+		st.tags = newTagCounts()
+		st.tags.rendered(tmpl)
 	}
+	return st
+}
+
+// renderFrame is an entry in the explicit stack used by renderElements. It tracks the position within a list of
+// elements, and for sections, which of the section's contexts is being rendered.
+type renderFrame struct {
+	elems    []interface{}
+	pos      int
+	contexts sectionContexts
+	ctx      int
+	chain    []reflect.Value
+	// path is the path of the section, for a UsageReport
+	path string
+	// array separates the elements of a section in a JSON array, as set with WithJSONArrays, writing to the writer
+	// of the enclosing frame
+	array *jsonArrayWriter
+}
+
+// renderElements renders a list of elements, descending into sections using an explicit stack rather than recursion,
+// so that deeply nested templates cannot exhaust the goroutine stack.
+func (tmpl *Template) renderElements(st *renderState, elems []interface{}, contextChain []reflect.Value, buf io.Writer) error {
+	var cw *countingWriter
+	if st.tags != nil {
+		cw = &countingWriter{w: buf}
+		buf = cw
+	}
+	// only the sections of the outermost template are flush points, not those of its partials
+	flush := st.flush
+	st.flush = nil
+	progress := st.progress
+	st.progress = nil
+	stack := []renderFrame{{elems: elems, chain: contextChain, path: tmpl.name}}
+	for len(stack) > 0 {
+		if progress != nil && progress.due(stack) {
+			progress.report(stack)
+		}
+		frame := &stack[len(stack)-1]
+		if frame.pos == len(frame.elems) {
+			// move on to the section's next context, or finish the frame
+			frame.ctx++
+			if frame.ctx < frame.contexts.count {
+				frame.chain[0] = frame.contexts.at(frame.ctx)
+				frame.pos = 0
+				st.iterations[len(frame.chain)-1].index = frame.ctx
+				if frame.array != nil {
+					frame.array.next()
+				}
+				continue
+			}
+			if frame.array != nil {
+				if err := frame.array.close(); err != nil {
+					return err
+				}
+				buf = frame.array.w
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 1 && flush != nil {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		elem := frame.elems[frame.pos]
+		frame.pos++
+		section, ok := elem.(*sectionElement)
+		if !ok || section.sigil != 0 {
+			var written int64
+			if cw != nil {
+				written = cw.n
+			}
+			if st.origins != nil {
+				st.origins.record(tmpl, elem)
+			}
+			if err := tmpl.renderElement(st, elem, frame.chain, buf); err != nil {
+				return tmpl.snapshotError(tmpl.positionError(err, elem), frame.chain)
+			}
+			if st.frozen != nil && tagKey(elem) != "" {
+				if err := st.frozen.check(elem); err != nil {
+					return err
+				}
+			}
+			if key := tagKey(elem); cw != nil && key != "" {
+				st.tags.record(tagPath(frame.path, key), cw.n > written)
+			}
+			continue
+		}
 
-	chain2 := make([]interface{}, len(contextChain)+1)
-	copy(chain2[1:], contextChain)
-	// by default we execute the section
-	for _, ctx := range contexts {
-		chain2[0] = ctx
-		for _, elem := range section.elems {
-			if err := tmpl.renderElement(elem, chain2, buf); err != nil {
+		var written int64
+		if cw != nil {
+			written = cw.n
+		}
+		if st.origins != nil {
+			st.origins.record(tmpl, section)
+		}
+		contexts, err := tmpl.sectionContexts(st, section, frame.chain, buf)
+		if err != nil {
+			return tmpl.snapshotError(tmpl.positionError(err, section), frame.chain)
+		}
+		if st.frozen != nil {
+			if err := st.frozen.check(section); err != nil {
 				return err
 			}
 		}
+		var path string
+		if cw != nil {
+			path = tagPath(frame.path, tagKey(section))
+			st.tags.record(path, contexts.count > 0 || cw.n > written)
+		}
+		if contexts.count == 0 {
+			continue
+		}
+		// reuse the context chain of the last frame popped at this depth, which nothing refers to any more
+		var chain []reflect.Value
+		if len(stack) < cap(stack) {
+			chain = stack[:len(stack)+1][len(stack)].chain
+		}
+		if cap(chain) > len(frame.chain) {
+			chain = chain[:len(frame.chain)+1]
+		} else {
+			chain = make([]reflect.Value, len(frame.chain)+1)
+		}
+		copy(chain[1:], frame.chain)
+		chain[0] = contexts.at(0)
+		it := iteration{}
+		if contexts.list.IsValid() || contexts.repeat {
+			it.count = contexts.count
+		}
+		st.iterations = append(st.iterations[:len(frame.chain)], it)
+		if st.usage != nil {
+			st.usage.paths = append(st.usage.paths[:len(frame.chain)], st.usage.section)
+		}
+		var array *jsonArrayWriter
+		if (contexts.list.IsValid() || contexts.repeat) && tmpl.inJSONArray(frame.elems, frame.pos-1) {
+			array = &jsonArrayWriter{w: buf}
+			buf = array
+		}
+		stack = append(stack, renderFrame{elems: section.elems, contexts: contexts, chain: chain, path: path, array: array})
+	}
+	if progress != nil {
+		progress.done(len(elems))
 	}
 	return nil
 }
 
+// JSONEscape writes data to dest escaped as in the EscapeJSON mode, for use within the quotes of a JSON string: quotes
+// and backslashes are escaped with a backslash, and control characters as \n or \u001f for instance.
 func JSONEscape(dest io.Writer, data string) error {
 	for _, r := range data {
 		var err error
@@ -766,8 +1626,16 @@ func getElementText(element interface{}, buf io.Writer) {
 		fmt.Fprintf(buf, "%s", elem.text)
 	case *varElement:
 		fmt.Fprintf(buf, "{{%s}}", elem.name)
+	case *helperElement:
+		fmt.Fprintf(buf, "{{%s}}", elem)
+	case *exprElement:
+		fmt.Fprintf(buf, "{{%s}}", elem.src)
+	case *customTagElement:
+		fmt.Fprintf(buf, "{{%s}}", elem)
 	case *sectionElement:
-		if elem.inverted {
+		if elem.sigil != 0 {
+			fmt.Fprintf(buf, "{{%c%s}}", elem.sigil, elem.name)
+		} else if elem.inverted {
 			fmt.Fprintf(buf, "{{^%s}}", elem.name)
 		} else {
 			fmt.Fprintf(buf, "{{#%s}}", elem.name)
@@ -781,96 +1649,323 @@ func getElementText(element interface{}, buf io.Writer) {
 	}
 }
 
-func (tmpl *Template) valueString(value any) (string, error) {
-	if tmpl.valueStringer != nil {
-		return tmpl.valueStringer(value)
+// valueString converts the value of a variable tag to a string, as JSON if it is structured, as HTML if it is Attrs,
+// or otherwise using the most specific stringer configured for it.
+func (tmpl *Template) valueString(elem *varElement, value any) (string, error) {
+	if tmpl.isStructured(elem.raw, value) {
+		return toJSONString(value)
+	}
+	if tmpl.isAttrs(value) {
+		return indirectValue(value).(Attrs).String(), nil
+	}
+	if vs := tmpl.parent.tagStringers; vs != nil {
+		if f, ok := vs[ImplicitIterator]; ok && elem.name == "." {
+			return f(value)
+		}
+		target := EscapedVariable
+		if elem.raw {
+			target = RawVariable
+		}
+		if f, ok := vs[target]; ok {
+			return f(value)
+		}
+	}
+	if !elem.raw {
+		if f, ok := tmpl.parent.modeStringers[tmpl.outputMode]; ok {
+			return f(value)
+		}
+		if tmpl.valueStringer != nil {
+			return tmpl.valueStringer(value)
+		}
+		if tmpl.outputMode == EscapeJSONValue {
+			return toJSONString(value)
+		}
+	}
+	// write what pointers point to, rather than their addresses
+	value = indirectValue(value)
+	if tmpl.parent.deterministic {
+		return stableString(value)
 	}
 	return fmt.Sprint(value), nil
 }
 
-func (tmpl *Template) renderElement(element interface{}, contextChain []interface{}, buf io.Writer) error {
+// NameLookuper is implemented by contexts which resolve names themselves, rather than as the methods, fields and keys
+// found by reflection, such as the wrappers of protocol buffer messages in the protomustache module. LookupName
+// returns the value of a name and whether the context has it; a nil value is present but falsy. Values returned are
+// looked up in the same way, so a NameLookuper can return others for nested contexts.
+type NameLookuper interface {
+	LookupName(name string) (interface{}, bool)
+}
+
+// lookupName resolves a name against a context which is a NameLookuper, and reports whether it is one.
+func lookupName(v reflect.Value, name string) (value reflect.Value, found bool, ok bool) {
+	if name == "." || isNil(v) || !v.CanInterface() {
+		return reflect.Value{}, false, false
+	}
+	l, ok := v.Interface().(NameLookuper)
+	if !ok {
+		return reflect.Value{}, false, false
+	}
+	i, found := l.LookupName(name)
+	return reflect.ValueOf(i), found, true
+}
+
+// mapKey returns name as a key of a map with keys of type typ, such as a named string type or interface{}, and
+// whether it can be one.
+func mapKey(typ reflect.Type, name string) (reflect.Value, bool) {
+	key := reflect.ValueOf(name)
+	switch {
+	case typ.Kind() == reflect.String:
+		return key.Convert(typ), true
+	case typ.Kind() == reflect.Interface && key.Type().Implements(typ):
+		return key, true
+	}
+	return reflect.Value{}, false
+}
+
+// indirectValue returns the value at the end of a chain of pointers and interfaces, stopping at nil pointers and at
+// pointers which are Stringers or errors, as fmt formats those itself.
+func indirectValue(value any) any {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if isNil(v) || v.Kind() == reflect.Pointer && (v.Type().Implements(stringerType) || v.Type().Implements(errorType)) {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || !v.CanInterface() {
+		return value
+	}
+	return v.Interface()
+}
+
+var (
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+func (tmpl *Template) renderElement(st *renderState, element interface{}, contextChain []reflect.Value, buf io.Writer) error {
 	switch elem := element.(type) {
 	case *textElement:
-		_, err := buf.Write(elem.text)
-		return err
+		return st.writeText(buf, elem.text)
 	case *varElement:
-		defer func() {
-			if r := recover(); r != nil {
-				fmt.Printf("Panic while looking up %q: %s\n", elem.name, r)
-			}
-		}()
-		val, err := lookup(contextChain, elem.name, tmpl.errorOnMissing)
+		if err := st.startTag(buf); err != nil {
+			return err
+		}
+		val, frame, err := tmpl.lookup(st, contextChain, elem.name)
 		if err != nil {
 			return err
 		}
+		if st.usage != nil {
+			st.usage.use(contextChain, frame, elem.name, true)
+		}
 
 		if val.IsValid() {
 
+			s, err := tmpl.valueString(elem, val.Interface())
+			if err != nil {
+				return err
+			}
 			if elem.raw {
-				fmt.Fprint(buf, val.Interface())
-			} else {
-				s, err := tmpl.valueString(val.Interface())
-				if err != nil {
-					return err
-				}
-				switch tmpl.outputMode {
-				case EscapeJSON:
-					if err = JSONEscape(buf, s); err != nil {
-						return err
-					}
-				case EscapeHTML:
-					template.HTMLEscape(buf, []byte(s))
-				case Raw:
-					if _, err = buf.Write([]byte(s)); err != nil {
-						return err
-					}
-				}
+				s = tmpl.sanitizeRaw(val.Interface(), s)
+			}
+			if err := tmpl.writeEscaped(st, buf, s, elem.raw, tmpl.isStructured(elem.raw, val.Interface()) || tmpl.isAttrs(val.Interface())); err != nil {
+				return err
+			}
+		} else if tmpl.outputMode == EscapeJSONValue && !elem.raw {
+			if err := tmpl.writeEscaped(st, buf, "null", false, false); err != nil {
+				return err
 			}
 		}
 	case *sectionElement:
-		if err := tmpl.renderSection(elem, contextChain, buf); err != nil {
+		if elem.sigil != 0 {
+			return tmpl.renderInherited(st, elem, contextChain, buf)
+		}
+		if err := tmpl.renderElements(st, []interface{}{elem}, contextChain, buf); err != nil {
 			return err
 		}
 	case *partialElement:
-		partial, err := tmpl.getPartials(elem.prov, elem.name, elem.indent)
-		if err != nil {
-			if tmpl.errorOnMissing {
-				return err
-			}
-			return nil
+		if err := tmpl.renderPartial(st, elem, contextChain, buf); err != nil {
+			return err
 		}
-		if err := partial.renderTemplate(contextChain, buf); err != nil {
+	case *helperElement:
+		if err := st.startTag(buf); err != nil {
+			return err
+		}
+		if err := tmpl.renderHelper(st, elem, contextChain, buf); err != nil {
+			return err
+		}
+	case *exprElement:
+		if err := st.startTag(buf); err != nil {
+			return err
+		}
+		if err := tmpl.renderExpression(st, elem, contextChain, buf); err != nil {
+			return err
+		}
+	case *customTagElement:
+		if err := st.startTag(buf); err != nil {
+			return err
+		}
+		if err := tmpl.renderCustomTag(st, elem, contextChain, buf); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (tmpl *Template) renderTemplate(contextChain []interface{}, buf io.Writer) error {
-	for _, elem := range tmpl.elems {
-		if err := tmpl.renderElement(elem, contextChain, buf); err != nil {
-			return err
-		}
+// writeEscaped writes the string form of a value, escaped for the output mode unless raw is set. In the JSON value
+// mode, s is expected to be JSON already, and if escaped is set, as for structured values and Attrs, s is expected to
+// be in the form the output needs.
+func (tmpl *Template) writeEscaped(st *renderState, buf io.Writer, s string, raw, escaped bool) error {
+	var err error
+	if raw {
+		_, err = io.WriteString(buf, s)
+		return err
 	}
-	return nil
+	if st.jsonStrings != nil && st.jsonStrings.inString && (escaped || tmpl.outputMode == EscapeJSONValue) {
+		return writeInString(buf, s)
+	}
+	if escaped {
+		_, err = io.WriteString(buf, s)
+		return err
+	}
+	switch tmpl.outputMode {
+	case EscapeJSON:
+		err = JSONEscape(buf, s)
+	case EscapeHTML:
+		err = htmlEscape(buf, s)
+	case Raw, EscapeJSONValue:
+		_, err = buf.Write([]byte(s))
+	}
+	return err
+}
+
+func (tmpl *Template) renderTemplate(st *renderState, contextChain []reflect.Value, buf io.Writer) error {
+	return tmpl.renderElements(st, tmpl.elems, contextChain, buf)
 }
 
 // Frender uses the given data source - generally a map or struct - to
 // render the compiled template to an io.Writer.
-func (tmpl *Template) Frender(out io.Writer, context ...interface{}) error {
-	var contextChain []interface{}
-	for _, c := range context {
-		val := reflect.ValueOf(c)
-		contextChain = append(contextChain, val)
+func (tmpl *Template) Frender(out io.Writer, data ...interface{}) error {
+	return tmpl.FrenderContext(context.Background(), out, data...)
+}
+
+// FrenderContext renders the compiled template to an io.Writer like Frender. The spans created for a Tracer set with
+// WithTracer are children of any span held by ctx.
+func (tmpl *Template) FrenderContext(ctx context.Context, out io.Writer, data ...interface{}) error {
+	return tmpl.frenderPrepared(ctx, out, data, nil)
+}
+
+// frenderPrepared renders the template, or its fallback if it has one and the render fails. prepare, if not nil, is
+// called with the template being rendered and the state of its render before it begins, to set up the reports which
+// methods such as RenderUnused collect; it is called again for the fallback.
+func (tmpl *Template) frenderPrepared(ctx context.Context, out io.Writer, data []interface{}, prepare func(*Template, *renderState)) error {
+	if tmpl.fallback != nil {
+		return tmpl.frenderWithFallback(ctx, out, data, prepare)
+	}
+	return tmpl.frenderContext(ctx, out, tmpl.elems, data, prepare)
+}
+
+// frenderContext renders elems of the template within a render span, reporting a summary if one was requested.
+func (tmpl *Template) frenderContext(ctx context.Context, out io.Writer, elems []interface{}, data []interface{}, prepare func(*Template, *renderState)) error {
+	st := tmpl.newRenderState()
+	if f, ok := out.(Flusher); ok {
+		st.flush = f.Flush
 	}
-	return tmpl.renderTemplate(contextChain, out)
+	if prepare != nil {
+		prepare(tmpl, st)
+	}
+	ctx, span := tmpl.startRenderSpan(ctx)
+	st.ctx = ctx
+	var err error
+	if st.summary == nil {
+		err = tmpl.frender(st, elems, out, data...)
+	} else {
+		err = st.summary.measure(tmpl.parent.renderSummary, out, func(out io.Writer) error {
+			return tmpl.frender(st, elems, out, data...)
+		})
+	}
+	if tmpl.parent.usageReport != nil && st.tags != nil {
+		tmpl.parent.usageReport.merge(st.tags)
+	}
+	err = tmpl.audit(ctx, err)
+	span.End(err)
+	return err
+}
+
+func (tmpl *Template) frender(st *renderState, elems []interface{}, out io.Writer, context ...interface{}) (err error) {
+	defer recoverInternal(&err, "rendering")
+	if tmpl.parent.charset.NewEncoder != nil {
+		cw := newCharsetWriter(out, tmpl.parent.charset, tmpl.parent.unmappable)
+		out = cw
+		defer func() {
+			if cerr := cw.close(); err == nil {
+				err = cerr
+			}
+		}()
+	}
+	contextChain := make([]reflect.Value, len(context))
+	for i, c := range context {
+		contextChain[i] = reflect.ValueOf(c)
+	}
+	st.iterations = make([]iteration, len(contextChain))
+	if tmpl.parent.frozenContext {
+		st.frozen = newFrozenContext(contextChain)
+	}
+	if tmpl.parent.postValidator == nil && len(tmpl.parent.postProcessors) == 0 {
+		if st.progress != nil {
+			out = st.progress.wrap(out)
+		}
+		if st.origins != nil {
+			out = st.origins.wrap(out)
+		}
+		if err := tmpl.renderElements(st, elems, contextChain, out); err != nil {
+			return err
+		}
+		_, err = io.WriteString(out, tmpl.watermarkText())
+		return err
+	}
+
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	if st.progress != nil {
+		w = st.progress.wrap(w)
+	}
+	if st.origins != nil {
+		w = st.origins.wrap(w)
+	}
+	st.flush = nil
+	if err := tmpl.renderElements(st, elems, contextChain, w); err != nil {
+		return err
+	}
+	output := buf.Bytes()
+	for _, process := range tmpl.parent.postProcessors {
+		var err error
+		if output, err = process(output); err != nil {
+			return err
+		}
+	}
+	output = append(output, tmpl.watermarkText()...)
+	if tmpl.parent.postValidator != nil {
+		if err := tmpl.parent.postValidator(output); err != nil {
+			return err
+		}
+	}
+	_, err = out.Write(output)
+	return err
 }
 
 // Render uses the given data source - generally a map or struct - to render
 // the compiled template and return the output.
-func (tmpl *Template) Render(context ...interface{}) (string, error) {
+func (tmpl *Template) Render(data ...interface{}) (string, error) {
+	return tmpl.RenderContext(context.Background(), data...)
+}
+
+// RenderContext renders the compiled template and returns the output like Render, with spans which are children of
+// any span held by ctx.
+func (tmpl *Template) RenderContext(ctx context.Context, data ...interface{}) (string, error) {
 	var buf bytes.Buffer
-	err := tmpl.Frender(&buf, context...)
+	err := tmpl.FrenderContext(ctx, &buf, data...)
 	return buf.String(), err
 }
 
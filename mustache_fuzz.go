@@ -1,3 +1,4 @@
+//go:build gofuzz
 // +build gofuzz
 
 package mustache
@@ -9,9 +10,11 @@ package mustache
 //   go get -u github.com/dvyukov/go-fuzz/go-fuzz github.com/dvyukov/go-fuzz/go-fuzz-build
 //   go-fuzz-build
 //   go-fuzz
+//
+// Inputs which crash the parser should be added to testdata/crashers, where TestCrashers picks them up.
 
 func Fuzz(data []byte) int {
-	_, err := ParseString(string(data))
+	_, err := New().CompileBytes(data)
 	if err == nil {
 		return 1
 	}
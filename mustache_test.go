@@ -3,9 +3,14 @@ package mustache
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -82,6 +87,53 @@ func (c Category) DisplayName() string {
 	return c.Tag + " - " + c.Description
 }
 
+// skipLight skips tests which render structs, or call methods, in the mustache_light build.
+func skipLight(t *testing.T) {
+	if lightBuild {
+		t.Skip("the mustache_light build resolves names only as map keys")
+	}
+}
+
+// needsMethods reports whether rendering data may resolve names to struct fields or methods, which the
+// mustache_light build does not.
+func needsMethods(data ...interface{}) bool {
+	for _, d := range data {
+		if needsMethodsValue(reflect.ValueOf(d)) {
+			return true
+		}
+	}
+	return false
+}
+
+func needsMethodsValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	if v.Kind() != reflect.Interface && v.Type().NumMethod() > 0 {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return true
+	case reflect.Ptr, reflect.Interface:
+		return needsMethodsValue(v.Elem())
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if needsMethodsValue(iter.Value()) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if needsMethodsValue(v.Index(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func TestTagType(t *testing.T) {
 	tt := Partial
 	ts := tt.String()
@@ -91,7 +143,7 @@ func TestTagType(t *testing.T) {
 }
 
 var tests = []Test{
-	{`{{/}}`, nil, "", parseError{line: 1, message: "unmatched close tag"}},
+	{`{{/}}`, nil, "", &ParseError{Line: 1, Column: 1, Message: "unmatched close tag"}},
 	{`hello world`, nil, "hello world", nil},
 	{`hello {{name}}`, map[string]string{"name": "world"}, "hello world", nil},
 	{`{{var}}`, map[string]string{"var": "5 > 2"}, "5 &gt; 2", nil},
@@ -105,7 +157,7 @@ var tests = []Test{
 	{`{{ a }}{{= <% %> =}}<%b %><%= {{ }}=%>{{c}}`, map[string]string{"a": "a", "b": "b", "c": "c"}, "abc", nil},
 
 	// section tests
-	{`{{#A}}`, Data{true, "hello"}, "", parseError{line: 1, message: "Section A has no closing tag"}},
+	{`{{#A}}`, Data{true, "hello"}, "", &ParseError{Line: 1, Column: 1, Message: "Section A has no closing tag"}},
 	{`{{#A}}{{B}}{{/A}}`, Data{true, "hello"}, "hello", nil},
 	{`{{#A}}{{{B}}}{{/A}}`, Data{true, "5 > 2"}, "5 > 2", nil},
 	{`{{#A}}{{B}}{{/A}}`, Data{true, "5 > 2"}, "5 &gt; 2", nil},
@@ -231,12 +283,15 @@ var tests = []Test{
 func TestBasic(t *testing.T) {
 	// Default behavior, AllowMissingVariables=true
 	for _, test := range tests {
+		if lightBuild && needsMethods(test.context) {
+			continue
+		}
 		tm, err := New().CompileString(test.tmpl)
 		var output string
 		if err == nil && tm != nil {
 			output, err = tm.Render(test.tmpl, test.context)
 		}
-		if err != test.err {
+		if !reflect.DeepEqual(err, test.err) {
 			t.Errorf("%q expected %q but got error %v", test.tmpl, test.expected, err)
 		} else if output != test.expected {
 			t.Errorf("%q expected %q got %q", test.tmpl, test.expected, output)
@@ -302,97 +357,252 @@ func TestMissing(t *testing.T) {
 	}
 }
 
-func TestFile(t *testing.T) {
-	filename := path.Join(path.Join(os.Getenv("PWD"), "tests"), "test1.mustache")
+func TestCompileBytesAndReader(t *testing.T) {
 	expected := "hello world"
-	cmpl, err := New().CompileFile(filename)
+	tmpl, err := New().CompileBytes([]byte("hello {{name}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := tmpl.Render(map[string]string{"name": "world"})
 	if err != nil {
 		t.Error(err)
+	} else if output != expected {
+		t.Errorf("CompileBytes expected %q got %q", expected, output)
 	}
-	output, err := cmpl.Render(map[string]string{"name": "world"})
+
+	tmpl, err = New().CompileReader(strings.NewReader("hello {{name}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err = tmpl.Render(map[string]string{"name": "world"})
 	if err != nil {
 		t.Error(err)
 	} else if output != expected {
-		t.Errorf("testfile expected %q got %q", expected, output)
+		t.Errorf("CompileReader expected %q got %q", expected, output)
 	}
 }
 
-func TestFRender(t *testing.T) {
-	filename := path.Join(path.Join(os.Getenv("PWD"), "tests"), "test1.mustache")
-	expected := "hello world"
-	tmpl, err := New().CompileFile(filename)
+type countingProvider struct {
+	StaticProvider
+	gets int
+}
+
+func (cp *countingProvider) Get(name string) (string, error) {
+	cp.gets++
+	return cp.StaticProvider.Get(name)
+}
+
+func TestPartialCache(t *testing.T) {
+	cp := &countingProvider{StaticProvider: StaticProvider{Partials: map[string]string{
+		"footer": "({{site}})",
+		"item":   "[{{name}}]",
+	}}}
+	tmpl, err := New().WithPartials(cp).WithPartialCache(true).CompileString("{{#items}}{{>item}}{{>footer}}{{/items}}")
 	if err != nil {
 		t.Fatal(err)
 	}
-	var buf bytes.Buffer
-	err = tmpl.Frender(&buf, map[string]string{"name": "world"})
+	data := map[string]interface{}{
+		"site":  "example",
+		"items": []map[string]string{{"name": "a"}, {"name": "b"}, {"name": "a"}},
+	}
+	output, err := tmpl.Render(data)
 	if err != nil {
 		t.Fatal(err)
 	}
-	output := buf.String()
+	expected := "[a](example)[b](example)[a](example)"
 	if output != expected {
-		t.Fatalf("testfile expected %q got %q", expected, output)
+		t.Errorf("expected %q got %q", expected, output)
+	}
+	// footer is loaded once, item once for each distinct name
+	if cp.gets != 3 {
+		t.Errorf("expected 3 partial loads, got %d", cp.gets)
 	}
-}
 
-func TestPartial(t *testing.T) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		t.Error(err)
+	// the cache does not outlive a single render
+	if _, err = tmpl.Render(data); err != nil {
+		t.Fatal(err)
 	}
-	testdir := path.Join(cwd, "tests")
-	filename := path.Join(testdir, "test2.mustache")
-	expected := "hello world"
-	tmpl, err := New().WithErrors(true).
-		WithPartials(&FileProvider{Paths: []string{testdir}, Extensions: []string{".mustache"}}).
-		CompileFile(filename)
+	if cp.gets != 6 {
+		t.Errorf("expected 6 partial loads, got %d", cp.gets)
+	}
+
+	// partials which refer explicitly to the data are cached by the values they refer to
+	cp.Partials["title"] = "[{{.title}}]"
+	tmpl, err = New().WithPartials(cp).WithPartialCache(true).CompileString("{{#items}}{{>title}}{{/items}}")
 	if err != nil {
-		t.Error(err)
-		return
+		t.Fatal(err)
+	}
+	output, err = tmpl.Render(map[string]interface{}{"items": []map[string]string{{"title": "a"}, {"title": "b"}}})
+	if err != nil || output != "[a][b]" {
+		t.Errorf("expected %q, got %q, %v", "[a][b]", output, err)
 	}
-	output, err := tmpl.Render(map[string]string{"Name": "world"})
+
+	// partials which use the position in the list are not cached
+	cp.Partials["index"] = "{{-index}}{{^-last}},{{/-last}}"
+	tmpl, err = New().WithPartials(cp).WithPartialCache(true).CompileString("{{#items}}{{>index}}{{/items}}")
 	if err != nil {
-		t.Error(err)
-		return
-	} else if output != expected {
-		t.Errorf("testpartial expected %q got %q", expected, output)
-		return
+		t.Fatal(err)
 	}
+	if output, err := tmpl.Render(data); err != nil || output != "1,2,3" {
+		t.Errorf("expected %q, got %q, %v", "1,2,3", output, err)
+	}
+}
 
-	expectedTags := []tag{
-		{
-			Type: Partial,
-			Name: "partial",
-		},
+func TestPartialIndentation(t *testing.T) {
+	partials := &StaticProvider{Partials: map[string]string{
+		"item":   "<li>{{n}}</li>\n",
+		"list":   "{{#items}}\n{{>item}}\n{{/items}}\n",
+		"lines":  ">\n>",
+		"value":  "|\n{{{content}}}\n|\n",
+		"blank":  "{{#none}}x{{/none}}\n\nend\n",
+		"lambda": "{{#lambda}}\na\n  b\n{{/lambda}}\n",
+	}}
+	data := map[string]interface{}{
+		"content": "<\n->",
+		"items":   []map[string]string{{"n": "1"}, {"n": "2"}},
+		"lambda":  func(text string, render RenderFn) (string, error) { return text, nil },
+	}
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		// only the lines of the partial are indented, not those of the values it interpolates
+		{"\\\n {{>value}}\n/\n", "\\\n |\n <\n->\n |\n/\n"},
+		{"<ul>\n  {{>list}}\n</ul>\n", "<ul>\n  <li>1</li>\n  <li>2</li>\n</ul>\n"},
+		{">\n  {{>lines}}", ">\n  >\n  >"},
+		// a partial tag which is not standalone is not indented
+		{"  {{>lines}} x\n", "  >\n> x\n"},
+		{"  {{>blank}}\n", "\n\n  end\n"},
+		{"  {{>lambda}}\n", "  a\n    b\n"},
+	}
+	for _, test := range tests {
+		for _, cache := range []bool{false, true} {
+			tmpl, err := New().WithPartials(partials).WithPartialCache(cache).CompileString(test.tmpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			output, err := tmpl.Render(data)
+			if err != nil {
+				t.Error(err)
+			} else if output != test.expected {
+				t.Errorf("%q expected %q got %q", test.tmpl, test.expected, output)
+			}
+		}
+	}
+}
+
+func TestIndentLines(t *testing.T) {
+	// indentLines replaced a regular expression, which it must match
+	re := regexp.MustCompile(`(?m:^(.+)$)`)
+	for _, data := range []string{"", "\n", "a", "a\n", "\n\na\n\nb", "a\r\n\r\nb\r\n", " \n\t\n"} {
+		expected := re.ReplaceAllString(data, "  $1")
+		if output := string(indentLines(data, "  ")); output != expected {
+			t.Errorf("%q: expected %q got %q", data, expected, output)
+		}
 	}
-	compareTags(t, tmpl.Tags(), expectedTags)
 }
 
-func TestPartialSafety(t *testing.T) {
-	tmpl, err := New().WithErrors(true).WithPartials(&FileProvider{}).CompileString("{{>../unsafe}}")
+func BenchmarkPartials(b *testing.B) {
+	partials := &StaticProvider{Partials: map[string]string{
+		"row":  "<tr>\n  {{>cell}}\n  {{>cell}}\n</tr>\n",
+		"cell": "<td>\n  {{name}}\n</td>\n",
+	}}
+	tmpl, err := New().WithPartials(partials).CompileString("<table>\n{{#rows}}\n  {{>row}}\n{{/rows}}\n</table>\n")
 	if err != nil {
-		t.Error(err)
+		b.Fatal(err)
 	}
-	txt, err := tmpl.Render(nil)
-	if err == nil {
-		t.Errorf("expected error for unsafe partial")
+	rows := make([]map[string]string, 100)
+	for i := range rows {
+		rows[i] = map[string]string{"name": strconv.Itoa(i)}
 	}
-	if txt != "" {
-		t.Errorf("expected unsafe partial to fail")
+	data := map[string]interface{}{"rows": rows}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := tmpl.Frender(io.Discard, data); err != nil {
+			b.Fatal(err)
+		}
 	}
 }
 
-func TestPartialSafetyWindows(t *testing.T) {
-	tmpl, err := New().WithErrors(true).WithPartials(&FileProvider{}).CompileString("{{>spec/..\\..\\test.txt}}")
+func TestPartialCompiledOnce(t *testing.T) {
+	tracer := &recordingTracer{}
+	partials := &StaticProvider{Partials: map[string]string{"p": "<{{name}}>"}}
+	tmpl, err := New().WithTracer(tracer).WithPartials(partials).CompileString("{{>p}}\n  {{>p}}\n")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	txt, err := tmpl.Render(nil)
-	if err == nil {
-		t.Errorf("expected error for unsafe partial")
+	compiles := func() int {
+		n := 0
+		for _, span := range tracer.spans {
+			if strings.HasSuffix(span.path, SpanCompile) {
+				n++
+			}
+		}
+		return n
+	}
+	tracer.spans = nil
+	for i := 0; i < 3; i++ {
+		if output, err := tmpl.Render(map[string]string{"name": "x"}); err != nil || output != "<x>  <x>" {
+			t.Fatalf("unexpected output %q, %v", output, err)
+		}
+	}
+	if n := compiles(); n != 1 {
+		t.Errorf("expected the partial to be compiled once, got %d compiles", n)
 	}
-	if txt != "" {
-		t.Errorf("expected unsafe partial to fail")
+
+	// a partial is compiled again when its source changes
+	partials.Partials["p"] = "[{{name}}]"
+	if output, err := tmpl.Render(map[string]string{"name": "x"}); err != nil || output != "[x]  [x]" {
+		t.Fatalf("unexpected output %q, %v", output, err)
+	}
+	if n := compiles(); n != 2 {
+		t.Errorf("expected the partial to be compiled again, got %d compiles", n)
+	}
+}
+
+func TestPartialEscapeMode(t *testing.T) {
+	data := map[string]string{"name": `Tom & "Jerry"`}
+	tests := []struct {
+		provider *StaticProvider
+		expected string
+	}{
+		{
+			&StaticProvider{Partials: map[string]string{"ld": `{"name": {{name}}}`}},
+			`<p>Tom &amp; &#34;Jerry&#34;</p><script>{"name": Tom &amp; &#34;Jerry&#34;}</script>`,
+		},
+		{
+			&StaticProvider{
+				Partials:    map[string]string{"ld": `{"name": {{name}}}`},
+				EscapeModes: map[string]EscapeMode{"ld": EscapeJSONValue},
+			},
+			`<p>Tom &amp; &#34;Jerry&#34;</p><script>{"name": "Tom \u0026 \"Jerry\""}</script>`,
+		},
+		{
+			&StaticProvider{
+				Partials:    map[string]string{"ld": "{{%ESCAPE JSON}}\n{\"name\": \"{{name}}\"}"},
+				EscapeModes: map[string]EscapeMode{"ld": Raw},
+			},
+			`<p>Tom &amp; &#34;Jerry&#34;</p><script>{"name": "Tom & \"Jerry\""}</script>`,
+		},
+	}
+	for _, test := range tests {
+		tmpl, err := New().WithPartials(test.provider).CompileString("<p>{{name}}</p><script>{{>ld}}</script>")
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Error(err)
+		} else if output != test.expected {
+			t.Errorf("expected %q got %q", test.expected, output)
+		}
+	}
+
+	for _, bad := range []string{"{{%ESCAPE}}", "{{%ESCAPE XML}}", "{{%UNKNOWN}}"} {
+		if _, err := New().CompileString(bad); err == nil {
+			t.Errorf("%q expected parse error", bad)
+		}
 	}
 }
 
@@ -448,6 +658,7 @@ func TestRenderRaw(t *testing.T) {
 }
 
 func TestCustomValueStringer(t *testing.T) {
+	skipLight(t)
 
 	type testStruct struct {
 		A string
@@ -486,7 +697,195 @@ func TestCustomValueStringer(t *testing.T) {
 	}
 }
 
+func TestScopedValueStringers(t *testing.T) {
+	upper := func(v any) (string, error) { return strings.ToUpper(fmt.Sprint(v)), nil }
+	brackets := func(v any) (string, error) { return fmt.Sprintf("[%v]", v), nil }
+	data := map[string]interface{}{"a": "x<", "list": []string{"y", "z"}}
+
+	tests := []struct {
+		compiler *Compiler
+		tmpl     string
+		expected string
+	}{
+		{New().WithTagValueStringer(RawVariable, upper), `{{a}}{{{a}}}{{&a}}`, "x&lt;X<X<"},
+		{New().WithTagValueStringer(ImplicitIterator, brackets), `{{a}}{{#list}}{{.}}{{/list}}`, "x&lt;[y][z]"},
+		{New().WithValueStringer(brackets).WithTagValueStringer(EscapedVariable, upper), `{{a}}{{{a}}}`, "X&lt;x<"},
+		{
+			New().WithValueStringer(brackets).WithEscapeModeValueStringer(EscapeJSON, toJSONString).WithEscapeMode(EscapeJSON),
+			`{{list}}`, `[\"y\",\"z\"]`,
+		},
+		{
+			New().WithValueStringer(brackets).WithEscapeModeValueStringer(EscapeJSON, toJSONString),
+			`{{list}}`, "[[y z]]",
+		},
+	}
+	for _, test := range tests {
+		tmpl, err := test.compiler.CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Error(err)
+		} else if output != test.expected {
+			t.Errorf("%q expected %q got %q", test.tmpl, test.expected, output)
+		}
+	}
+}
+
+func TestRenderJSONValue(t *testing.T) {
+	skipLight(t)
+	type person struct {
+		Name    string
+		Age     int
+		Admin   bool
+		Tags    []string
+		Manager *person
+	}
+	tmpl, err := JSONTemplate(`{"name": {{Name}}, "age": {{Age}}, "admin": {{Admin}}, "tags": {{Tags}}, "manager": {{Manager}}, "missing": {{Missing}}, "raw": {{{Age}}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt, err := tmpl.Render(person{Name: `Jo "JJ"`, Age: 25, Admin: true, Tags: []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"name": "Jo \"JJ\"", "age": 25, "admin": true, "tags": ["a"], "manager": null, "missing": null, "raw": 25}`
+	if txt != expected {
+		t.Errorf("expected %s got %s", expected, txt)
+	}
+}
+
+func TestPostValidator(t *testing.T) {
+	tmpl, err := JSONTemplate("{\"items\": [\n{{#items}}{{.}},{{/items}}\n]}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Frender(&buf, map[string]interface{}{"items": []int{1, 2}})
+	var oe *OutputError
+	if !errors.As(err, &oe) {
+		t.Fatalf("expected an OutputError, got %v", err)
+	}
+	if oe.Line != 3 || oe.Column != 1 {
+		t.Errorf("expected error at line 3, column 1, got line %d, column %d", oe.Line, oe.Column)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output for invalid JSON, got %q", buf.String())
+	}
+
+	output, err := tmpl.Render(map[string]interface{}{})
+	if err != nil {
+		t.Error(err)
+	} else if output != "{\"items\": [\n\n]}" {
+		t.Errorf("unexpected output %q", output)
+	}
+
+	tmpl, err = New().WithPostValidator(func(out []byte) error {
+		if bytes.Contains(out, []byte("forbidden")) {
+			return errors.New("forbidden word")
+		}
+		return nil
+	}).CompileString("{{word}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tmpl.Render(map[string]string{"word": "forbidden"}); err == nil {
+		t.Error("expected validation error")
+	}
+}
+
+func TestIterationNames(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a", "tags": []string{"x", "y"}},
+			map[string]interface{}{"name": "b", "tags": []string{"z"}, "info": map[string]string{"k": "v"}},
+		},
+		"single": map[string]string{"name": "s"},
+	}
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{`[{{#items}}"{{name}}"{{^-last}},{{/-last}}{{/items}}]`, `["a","b"]`},
+		{`{{#items}}{{-index}}:{{name}}{{#-first}}(first){{/-first}} {{/items}}`, `1:a(first) 2:b `},
+		{`{{#items}}{{name}}=[{{#tags}}{{.}}{{^-last}},{{/-last}}{{/tags}}]{{/items}}`, `a=[x,y]b=[z]`},
+		{`{{#items}}{{#info}}{{k}}{{-index}}{{/info}}{{/items}}`, `v2`},
+		{`{{#single}}{{name}}{{-index}}{{/single}}{{-first}}`, `s`},
+	}
+	for _, test := range tests {
+		tmpl, err := New().CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Error(err)
+		} else if output != test.expected {
+			t.Errorf("%q expected %q got %q", test.tmpl, test.expected, output)
+		}
+	}
+}
+
+func TestSiblingSections(t *testing.T) {
+	// sections at the same depth share context chains, which must not leak contexts between them
+	data := map[string]interface{}{
+		"x": "top",
+		"a": []map[string]interface{}{{"x": "a1", "b": []map[string]string{{"y": "b1"}, {"x": "b2"}}}, {"y": "a2"}},
+		"c": map[string]string{"y": "c"},
+	}
+	tmpl, err := New().CompileString("{{#a}}[{{x}}{{y}}{{#b}}({{x}}{{y}}){{/b}}]{{/a}}{{#c}}<{{x}}{{y}}>{{#b}}no{{/b}}{{/c}}{{x}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := tmpl.Render(data)
+	expected := "[a1(a1b1)(b2)][topa2]<topc>top"
+	if err != nil {
+		t.Fatal(err)
+	} else if output != expected {
+		t.Errorf("expected %q got %q", expected, output)
+	}
+}
+
+func TestReservedNames(t *testing.T) {
+	data := map[string]interface{}{
+		"items":  []map[string]interface{}{{"-index": "x"}, {"name": "b"}},
+		"-first": "data",
+	}
+	tests := []struct {
+		tmpl     string
+		strict   bool
+		expected string
+		err      string
+	}{
+		{`{{#items}}{{-index}}{{.-index}};{{/items}}`, false, "1x;2;", ""},
+		{`{{-first}}|{{.-first}}`, false, "data|data", ""},
+		{`{{#items}}{{.-index}};{{/items}}`, true, "x;;", ""},
+		{`{{.-first}}`, true, "data", ""},
+		{`{{#items}}{{-index}};{{/items}}`, true, "", `data shadows the reserved name "-index"; use ".-index" to refer to it`},
+		{`{{#items}}{{#-first}}first{{/-first}}{{/items}}`, true, "", `data shadows the reserved name "-first"`},
+		{`{{#items}}{{-last}}{{/items}}`, true, "falsetrue", ""},
+	}
+	for _, test := range tests {
+		tmpl, err := New().WithReservedNames(test.strict).CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(data)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: expected error %q, got %v", test.tmpl, test.err, err)
+			}
+		} else if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+		} else if output != test.expected {
+			t.Errorf("%q expected %q got %q", test.tmpl, test.expected, output)
+		}
+	}
+}
+
 func TestRenderJSON(t *testing.T) {
+	skipLight(t)
 	type item struct {
 		Emoji string
 		Name  string
@@ -544,20 +943,92 @@ func TestRenderJSON(t *testing.T) {
 	}
 }
 
-// Make sure bugs caught by fuzz testing don't creep back in
+// Make sure bugs caught by fuzz testing don't creep back in. Each file in testdata/crashers is a template which must
+// fail to compile with a parse error; if a matching .err file exists, it holds the expected error message.
 func TestCrashers(t *testing.T) {
-	crashers := []string{
-		`{{#}}{{#}}{{#}}{{#}}{{#}}{{=}}`,
-		`{{#}}{{#}}{{#}}{{#}}{{#}}{{#}}{{#}}{{#}}{{=}}`,
-		`{{=}}`,
-	}
-	for i, c := range crashers {
-		t.Log(i)
-		_, err := New().CompileString(c)
+	crashers, err := filepath.Glob(filepath.Join("testdata", "crashers", "*.mustache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crashers) == 0 {
+		t.Fatal("no crashers found in testdata/crashers")
+	}
+	for _, c := range crashers {
+		data, err := os.ReadFile(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = New().CompileBytes(data)
 		if err == nil {
-			t.Error(err)
+			t.Errorf("%s: expected parse error", c)
+			continue
+		}
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%s: expected parse error, got %T: %v", c, err, err)
+		}
+		expected, rerr := os.ReadFile(strings.TrimSuffix(c, ".mustache") + ".err")
+		if rerr == nil && err.Error() != strings.TrimSpace(string(expected)) {
+			t.Errorf("%s: expected error %q got %q", c, strings.TrimSpace(string(expected)), err.Error())
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	_, err := New().WithMaxTemplateBytes(8).CompileString("hello {{name}}")
+	if !errors.Is(err, ErrTemplateTooLarge) {
+		t.Errorf("expected ErrTemplateTooLarge, got %v", err)
+	}
+	_, err = New().WithMaxTemplateBytes(8).CompileReader(strings.NewReader("hello {{name}}"))
+	if !errors.Is(err, ErrTemplateTooLarge) {
+		t.Errorf("expected ErrTemplateTooLarge from reader, got %v", err)
+	}
+	if _, err = New().WithMaxTemplateBytes(14).CompileString("hello {{name}}"); err != nil {
+		t.Error(err)
+	}
+
+	deep := strings.Repeat("{{#a}}", 4) + "\n" + strings.Repeat("{{/a}}", 4)
+	_, err = New().WithMaxSectionDepth(3).CompileString(deep)
+	var le *LimitError
+	if !errors.As(err, &le) || !errors.Is(err, ErrSectionTooDeep) {
+		t.Errorf("expected ErrSectionTooDeep, got %v", err)
+	} else if le.Max != 3 || le.Line != 1 {
+		t.Errorf("expected limit 3 at line 1, got %d at line %d", le.Max, le.Line)
+	}
+	if _, err = New().WithMaxSectionDepth(4).CompileString(deep); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeepNesting(t *testing.T) {
+	const depth = 2000
+	tmpl, err := New().CompileString(strings.Repeat("{{#a}}", depth) + "{{b}}" + strings.Repeat("{{/a}}", depth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := tmpl.Render(map[string]interface{}{"a": true, "b": "deep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "deep" {
+		t.Errorf("expected %q got %q", "deep", output)
+	}
+}
+
+func FuzzCompile(f *testing.F) {
+	crashers, _ := filepath.Glob(filepath.Join("testdata", "crashers", "*.mustache"))
+	for _, c := range crashers {
+		if data, err := os.ReadFile(c); err == nil {
+			f.Add(string(data))
 		}
 	}
+	f.Fuzz(func(t *testing.T, data string) {
+		tmpl, err := New().CompileString(data)
+		if err != nil {
+			return
+		}
+		_, _ = tmpl.Render(map[string]interface{}{})
+	})
 }
 
 /*
@@ -572,6 +1043,7 @@ func TestCrashers(t *testing.T) {
 	}
 */
 func TestMultiContext(t *testing.T) {
+	skipLight(t)
 	tmpl, err := New().CompileString(`{{hello}} {{World}}`)
 	if err != nil {
 		t.Error(err)
@@ -632,11 +1104,15 @@ func TestLambdaError(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	output, _ := tmpl.Render(data)
+	output, err := tmpl.Render(data)
 	expect := "stop_at_error."
 	if output != expect {
 		t.Fatalf("TestLambdaError expected %q got %q", expect, output)
 	}
+	var lerr *LambdaError
+	if !errors.As(err, &lerr) || lerr.Name != "lambda" || lerr.Line != 1 || lerr.Err.Error() != "test err" {
+		t.Fatalf("TestLambdaError expected a *LambdaError, got %v", err)
+	}
 }
 
 var malformed = []Test{
@@ -645,7 +1121,46 @@ var malformed = []Test{
 	{`{{}`, nil, "", fmt.Errorf("line 1: unmatched open tag")},
 	{`{{`, nil, "", fmt.Errorf("line 1: unmatched open tag")},
 	// invalid syntax - https://github.com/hoisie/mustache/issues/10
-	{`{{#a}}{{#b}}{{/a}}{{/b}}}`, map[string]interface{}{}, "", fmt.Errorf("line 1: interleaved closing tag: a")},
+	{`{{#a}}{{#b}}{{/a}}{{/b}}}`, map[string]interface{}{}, "", fmt.Errorf("line 1: interleaved closing tag: a (expected b, opened at line 1 column 7; closed at line 1 column 13)")},
+	{"{{#a}}\n  {{#b}}\n  x\n{{/a}}\n", map[string]interface{}{}, "", fmt.Errorf("line 4: interleaved closing tag: a (expected b, opened at line 2 column 3; closed at line 4 column 1)")},
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	tests := []struct {
+		tmpl     string
+		expected ParseError
+	}{
+		{"abc {{/a}}", ParseError{Line: 1, Column: 5, Offset: 4, Message: "unmatched close tag"}},
+		{"a\nbc {{", ParseError{Line: 2, Column: 4, Offset: 5, Message: "unmatched open tag"}},
+		{"a\n\n  {{#x}}\ny", ParseError{Line: 3, Column: 3, Offset: 5, Message: "Section x has no closing tag"}},
+		{"{{#a}}\n {{/b}}", ParseError{Line: 2, Column: 2, Offset: 8, Message: "interleaved closing tag: b (expected a, opened at line 1 column 1; closed at line 2 column 2)"}},
+		{"\u00e9 {{%NOPE}}", ParseError{Line: 1, Column: 4, Offset: 3, Message: "unknown pragma: NOPE"}},
+	}
+	for _, test := range tests {
+		_, err := New().CompileString(test.tmpl)
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%q: expected a *ParseError, got %T: %v", test.tmpl, err, err)
+			continue
+		}
+		if *pe != test.expected {
+			t.Errorf("%q: expected %+v, got %+v", test.tmpl, test.expected, *pe)
+		}
+		if !strings.HasPrefix(test.tmpl[pe.Offset:], "{{") {
+			t.Errorf("%q: offset %d is not that of a tag", test.tmpl, pe.Offset)
+		}
+	}
+
+	// the errors of partials which fail to parse as they are rendered expose their ParseError
+	tmpl, err := New().WithPartials(&StaticProvider{Partials: map[string]string{"p": "x\n{{#a}}"}}).CompileString("{{>p}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tmpl.Render(nil)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 2 || pe.Offset != 2 || pe.Message != "Section a has no closing tag" {
+		t.Errorf("expected a *ParseError, got %v", err)
+	}
 }
 
 func TestMalformed(t *testing.T) {
@@ -671,6 +1186,80 @@ func TestMalformed(t *testing.T) {
 	}
 }
 
+func TestStrictDelimiters(t *testing.T) {
+	tests := []struct {
+		tmpl     string
+		expected string
+		err      string
+	}{
+		{"{{=<% %>=}}<% a %>", "1", ""},
+		{"{{= <%   %> =}}<% a %>", "1", ""},
+		{"{{=<%=}}<% a %>{{a}}", "", "line 1: set delimiter tag at column 1 has 1 delimiters rather than 2"},
+		{"x\n  {{=<% %> |=}}", "", "line 2: set delimiter tag at column 3 has 3 delimiters rather than 2"},
+		{"{{=<%= %>=}}", "", "line 1: set delimiter tag at column 1 has a delimiter containing '='"},
+	}
+	for _, test := range tests {
+		tmpl, err := New().WithStrictParsing(true).CompileString(test.tmpl)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: expected error %q, got %v", test.tmpl, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+			continue
+		}
+		if output, _ := tmpl.Render(map[string]int{"a": 1}); output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.tmpl, test.expected, output)
+		}
+	}
+
+	// without strict parsing, the malformed tag is ignored
+	tmpl, err := New().CompileString("{{=<%=}}<% a %>{{a}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := tmpl.Render(map[string]int{"a": 1}); output != "<% a %>1" {
+		t.Errorf("expected the tag to be ignored, got %q", output)
+	}
+}
+
+func TestCompileStringLenient(t *testing.T) {
+	tests := []struct {
+		tmpl     string
+		expected string
+		errs     []string
+	}{
+		{"{{#a}}x{{/b}}y", "xy", []string{"line 1: interleaved closing tag: b", "line 1: Section a has no closing tag"}},
+		{"{{#a}}{{#b}}{{/a}}z{{#b}}!{{/b}}", "z!", []string{"line 1: interleaved closing tag: a"}},
+		{"Hi {{}}{{name}} {{/x}}{{=bad}}\n{{#a}}\n{{unclosed", "Hi Jo \n{{unclosed", []string{"line 1: empty tag", "line 1: unmatched close tag", "line 1: invalid meta tag", "line 3: unmatched open tag", "line 2: Section a has no closing tag"}},
+		{"{{name}}", "Jo", nil},
+	}
+	for _, test := range tests {
+		tmpl, errs := New().CompileStringLenient(test.tmpl)
+		if len(errs) != len(test.errs) {
+			t.Errorf("%q: expected %d errors, got %v", test.tmpl, len(test.errs), errs)
+			continue
+		}
+		for i, err := range errs {
+			if !strings.HasPrefix(err.Error(), test.errs[i]) {
+				t.Errorf("%q: expected error %q, got %q", test.tmpl, test.errs[i], err)
+			}
+		}
+		output, err := tmpl.Render(map[string]interface{}{"name": "Jo", "a": true, "b": true})
+		if err != nil {
+			t.Error(err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.tmpl, test.expected, output)
+		}
+	}
+
+	if _, errs := New().WithMaxTemplateBytes(2).CompileStringLenient("{{a}}"); len(errs) != 1 || !errors.Is(errs[0], ErrTemplateTooLarge) {
+		t.Errorf("expected a size error, got %v", errs)
+	}
+}
+
 type LayoutTest struct {
 	layout   string
 	tmpl     string
@@ -741,6 +1330,7 @@ func (p Person) Name2() string {
 }
 
 func TestPointerReceiver(t *testing.T) {
+	skipLight(t)
 	p := Person{"John", "Smith"}
 	tests := []struct {
 		tmpl     string
@@ -849,6 +1439,40 @@ func testTags(t *testing.T, test *tagsTest) {
 	compareTags(t, tmpl.Tags(), test.tags)
 }
 
+func TestTagPositions(t *testing.T) {
+	tmpl, err := New().CompileString("<ul>\n{{#items}}\n  <li>{{name}}</li>{{>item}}\n{{/items}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"items 2:1@5", "name 3:7@22", "item 3:20@35"}
+	if positions := tagPositions(tmpl.Tags()); !reflect.DeepEqual(positions, expected) {
+		t.Errorf("expected %v, got %v", expected, positions)
+	}
+	if tag := tmpl.Tags()[0]; string(tmpl.data[tag.ByteOffset():tag.ByteOffset()+9]) != "{{#items}" {
+		t.Errorf("expected the offset to point at the tag, got %d", tag.ByteOffset())
+	}
+
+	ast, _ := tmpl.MarshalJSON()
+	if tmpl, err = New().CompileJSON(ast); err != nil {
+		t.Fatal(err)
+	}
+	if tag := tmpl.Tags()[0].Tags()[0]; tag.Line() != 0 {
+		t.Errorf("expected no position for a tag without source, got line %d", tag.Line())
+	}
+}
+
+// tagPositions lists the names and positions of tags and their children, as "name line:column@offset".
+func tagPositions(tags []Tag) []string {
+	var positions []string
+	for _, tag := range tags {
+		positions = append(positions, fmt.Sprintf("%s %d:%d@%d", tag.Name(), tag.Line(), tag.Column(), tag.ByteOffset()))
+		if tag.Type() != Variable {
+			positions = append(positions, tagPositions(tag.Tags())...)
+		}
+	}
+	return positions
+}
+
 func compareTags(t *testing.T, actual []Tag, expected []tag) {
 	if len(actual) != len(expected) {
 		t.Errorf("expected %d tags, got %d", len(expected), len(actual))
@@ -883,3 +1507,138 @@ func compareTags(t *testing.T, actual []Tag, expected []tag) {
 		}
 	}
 }
+
+type pointerKey string
+
+type pointerStringer struct{ s string }
+
+func (p *pointerStringer) String() string { return "stringer " + p.s }
+
+func TestPointerChains(t *testing.T) {
+	skipLight(t)
+	inner := map[string]interface{}{"b": "B"}
+	pinner := &inner
+	var iface interface{} = pinner
+	s := "text"
+	ps := &s
+	n := 0
+	tests := []struct {
+		template string
+		data     interface{}
+		expected string
+	}{
+		{"{{a.b}}", &map[string]interface{}{"a": inner}, "B"},
+		{"{{a.b}}", map[string]*map[string]interface{}{"a": pinner}, "B"},
+		{"{{a.b}}", map[string]interface{}{"a": &pinner}, "B"},
+		{"{{a.b}}", map[string]interface{}{"a": &iface}, "B"},
+		{"{{#a}}{{b}}{{/a}}", map[string]interface{}{"a": &iface}, "B"},
+		{"{{#a}}{{b}}{{/a}}", map[string]interface{}{"a": &[]interface{}{pinner, &iface}}, "BB"},
+		{"{{#a}}{{.}}{{/a}}", map[string]interface{}{"a": &[]*string{ps, ps}}, "texttext"},
+		{"{{a.B}}", map[string]interface{}{"a": &struct{ B **string }{&ps}}, "text"},
+		// pointers are written as what they point to
+		{"{{a}} {{{a}}}", map[string]interface{}{"a": &ps}, "text text"},
+		{"{{a}}", map[string]interface{}{"a": &n}, "0"},
+		{"{{a}}", map[string]interface{}{"a": &inner}, "map[b:B]"},
+		{"{{a}}", map[string]interface{}{"a": &pointerStringer{"x"}}, "stringer x"},
+		// maps with keys of other types
+		{"{{a}}", map[pointerKey]string{"a": "named"}, "named"},
+		{"{{a}}", map[interface{}]interface{}{"a": "any"}, "any"},
+		{"[{{a}}]", map[int]string{1: "int"}, "[]"},
+	}
+	for _, test := range tests {
+		tmpl, err := New().CompileString(test.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(test.data)
+		if err != nil {
+			t.Errorf("%q: %v", test.template, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.template, test.expected, output)
+		}
+	}
+}
+
+type nilUser struct{ Name string }
+
+func (u *nilUser) Greeting() string { return "hello " + u.Name }
+
+func TestNilMatrix(t *testing.T) {
+	var user *nilUser
+	var iface interface{} = user
+	var nilMap map[string]interface{}
+	var nilSlice []interface{}
+	nils := map[string]interface{}{
+		"nil":                  nil,
+		"nil pointer":          user,
+		"interface of nil":     iface,
+		"pointer to interface": &iface,
+		"pointer to pointer":   &user,
+		"nil map":              nilMap,
+		"nil slice":            nilSlice,
+		"pointer to nil map":   &nilMap,
+	}
+	type holder struct {
+		X interface{}
+	}
+	for desc, value := range nils {
+		// the same value in each position a name can be resolved in
+		placements := []struct {
+			where    string
+			template string
+			data     interface{}
+		}{
+			{"map", "{{#X}}yes{{/X}}{{^X}}no{{/X}}{{X.Name}}{{X.Greeting}}", map[string]interface{}{"X": value}},
+			{"struct", "{{#X}}yes{{/X}}{{^X}}no{{/X}}{{X.Name}}{{X.Greeting}}", holder{value}},
+			{"pointer to struct", "{{#X}}yes{{/X}}{{^X}}no{{/X}}{{X.Name}}{{X.Greeting}}", &holder{value}},
+			{"nested", "{{#a.b.X}}yes{{/a.b.X}}{{^a.b.X}}no{{/a.b.X}}{{a.b.X.Name}}{{a.b.X.Greeting}}",
+				map[string]interface{}{"a": &map[string]interface{}{"b": &holder{value}}}},
+			{"list element", "{{#list}}{{#.}}yes{{/.}}{{^.}}no{{/.}}{{Name}}{{Greeting}}{{/list}}",
+				map[string]interface{}{"list": []interface{}{value}}},
+			{"section context", "{{#h}}{{#X}}yes{{/X}}{{^X}}no{{/X}}{{#X}}{{Greeting}}{{/X}}{{/h}}",
+				map[string]interface{}{"h": []holder{{value}}}},
+		}
+		for _, p := range placements {
+			tmpl, err := New().CompileString(p.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			output, err := tmpl.Render(p.data)
+			if err != nil || output != "no" {
+				t.Errorf("%s in %s: expected %q, got %q, %v", desc, p.where, "no", output, err)
+			}
+		}
+	}
+}
+
+// upperLookuper resolves every name to its upper case, and "nested" to another upperLookuper.
+type upperLookuper bool
+
+func (upperLookuper) LookupName(name string) (interface{}, bool) {
+	switch name {
+	case "nested":
+		return upperLookuper(true), true
+	case "absent":
+		return nil, false
+	case "unset":
+		return nil, true
+	}
+	return strings.ToUpper(name), true
+}
+
+func TestNameLookuper(t *testing.T) {
+	tmpl, err := New().WithErrors(true).CompileString("{{a}} {{nested.b}} {{#nested}}{{c}}{{/nested}}{{^unset}} unset{{/unset}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(upperLookuper(true)); err != nil || output != "A B C unset" {
+		t.Errorf("got %q, %v", output, err)
+	}
+	tmpl, err = New().WithErrors(true).CompileString("{{absent}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(upperLookuper(true), map[string]string{"absent": "found"}); err != nil {
+		t.Errorf("expected the name to be found in the next context, got %v", err)
+	}
+}
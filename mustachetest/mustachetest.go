@@ -0,0 +1,237 @@
+// Package mustachetest helps test mustache templates by rendering them with randomly generated contexts, checking
+// that rendering never panics or fails and that HTML templates always escape the values they write:
+//
+//	func TestPage(t *testing.T) {
+//		mustachetest.Check(t, page, 1000)
+//	}
+package mustachetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hayeah/mustache/v2"
+)
+
+// Probe is contained in some of the strings a Generator produces. It is an HTML tag, so it only appears in the output
+// of a template in the HTML escape mode if the template writes a value without escaping it.
+const Probe = "<mustache-probe>"
+
+// stringValues holds the strings a Generator chooses from: ones with HTML and JSON metacharacters, other scripts, control
+// characters and mustache tags, which must be written as text.
+var stringValues = []string{
+	"",
+	"plain",
+	Probe,
+	`"'&<>`,
+	`</script>` + Probe + `<script>alert(1)</script>`,
+	`" onmouseover="` + Probe,
+	"ünïcødé ✓ 漢字",
+	"line\nbreak\ttab\r\n",
+	"{{name}}{{{name}}}",
+	"\x00\x1f\\",
+}
+
+// Generator generates random contexts for a template, with a value for each name the template looks up. Its fields
+// may be changed before the first call to Next.
+type Generator struct {
+	// Rand is the source of randomness. GenContext seeds it with 1, so that the contexts, and any failures, are the
+	// same on each run.
+	Rand *rand.Rand
+	// MaxItems bounds the length of the lists for sections, which is at least 0.
+	MaxItems int
+	// MaxDepth bounds the nesting of lists within lists, for sections over lists.
+	MaxDepth int
+
+	root *node
+}
+
+// node is a name in the schema of a template: a value which is looked up, with the names looked up within it.
+type node struct {
+	children map[string]*node
+	section  bool // whether the value is used as a section
+	raw      bool // whether the value is written without escaping
+}
+
+func newNode() *node {
+	return &node{children: make(map[string]*node)}
+}
+
+// GenContext returns a Generator for the contexts of tmpl. The names the template looks up, including those within
+// sections and in the arguments of helpers, are derived from the template; partials are not looked into, so the names
+// only they look up are missing from the contexts.
+func GenContext(tmpl *mustache.Template) *Generator {
+	g := &Generator{Rand: rand.New(rand.NewSource(1)), MaxItems: 3, MaxDepth: 3, root: newNode()}
+	g.add(g.root, tmpl.AST().Nodes)
+	return g
+}
+
+// add adds the names looked up by nodes to the schema, within scope.
+func (g *Generator) add(scope *node, nodes []mustache.ASTNode) {
+	for _, n := range nodes {
+		switch n.Type {
+		case mustache.NodeVariable:
+			if v := lookup(scope, n.Name); v != nil && n.Raw {
+				v.raw = true
+			}
+		case mustache.NodeHelper:
+			for _, arg := range n.Args {
+				if v := lookup(scope, arg.Name); v != nil && n.Raw {
+					v.raw = true
+				}
+			}
+		case mustache.NodePartial:
+			lookup(scope, n.Context)
+			for _, param := range n.Params {
+				lookup(scope, param.Name)
+			}
+		case mustache.NodeSection:
+			v := lookup(scope, n.Name)
+			if v == nil || n.Condition || n.Inverted {
+				// the contents are rendered in the enclosing context
+				if v != nil {
+					v.section = true
+				}
+				g.add(scope, n.Nodes)
+				continue
+			}
+			v.section = true
+			g.add(v, n.Nodes)
+		}
+	}
+}
+
+// lookup returns the node for name within scope, adding it if need be, or nil if the name does not refer to the data.
+func lookup(scope *node, name string) *node {
+	if len(name) > 1 && name[0] == '.' {
+		name = name[1:]
+	} else if name == "" || name == "." || name[0] == '-' {
+		return nil
+	}
+	v := scope
+	for _, part := range strings.Split(name, ".") {
+		child, ok := v.children[part]
+		if !ok {
+			child = newNode()
+			v.children[part] = child
+		}
+		v = child
+	}
+	return v
+}
+
+// Next returns a new random context.
+func (g *Generator) Next() map[string]interface{} {
+	return g.object(g.root, 0)
+}
+
+func (g *Generator) object(n *node, depth int) map[string]interface{} {
+	// the names are visited in order, so that the same seed generates the same contexts
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	obj := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		obj[name] = g.value(n.children[name], depth)
+	}
+	return obj
+}
+
+// value returns a random value for n.
+func (g *Generator) value(n *node, depth int) interface{} {
+	r := g.Rand
+	if !n.section {
+		if len(n.children) > 0 && r.Intn(8) > 0 {
+			return g.object(n, depth)
+		}
+		return g.leaf(n.raw)
+	}
+	switch r.Intn(6) {
+	case 0:
+		return nil
+	case 1:
+		return r.Intn(2) == 0
+	case 2:
+		if len(n.children) > 0 {
+			return g.object(n, depth)
+		}
+		return g.leaf(n.raw)
+	}
+	items := make([]interface{}, r.Intn(g.MaxItems+1))
+	for i := range items {
+		switch {
+		case len(n.children) > 0:
+			items[i] = g.object(n, depth+1)
+		case depth < g.MaxDepth && r.Intn(4) == 0:
+			items[i] = g.value(n, depth+1)
+		default:
+			items[i] = g.leaf(n.raw)
+		}
+	}
+	return items
+}
+
+// leaf returns a random string, number, boolean or nil. Strings for values written without escaping don't contain
+// the probe.
+func (g *Generator) leaf(raw bool) interface{} {
+	r := g.Rand
+	switch r.Intn(8) {
+	case 0:
+		return nil
+	case 1:
+		return r.Intn(2) == 0
+	case 2:
+		return r.Intn(2001) - 1000
+	case 3:
+		return r.NormFloat64() * 1e6
+	}
+	s := stringValues[r.Intn(len(stringValues))]
+	if raw {
+		s = strings.ReplaceAll(s, Probe, "probe")
+	}
+	return s
+}
+
+// Check renders tmpl with n contexts from GenContext, and reports through t each render which panics or fails, or,
+// in the HTML escape mode, writes the Probe, so without escaping a value which is not written by a raw tag.
+func Check(t testing.TB, tmpl *mustache.Template, n int) {
+	t.Helper()
+	g := GenContext(tmpl)
+	for i := 0; i < n; i++ {
+		ctx := g.Next()
+		output, err := render(tmpl, ctx)
+		switch {
+		case err != nil:
+			t.Errorf("%v\ncontext: %s", err, describe(ctx))
+		case tmpl.EscapeMode() == mustache.EscapeHTML && strings.Contains(output, Probe):
+			t.Errorf("a value was written without escaping: %q\ncontext: %s", output, describe(ctx))
+		default:
+			continue
+		}
+		return
+	}
+}
+
+// render renders tmpl with ctx, turning a panic into an error.
+func render(tmpl *mustache.Template, ctx interface{}) (output string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("render panicked: %v", r)
+		}
+	}()
+	return tmpl.Render(ctx)
+}
+
+func describe(ctx interface{}) string {
+	b, err := json.Marshal(ctx)
+	if err != nil {
+		return fmt.Sprintf("%#v", ctx)
+	}
+	return string(b)
+}
@@ -0,0 +1,93 @@
+package mustachetest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hayeah/mustache/v2"
+)
+
+// recorder records the failures Check reports.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestGenContext(t *testing.T) {
+	tmpl, err := mustache.New().CompileString(
+		"{{title}} {{user.name}}{{#items}}{{label}}{{#tags}}{{.}}{{/tags}}{{/items}}{{^items}}{{empty}}{{/items}}{{-first}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := GenContext(tmpl)
+	sawList, sawProbe := false, false
+	for i := 0; i < 200; i++ {
+		ctx := g.Next()
+		for _, name := range []string{"title", "user", "items", "empty"} {
+			if _, ok := ctx[name]; !ok {
+				t.Fatalf("context without %q: %v", name, ctx)
+			}
+		}
+		if len(ctx) != 4 {
+			t.Fatalf("unexpected names in %v", ctx)
+		}
+		if items, ok := ctx["items"].([]interface{}); ok && len(items) > 0 {
+			sawList = true
+			if item, ok := items[0].(map[string]interface{}); !ok || len(item) != 2 {
+				t.Fatalf("unexpected item %v", items[0])
+			}
+		}
+		if s, ok := ctx["title"].(string); ok && strings.Contains(s, Probe) {
+			sawProbe = true
+		}
+	}
+	if !sawList || !sawProbe {
+		t.Errorf("expected lists and probes among the contexts")
+	}
+
+	// the same seed generates the same contexts
+	a, b := describe(GenContext(tmpl).Next()), describe(GenContext(tmpl).Next())
+	if a != b {
+		t.Errorf("expected the same context, got %s and %s", a, b)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	partials := &mustache.StaticProvider{Partials: map[string]string{"raw": "{{{name}}}"}}
+	cmpl := mustache.New().WithPartials(partials).WithHelper("boom", func(args ...interface{}) (string, error) {
+		if s, ok := args[0].(string); ok && s == "plain" {
+			panic("boom")
+		}
+		return "", nil
+	})
+	tests := []struct {
+		template string
+		failure  string
+	}{
+		{"<p title=\"{{name}}\">{{#items}}{{name}}{{{html}}}{{/items}}</p>", ""},
+		// the partial writes the name without escaping, which the generator can't see
+		{"{{name}}{{>raw}}", "without escaping"},
+		{"{{boom name}}", "internal error while rendering: boom"},
+	}
+	for _, test := range tests {
+		tmpl, err := cmpl.CompileString(test.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &recorder{TB: t}
+		Check(r, tmpl, 200)
+		switch {
+		case test.failure == "" && len(r.errors) > 0:
+			t.Errorf("%q: unexpected failures %v", test.template, r.errors)
+		case test.failure != "" && (len(r.errors) != 1 || !strings.Contains(r.errors[0], test.failure)):
+			t.Errorf("%q: expected a failure containing %q, got %v", test.template, test.failure, r.errors)
+		}
+	}
+}
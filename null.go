@@ -0,0 +1,27 @@
+package mustache
+
+import (
+	"database/sql/driver"
+	"reflect"
+)
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// nullValue returns the value held by a nullable database value such as a sql.NullString, sql.NullInt64 or
+// sql.Null[T]: a struct with a Valid field which implements driver.Valuer. A value which is not valid is returned as
+// missing, so that a NULL column is falsy and writes nothing, or null in the EscapeJSONValue mode. Other values are
+// returned as they are.
+func nullValue(v reflect.Value) reflect.Value {
+	ind := indirect(v)
+	if isNil(ind) || ind.Kind() != reflect.Struct || !ind.Type().Implements(valuerType) || !ind.CanInterface() {
+		return v
+	}
+	if valid, ok := ind.Type().FieldByName("Valid"); !ok || valid.Type.Kind() != reflect.Bool {
+		return v
+	}
+	value, err := ind.Interface().(driver.Valuer).Value()
+	if err != nil {
+		return v
+	}
+	return reflect.ValueOf(value)
+}
@@ -0,0 +1,45 @@
+package mustache
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestNullValues(t *testing.T) {
+	skipLight(t)
+	name, count := "Ann", 3
+	data := map[string]interface{}{
+		"nick":    sql.NullString{String: "annie", Valid: true},
+		"email":   sql.NullString{},
+		"visits":  sql.NullInt64{Int64: 12, Valid: true},
+		"score":   sql.NullFloat64{},
+		"joined":  sql.NullTime{Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+		"name":    &name,
+		"count":   &count,
+		"noCount": (*int)(nil),
+	}
+	tests := []struct {
+		tmpl     string
+		mode     EscapeMode
+		expected string
+	}{
+		{"{{nick}}|{{email}}|{{visits}}|{{score}}|{{joined}}", EscapeHTML, "annie||12||2024-05-01 00:00:00 +0000 UTC"},
+		{"{{#nick}}<{{.}}>{{/nick}}{{^email}}no email{{/email}}{{#score}}score{{/score}}", EscapeHTML, "<annie>no email"},
+		{"{{nick.String}} {{email.Valid}}", EscapeHTML, "annie false"},
+		{`{"nick": {{nick}}, "email": {{email}}, "visits": {{visits}}}`, EscapeJSONValue, `{"nick": "annie", "email": null, "visits": 12}`},
+		{`{{name}} {{count}} {{plural count "item" "items"}} {{truncate name 2}}|{{truncate noCount 2}}`, EscapeHTML, "Ann 3 items An|"},
+	}
+	for _, test := range tests {
+		tmpl, err := New().WithEscapeMode(test.mode).WithErrors(true).CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.tmpl, test.expected, output)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package otelmustache reports the compilation and rendering of mustache templates as OpenTelemetry spans.
+//
+//	tmpl, err := mustache.New().WithTracer(otelmustache.NewTracer(nil)).CompileString(src)
+//	...
+//	out, err := tmpl.RenderContext(ctx, data)
+package otelmustache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hayeah/mustache/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope used for spans when NewTracer is given no TracerProvider.
+const ScopeName = "github.com/hayeah/mustache/v2/otelmustache"
+
+// Tracer implements mustache.Tracer using an OpenTelemetry tracer.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ mustache.Tracer = (*Tracer)(nil)
+
+// NewTracer returns a Tracer which creates spans with a tracer from tp, or from the global TracerProvider if tp is
+// nil.
+func NewTracer(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(ScopeName)}
+}
+
+// Start implements mustache.Tracer.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...mustache.Attribute) (context.Context, mustache.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(convert(attrs)...))
+	return ctx, spanAdapter{span}
+}
+
+type spanAdapter struct {
+	span trace.Span
+}
+
+func (s spanAdapter) SetAttributes(attrs ...mustache.Attribute) {
+	s.span.SetAttributes(convert(attrs)...)
+}
+
+func (s spanAdapter) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+func convert(attrs []mustache.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		switch v := attr.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(attr.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(attr.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(attr.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(attr.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
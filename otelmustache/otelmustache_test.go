@@ -0,0 +1,54 @@
+package otelmustache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hayeah/mustache/v2"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	cmpl := mustache.New().
+		WithTracer(NewTracer(tp)).
+		WithPartials(&mustache.StaticProvider{Partials: map[string]string{"p": "{{name}}"}})
+	tmpl, err := cmpl.CompileString("Hello {{>p}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	output, err := tmpl.RenderContext(ctx, map[string]string{"name": "world"})
+	parent.End()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "Hello world" {
+		t.Errorf("expected %q, got %q", "Hello world", output)
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	render, ok := spans[mustache.SpanRender]
+	if !ok {
+		t.Fatalf("no render span in %v", exporter.GetSpans())
+	}
+	if render.Parent.SpanID() != spans["request"].SpanContext.SpanID() {
+		t.Errorf("expected the render span to be a child of the request span")
+	}
+	if partial := spans[mustache.SpanPartial]; partial.Parent.SpanID() != render.SpanContext.SpanID() {
+		t.Errorf("expected the partial span to be a child of the render span")
+	}
+	attrs := make(map[string]interface{})
+	for _, kv := range render.Attributes {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attrs[mustache.AttrPartials] != int64(1) || attrs[mustache.AttrEscapeMode] != "HTML" {
+		t.Errorf("unexpected render span attributes %v", attrs)
+	}
+}
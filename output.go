@@ -0,0 +1,65 @@
+package mustache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// WithPostValidator sets a function which checks the complete output of each render. Output is buffered until it has
+// been validated, and if the validator returns an error, nothing is written and the error is returned from the render.
+func (r *Compiler) WithPostValidator(v func([]byte) error) *Compiler {
+	r.postValidator = v
+	return r
+}
+
+// WithPostProcessor adds a function which transforms the complete output of each render, such as MinifyHTML or a
+// RewriteLinks function. Processors run in the order they were added, before any post validator, and as they need
+// the complete output, it is buffered until they have run. If a processor returns an error, nothing is written and
+// the error is returned from the render. Partials are processed as part of the template which includes them.
+func (r *Compiler) WithPostProcessor(p func([]byte) ([]byte, error)) *Compiler {
+	r.postProcessors = append(r.postProcessors, p)
+	return r
+}
+
+// OutputError reports a problem found in rendered output, such as invalid JSON, and where in the output it was found.
+type OutputError struct {
+	Offset int // byte offset in the output, starting at 0
+	Line   int // line in the output, starting at 1
+	Column int // byte column in the line, starting at 1
+	Err    error
+}
+
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("output line %d, column %d: %s", e.Line, e.Column, e.Err)
+}
+
+func (e *OutputError) Unwrap() error {
+	return e.Err
+}
+
+func newOutputError(out []byte, offset int, err error) *OutputError {
+	if offset > len(out) {
+		offset = len(out)
+	}
+	line := bytes.Count(out[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(out[:offset], '\n')
+	return &OutputError{Offset: offset, Line: line, Column: column, Err: err}
+}
+
+// ValidateJSON is a post validator which checks that the output is a single valid JSON value, returning an
+// *OutputError locating the problem if not. Trailing commas left by loops are a typical mistake it catches.
+func ValidateJSON(out []byte) error {
+	var v json.RawMessage
+	err := json.Unmarshal(out, &v)
+	if err == nil {
+		return nil
+	}
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		// the offset counts the bytes read when the error was detected, including the offending one
+		return newOutputError(out, int(se.Offset)-1, err)
+	}
+	return newOutputError(out, len(out), err)
+}
@@ -0,0 +1,50 @@
+package mustache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// WithParseTrace writes a line to w for each step the parser takes: the text and tags it reads, with their positions,
+// whether each tag stands alone on its line and so has its line removed, and why, changes of delimiters, and errors.
+// It is meant for diagnosing templates whose whitespace or delimiters don't come out as expected:
+//
+//	page.mustache:3:1: tag "{{#items}}" at depth 0, standalone: its line is removed
+//	page.mustache:4:3: tag "{{name}}" at depth 1, not standalone: tags of its kind never are
+//
+// Partials are traced as they are compiled, which may be while templates are rendering, so w must be safe for
+// concurrent use if templates are rendered concurrently.
+func (r *Compiler) WithParseTrace(w io.Writer) *Compiler {
+	r.parseTrace = w
+	return r
+}
+
+// trace writes a line of the parse trace for the byte of the source at offset p, which is on the given line.
+func (tmpl *Template) trace(line, p int, format string, args ...interface{}) {
+	name := tmpl.name
+	if name == "" {
+		name = "template"
+	}
+	col := p - bytes.LastIndexByte(tmpl.data[:p], '\n')
+	fmt.Fprintf(tmpl.parent.parseTrace, "%s:%d:%d: %s\n", name, line, col, fmt.Sprintf(format, args...))
+}
+
+// traceTag traces a tag which has been read, before it is parsed, given the offset of its opening delimiter and the
+// number of sections open around it.
+func (tmpl *Template) traceTag(tag *tagReadingResult, indent []byte, tagStart, depth int) {
+	msg := fmt.Sprintf("tag %q at depth %d, %s", tmpl.otag+tag.tag+tmpl.ctag, depth, tag.why)
+	if len(indent) > 0 {
+		msg += fmt.Sprintf(", indentation %q", indent)
+	}
+	tmpl.trace(tmpl.tagLine, tagStart, "%s", msg)
+}
+
+// traceDelimiters traces the outcome of a set delimiter tag, given the delimiters before it.
+func (tmpl *Template) traceDelimiters(tagStart int, otag, ctag string) {
+	if otag == tmpl.otag && ctag == tmpl.ctag {
+		tmpl.trace(tmpl.tagLine, tagStart, "delimiters unchanged")
+		return
+	}
+	tmpl.trace(tmpl.tagLine, tagStart, "delimiters changed from %q %q to %q %q", otag, ctag, tmpl.otag, tmpl.ctag)
+}
@@ -0,0 +1,40 @@
+package mustache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTrace(t *testing.T) {
+	var trace strings.Builder
+	_, err := New().WithParseTrace(&trace).CompileString("Hi {{name}}\n{{#items}}\n  {{>item}}\n{{/items}}\n{{=<% %>=}}\n<%x%> {{= bad =}}\n<%= a =%>\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `template:1:1: text "Hi "
+template:1:4: tag "{{name}}" at depth 0, not standalone: text precedes it on its line
+template:1:12: text "\n"
+template:2:1: tag "{{#items}}" at depth 0, standalone: its line is removed
+template:3:3: tag "{{>item}}" at depth 1, standalone: its line is removed, indentation "  "
+template:4:1: tag "{{/items}}" at depth 1, standalone: its line is removed
+template:5:1: tag "{{=<% %>=}}" at depth 0, standalone: its line is removed
+template:5:1: delimiters changed from "{{" "}}" to "<%" "%>"
+template:6:1: tag "<%x%>" at depth 0, not standalone: tags of its kind never are
+template:6:6: text " {{= bad =}}\n"
+template:7:1: tag "<%= a =%>" at depth 0, standalone: its line is removed
+template:7:1: delimiters unchanged
+template:8:1: end of template, open sections: 0
+`
+	if trace.String() != expected {
+		t.Errorf("expected trace:\n%s\ngot:\n%s", expected, trace.String())
+	}
+
+	trace.Reset()
+	cmpl := New().WithParseTrace(&trace)
+	if _, err := cmpl.CompileString("x\n{{/a}}"); err == nil {
+		t.Fatal("expected a parse error")
+	}
+	if !strings.Contains(trace.String(), `template:2:1: error: line 2: unmatched close tag`) {
+		t.Errorf("expected the error to be traced, got:\n%s", trace.String())
+	}
+}
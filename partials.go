@@ -1,95 +1,279 @@
 package mustache
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
-	"regexp"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // PartialProvider comprises the behaviors required of a struct to be able to provide partials to the mustache rendering
 // engine.
 type PartialProvider interface {
-	// Get accepts the name of a partial and returns the parsed partial, if it could be found; a valid but empty
-	// template, if it could not be found; or nil and error if an error occurred (other than an inability to find
-	// the partial).
+	// Get accepts the name of a partial and returns the parsed partial, if it could be found; an error wrapping
+	// ErrPartialNotFound, or for compatibility a valid but empty template, if it could not be found; or nil and error
+	// if an error occurred (other than an inability to find the partial).
 	Get(name string) (string, error)
 }
 
-// FileProvider implements the PartialProvider interface by providing partials drawn from a filesystem. When a partial
-// named `NAME`  is requested, FileProvider searches each listed path for a file named as `NAME` followed by any of the
-// listed extensions. The default for `Paths` is to search the current working directory. The default for `Extensions`
-// is to examine, in order, no extension; then ".mustache"; then ".stache". If Unsafe is set, partial names are allowed
-// to begin with '.' or '..' after cleaning, meaning they can potentially refer to files outside any of the listed
-// directory paths.
-type FileProvider struct {
-	Paths      []string
+// ContextPartialProvider may be implemented by a PartialProvider whose partials are loaded with IO which can be
+// cancelled, such as reading files or making network requests. Partials are then loaded with GetContext, with the
+// context passed to RenderContext or FrenderContext, so that a render whose deadline passes stops waiting for them.
+// Other providers are called directly, and can't be cancelled: a render waits for their Get to return, though it
+// gives up before calling it once its context is done.
+type ContextPartialProvider interface {
+	PartialProvider
+	// GetContext returns a partial like Get, giving up with the context's error once it is done.
+	GetContext(ctx context.Context, name string) (string, error)
+}
+
+// getPartialSource loads the source of a partial, using GetContext if the provider implements ContextPartialProvider.
+func getPartialSource(ctx context.Context, partials PartialProvider, name string) (string, error) {
+	if p, ok := partials.(ContextPartialProvider); ok {
+		return p.GetContext(ctx, name)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return partials.Get(name)
+}
+
+// withContext calls get, returning early with the context's error if ctx is done first. get is called on another
+// goroutine if ctx can be cancelled, and carries on in the background if the render gives up on it, so it is only used
+// for IO which may block, such as that of FileProvider.
+func withContext(ctx context.Context, get func() (string, error)) (string, error) {
+	if ctx.Done() == nil {
+		return get()
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	type result struct {
+		data string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		defer func() { done <- res }()
+		// a panic here could not be recovered by the caller
+		defer recoverInternal(&res.err, "loading a partial")
+		res.data, res.err = get()
+	}()
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// FSProvider implements the PartialProvider interface by providing partials drawn from an fs.FS, such as an embed.FS,
+// so that a tree of embedded templates reads as a namespace rather than as file paths. A partial named `NAME` is read
+// from the file `NAME` followed by the first of the listed extensions which exists, in the Root directory of FS. So
+// with a Root of "templates", {{>cards/product}} includes "templates/cards/product.mustache". If Index is set, a name
+// which refers to a directory includes the file named Index in that directory instead, so that {{>cards}} can include
+// "templates/cards/index.mustache".
+//
+// Names are slash separated, as in fs.FS. A leading slash is ignored, and names with empty, "." or ".." segments are
+// refused, so that partials cannot be read from outside Root. Byte order marks are handled as by CompileFile.
+type FSProvider struct {
+	FS   fs.FS
+	Root string // the directory holding the partials, or "" or "." for the root of FS
+	// Extensions are tried in order. The default is ".mustache", then ".stache", then no extension, so that names may
+	// include the extension of their file.
 	Extensions []string
-	Unsafe     bool
+	Index      string // the name of the partial included for a directory, such as "index", or "" for none
+	// RawExtensions optionally lists the extensions, such as ".css" and ".js", of partials which are included verbatim,
+	// as described for RawPartialProvider. They are matched against the names of partials.
+	RawExtensions []string
 }
 
 // Get accepts the name of a partial and returns the parsed partial.
-func (fp *FileProvider) Get(name string) (string, error) {
-	clean := name
-	if !fp.Unsafe {
-		// Use a '/' prefix so filepath.Clean can prevent a directory traversal
-		cname := "/" + strings.Trim(name, "/\\")
-		cname = strings.ReplaceAll(filepath.Clean(cname), "\\", "/")
-		cname = strings.TrimLeft(cname, "/")
-		if cname != name || cname == "" {
-			return "", fmt.Errorf("unsafe partial name passed to FileProvider: %s", name)
-		}
-		clean = cname
-	}
-
-	var paths []string
-	if fp.Paths != nil {
-		paths = fp.Paths
-	} else {
-		paths = []string{""}
-	}
-
-	var exts []string
-	if fp.Extensions != nil {
-		exts = fp.Extensions
-	} else {
-		exts = []string{"", ".mustache", ".stache"}
-	}
-
-	var f *os.File
-	var err error
-	for _, p := range paths {
-		for _, e := range exts {
-			pname := filepath.Join(p, clean+e)
-			f, err = os.Open(pname)
-			if err == nil {
-				break
+func (p *FSProvider) Get(name string) (string, error) {
+	clean := strings.TrimPrefix(name, "/")
+	if !fs.ValidPath(clean) || clean == "." {
+		return "", fmt.Errorf("unsafe partial name passed to FSProvider: %s", name)
+	}
+	data, ok, err := p.read(clean)
+	if !ok && err == nil && p.Index != "" {
+		data, ok, err = p.read(path.Join(clean, p.Index))
+	}
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%s: %w", name, ErrPartialNotFound)
+	}
+	return data, nil
+}
+
+// read reads the file for a cleaned name with the first extension which names a regular file.
+func (p *FSProvider) read(name string) (string, bool, error) {
+	exts := p.Extensions
+	if exts == nil {
+		exts = []string{".mustache", ".stache", ""}
+	}
+	for _, ext := range exts {
+		f, err := p.FS.Open(path.Join(p.root(), name+ext))
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		if info, err := f.Stat(); err != nil || info.IsDir() {
+			continue
+		}
+		data, err := io.ReadAll(f)
+		if err == nil {
+			data, err = decodeFile(data)
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return string(data), true, nil
+	}
+	return "", false, nil
+}
+
+func (p *FSProvider) root() string {
+	if p.Root == "" {
+		return "."
+	}
+	return p.Root
+}
+
+// Names returns the sorted names of the partials in the provider: the path below Root of each file with one of the
+// Extensions, without the extension, and for directories holding an Index file, the path of the directory.
+func (p *FSProvider) Names() ([]string, error) {
+	exts := p.Extensions
+	if exts == nil {
+		exts = []string{".mustache", ".stache", ""}
+	}
+	seen := make(map[string]bool)
+	root := p.root()
+	err := fs.WalkDir(p.FS, root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(file, root), "/")
+		if root == "." {
+			rel = file
+		}
+		for _, ext := range exts {
+			if !strings.HasSuffix(rel, ext) {
+				continue
 			}
+			name := strings.TrimSuffix(rel, ext)
+			seen[name] = true
+			if p.Index != "" && path.Base(name) == p.Index && path.Dir(name) != "." {
+				seen[path.Dir(name)] = true
+			}
+			break
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
 
-	if f == nil {
-		return "", fmt.Errorf("%s: partial not found", name) // nil
+// RawPartial implements RawPartialProvider.
+func (p *FSProvider) RawPartial(name string) bool {
+	return hasRawExtension(name, p.RawExtensions)
+}
+
+var _ PartialProvider = (*FSProvider)(nil)
+var _ RawPartialProvider = (*FSProvider)(nil)
+
+// CompileFS compiles the template in the file name of fsys, such as an embed.FS, so that the main template can be
+// embedded along with its partials. Unless the compiler has a PartialProvider, partials are read from fsys as by an
+// FSProvider rooted at the root of fsys, so {{>shared/footer}} includes "shared/footer.mustache", and relative names
+// such as {{>./card}} are resolved against the directory of name. To compile templates kept in a directory of fsys,
+// pass fs.Sub(fsys, dir). Byte order marks are handled as by CompileFile.
+func (r *Compiler) CompileFS(fsys fs.FS, name string) (*Template, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
 	}
 	defer f.Close()
-
-	data, err := io.ReadAll(f)
+	data, err := r.readTemplate(f)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if data, err = decodeFile(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
 	}
+	cmpl := r
+	if r.partial == nil {
+		c := *r
+		c.partial = &FSProvider{FS: fsys}
+		if c.compiledPartials != nil {
+			// the partials of another file system must not be mistaken for those of this one
+			c.compiledPartials = newCompiledPartials()
+		}
+		cmpl = &c
+	}
+	return cmpl.compile(context.Background(), name, data)
+}
 
-	return string(data), nil
+// EscapeModeProvider may be implemented by a PartialProvider to declare that individual partials are rendered with
+// their own escape mode, regardless of the mode of the template including them; for instance, so that an HTML page
+// can include a JSON-LD script partial rendered with JSON escaping. An {{%ESCAPE mode}} pragma in the partial itself
+// takes precedence.
+type EscapeModeProvider interface {
+	// PartialEscapeMode returns the escape mode for the named partial, and whether it has one.
+	PartialEscapeMode(name string) (EscapeMode, bool)
 }
 
-var _ PartialProvider = (*FileProvider)(nil)
+// RawPartialProvider may be implemented by a PartialProvider to declare that individual partials are included
+// verbatim, as a tag such as {{>site.css raw}} includes any partial, rather than parsed as templates; for instance,
+// style sheets and scripts which hold {{ sequences of their own. Verbatim partials are still indented like the lines
+// of other partials when their tag stands alone.
+type RawPartialProvider interface {
+	// RawPartial reports whether the named partial is included verbatim.
+	RawPartial(name string) bool
+}
+
+// isRawPartial reports whether a partial of a provider is included verbatim whatever its tag.
+func isRawPartial(partials PartialProvider, name string) bool {
+	rp, ok := partials.(RawPartialProvider)
+	return ok && rp.RawPartial(name)
+}
+
+// hasRawExtension reports whether a partial name ends with one of the extensions, as set in the RawExtensions fields
+// of providers.
+func hasRawExtension(name string, exts []string) bool {
+	for _, ext := range exts {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
 
 // StaticProvider implements the PartialProvider interface by providing partials drawn from a map, which maps partial
-// name to template contents.
+// name to template contents. For compatibility, a partial missing from the map is treated as empty, unless
+// ReportMissing is set, in which case Get returns ErrPartialNotFound.
 type StaticProvider struct {
-	Partials map[string]string
+	Partials      map[string]string
+	ReportMissing bool
+	// EscapeModes optionally sets the escape mode of individual partials, as described for EscapeModeProvider.
+	EscapeModes map[string]EscapeMode
+	// RawPartials optionally marks partials which are included verbatim, as described for RawPartialProvider.
+	RawPartials map[string]bool
 }
 
 // Get accepts the name of a partial and returns the parsed partial.
@@ -100,23 +284,436 @@ func (sp *StaticProvider) Get(name string) (string, error) {
 		}
 	}
 
+	if sp.ReportMissing {
+		return "", fmt.Errorf("%s: %w", name, ErrPartialNotFound)
+	}
 	return "", nil
 }
 
+// PartialEscapeMode returns the escape mode set for the named partial in EscapeModes.
+func (sp *StaticProvider) PartialEscapeMode(name string) (EscapeMode, bool) {
+	mode, ok := sp.EscapeModes[name]
+	return mode, ok
+}
+
+// RawPartial implements RawPartialProvider.
+func (sp *StaticProvider) RawPartial(name string) bool {
+	return sp.RawPartials[name]
+}
+
 var _ PartialProvider = (*StaticProvider)(nil)
+var _ EscapeModeProvider = (*StaticProvider)(nil)
+var _ RawPartialProvider = (*StaticProvider)(nil)
+
+// SetPartialProvider returns a copy of the template which loads its partials, and the partials they include, from pp in
+// place of the provider it was compiled with, so that a template compiled once can be rendered with the partials of
+// each tenant or theme. The copy shares all but the template's partial and parent tags, and the template itself is
+// not modified, so the provider can be set while the template is being rendered. Partials compiled for the copy are
+// cached with it rather than with the compiler, as they include their own partials from pp, so a copy is best kept for
+// as long as its provider is in use rather than made for each render.
+func (tmpl *Template) SetPartialProvider(pp PartialProvider) *Template {
+	cmpl := *tmpl.parent
+	cmpl.partial = pp
+	if cmpl.compiledPartials != nil {
+		cmpl.compiledPartials = newCompiledPartials()
+	}
+	out := *tmpl
+	out.partial, out.parent = pp, &cmpl
+	out.elems = withPartialProvider(tmpl.elems, pp)
+	return &out
+}
+
+// withPartialProvider returns a copy of elems whose partial and parent tags load their partials from pp, sharing the
+// elements of other tags.
+func withPartialProvider(elems []interface{}, pp PartialProvider) []interface{} {
+	out := make([]interface{}, len(elems))
+	for i, elem := range elems {
+		switch elem := elem.(type) {
+		case *partialElement:
+			e := *elem
+			e.prov = pp
+			out[i] = &e
+		case *sectionElement:
+			e := *elem
+			if e.sigil == parentSigil {
+				e.prov = pp
+			}
+			e.elems = withPartialProvider(elem.elems, pp)
+			out[i] = &e
+		default:
+			out[i] = elem
+		}
+	}
+	return out
+}
+
+// compiledPartials holds the compiled form of each partial by name, along with the source it was compiled from, so
+// that a partial is compiled again only when its provider returns a different source. A compiled partial includes its
+// own partials from the provider it was compiled with, so each provider a compiler is copied with, as by
+// SetPartialProvider and for each TemplateSet, is given a cache of its own.
+type compiledPartials struct {
+	mu     sync.Mutex
+	byName map[string]compiledPartial
+}
+
+func newCompiledPartials() *compiledPartials {
+	return &compiledPartials{byName: make(map[string]compiledPartial)}
+}
+
+type compiledPartial struct {
+	data string
+	mode EscapeMode
+	tmpl *Template
+}
 
-func (tmpl *Template) getPartials(partials PartialProvider, name, indent string) (*Template, error) {
+// getPartials loads a partial from a provider and returns it compiled. Partials are compiled without the indentation
+// of their tags, which is applied as they are rendered, so the compiled form of a partial is shared by all its tags.
+func (tmpl *Template) getPartials(ctx context.Context, partials PartialProvider, name string) (*Template, error) {
 	if partials == nil {
-		return nil, errors.New("no partial provider specified")
+		return nil, errNoPartialProvider
 	}
-	data, err := partials.Get(name)
+	data, err := getPartialSource(ctx, partials, name)
 	if err != nil {
 		return nil, err
 	}
 
-	// indent non empty lines
-	r := regexp.MustCompile(`(?m:^(.+)$)`)
-	data = r.ReplaceAllString(data, indent+"$1")
+	return tmpl.parent.compilePartial(ctx, name, data, tmpl.parent.partialMode(partials, name))
+}
 
-	return tmpl.parent.CompileString(data) //, partials)
+// partialMode returns the escape mode a partial is compiled in: that given by its provider, if it implements
+// EscapeModeProvider and gives one, and otherwise that of the compiler.
+func (r *Compiler) partialMode(partials PartialProvider, name string) EscapeMode {
+	if emp, ok := partials.(EscapeModeProvider); ok {
+		if mode, ok := emp.PartialEscapeMode(name); ok {
+			return mode
+		}
+	}
+	return r.outputMode
+}
+
+// compilePartial returns the compiled form of a partial's source in the given escape mode, compiling it only if it
+// has changed since the partial was last compiled.
+func (r *Compiler) compilePartial(ctx context.Context, name, data string, mode EscapeMode) (*Template, error) {
+	cache := r.compiledPartials
+	if cache == nil {
+		return r.compileInMode(ctx, name, []byte(data), mode)
+	}
+	cache.mu.Lock()
+	cached, ok := cache.byName[name]
+	cache.mu.Unlock()
+	if ok && cached.data == data && cached.mode == mode {
+		return cached.tmpl, nil
+	}
+	partial, err := r.compileInMode(ctx, name, []byte(data), mode)
+	if err != nil {
+		return nil, err
+	}
+	cache.mu.Lock()
+	cache.byName[name] = compiledPartial{data, mode, partial}
+	cache.mu.Unlock()
+	return partial, nil
+}
+
+// compileInMode compiles a template as compile does, but in the given escape mode rather than the compiler's, so that
+// its constants are escaped for the mode it renders in. An ESCAPE pragma in the template still takes precedence.
+func (r *Compiler) compileInMode(ctx context.Context, name string, data []byte, mode EscapeMode) (*Template, error) {
+	if mode == r.outputMode {
+		return r.compile(ctx, name, data)
+	}
+	cmpl := *r
+	cmpl.outputMode = mode
+	tmpl, err := cmpl.compile(ctx, name, data)
+	if err != nil {
+		return nil, err
+	}
+	// the partials and lambdas it includes are compiled in the compiler's own mode
+	tmpl.parent = r
+	return tmpl, nil
+}
+
+// getIndentedPartial loads a partial and compiles it with its lines indented, for ExportJS, which has no indentation
+// of its own to apply at render time.
+func (tmpl *Template) getIndentedPartial(ctx context.Context, partials PartialProvider, name, indent string) (*Template, error) {
+	if indent == "" {
+		return tmpl.getPartials(ctx, partials, name)
+	}
+	if partials == nil {
+		return nil, errNoPartialProvider
+	}
+	data, err := getPartialSource(ctx, partials, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl.parent.compileInMode(ctx, name, indentLines(data, indent), tmpl.parent.partialMode(partials, name))
+}
+
+// indentLines returns data with each line which is not empty prefixed by indent.
+func indentLines(data, indent string) []byte {
+	out := make([]byte, 0, len(data)+len(indent)*(strings.Count(data, "\n")+1))
+	for len(data) > 0 {
+		line := data
+		if i := strings.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i+1]
+		}
+		if line[0] != '\n' {
+			out = append(out, indent...)
+		}
+		out = append(out, line...)
+		data = data[len(line):]
+	}
+	return out
+}
+
+// indentation is the state of the indentation of a render's output. Within a standalone partial tag, such as
+// "  {{>item}}", each line of the partial which is not empty is indented as the tag is, along with any indentation
+// of the partial which includes it. Only the lines of the partial's text are indented: lines within the values it
+// interpolates are written as they are.
+type indentation struct {
+	indent string
+	// lineStart is set at the start of a line of the partial, when the indentation is due before its first output
+	lineStart bool
+}
+
+// writeText writes text from a template to buf, indenting each line of it which is not empty.
+func (st *renderState) writeText(buf io.Writer, text []byte) error {
+	if st.jsonStrings != nil {
+		st.jsonStrings.scan(text)
+	}
+	if st.indent == "" {
+		_, err := buf.Write(text)
+		return err
+	}
+	for len(text) > 0 {
+		if st.lineStart && text[0] != '\n' {
+			if _, err := io.WriteString(buf, st.indent); err != nil {
+				return err
+			}
+		}
+		line := text
+		if i := bytes.IndexByte(text, '\n'); i >= 0 {
+			line = text[:i+1]
+		}
+		if _, err := buf.Write(line); err != nil {
+			return err
+		}
+		st.lineStart = line[len(line)-1] == '\n'
+		text = text[len(line):]
+	}
+	return nil
+}
+
+// startTag writes the indentation due before a tag which starts a line, whether the tag writes anything or not.
+func (st *renderState) startTag(buf io.Writer) error {
+	if !st.lineStart || st.indent == "" {
+		return nil
+	}
+	st.lineStart = false
+	_, err := io.WriteString(buf, st.indent)
+	return err
+}
+
+// partialCache holds rendered partials for the duration of a single render, when enabled with WithPartialCache.
+type partialCache struct {
+	names  map[*partialElement]partialNames
+	output map[string]cachedPartial
+}
+
+// cachedPartial is the output of a partial, and whether it ended at the start of a line.
+type cachedPartial struct {
+	output    []byte
+	lineStart bool
+}
+
+// partialNames lists the names a partial refers to. A partial's output depends only on the values of those names.
+type partialNames struct {
+	names     []string
+	cacheable bool
+}
+
+func newPartialCache() *partialCache {
+	return &partialCache{
+		names:  make(map[*partialElement]partialNames),
+		output: make(map[string]cachedPartial),
+	}
+}
+
+// collectNames adds the first component of each name referred to by elems to names. It returns false if elems include
+// a partial, since the names used by nested partials are not known until they are loaded, or a name such as -index,
+// whose value depends on the iteration being rendered rather than on the context.
+func collectNames(elems []interface{}, names map[string]struct{}) bool {
+	for _, elem := range elems {
+		var name string
+		switch elem := elem.(type) {
+		case *varElement:
+			name = elem.name
+		case *sectionElement:
+			if elem.sigil == parentSigil {
+				return false
+			}
+			if !collectNames(elem.elems, names) {
+				return false
+			}
+			if elem.sigil == blockSigil {
+				continue
+			}
+			name = elem.name
+		case *partialElement, *customTagElement:
+			return false
+		case *helperElement:
+			for _, arg := range elem.args {
+				if arg.name != "" && !collectNames([]interface{}{&varElement{name: arg.name}}, names) {
+					return false
+				}
+			}
+			continue
+		case *exprElement:
+			for _, name := range elem.names() {
+				if !collectNames([]interface{}{&varElement{name: name}}, names) {
+					return false
+				}
+			}
+			continue
+		default:
+			continue
+		}
+		if isReservedName(name) {
+			return false
+		}
+		if len(name) > 1 && name[0] == '.' {
+			// an explicit reference to the data
+			name = name[1:]
+		}
+		if name != "." {
+			name, _, _ = strings.Cut(name, ".")
+		}
+		names[name] = struct{}{}
+	}
+	return true
+}
+
+// key returns the cache key for rendering a partial with the given context chain and indentation.
+func (pn partialNames) key(elem *partialElement, contextChain []reflect.Value, in indentation) string {
+	var key bytes.Buffer
+	key.WriteString(elem.name)
+	key.WriteByte(0)
+	key.WriteString(in.indent)
+	if in.lineStart {
+		key.WriteByte(1)
+	}
+	for _, name := range pn.names {
+		val, _ := lookup(contextChain, name, false)
+		key.WriteByte(0)
+		fmt.Fprintf(&key, "%#v", val)
+	}
+	return key.String()
+}
+
+// partialMissing returns the error from loading a partial, unless the partial is missing and missing partials are
+// ignored, in which case the miss is reported to the compiler's handler.
+func (tmpl *Template) partialMissing(name string, err error) error {
+	if tmpl.errorOnMissing || !errors.Is(err, ErrPartialNotFound) {
+		return err
+	}
+	if tmpl.parent.partialMiss != nil {
+		tmpl.parent.partialMiss(name)
+	}
+	return nil
+}
+
+// renderRawPartial writes the source of a partial which is included verbatim.
+func (tmpl *Template) renderRawPartial(st *renderState, elem *partialElement, buf io.Writer) error {
+	if err := spend(&st.spent.partialFetches, tmpl.parent.budget.partialFetches, ErrTooManyPartialFetches); err != nil {
+		return err
+	}
+	ctx, span := tmpl.parent.startSpan(st.ctx, SpanPartial, Attribute{AttrPartial, elem.name})
+	data, err := "", errNoPartialProvider
+	if elem.prov != nil {
+		data, err = getPartialSource(ctx, elem.prov, elem.name)
+	}
+	span.End(err)
+	if err != nil {
+		return tmpl.partialMissing(elem.name, err)
+	}
+	if st.summary != nil {
+		st.summary.Partials++
+	}
+	return st.writeText(buf, []byte(data))
+}
+
+func (tmpl *Template) renderPartial(st *renderState, elem *partialElement, contextChain []reflect.Value, buf io.Writer) error {
+	if elem.context != "" || len(elem.params) > 0 {
+		var err error
+		if contextChain, err = tmpl.partialContexts(st, elem, contextChain); err != nil {
+			return err
+		}
+	}
+
+	// the lines of a partial are indented by the indentation of its tag, if it is standalone, within the indentation
+	// of the partial which includes it
+	outer := st.indent
+	if elem.indent != "" {
+		st.indentation = indentation{outer + elem.indent, true}
+	}
+	defer func() { st.indent = outer }()
+
+	if elem.raw || isRawPartial(elem.prov, elem.name) {
+		return tmpl.renderRawPartial(st, elem, buf)
+	}
+
+	var key string
+	pn, seen := partialNames{}, false
+	if st.partials != nil {
+		pn, seen = st.partials.names[elem]
+		if seen && pn.cacheable {
+			key = pn.key(elem, contextChain, st.indentation)
+			if cached, ok := st.partials.output[key]; ok {
+				st.lineStart = cached.lineStart
+				_, err := buf.Write(cached.output)
+				return err
+			}
+		}
+	}
+
+	if err := spend(&st.spent.partialFetches, tmpl.parent.budget.partialFetches, ErrTooManyPartialFetches); err != nil {
+		return err
+	}
+	ctx, span := tmpl.parent.startSpan(st.ctx, SpanPartial, Attribute{AttrPartial, elem.name})
+	partial, err := tmpl.getPartials(ctx, elem.prov, elem.name)
+	span.End(err)
+	if err != nil {
+		return tmpl.partialMissing(elem.name, err)
+	}
+	if st.summary != nil {
+		st.summary.Partials++
+	}
+	if st.tags != nil {
+		st.tags.rendered(partial)
+	}
+	if st.partials == nil {
+		return partial.renderTemplate(st, contextChain, buf)
+	}
+
+	if !seen {
+		names := make(map[string]struct{})
+		pn.cacheable = collectNames(partial.elems, names)
+		for name := range names {
+			pn.names = append(pn.names, name)
+		}
+		st.partials.names[elem] = pn
+		if pn.cacheable {
+			key = pn.key(elem, contextChain, st.indentation)
+		}
+	}
+	if !pn.cacheable {
+		return partial.renderTemplate(st, contextChain, buf)
+	}
+
+	var out bytes.Buffer
+	if err := partial.renderTemplate(st, contextChain, &out); err != nil {
+		return err
+	}
+	st.partials.output[key] = cachedPartial{out.Bytes(), st.lineStart}
+	_, err = buf.Write(out.Bytes())
+	return err
 }
@@ -0,0 +1,295 @@
+package mustache
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// slowProvider is a ContextPartialProvider which blocks until it is released, or its context is done.
+type slowProvider struct {
+	release chan struct{}
+}
+
+func (sp *slowProvider) Get(name string) (string, error) {
+	return sp.GetContext(context.Background(), name)
+}
+
+func (sp *slowProvider) GetContext(ctx context.Context, name string) (string, error) {
+	select {
+	case <-sp.release:
+		return "slow", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// assertPrompt checks that a render failed with err within a second.
+func assertPrompt(t *testing.T, start time.Time, err, expected error) {
+	t.Helper()
+	if !errors.Is(err, expected) {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the render took %v to abort", elapsed)
+	}
+}
+
+func TestPartialDeadline(t *testing.T) {
+	sp := &slowProvider{release: make(chan struct{})}
+	defer close(sp.release)
+	tmpl, err := New().WithPartials(sp).CompileString("a{{>p}}b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = tmpl.RenderContext(ctx)
+	assertPrompt(t, start, err, context.DeadlineExceeded)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = tmpl.RenderContext(ctx)
+	assertPrompt(t, start, err, context.Canceled)
+
+	// other providers are called directly, but not once the render's context is done
+	cp := &countingProvider{StaticProvider: StaticProvider{Partials: map[string]string{"p": "x"}}}
+	tmpl, err = New().WithPartials(cp).CompileString("a{{>p}}b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline, cancelDeadline := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelDeadline()
+	if output, err := tmpl.RenderContext(deadline); err != nil || output != "axb" {
+		t.Errorf("expected %q, got %q, %v", "axb", output, err)
+	}
+	if _, err := tmpl.RenderContext(ctx); !errors.Is(err, context.Canceled) || cp.gets != 1 {
+		t.Errorf("expected the provider to be called once, got %d calls, %v", cp.gets, err)
+	}
+}
+
+func TestFSProvider(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/page.mustache":          {Data: []byte("{{>cards/product}}|{{>/footer}}|{{>cards}}")},
+		"templates/footer.stache":          {Data: []byte("footer")},
+		"templates/cards/product.mustache": {Data: []byte("product")},
+		"templates/cards/index.mustache":   {Data: []byte("cards")},
+		"templates/notes.txt":              {Data: []byte("notes")},
+		"secret.mustache":                  {Data: []byte("secret")},
+	}
+	prov := &FSProvider{FS: fsys, Root: "templates", Index: "index"}
+	tmpl, err := New().WithPartials(prov).CompileString("{{>page}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(nil); err != nil || output != "product|footer|cards" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+	if data, err := prov.Get("notes.txt"); err != nil || data != "notes" {
+		t.Errorf("expected a name with its extension to be found, got %q, error %v", data, err)
+	}
+	if _, err := prov.Get("missing"); !errors.Is(err, ErrPartialNotFound) {
+		t.Errorf("expected ErrPartialNotFound, got %v", err)
+	}
+	for _, name := range []string{"../secret", "cards/../../secret", "cards//product", "./page", ""} {
+		if _, err := prov.Get(name); err == nil || errors.Is(err, ErrPartialNotFound) {
+			t.Errorf("%q: expected the name to be refused, got %v", name, err)
+		}
+	}
+
+	names, err := prov.Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"cards", "cards/index", "cards/product", "footer", "notes.txt", "page"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected names %v, got %v", expected, names)
+	}
+}
+
+func TestCompileFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pages/home.mustache":     {Data: []byte("{{>./card}}|{{>../shared/footer}}|{{>shared/footer}}")},
+		"pages/card.mustache":     {Data: []byte("card {{name}}")},
+		"shared/footer.stache":    {Data: []byte("footer")},
+		"bom.mustache":            {Data: append([]byte("\ufeff"), "{{name}}"...)},
+		"templates/page.mustache": {Data: []byte("{{>nav}}")},
+		"templates/nav.mustache":  {Data: []byte("nav")},
+	}
+	tmpl, err := New().CompileFS(fsys, "pages/home.mustache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(map[string]string{"name": "Jo"}); err != nil || output != "card Jo|footer|footer" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+	if tmpl.Name() != "pages/home.mustache" {
+		t.Errorf("unexpected name %q", tmpl.Name())
+	}
+
+	tmpl, err = New().CompileFS(fsys, "bom.mustache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(map[string]string{"name": "Jo"}); err != nil || output != "Jo" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+
+	sub, err := fs.Sub(fsys, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl, err = New().CompileFS(sub, "page.mustache"); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(nil); err != nil || output != "nav" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+
+	// the compiler's own provider takes precedence
+	cmpl := New().WithPartials(&StaticProvider{Partials: map[string]string{"nav": "static"}})
+	if tmpl, err = cmpl.CompileFS(sub, "page.mustache"); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(nil); err != nil || output != "static" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+
+	if _, err := New().CompileFS(fsys, "missing.mustache"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestRawPartials(t *testing.T) {
+	partials := &StaticProvider{
+		Partials:    map[string]string{"style.css": "a { b: {{c}} }\n", "icon": "<svg>{{#x}}</svg>", "card": "[{{name}}]"},
+		RawPartials: map[string]bool{"icon": true},
+	}
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{"<style>{{>style.css raw}}</style>", "<style>a { b: {{c}} }\n</style>"},
+		{"{{>icon}}{{>card}}", "<svg>{{#x}}</svg>[Jo]"},
+		{"<style>\n  {{>style.css raw}}\n</style>", "<style>\n  a { b: {{c}} }\n</style>"},
+		{"{{#items}}{{>card raw}}{{/items}}", "[{{name}}][{{name}}]"},
+		{"<{{>missing raw}}>", "<>"},
+	}
+	data := map[string]interface{}{"name": "Jo", "c": "red", "items": []int{1, 2}}
+	for _, test := range tests {
+		tmpl, err := New().WithPartials(partials).CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output, err := tmpl.Render(data); err != nil || output != test.expected {
+			t.Errorf("%q: expected %q, got %q, %v", test.tmpl, test.expected, output, err)
+		}
+	}
+
+	strict := &StaticProvider{ReportMissing: true, Partials: partials.Partials}
+	tmpl, err := New().WithPartials(strict).WithErrors(true).CompileString("{{>missing raw}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(nil); !errors.Is(err, ErrPartialNotFound) {
+		t.Errorf("expected ErrPartialNotFound, got %v", err)
+	}
+
+	fsys := fstest.MapFS{
+		"page.mustache": {Data: []byte("<script>{{>app.js}}</script>{{>app}}")},
+		"app.js":        {Data: []byte("let s = `{{x}}`;")},
+		"app.mustache":  {Data: []byte("{{x}}")},
+	}
+	tmpl, err = New().WithPartials(&FSProvider{FS: fsys, RawExtensions: []string{".js"}}).CompileString("{{>page}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(map[string]string{"x": "y"}); err != nil || output != "<script>let s = `{{x}}`;</script>y" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+}
+
+func TestPartialMissHandler(t *testing.T) {
+	partials := &StaticProvider{ReportMissing: true, Partials: map[string]string{"item": "<{{>icon}}>"}}
+	var misses []string
+	cmpl := New().WithPartials(partials).WithPartialMissHandler(func(name string) { misses = append(misses, name) })
+	tmpl, err := cmpl.CompileString("{{#items}}{{>item}}{{/items}}{{>style.css raw}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(map[string][]int{"items": {1, 2}}); err != nil || output != "<><>" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+	if expected := []string{"icon", "icon", "style.css"}; !reflect.DeepEqual(misses, expected) {
+		t.Errorf("expected misses %v, got %v", expected, misses)
+	}
+
+	misses = nil
+	tmpl, err = cmpl.WithErrors(true).CompileString("{{>icon}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(nil); !errors.Is(err, ErrPartialNotFound) || misses != nil {
+		t.Errorf("expected ErrPartialNotFound without a miss in strict mode, got %v, %v", err, misses)
+	}
+}
+
+func TestRelativePartials(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pages/home.mustache":      {Data: []byte("{{>./header}}|{{>../shared/footer}}")},
+		"pages/header.mustache":    {Data: []byte("header {{>../shared/logo}}")},
+		"shared/footer.mustache":   {Data: []byte("footer")},
+		"shared/logo.mustache":     {Data: []byte("logo")},
+		"pages/escape.mustache":    {Data: []byte("{{>../../secret}}")},
+		"pages/deep/page.mustache": {Data: []byte("{{>../header}}")},
+	}
+	cmpl := New().WithErrors(true).WithPartials(&FSProvider{FS: fsys})
+	tmpl, err := cmpl.CompileString("{{>pages/home}} {{>pages/deep/page}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(nil); err != nil || output != "header logo|footer header logo" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+	escape, err := cmpl.CompileString("{{>pages/escape}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := escape.Render(nil); err == nil || !strings.Contains(err.Error(), "unsafe partial name") {
+		t.Errorf("expected the provider to refuse the partial, got %v", err)
+	}
+}
+
+func TestSetPartialProvider(t *testing.T) {
+	light := &StaticProvider{Partials: map[string]string{
+		"header": "<h1 class=light>{{>logo}}</h1>", "logo": "sun", "layout": "[{{$body}}{{/body}}]"}}
+	dark := &StaticProvider{Partials: map[string]string{
+		"header": "<h1 class=light>{{>logo}}</h1>", "logo": "moon", "layout": "({{$body}}{{/body}})"}}
+	tmpl, err := New().WithPartials(light).CompileString("{{#page}}{{>header}}{{/page}}{{<layout}}{{$body}}x{{/body}}{{/layout}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]bool{"page": true}
+	if output, err := tmpl.Render(data); err != nil || output != "<h1 class=light>sun</h1>[x]" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+
+	// the partials of partials come from the new provider, though the header's source is the same
+	themed := tmpl.SetPartialProvider(dark)
+	if output, err := themed.Render(data); err != nil || output != "<h1 class=light>moon</h1>(x)" {
+		t.Errorf("unexpected output %q, error %v", output, err)
+	}
+	if output, err := tmpl.Render(data); err != nil || output != "<h1 class=light>sun</h1>[x]" {
+		t.Errorf("expected the template to be unchanged, got %q, %v", output, err)
+	}
+
+	if output, err := tmpl.SetPartialProvider(nil).Render(data); err != nil || output != "" {
+		t.Errorf("expected partials to be missing, got %q, %v", output, err)
+	}
+}
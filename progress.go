@@ -0,0 +1,87 @@
+package mustache
+
+import (
+	"io"
+	"time"
+)
+
+// Progress describes how far a render has got, for the callback set with WithProgress.
+type Progress struct {
+	Bytes    int64 // the bytes of output rendered so far
+	Elements int   // the top level elements of the template rendered so far: text, tags and whole sections
+	Total    int   // the number of top level elements of the template
+	// Fraction estimates the fraction of the render done, from 0 to 1, counting the iterations of the top level
+	// section being rendered, so that a template which is one large loop still reports steady progress.
+	Fraction float64
+	Done     bool // set for the last report of a render which succeeds
+}
+
+// WithProgress sets a function which is called as renders of the compiled templates progress, for long running jobs
+// such as exports of large documents. It is called at most once per interval, as each top level element of the
+// template is rendered and as each iteration of a top level section begins, and once more when the render is done.
+// With an interval of zero it is called at each of those points. It is called on the goroutine rendering the template,
+// which it holds up, so it should be quick. Partials and lambdas report no progress of their own.
+func (r *Compiler) WithProgress(interval time.Duration, fn func(Progress)) *Compiler {
+	r.progress = progressOptions{interval: interval, fn: fn}
+	return r
+}
+
+type progressOptions struct {
+	interval time.Duration
+	fn       func(Progress)
+}
+
+// progressTracker reports the progress of a render.
+type progressTracker struct {
+	progressOptions
+	written *int64 // the number of bytes of output written so far
+	last    time.Time
+}
+
+// wrap returns a writer which counts the output written to w.
+func (p *progressTracker) wrap(w io.Writer) io.Writer {
+	cw := &countingWriter{w: w}
+	p.written = &cw.n
+	return cw
+}
+
+// due reports whether progress is reported at this point of a render: as a top level element other than a section
+// begins, or as an iteration of a top level section begins.
+func (p *progressTracker) due(stack []renderFrame) bool {
+	if len(stack) == 2 {
+		return stack[1].pos == 0
+	}
+	if top := stack[0]; len(stack) == 1 && top.pos < len(top.elems) {
+		section, ok := top.elems[top.pos].(*sectionElement)
+		return !ok || section.sigil != 0
+	}
+	return false
+}
+
+// report reports the progress of a render of the top level elements in stack[0], unless the interval has not passed
+// since the last report.
+func (p *progressTracker) report(stack []renderFrame) {
+	now := time.Now()
+	if p.interval > 0 && now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+	top := stack[0]
+	done := top.pos
+	fraction := float64(top.pos)
+	if len(stack) > 1 {
+		// the element at pos-1 is the section being rendered
+		done--
+		fraction = float64(done) + float64(stack[1].ctx)/float64(stack[1].contexts.count)
+	}
+	progress := Progress{Bytes: *p.written, Elements: done, Total: len(top.elems)}
+	if progress.Total > 0 {
+		progress.Fraction = fraction / float64(progress.Total)
+	}
+	p.fn(progress)
+}
+
+// done reports the end of a render.
+func (p *progressTracker) done(total int) {
+	p.fn(Progress{Bytes: *p.written, Elements: total, Total: total, Fraction: 1, Done: true})
+}
@@ -0,0 +1,49 @@
+package mustache
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	var reports []Progress
+	cmpl := New().WithProgress(0, func(p Progress) { reports = append(reports, p) })
+	tmpl, err := cmpl.CompileString("<ul>{{#rows}}<li>{{.}}</li>{{/rows}}</ul>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := tmpl.Render(map[string]interface{}{"rows": []string{"a", "b", "c", "d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Progress{
+		{Bytes: 0, Elements: 0, Total: 3, Fraction: 0},
+		{Bytes: 4, Elements: 1, Total: 3, Fraction: 1.0 / 3},
+		{Bytes: 14, Elements: 1, Total: 3, Fraction: 1.25 / 3},
+		{Bytes: 24, Elements: 1, Total: 3, Fraction: 1.5 / 3},
+		{Bytes: 34, Elements: 1, Total: 3, Fraction: 1.75 / 3},
+		{Bytes: 44, Elements: 2, Total: 3, Fraction: 2.0 / 3},
+		{Bytes: 49, Elements: 3, Total: 3, Fraction: 1, Done: true},
+	}
+	if !reflect.DeepEqual(reports, expected) {
+		t.Errorf("expected\n%v\ngot\n%v", expected, reports)
+	}
+	if int(reports[len(reports)-1].Bytes) != len(output) {
+		t.Errorf("expected the last report to count %d bytes", len(output))
+	}
+
+	reports = nil
+	cmpl = New().WithProgress(time.Hour, func(p Progress) { reports = append(reports, p) }).
+		WithPostProcessor(func(b []byte) ([]byte, error) { return []byte(strings.ToUpper(string(b))), nil })
+	if tmpl, err = cmpl.CompileString("{{#rows}}{{.}}{{/rows}}"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(map[string]interface{}{"rows": []string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || !reports[1].Done || reports[1].Bytes != 2 {
+		t.Errorf("expected a report at the start and at the end, got %v", reports)
+	}
+}
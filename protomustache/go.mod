@@ -0,0 +1,8 @@
+module github.com/hayeah/mustache/v2/protomustache
+
+go 1.21
+
+require (
+	github.com/hayeah/mustache/v2 v2.0.0
+	google.golang.org/protobuf v1.36.1
+)
@@ -0,0 +1,98 @@
+// Package protomustache renders mustache templates from protocol buffer messages, so that gRPC services can pass
+// their responses straight to templates without converting them to maps:
+//
+//	out, err := tmpl.Render(protomustache.Context(resp))
+//
+// Fields are looked up by their proto names, such as {{display_name}}, or their JSON names, such as {{displayName}}.
+package protomustache
+
+import (
+	"encoding/base64"
+
+	"github.com/hayeah/mustache/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Message is a context which resolves names to the fields of a protocol buffer message.
+//
+// Nested messages are Messages in turn, repeated fields are lists which sections iterate over, and map fields are maps
+// with string keys. Enums are written as the names of their values, bytes as base64 as in the JSON mapping, and
+// google.protobuf.Timestamp and Duration messages as time.Time and time.Duration values. Fields which track presence,
+// such as message fields and optional scalars, are falsy when they are not set; other fields have their default
+// values, so 0 and "" are falsy.
+type Message struct {
+	msg protoreflect.Message
+}
+
+var _ mustache.NameLookuper = Message{}
+
+// Context returns a context for rendering templates with msg.
+func Context(msg proto.Message) Message {
+	return Message{msg: msg.ProtoReflect()}
+}
+
+// LookupName implements mustache.NameLookuper.
+func (m Message) LookupName(name string) (interface{}, bool) {
+	fields := m.msg.Descriptor().Fields()
+	fd := fields.ByName(protoreflect.Name(name))
+	if fd == nil {
+		fd = fields.ByJSONName(name)
+	}
+	if fd == nil {
+		return nil, false
+	}
+	if fd.HasPresence() && !m.msg.Has(fd) {
+		return nil, true
+	}
+	return fieldValue(fd, m.msg.Get(fd)), true
+}
+
+// fieldValue converts the value of a field to one templates can render.
+func fieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		items := make([]interface{}, list.Len())
+		for i := range items {
+			items[i] = singularValue(fd, list.Get(i))
+		}
+		return items
+	case fd.IsMap():
+		entries := make(map[string]interface{}, v.Map().Len())
+		v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			entries[k.String()] = singularValue(fd.MapValue(), v)
+			return true
+		})
+		return entries
+	}
+	return singularValue(fd, v)
+}
+
+func singularValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(v.Enum())
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageValue(v.Message())
+	}
+	return v.Interface()
+}
+
+// messageValue returns a Message for a nested message, or the Go value of a well known type.
+func messageValue(msg protoreflect.Message) interface{} {
+	switch m := msg.Interface().(type) {
+	case *timestamppb.Timestamp:
+		return m.AsTime()
+	case *durationpb.Duration:
+		return m.AsDuration()
+	}
+	return Message{msg: msg}
+}
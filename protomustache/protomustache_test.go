@@ -0,0 +1,73 @@
+package protomustache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hayeah/mustache/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestContext(t *testing.T) {
+	file := &descriptorpb.FileDescriptorProto{
+		Name: proto.String("shop.proto"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("id"), JsonName: proto.String("id"), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()},
+				{Name: proto.String("line_items"), JsonName: proto.String("lineItems"), Label: descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()},
+			},
+		}},
+	}
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		// proto names and JSON names, repeated and nested messages, and enums
+		{"{{name}}:{{#message_type}} {{name}}({{#field}}{{jsonName}} {{type}};{{/field}}){{/message_type}}", "shop.proto: Order(id TYPE_INT64;lineItems ;)"},
+		{"{{#messageType}}{{#field}}{{name}}{{#label}}*{{/label}} {{/field}}{{/messageType}}", "id line_items* "},
+		// message fields which are not set are falsy, and unknown names are missing
+		{"{{^options}}no options{{/options}}{{#syntax}}{{syntax}}{{/syntax}}{{nothing}}", "no options"},
+	}
+	for _, test := range tests {
+		tmpl, err := mustache.New().CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(Context(file))
+		if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.tmpl, test.expected, output)
+		}
+	}
+
+	tmpl, err := mustache.New().WithErrors(true).CompileString("{{nothing}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(Context(file)); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestWellKnownTypes(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s, err := structpb.NewStruct(map[string]interface{}{"tags": []interface{}{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := mustache.New().CompileString("{{#fields}}{{#tags}}{{#listValue}}{{#values}}{{stringValue}}{{/values}}{{/listValue}}{{/tags}}{{/fields}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(Context(s)); err != nil || output != "ab" {
+		t.Errorf("expected map fields to be maps, got %q, %v", output, err)
+	}
+	if v := messageValue(timestamppb.New(at).ProtoReflect()); v != at {
+		t.Errorf("expected %v, got %v", at, v)
+	}
+}
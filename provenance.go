@@ -0,0 +1,129 @@
+package mustache
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Metadata describes the provenance of a template, such as who wrote it, the commit it was built from and the approval
+// it was released under. It is set on the spans of a Tracer, added to the errors of failed renders, and passed to the
+// hook set with WithAuditHook. The keys are free form; MetaAuthor, MetaCommit and MetaApproval are suggested for the
+// common cases.
+type Metadata map[string]string
+
+// Suggested keys of Metadata.
+const (
+	MetaAuthor   = "author"
+	MetaCommit   = "commit"
+	MetaApproval = "approval"
+)
+
+// AttrMetadata is the prefix of the keys of the span attributes holding the metadata of a template, which are followed
+// by the metadata key, as in "mustache.metadata.commit".
+const AttrMetadata = "mustache.metadata."
+
+// WithMetadata sets metadata which the compiled templates, and the templates of sets loaded with the compiler, carry.
+// The entries of a bundle's manifest can add their own.
+func (r *Compiler) WithMetadata(md Metadata) *Compiler {
+	r.metadata = md
+	return r
+}
+
+// AuditEvent records a render of a template with metadata, for the hook set with WithAuditHook.
+type AuditEvent struct {
+	Template string   // the name of the template, if it has one
+	Metadata Metadata // the metadata of the template
+	Time     time.Time
+	Err      error // the error the render failed with, if any
+}
+
+// WithAuditHook sets a function which is called once each render of the compiled templates finishes, including
+// renders which fail, with the metadata of the template. ctx is the context the render was called with.
+func (r *Compiler) WithAuditHook(hook func(ctx context.Context, e AuditEvent)) *Compiler {
+	r.auditHook = hook
+	return r
+}
+
+// Metadata returns the metadata of the template: that set with Template.WithMetadata, or by a bundle's manifest,
+// over that set with Compiler.WithMetadata. It must not be modified.
+func (tmpl *Template) Metadata() Metadata {
+	if tmpl.metadata != nil {
+		return tmpl.metadata
+	}
+	return tmpl.parent.metadata
+}
+
+// WithMetadata returns a copy of the template which carries md in addition to its own metadata. Entries of md replace
+// the template's entries with the same key. The template itself is not modified, so the metadata can be added to a
+// template which is being rendered.
+func (tmpl *Template) WithMetadata(md Metadata) *Template {
+	merged := make(Metadata, len(tmpl.Metadata())+len(md))
+	for k, v := range tmpl.Metadata() {
+		merged[k] = v
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	out := *tmpl
+	out.metadata = merged
+	return &out
+}
+
+// String formats the metadata as space separated key=value pairs, sorted by key.
+func (md Metadata) String() string {
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(md[k])
+	}
+	return b.String()
+}
+
+// metadataAttributes returns the span attributes holding the metadata of the template.
+func (tmpl *Template) metadataAttributes() []Attribute {
+	md := tmpl.Metadata()
+	attrs := make([]Attribute, 0, len(md))
+	for k, v := range md {
+		attrs = append(attrs, Attribute{AttrMetadata + k, v})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
+
+// MetadataError wraps the error of a failed render of a template with metadata, adding the metadata to its message.
+type MetadataError struct {
+	Template string
+	Metadata Metadata
+	Err      error
+}
+
+func (e *MetadataError) Error() string {
+	return e.Err.Error() + " [" + e.Metadata.String() + "]"
+}
+
+func (e *MetadataError) Unwrap() error {
+	return e.Err
+}
+
+// audit wraps the error of a render of the template with its metadata, and reports the render to the audit hook.
+func (tmpl *Template) audit(ctx context.Context, err error) error {
+	md := tmpl.Metadata()
+	if err != nil && len(md) > 0 {
+		err = &MetadataError{Template: tmpl.name, Metadata: md, Err: err}
+	}
+	if hook := tmpl.parent.auditHook; hook != nil {
+		hook(ctx, AuditEvent{Template: tmpl.name, Metadata: md, Time: time.Now(), Err: err})
+	}
+	return err
+}
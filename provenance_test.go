@@ -0,0 +1,47 @@
+package mustache
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	tracer := &recordingTracer{}
+	var events []AuditEvent
+	cmpl := New().WithErrors(true).WithTracer(tracer).WithMetadata(Metadata{MetaAuthor: "jo", MetaCommit: "abc123"}).
+		WithAuditHook(func(ctx context.Context, e AuditEvent) {
+			events = append(events, e)
+		})
+	tmpl, err := cmpl.CompileString("Hello {{name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	approved := tmpl.WithMetadata(Metadata{MetaApproval: "A-7", MetaCommit: "def456"})
+	if md := tmpl.Metadata().String(); md != "author=jo commit=abc123" {
+		t.Errorf("the original template has metadata %q", md)
+	}
+	if md := approved.Metadata().String(); md != "approval=A-7 author=jo commit=def456" {
+		t.Errorf("unexpected metadata %q", md)
+	}
+
+	if _, err := approved.Render(map[string]string{"name": "Mary"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = approved.Render(nil)
+	var mdErr *MetadataError
+	if !errors.As(err, &mdErr) || mdErr.Metadata[MetaApproval] != "A-7" {
+		t.Fatalf("expected a MetadataError, got %v", err)
+	}
+	if expected := `line 1, column 7: missing variable "name" [approval=A-7 author=jo commit=def456]`; err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+
+	if len(events) != 2 || events[0].Err != nil || events[1].Err != err || events[1].Metadata[MetaAuthor] != "jo" {
+		t.Errorf("unexpected audit events %+v", events)
+	}
+	span := tracer.spans[len(tracer.spans)-1]
+	if span.path != SpanRender || span.attrs[AttrMetadata+MetaApproval] != "A-7" || span.err != err {
+		t.Errorf("unexpected render span %+v", span)
+	}
+}
@@ -0,0 +1,177 @@
+package mustache
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+)
+
+// Edit is a change to the source of a template: the bytes from Start up to End are replaced by Text.
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// checkpoint is a position at the start of a line, outside any section, at which the parser can resume.
+type checkpoint struct {
+	p     int // the offset in the source
+	elems int // the number of top level elements before p
+	line  int
+	otag  string
+	ctag  string
+}
+
+// Reparse returns the template for the source of tmpl with an edit applied, for editors which compile a template
+// after each change. Rather than parsing the whole source again, it parses the lines from the last top level tag
+// before the edit which ends a line, to the first such tag after it, and reuses the elements before and after them.
+// The whole source is parsed when the edit changes the delimiters which apply after it, adds or removes an ESCAPE
+// pragma, or breaks the nesting of sections, and always when the compiler has defines, constants or transforms, so the
+// result is always the template CompileBytes would return. tmpl is not modified.
+func (tmpl *Template) Reparse(edit Edit) (*Template, error) {
+	cps := tmpl.checkpoints
+	if len(cps) == 0 {
+		return nil, errors.New("mustache: the template was not compiled from source")
+	}
+	if edit.Start < 0 || edit.Start > edit.End || edit.End > len(tmpl.data) {
+		return nil, errors.New("mustache: edit out of range")
+	}
+	data := make([]byte, 0, len(tmpl.data)-(edit.End-edit.Start)+len(edit.Text))
+	data = append(data, tmpl.data[:edit.Start]...)
+	data = append(data, edit.Text...)
+	data = append(data, tmpl.data[edit.End:]...)
+	// folding defined sections and constants, and transforms, leave the checkpoints pointing at the wrong elements
+	if tmpl.escapePragma || len(tmpl.parent.defines) > 0 || len(tmpl.parent.constants) > 0 || len(tmpl.parent.transforms) > 0 {
+		return tmpl.parent.parse(tmpl.name, data)
+	}
+	if limit := tmpl.parent.maxTemplateBytes; limit > 0 && len(data) > limit {
+		return nil, &LimitError{Err: ErrTemplateTooLarge, Max: limit}
+	}
+
+	// parse from the last checkpoint at or before the edit to the first after it, or the end of the source
+	first := sort.Search(len(cps), func(i int) bool { return cps[i].p > edit.Start }) - 1
+	last := sort.Search(len(cps), func(i int) bool { return cps[i].p > edit.End })
+	start := cps[first]
+	delta := len(data) - len(tmpl.data)
+	end := len(data)
+	if last < len(cps) {
+		end = cps[last].p + delta
+	}
+	region := &Template{
+		data:           data[:end],
+		otag:           start.otag,
+		ctag:           start.ctag,
+		p:              start.p,
+		curline:        start.line,
+		elems:          []interface{}{},
+		partial:        tmpl.partial,
+		outputMode:     tmpl.outputMode,
+		valueStringer:  tmpl.valueStringer,
+		errorOnMissing: tmpl.errorOnMissing,
+		parent:         tmpl.parent,
+		name:           tmpl.name,
+	}
+	if err := region.parse(); err != nil || region.escapePragma {
+		return tmpl.parent.parse(tmpl.name, data)
+	}
+	regionCps := region.checkpoints
+	regionElems := region.elems
+	if last < len(cps) {
+		// the parser must reach the end of the region between tags, in the state it was in before the edit
+		resume := regionCps[len(regionCps)-1]
+		if resume.p != end || resume.otag != cps[last].otag || resume.ctag != cps[last].ctag {
+			return tmpl.parent.parse(tmpl.name, data)
+		}
+		// the region ends with the empty text the parser reads at the end of the input
+		regionCps = regionCps[:len(regionCps)-1]
+		regionElems = regionElems[:len(regionElems)-1]
+	}
+
+	out := *tmpl
+	out.data = data
+	out.elems = make([]interface{}, 0, len(tmpl.elems)+len(regionElems))
+	out.elems = append(out.elems, tmpl.elems[:start.elems]...)
+	out.elems = append(out.elems, regionElems...)
+	out.checkpoints = append([]checkpoint(nil), cps[:first]...)
+	out.delimiterChanges = nil
+	for _, c := range tmpl.delimiterChanges {
+		if c.at <= start.p {
+			out.delimiterChanges = append(out.delimiterChanges, c)
+		}
+	}
+	out.delimiterChanges = append(out.delimiterChanges, region.delimiterChanges...)
+	for _, cp := range regionCps {
+		cp.elems += start.elems
+		out.checkpoints = append(out.checkpoints, cp)
+	}
+	if last < len(cps) {
+		lines := bytes.Count([]byte(edit.Text), []byte("\n")) - bytes.Count(tmpl.data[edit.Start:edit.End], []byte("\n"))
+		shift := len(out.elems) - cps[last].elems
+		out.elems = append(out.elems, shiftPositions(tmpl.elems[cps[last].elems:], lines, delta)...)
+		for _, cp := range cps[last:] {
+			cp.p += delta
+			cp.elems += shift
+			cp.line += lines
+			out.checkpoints = append(out.checkpoints, cp)
+		}
+		for _, c := range tmpl.delimiterChanges {
+			if c.at > cps[last].p {
+				c.at += delta
+				out.delimiterChanges = append(out.delimiterChanges, c)
+			}
+		}
+	}
+	return &out, nil
+}
+
+// shiftPositions returns elems with the lines of their tags moved by lines, and their offsets by offset, copying the
+// elements which change. The text of the elements is not copied: it is the same in the edited source, and stays valid
+// as the old source is never modified.
+func shiftPositions(elems []interface{}, lines, offset int) []interface{} {
+	if lines == 0 && offset == 0 {
+		return elems
+	}
+	out := make([]interface{}, len(elems))
+	for i, elem := range elems {
+		switch e := elem.(type) {
+		case *sectionElement:
+			shifted := *e
+			shifted.startline += lines
+			shifted.startoffset += offset
+			shifted.elems = shiftPositions(e.elems, lines, offset)
+			elem = &shifted
+		case *varElement:
+			shifted := *e
+			shifted.line += lines
+			shifted.offset += offset
+			elem = &shifted
+		case *partialElement:
+			shifted := *e
+			shifted.line += lines
+			shifted.offset += offset
+			elem = &shifted
+		case *helperElement:
+			shifted := *e
+			shifted.line += lines
+			shifted.offset += offset
+			elem = &shifted
+		case *exprElement:
+			shifted := *e
+			shifted.line += lines
+			shifted.offset += offset
+			elem = &shifted
+		case *customTagElement:
+			shifted := *e
+			shifted.line += lines
+			shifted.offset += offset
+			elem = &shifted
+		case *commentElement:
+			shifted := *e
+			shifted.line += lines
+			shifted.offset += offset
+			elem = &shifted
+		}
+		out[i] = elem
+	}
+	return out
+}
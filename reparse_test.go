@@ -0,0 +1,81 @@
+package mustache
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReparse(t *testing.T) {
+	source := strings.Repeat("<h1>{{title}}</h1>\n{{#items}}\n  <li>{{name}}</li>\n  {{>item}}\n{{/items}}\n{{^items}}\nnone\n{{/items}}\n", 20)
+	fragments := []string{"", "x", "\n", "{{name}}", "{{#a}}", "{{/a}}", "{{#a}}\n{{b}}\n{{/a}}\n", "{{=<% %>=}}", "{{", "}}", "{{! c }}\n", "{{%ESCAPE JSON}}"}
+	rnd := rand.New(rand.NewSource(1))
+	tmpl, err := New().CompileString(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reused := 0
+	for i := 0; i < 500; i++ {
+		start := rnd.Intn(len(source) + 1)
+		n := len(source) - start
+		if n > 20 {
+			n = 20
+		}
+		end := start + rnd.Intn(n+1)
+		edit := Edit{start, end, fragments[rnd.Intn(len(fragments))]}
+		edited := source[:start] + edit.Text + source[end:]
+
+		expected, expectedErr := New().CompileString(edited)
+		actual, err := tmpl.Reparse(edit)
+		if (err == nil) != (expectedErr == nil) {
+			t.Fatalf("%+v: expected error %v, got %v", edit, expectedErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if string(actual.data) != edited {
+			t.Fatalf("%+v: unexpected source %q", edit, actual.data)
+		}
+		expectedAST, _ := expected.MarshalJSON()
+		actualAST, _ := actual.MarshalJSON()
+		if string(actualAST) != string(expectedAST) {
+			t.Fatalf("%+v: expected %s, got %s", edit, expectedAST, actualAST)
+		}
+		if expected, actual := tagPositions(expected.Tags()), tagPositions(actual.Tags()); !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("%+v: expected tags %v, got %v", edit, expected, actual)
+		}
+		if !reflect.DeepEqual(actual.checkpoints, expected.checkpoints) {
+			t.Fatalf("%+v: expected checkpoints %v, got %v", edit, expected.checkpoints, actual.checkpoints)
+		}
+		if len(actual.elems) > 0 && len(tmpl.elems) > 0 && actual.elems[0] == tmpl.elems[0] {
+			reused++
+		}
+		// continue editing the result, to check that it can be reparsed in turn
+		tmpl, source = actual, edited
+	}
+	if reused < 250 {
+		t.Errorf("expected most edits to be parsed incrementally, got %d", reused)
+	}
+}
+
+func TestReparseErrors(t *testing.T) {
+	tmpl, err := New().CompileString("{{a}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Reparse(Edit{Start: 3, End: 9}); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("expected an out of range error, got %v", err)
+	}
+	if _, err := tmpl.Reparse(Edit{Start: 0, End: 0, Text: "{{/b}}"}); err == nil || !strings.Contains(err.Error(), "unmatched close tag") {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+
+	ast, _ := tmpl.MarshalJSON()
+	if tmpl, err = New().CompileJSON(ast); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Reparse(Edit{}); err == nil || !strings.Contains(err.Error(), "not compiled from source") {
+		t.Errorf("expected an error for a template without source, got %v", err)
+	}
+}
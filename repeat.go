@@ -0,0 +1,70 @@
+package mustache
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// maxRepeat limits the number of times a numeric section repeats, since the number may come from the template.
+const maxRepeat = 10000
+
+// WithNumericSections makes a section over a number repeat its contents that many times, with {{.}} holding the
+// number of the repetition from 1, so that star ratings and placeholders need no slices built for them in Go:
+//
+//	{{#rating}}★{{/rating}}
+//
+// A section whose name is a number, such as {{#3}}, repeats that many times without looking the name up. Numbers must
+// be whole, but may be floating point, as numbers decoded from JSON are; negative numbers repeat no times. -first,
+// -last and -index apply as they do to lists. A section may repeat at most 10000 times. Inverted sections are
+// unaffected.
+func (r *Compiler) WithNumericSections(b bool) *Compiler {
+	r.numericSections = b
+	return r
+}
+
+// literalRepeat returns the number a section is named with, if numeric sections are enabled.
+func (tmpl *Template) literalRepeat(name string) (int, bool) {
+	if !tmpl.parent.numericSections || name == "" {
+		return 0, false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < '0' || name[i] > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(name)
+	if err != nil {
+		// too large to repeat anyway
+		return math.MaxInt, true
+	}
+	return n, true
+}
+
+// repeatContexts returns the contexts of a section over v, if numeric sections are enabled and v is a whole number.
+func (tmpl *Template) repeatContexts(section *sectionElement, v reflect.Value) (sectionContexts, bool, error) {
+	if !tmpl.parent.numericSections {
+		return sectionContexts{}, false, nil
+	}
+	var n float64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		if n = v.Float(); n != math.Trunc(n) {
+			return sectionContexts{}, false, nil
+		}
+	default:
+		return sectionContexts{}, false, nil
+	}
+	if n > maxRepeat {
+		return sectionContexts{}, true, fmt.Errorf("section %q would repeat %v times, more than the limit of %d", section.name, n, maxRepeat)
+	}
+	if n < 0 {
+		n = 0
+	}
+	return sectionContexts{repeat: true, count: int(n)}, true, nil
+}
@@ -0,0 +1,53 @@
+package mustache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNumericSections(t *testing.T) {
+	tests := []struct {
+		template string
+		data     interface{}
+		expected string
+	}{
+		{"{{#rating}}★{{/rating}}", map[string]interface{}{"rating": 3}, "★★★"},
+		{"{{#rating}}{{.}}{{^-last}},{{/-last}}{{/rating}}", map[string]interface{}{"rating": 4.0}, "1,2,3,4"},
+		{"{{#rating}}{{name}}{{-index}}{{/rating}}", map[string]interface{}{"rating": uint8(2), "name": "x"}, "x1x2"},
+		{"{{#rating}}★{{/rating}}{{^rating}}none{{/rating}}", map[string]interface{}{"rating": 0}, "none"},
+		{"[{{#rating}}★{{/rating}}]", map[string]interface{}{"rating": -2}, "[]"},
+		// fractions and other values behave as they otherwise would
+		{"{{#rating}}{{.}}{{/rating}}", map[string]interface{}{"rating": 2.5}, "2.5"},
+		{"{{#rating}}{{.}}{{/rating}}", map[string]interface{}{"rating": "3"}, "3"},
+		{"{{#3}}<li></li>{{/3}}", nil, "<li></li><li></li><li></li>"},
+		{"{{#0}}x{{/0}}{{^0}}y{{/0}}", nil, "y"},
+	}
+	for _, test := range tests {
+		tmpl, err := New().WithNumericSections(true).CompileString(test.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(test.data)
+		if err != nil {
+			t.Errorf("%q: %v", test.template, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.template, test.expected, output)
+		}
+	}
+
+	for _, src := range []string{"{{#n}}x{{/n}}", "{{#99999999999999999999}}x{{/99999999999999999999}}"} {
+		tmpl, err := New().WithNumericSections(true).CompileString(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tmpl.Render(map[string]int{"n": maxRepeat + 1}); err == nil || !strings.Contains(err.Error(), "more than the limit") {
+			t.Errorf("%q: expected an error, got %v", src, err)
+		}
+	}
+
+	// without the option, a number is a single context
+	tmpl, _ := New().CompileString("{{#n}}[{{.}}]{{/n}}{{#3}}x{{/3}}")
+	if output, err := tmpl.Render(map[string]int{"n": 3}); err != nil || output != "[3]" {
+		t.Errorf("expected a single context, got %q, %v", output, err)
+	}
+}
@@ -0,0 +1,185 @@
+package mustache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ResilientProvider wraps a networked PartialProvider, such as an HTTPProvider, so that a flaky server doesn't turn
+// into render latency spikes or storms of requests:
+//
+//	partials := &mustache.ResilientProvider{
+//		Provider:         &mustache.HTTPProvider{BaseURL: "https://cdn.example.com/partials/"},
+//		Retries:          2,
+//		MissingTTL:       time.Minute,
+//		FailureThreshold: 5,
+//	}
+//
+// Loads which fail are retried with exponential backoff, and concurrent loads of the same partial are combined into
+// one. Names which the provider reports missing are remembered for MissingTTL, and reported missing again without
+// asking it. Once FailureThreshold loads in a row have failed, after their retries, the circuit opens: loads fail at
+// once with an error wrapping ErrCircuitOpen until Cooldown has passed, after which a single load is let through to
+// try the provider again, closing the circuit if it succeeds.
+//
+// A load goes on after the renders waiting for it give up, so that its result can be shared with later ones, so the
+// wrapped provider should have a timeout of its own, such as HTTPProvider's Timeout. A ResilientProvider must not be
+// copied after first use.
+type ResilientProvider struct {
+	Provider PartialProvider
+	// Retries is the number of times a failed load is retried. Missing partials and loads abandoned because their
+	// context is done are not retried.
+	Retries int
+	// Backoff is the delay before the first retry, which is doubled before each further retry. It defaults to 100ms.
+	Backoff time.Duration
+	// MissingTTL is how long names reported missing are remembered, or zero not to remember them.
+	MissingTTL time.Duration
+	// FailureThreshold is the number of loads in a row which must fail for the circuit to open, or zero for it never to.
+	FailureThreshold int
+	// Cooldown is how long the circuit stays open. It defaults to 10 seconds.
+	Cooldown time.Duration
+
+	mu        sync.Mutex
+	now       func() time.Time       // the clock, replaced by tests
+	missing   map[string]time.Time   // the names reported missing, with the times until which they are remembered
+	loads     map[string]*sharedLoad // the loads in progress, by name
+	failures  int                    // the number of loads in a row which have failed
+	openUntil time.Time              // the time until which the circuit is open, if it is
+	probing   bool                   // whether a load is trying the provider again after the circuit was open
+}
+
+// sharedLoad is a load of a partial whose result is shared by the callers asking for it while it is in progress.
+type sharedLoad struct {
+	done chan struct{}
+	data string
+	err  error
+}
+
+// Get accepts the name of a partial and returns the parsed partial.
+func (rp *ResilientProvider) Get(name string) (string, error) {
+	return rp.GetContext(context.Background(), name)
+}
+
+// GetContext returns a partial like Get, giving up once ctx is done.
+func (rp *ResilientProvider) GetContext(ctx context.Context, name string) (string, error) {
+	rp.mu.Lock()
+	now := rp.clock()
+	if until, ok := rp.missing[name]; ok {
+		if now.Before(until) {
+			rp.mu.Unlock()
+			return "", fmt.Errorf("%s: %w", name, ErrPartialNotFound)
+		}
+		delete(rp.missing, name)
+	}
+	load, ok := rp.loads[name]
+	if !ok {
+		if err := rp.admit(now); err != nil {
+			rp.mu.Unlock()
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		load = &sharedLoad{done: make(chan struct{})}
+		if rp.loads == nil {
+			rp.loads = make(map[string]*sharedLoad)
+		}
+		rp.loads[name] = load
+		// the load is not tied to the context of the caller starting it, which may give up before the others
+		go rp.load(name, load)
+	}
+	rp.mu.Unlock()
+
+	select {
+	case <-load.done:
+		return load.data, load.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// admit reports whether a load may go ahead with the circuit in its current state, and if it is trying the provider
+// again, marks it as the one load which may do so.
+func (rp *ResilientProvider) admit(now time.Time) error {
+	if rp.FailureThreshold <= 0 || rp.failures < rp.FailureThreshold {
+		return nil
+	}
+	if rp.probing || now.Before(rp.openUntil) {
+		return ErrCircuitOpen
+	}
+	rp.probing = true
+	return nil
+}
+
+// load loads a partial with retries, and records the outcome.
+func (rp *ResilientProvider) load(name string, load *sharedLoad) {
+	backoff := rp.Backoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+	for attempt := 0; ; attempt++ {
+		load.data, load.err = rp.attempt(name)
+		if load.err == nil || errors.Is(load.err, ErrPartialNotFound) || attempt >= rp.Retries {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	delete(rp.loads, name)
+	rp.probing = false
+	now := rp.clock()
+	switch {
+	case load.err == nil:
+		rp.failures = 0
+	case errors.Is(load.err, ErrPartialNotFound):
+		rp.failures = 0
+		if rp.MissingTTL > 0 {
+			if rp.missing == nil {
+				rp.missing = make(map[string]time.Time)
+			}
+			rp.missing[name] = now.Add(rp.MissingTTL)
+		}
+	default:
+		rp.failures++
+		if rp.FailureThreshold > 0 && rp.failures >= rp.FailureThreshold {
+			cooldown := rp.Cooldown
+			if cooldown <= 0 {
+				cooldown = 10 * time.Second
+			}
+			rp.openUntil = now.Add(cooldown)
+		}
+	}
+	close(load.done)
+}
+
+// attempt loads a partial once. A panic here could not be recovered by the caller.
+func (rp *ResilientProvider) attempt(name string) (data string, err error) {
+	defer recoverInternal(&err, "loading a partial")
+	return getPartialSource(context.Background(), rp.Provider, name)
+}
+
+func (rp *ResilientProvider) clock() time.Time {
+	if rp.now != nil {
+		return rp.now()
+	}
+	return time.Now()
+}
+
+// PartialEscapeMode implements EscapeModeProvider for the wrapped provider, if it does.
+func (rp *ResilientProvider) PartialEscapeMode(name string) (EscapeMode, bool) {
+	if emp, ok := rp.Provider.(EscapeModeProvider); ok {
+		return emp.PartialEscapeMode(name)
+	}
+	return 0, false
+}
+
+// RawPartial implements RawPartialProvider for the wrapped provider, if it does.
+func (rp *ResilientProvider) RawPartial(name string) bool {
+	return isRawPartial(rp.Provider, name)
+}
+
+var _ ContextPartialProvider = (*ResilientProvider)(nil)
+var _ EscapeModeProvider = (*ResilientProvider)(nil)
+var _ RawPartialProvider = (*ResilientProvider)(nil)
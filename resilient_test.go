@@ -0,0 +1,108 @@
+package mustache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResilientProvider(t *testing.T) {
+	var calls int32
+	failing := errors.New("unavailable")
+	var fail int32 // the number of calls left to fail
+	prov := providerFunc(func(name string) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		if name == "missing" {
+			return "", ErrPartialNotFound
+		}
+		if atomic.LoadInt32(&fail) > 0 {
+			atomic.AddInt32(&fail, -1)
+			return "", failing
+		}
+		return "<" + name + ">", nil
+	})
+	now := time.Unix(0, 0)
+	rp := &ResilientProvider{Provider: prov, Retries: 2, Backoff: time.Millisecond, MissingTTL: time.Minute, FailureThreshold: 2}
+	rp.now = func() time.Time { return now }
+
+	// failures are retried
+	atomic.StoreInt32(&fail, 2)
+	if data, err := rp.Get("a"); err != nil || data != "<a>" || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("expected a success after two retries, got %q, %v after %d calls", data, err, atomic.LoadInt32(&calls))
+	}
+
+	// missing names are remembered until their time to live has passed
+	atomic.StoreInt32(&calls, 0)
+	for i := 0; i < 3; i++ {
+		if _, err := rp.Get("missing"); !errors.Is(err, ErrPartialNotFound) {
+			t.Errorf("expected ErrPartialNotFound, got %v", err)
+		}
+	}
+	now = now.Add(time.Minute)
+	rp.Get("missing")
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("expected the missing name to be asked for twice, got %d calls", atomic.LoadInt32(&calls))
+	}
+
+	// the circuit opens after two failed loads, and lets one load through once it has cooled down
+	atomic.StoreInt32(&fail, 100)
+	for i := 0; i < 2; i++ {
+		if _, err := rp.Get("a"); !errors.Is(err, failing) {
+			t.Errorf("expected the provider's error, got %v", err)
+		}
+	}
+	atomic.StoreInt32(&calls, 0)
+	if _, err := rp.Get("a"); !errors.Is(err, ErrCircuitOpen) || atomic.LoadInt32(&calls) != 0 {
+		t.Errorf("expected ErrCircuitOpen without calling the provider, got %v after %d calls", err, atomic.LoadInt32(&calls))
+	}
+	now = now.Add(10 * time.Second)
+	atomic.StoreInt32(&fail, 0)
+	if data, err := rp.Get("a"); err != nil || data != "<a>" {
+		t.Errorf("expected the circuit to close, got %q, %v", data, err)
+	}
+	if data, err := rp.Get("b"); err != nil || data != "<b>" {
+		t.Errorf("expected the circuit to stay closed, got %q, %v", data, err)
+	}
+}
+
+func TestResilientProviderSharesLoads(t *testing.T) {
+	release := make(chan struct{})
+	var calls int32
+	rp := &ResilientProvider{Provider: providerFunc(func(name string) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return name, nil
+	})}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if data, err := rp.Get("p"); err != nil || data != "p" {
+				t.Errorf("unexpected partial %q, %v", data, err)
+			}
+		}()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := rp.GetContext(ctx, "p"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to end the wait, got %v", err)
+	}
+	close(release)
+	wg.Wait()
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected concurrent loads to share one call, got %d", atomic.LoadInt32(&calls))
+	}
+
+	tmpl, err := New().WithPartials(rp).CompileString("[{{>p}}]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(nil); err != nil || output != "[p]" {
+		t.Errorf("unexpected output %q, %v", output, err)
+	}
+}
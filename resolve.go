@@ -0,0 +1,54 @@
+package mustache
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+)
+
+// Resolution records how a name was resolved while rendering a tag, as reported by RenderResolutions.
+type Resolution struct {
+	Template string // the name of the template or partial holding the tag, if it has one
+	Tag      string // the tag, such as "{{#items}}"
+	Line     int    // the line of the tag in its template
+	Column   int    // the column of the tag, counting bytes from 1
+	Offset   int    // the offset in the output at which the tag began to render
+	Name     string // the name looked up, which is one of the names of the tag for helper calls and expressions
+	Found    bool   // whether the name resolved to a value
+	// Frame is the context the name was found in, counting outwards from 0 for the innermost, or -1 if it was not
+	// found in a context, as for missing names and names such as -first.
+	Frame int
+	Path  string // the path of the value within the data, such as "items.name", if it was found in a context
+}
+
+// RenderResolutions renders the template like Render, and also reports how each name the template looked up was
+// resolved, in the order of the lookups: the tag it belongs to, where the tag's output begins, and which context, and
+// which path within the data, it was found in. It is meant for tools which explain templates to their authors, such as
+// the debugserver package, and is slower than Render. Post processors which change the output throw the offsets off.
+func (tmpl *Template) RenderResolutions(data ...interface{}) (string, []Resolution, error) {
+	var resolutions []Resolution
+	var buf bytes.Buffer
+	err := tmpl.frenderPrepared(context.Background(), &buf, data, func(_ *Template, st *renderState) {
+		resolutions = []Resolution{}
+		st.origins = &originRecorder{}
+		st.resolutions = &resolutions
+		st.usage = newUsageTracker(len(data))
+	})
+	return buf.String(), resolutions, err
+}
+
+// resolved records the resolution of a name looked up by lookup.
+func (st *renderState) resolved(contextChain []reflect.Value, name string, v reflect.Value, frame int) {
+	r := Resolution{Name: name, Found: v.IsValid(), Frame: frame}
+	if n := len(st.origins.origins); n > 0 {
+		o := st.origins.origins[n-1]
+		r.Template, r.Tag, r.Line, r.Column, r.Offset = o.template, o.tag, o.line, o.col, o.offset
+	}
+	if frame >= 0 && frame < len(contextChain) && len(st.usage.paths) >= len(contextChain) {
+		r.Path = st.usage.paths[len(contextChain)-1-frame]
+		if name != "." {
+			r.Path = joinPath(r.Path, name)
+		}
+	}
+	*st.resolutions = append(*st.resolutions, r)
+}
@@ -0,0 +1,35 @@
+package mustache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderResolutions(t *testing.T) {
+	tmpl, err := New().CompileString("Hi {{name}}!\n{{#items}}<{{label}} {{name}} {{-index}}>{{/items}}{{missing}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{
+		"name":  "Jo",
+		"items": []map[string]string{{"label": "a"}},
+	}
+	output, resolutions, err := tmpl.RenderResolutions(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "Hi Jo!\n<a Jo 1>" {
+		t.Errorf("unexpected output %q", output)
+	}
+	expected := []Resolution{
+		{Tag: "{{name}}", Line: 1, Column: 4, Offset: 3, Name: "name", Found: true, Frame: 0, Path: "name"},
+		{Tag: "{{#items}}", Line: 2, Column: 1, Offset: 7, Name: "items", Found: true, Frame: 0, Path: "items"},
+		{Tag: "{{label}}", Line: 2, Column: 12, Offset: 8, Name: "label", Found: true, Frame: 0, Path: "items.label"},
+		{Tag: "{{name}}", Line: 2, Column: 22, Offset: 10, Name: "name", Found: true, Frame: 1, Path: "name"},
+		{Tag: "{{-index}}", Line: 2, Column: 31, Offset: 13, Name: "-index", Found: true, Frame: -1},
+		{Tag: "{{missing}}", Line: 2, Column: 52, Offset: 15, Name: "missing", Frame: -1},
+	}
+	if !reflect.DeepEqual(resolutions, expected) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expected, resolutions)
+	}
+}
@@ -0,0 +1,44 @@
+package mustache
+
+// Sanitizer cleans untrusted HTML, for instance by removing scripts and event handler attributes. It is satisfied by
+// a *bluemonday.Policy.
+type Sanitizer interface {
+	Sanitize(s string) string
+}
+
+// SanitizerFunc adapts a function to a Sanitizer.
+type SanitizerFunc func(s string) string
+
+// Sanitize calls f(s).
+func (f SanitizerFunc) Sanitize(s string) string {
+	return f(s)
+}
+
+// TrustedHTML is a string which raw tags write as is when the compiler has a raw sanitizer. It marks HTML which comes
+// from the application rather than its users, and so needs no sanitizing.
+type TrustedHTML string
+
+// WithRawSanitizer passes the output of raw tags, {{{name}}} and {{&name}}, and of raw helper calls, through s in the
+// HTML escape mode, so that user generated rich text can be written without escaping and without risking script
+// injection:
+//
+//	cmpl := mustache.New().WithRawSanitizer(bluemonday.UGCPolicy())
+//
+// Once a sanitizer is set, only values of type TrustedHTML are written unsanitized. Templates whose escape mode is not
+// HTML are not affected.
+func (r *Compiler) WithRawSanitizer(s Sanitizer) *Compiler {
+	r.rawSanitizer = s
+	return r
+}
+
+// sanitizeRaw returns the string form s of a value written by a raw tag, sanitized unless there is no sanitizer, the
+// escape mode is not HTML or the value is TrustedHTML or Attrs.
+func (tmpl *Template) sanitizeRaw(value interface{}, s string) string {
+	if tmpl.parent.rawSanitizer == nil || tmpl.outputMode != EscapeHTML {
+		return s
+	}
+	if _, ok := value.(TrustedHTML); ok || tmpl.isAttrs(value) {
+		return s
+	}
+	return tmpl.parent.rawSanitizer.Sanitize(s)
+}
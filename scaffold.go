@@ -0,0 +1,110 @@
+package mustache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Scaffold generates a starter template for rendering values of a struct type, such as an admin or detail view, so
+// that the template begins with names which match the context exactly. structType is a value of the type, a pointer to
+// one, such as (*Order)(nil), or its reflect.Type. Each exported field, including those promoted from embedded
+// structs, becomes a line labeled with its name:
+//
+//	ID: {{ID}}
+//	Customer:
+//	{{#Customer}}
+//	  Name: {{Name}}
+//	{{/Customer}}
+//	Items:
+//	{{#Items}}
+//	  - Name: {{Name}}
+//	    Price: {{Price}}
+//	{{/Items}}
+//	{{^Items}}
+//	  (none)
+//	{{/Items}}
+//
+// Structs become sections holding their own fields, and slices and arrays become sections rendering each item, with
+// {{.}} for items which are not structs. Types which implement fmt.Stringer, such as time.Time, are written as values.
+// Maps, whose keys are not known, and fields of a struct type which contains itself are left as comments, while
+// functions and channels are left out.
+func Scaffold(structType any) (string, error) {
+	typ, ok := structType.(reflect.Type)
+	if !ok {
+		typ = reflect.TypeOf(structType)
+	}
+	if typ == nil {
+		return "", errors.New("scaffold: no type given")
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || isStringer(typ) {
+		return "", fmt.Errorf("scaffold: %s is not a struct type", typ)
+	}
+	var b strings.Builder
+	scaffoldFields(&b, typ, "", "", map[reflect.Type]bool{typ: true})
+	return b.String(), nil
+}
+
+// isStringer reports whether values of typ, or pointers to them, implement fmt.Stringer.
+func isStringer(typ reflect.Type) bool {
+	return typ.Implements(stringerType) || reflect.PointerTo(typ).Implements(stringerType)
+}
+
+// scaffoldFields writes the lines for the fields of typ. The first line begins with first, and the rest with indent.
+// open holds the struct types being written, so that a type which contains itself is not written forever.
+func scaffoldFields(b *strings.Builder, typ reflect.Type, first, indent string, open map[reflect.Type]bool) {
+	prefix := first
+	for _, f := range reflect.VisibleFields(typ) {
+		if !f.IsExported() || f.Anonymous && indirectType(f.Type).Kind() == reflect.Struct {
+			continue
+		}
+		if scaffoldField(b, f.Name, f.Type, prefix, indent, open) {
+			prefix = indent
+		}
+	}
+}
+
+// scaffoldField writes the lines for the field name of type typ, reporting whether it wrote any.
+func scaffoldField(b *strings.Builder, name string, typ reflect.Type, prefix, indent string, open map[reflect.Type]bool) bool {
+	elem := indirectType(typ)
+	switch {
+	case isStringer(typ) || isStringer(elem):
+		fmt.Fprintf(b, "%s%s: {{%s}}\n", prefix, name, name)
+	case elem.Kind() == reflect.Func || elem.Kind() == reflect.Chan || elem.Kind() == reflect.UnsafePointer:
+		return false
+	case elem.Kind() == reflect.Map:
+		fmt.Fprintf(b, "%s{{! %s: a map, whose keys are not known }}\n", prefix, name)
+	case elem.Kind() == reflect.Struct:
+		if open[elem] {
+			fmt.Fprintf(b, "%s{{! %s: a %s, which contains itself }}\n", prefix, name, elem)
+			break
+		}
+		open[elem] = true
+		fmt.Fprintf(b, "%s%s:\n%s{{#%s}}\n", prefix, name, indent, name)
+		scaffoldFields(b, elem, indent+"  ", indent+"  ", open)
+		fmt.Fprintf(b, "%s{{/%s}}\n", indent, name)
+		delete(open, elem)
+	case (elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array) && elem.Elem().Kind() != reflect.Uint8:
+		item := indirectType(elem.Elem())
+		if open[item] {
+			fmt.Fprintf(b, "%s{{! %s: a list of %s, which contains itself }}\n", prefix, name, item)
+			break
+		}
+		fmt.Fprintf(b, "%s%s:\n%s{{#%s}}\n", prefix, name, indent, name)
+		if item.Kind() == reflect.Struct && !isStringer(item) {
+			open[item] = true
+			scaffoldFields(b, item, indent+"  - ", indent+"    ", open)
+			delete(open, item)
+		} else {
+			fmt.Fprintf(b, "%s  - {{.}}\n", indent)
+		}
+		fmt.Fprintf(b, "%s{{/%s}}\n%s{{^%s}}\n%s  (none)\n%s{{/%s}}\n", indent, name, indent, name, indent, indent, name)
+	default:
+		fmt.Fprintf(b, "%s%s: {{%s}}\n", prefix, name, name)
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package mustache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type scaffoldCustomer struct {
+	Name  string
+	Email *string
+}
+
+type scaffoldItem struct {
+	SKU   string
+	Price float64
+}
+
+type scaffoldAudit struct {
+	Created time.Time
+	by      string
+}
+
+type scaffoldOrder struct {
+	scaffoldAudit
+	ID       int
+	Customer *scaffoldCustomer
+	Items    []scaffoldItem
+	Tags     []string
+	Attrs    map[string]string
+	Notify   func()
+	Related  []*scaffoldOrder
+	Checksum []byte
+}
+
+func TestScaffold(t *testing.T) {
+	skipLight(t)
+	expected := `Created: {{Created}}
+ID: {{ID}}
+Customer:
+{{#Customer}}
+  Name: {{Name}}
+  Email: {{Email}}
+{{/Customer}}
+Items:
+{{#Items}}
+  - SKU: {{SKU}}
+    Price: {{Price}}
+{{/Items}}
+{{^Items}}
+  (none)
+{{/Items}}
+Tags:
+{{#Tags}}
+  - {{.}}
+{{/Tags}}
+{{^Tags}}
+  (none)
+{{/Tags}}
+{{! Attrs: a map, whose keys are not known }}
+{{! Related: a list of mustache.scaffoldOrder, which contains itself }}
+Checksum: {{Checksum}}
+`
+	for _, v := range []any{scaffoldOrder{}, (*scaffoldOrder)(nil), reflect.TypeOf(scaffoldOrder{})} {
+		src, err := Scaffold(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src != expected {
+			t.Fatalf("expected\n%s\ngot\n%s", expected, src)
+		}
+	}
+
+	// the scaffold compiles, matches the type and renders the data
+	tmpl, err := CompileTyped[scaffoldOrder](New(), expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := "jo@example.com"
+	output, err := tmpl.Render(scaffoldOrder{
+		ID:       7,
+		Customer: &scaffoldCustomer{Name: "Jo", Email: &email},
+		Items:    []scaffoldItem{{"A1", 2.5}, {"B2", 10}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := `Created: 0001-01-01 00:00:00 +0000 UTC
+ID: 7
+Customer:
+  Name: Jo
+  Email: jo@example.com
+Items:
+  - SKU: A1
+    Price: 2.5
+  - SKU: B2
+    Price: 10
+Tags:
+  (none)
+Checksum: []
+`
+	if output != expectedOutput {
+		t.Errorf("expected\n%s\ngot\n%s", expectedOutput, output)
+	}
+
+	for _, v := range []any{nil, 3, time.Time{}, []scaffoldItem{}} {
+		if _, err := Scaffold(v); err == nil {
+			t.Errorf("%T: expected an error", v)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package mustache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// RenderSection renders only the first section of the template with the given name, such as {{#cart}}...{{/cart}},
+// for pages which update a fragment of a larger template. The section is rendered as if it were at the top level of
+// the template, so its name is looked up in data rather than in the contexts of any sections around it.
+func (tmpl *Template) RenderSection(name string, data ...interface{}) (string, error) {
+	var buf bytes.Buffer
+	err := tmpl.FrenderSection(&buf, name, data...)
+	return buf.String(), err
+}
+
+// FrenderSection renders the first section of the template with the given name to an io.Writer, like RenderSection.
+func (tmpl *Template) FrenderSection(out io.Writer, name string, data ...interface{}) error {
+	section := findSection(tmpl.elems, name)
+	if section == nil {
+		return fmt.Errorf("%s: %w", name, ErrSectionNotFound)
+	}
+	return tmpl.frenderContext(context.Background(), out, []interface{}{section}, data, nil)
+}
+
+// findSection returns the first section named name in elems or the sections within them, in the order they appear
+// in the template.
+func findSection(elems []interface{}, name string) *sectionElement {
+	for _, elem := range elems {
+		if section, ok := elem.(*sectionElement); ok {
+			if section.name == name {
+				return section
+			}
+			if found := findSection(section.elems, name); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
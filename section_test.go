@@ -0,0 +1,37 @@
+package mustache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRenderSection(t *testing.T) {
+	tmpl, err := New().CompileString("<h1>{{title}}</h1>\n{{#page}}\n<div id=\"cart\">\n{{#cart}}\n<li>{{name}}</li>\n{{/cart}}\n{{^cart}}\nempty\n{{/cart}}\n</div>\n{{/page}}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{
+		"title": "Shop",
+		"cart":  []map[string]string{{"name": "tea"}, {"name": "milk"}},
+	}
+	tests := []struct {
+		name     string
+		data     interface{}
+		expected string
+	}{
+		{"cart", data, "<li>tea</li>\n<li>milk</li>\n"},
+		{"cart", map[string]interface{}{}, ""},
+		{"page", map[string]interface{}{"page": true, "cart": false}, "<div id=\"cart\">\nempty\n</div>\n"},
+	}
+	for _, test := range tests {
+		output, err := tmpl.RenderSection(test.name, test.data)
+		if err != nil {
+			t.Error(err)
+		} else if output != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, output)
+		}
+	}
+	if _, err := tmpl.RenderSection("title", data); !errors.Is(err, ErrSectionNotFound) {
+		t.Errorf("expected ErrSectionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package mustache
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Severity ranks the issues reported by Template.SecurityLint.
+type Severity int
+
+// The severities of security issues, from least to most severe.
+const (
+	SeverityLow Severity = iota
+	SeverityMedium
+	SeverityHigh
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityLow:
+		return "low"
+	case SeverityMedium:
+		return "medium"
+	case SeverityHigh:
+		return "high"
+	}
+	return "Severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+// The rules of Template.SecurityLint.
+const (
+	// RuleRawOutput reports a raw tag, {{{name}}} or {{&name}}, or a raw helper call, which writes its value without
+	// escaping. It is of low severity if the compiler has a raw sanitizer, and high otherwise.
+	RuleRawOutput = "raw-output"
+	// RuleScript reports a tag in the contents of a script element, where HTML escaping does not prevent injection.
+	RuleScript = "script-context"
+	// RuleStyle reports a tag in the contents of a style element or in a style attribute.
+	RuleStyle = "style-context"
+	// RuleEventHandler reports a tag in the value of an event handler attribute, such as onclick.
+	RuleEventHandler = "event-handler"
+	// RuleURL reports a tag at the start of the value of an attribute holding a URL, such as href, which can supply a
+	// javascript: URL.
+	RuleURL = "url-position"
+	// RuleUnquotedAttribute reports a tag in an unquoted attribute value, which a space in the value can end.
+	RuleUnquotedAttribute = "unquoted-attribute"
+	// RuleAttributeName reports a tag within a tag but outside any attribute value, which can add attributes.
+	RuleAttributeName = "attribute-name"
+)
+
+// SecurityIssue is a tag reported by Template.SecurityLint.
+type SecurityIssue struct {
+	Rule     string // one of the Rule constants
+	Severity Severity
+	Tag      string // the name of the variable, or the helper call
+	Line     int    // the line of the tag, or 0 if the template was not compiled from source
+	Column   int    // the column of the tag, counting bytes from 1
+	Message  string
+}
+
+func (i SecurityIssue) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s): %s", i.Line, i.Column, i.Severity, i.Tag, i.Rule, i.Message)
+}
+
+// urlAttributes are the attributes whose values RuleURL checks.
+var urlAttributes = []string{"href", "src", "action", "formaction", "poster", "cite", "data", "background", "xlink:href"}
+
+// SecurityLint checks the variable and helper tags of a template in the HTML escape mode for uses which HTML escaping
+// does not make safe, for security reviews: raw tags, tags in script and style elements, in event handler and style
+// attributes, at the start of URL attributes and in unquoted attribute values, and tags which can add attributes to an
+// element. The issues are returned in the order of the template; there may be more than one for a tag. Templates in
+// other escape modes have no issues.
+//
+// The HTML around a tag is found from the text of the template alone, taking the contents of sections as if they were
+// always rendered once, and partials as if they were empty, so issues in partials are reported by linting the partials
+// themselves.
+func (tmpl *Template) SecurityLint() []SecurityIssue {
+	if tmpl.outputMode != EscapeHTML {
+		return nil
+	}
+	// replace each tag with a marker holding its index, so that the HTML can be scanned as a whole
+	skeleton, tags := tmpl.skeleton()
+
+	var issues []SecurityIssue
+	scanHTMLContexts(skeleton, func(i int, c htmlContext) {
+		issue, raw := tagIssue(tags[i])
+		if raw {
+			issues = append(issues, tmpl.rawIssue(issue))
+		}
+		if issue.Rule, issue.Severity, issue.Message = c.issue(); issue.Rule != "" {
+			issues = append(issues, issue)
+		}
+	})
+	return issues
+}
+
+// skeleton returns the text of the template with each variable and helper tag replaced by its index between NUL
+// bytes, taking the contents of sections as if they were always rendered once and partials as if they were empty, and
+// the tags in order.
+func (tmpl *Template) skeleton() ([]byte, []interface{}) {
+	var skeleton []byte
+	var tags []interface{}
+	var flatten func(elems []interface{})
+	flatten = func(elems []interface{}) {
+		for _, elem := range elems {
+			switch elem := elem.(type) {
+			case *textElement:
+				for _, c := range elem.text {
+					if c != 0 {
+						skeleton = append(skeleton, c)
+					}
+				}
+			case *varElement, *helperElement, *exprElement:
+				skeleton = append(skeleton, 0)
+				skeleton = strconv.AppendInt(skeleton, int64(len(tags)), 10)
+				skeleton = append(skeleton, 0)
+				tags = append(tags, elem)
+			case *sectionElement:
+				flatten(elem.elems)
+			}
+		}
+	}
+	flatten(tmpl.elems)
+	return skeleton, tags
+}
+
+// tagIssue returns an issue for a variable or helper tag, with no rule, and whether the tag is raw.
+func tagIssue(tag interface{}) (SecurityIssue, bool) {
+	switch elem := tag.(type) {
+	case *varElement:
+		return SecurityIssue{Tag: elem.name, Line: elem.line, Column: elem.col}, elem.raw
+	case *helperElement:
+		return SecurityIssue{Tag: elem.String(), Line: elem.line, Column: elem.col}, elem.raw
+	case *exprElement:
+		return SecurityIssue{Tag: elem.src, Line: elem.line, Column: elem.col}, elem.raw
+	}
+	return SecurityIssue{}, false
+}
+
+func (tmpl *Template) rawIssue(issue SecurityIssue) SecurityIssue {
+	issue.Rule = RuleRawOutput
+	if tmpl.parent.rawSanitizer != nil {
+		issue.Severity = SeverityLow
+		issue.Message = "the value is written without escaping, but sanitized"
+	} else {
+		issue.Severity = SeverityHigh
+		issue.Message = "the value is written without escaping or sanitizing"
+	}
+	return issue
+}
+
+// htmlContext is the position of a tag in the HTML of a template.
+type htmlContext struct {
+	element    string // the element the tag is in the contents of, if it is script or style
+	inTag      bool   // whether the tag is within a start tag
+	attr       string // the lower case name of the attribute whose value the tag is in, if any
+	quoted     bool   // whether the attribute value is quoted
+	valueStart bool   // whether the tag is at the start of the attribute value
+}
+
+// issue returns the rule the context breaks, if any, with its severity and a message.
+func (c htmlContext) issue() (string, Severity, string) {
+	switch {
+	case c.element == "script":
+		return RuleScript, SeverityHigh, "HTML escaping does not prevent injection into scripts"
+	case c.element == "style":
+		return RuleStyle, SeverityMedium, "HTML escaping does not prevent injection into style sheets"
+	case !c.inTag:
+		return "", 0, ""
+	case c.attr == "":
+		return RuleAttributeName, SeverityMedium, "the value can add attributes to the element"
+	case strings.HasPrefix(c.attr, "on"):
+		return RuleEventHandler, SeverityHigh, fmt.Sprintf("HTML escaping does not prevent injection into the %s handler", c.attr)
+	case c.attr == "style":
+		return RuleStyle, SeverityMedium, "HTML escaping does not prevent injection into style attributes"
+	case c.valueStart && isURLAttribute(c.attr):
+		return RuleURL, SeverityHigh, fmt.Sprintf("the value can set the scheme of the %s URL, as in javascript:", c.attr)
+	case !c.quoted:
+		return RuleUnquotedAttribute, SeverityMedium, fmt.Sprintf("the value of the %s attribute is not quoted", c.attr)
+	}
+	return "", 0, ""
+}
+
+func isURLAttribute(name string) bool {
+	for _, attr := range urlAttributes {
+		if name == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// scanHTMLContexts scans src, in which each tag is replaced by its index between NUL bytes, and calls fn with the
+// index and the HTML context of each tag in order.
+func scanHTMLContexts(src []byte, fn func(i int, c htmlContext)) {
+	// marker reports the index of the tag whose marker starts at i, and the length of the marker
+	marker := func(i int) (int, int, bool) {
+		if i >= len(src) || src[i] != 0 {
+			return 0, 0, false
+		}
+		end := bytes.IndexByte(src[i+1:], 0)
+		if end < 0 {
+			return 0, 0, false
+		}
+		n, _ := strconv.Atoi(string(src[i+1 : i+1+end]))
+		return n, end + 2, true
+	}
+	isName := func(c byte) bool {
+		return !isHTMLSpace(c) && c != '=' && c != '>' && c != '/' && c != 0
+	}
+
+	i := 0
+	for i < len(src) {
+		if n, size, ok := marker(i); ok {
+			fn(n, htmlContext{})
+			i += size
+			continue
+		}
+		if src[i] != '<' || i+1 == len(src) {
+			i++
+			continue
+		}
+		if bytes.HasPrefix(src[i:], []byte("<!--")) {
+			end := bytes.Index(src[i+4:], []byte("-->"))
+			if end < 0 {
+				return
+			}
+			i += 4 + end + 3
+			continue
+		}
+		if c := src[i+1]; !(isASCIILetter(c) || c == '/' || c == '!' || c == '?') {
+			i++
+			continue
+		}
+
+		// a tag: its name, then its attributes
+		closing := src[i+1] == '/'
+		i++
+		if closing {
+			i++
+		}
+		start := i
+		for i < len(src) && (isASCIILetter(src[i]) || src[i] >= '0' && src[i] <= '9' || src[i] == '-') {
+			i++
+		}
+		name := string(bytes.ToLower(src[start:i]))
+		for i < len(src) && src[i] != '>' {
+			if n, size, ok := marker(i); ok {
+				fn(n, htmlContext{inTag: true})
+				i += size
+				continue
+			}
+			if isHTMLSpace(src[i]) || src[i] == '/' {
+				i++
+				continue
+			}
+			start := i
+			for i < len(src) && isName(src[i]) {
+				i++
+			}
+			attr := string(bytes.ToLower(src[start:i]))
+			if attr == "" {
+				continue
+			}
+			j := i
+			for j < len(src) && isHTMLSpace(src[j]) {
+				j++
+			}
+			if j == len(src) || src[j] != '=' {
+				continue
+			}
+			i = j + 1
+			for i < len(src) && isHTMLSpace(src[i]) {
+				i++
+			}
+			var quote byte
+			if i < len(src) && (src[i] == '"' || src[i] == '\'') {
+				quote = src[i]
+				i++
+			}
+			valueStart := i
+			for i < len(src) {
+				if n, size, ok := marker(i); ok {
+					fn(n, htmlContext{inTag: true, attr: attr, quoted: quote != 0, valueStart: i == valueStart})
+					i += size
+					continue
+				}
+				c := src[i]
+				if quote != 0 && c == quote {
+					i++
+					break
+				}
+				if quote == 0 && (isHTMLSpace(c) || c == '>') {
+					break
+				}
+				i++
+			}
+		}
+		i++
+		if closing || name != "script" && name != "style" {
+			continue
+		}
+
+		// the contents of a script or style element
+		end := indexFold(src[i:], "</"+name)
+		if end < 0 {
+			end = len(src) - i
+		}
+		for j := i; j < i+end; j++ {
+			if n, size, ok := marker(j); ok {
+				fn(n, htmlContext{element: name})
+				j += size - 1
+			}
+		}
+		i += end
+	}
+}
@@ -0,0 +1,60 @@
+package mustache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSecurityLint(t *testing.T) {
+	source := `<!DOCTYPE html>
+<a href="{{url}}" title={{title}} onclick="go({{id}})">{{{label}}}</a>
+<a href="/users/{{id}}" class="{{class}}" {{attrs}}>{{name}}</a>
+{{#items}}<script>var item = {{item}};</script>{{/items}}
+<div style="color: {{color}}"><!-- {{comment}} --></div>
+<style>p { color: {{color}} }</style>{{&raw}}
+`
+	tmpl, err := New().CompileString(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type issue struct {
+		Rule     string
+		Severity Severity
+		Tag      string
+		Line     int
+		Column   int
+	}
+	expected := []issue{
+		{RuleURL, SeverityHigh, "url", 2, 10},
+		{RuleUnquotedAttribute, SeverityMedium, "title", 2, 25},
+		{RuleEventHandler, SeverityHigh, "id", 2, 47},
+		{RuleRawOutput, SeverityHigh, "label", 2, 56},
+		{RuleAttributeName, SeverityMedium, "attrs", 3, 43},
+		{RuleScript, SeverityHigh, "item", 4, 30},
+		{RuleStyle, SeverityMedium, "color", 5, 20},
+		{RuleStyle, SeverityMedium, "color", 6, 19},
+		{RuleRawOutput, SeverityHigh, "raw", 6, 38},
+	}
+	var actual []issue
+	for _, i := range tmpl.SecurityLint() {
+		actual = append(actual, issue{i.Rule, i.Severity, i.Tag, i.Line, i.Column})
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected\n%v\ngot\n%v", expected, actual)
+	}
+
+	sanitized, err := New().WithRawSanitizer(SanitizerFunc(func(s string) string { return s })).CompileString("{{{a}}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := sanitized.SecurityLint(); len(issues) != 1 || issues[0].Severity != SeverityLow {
+		t.Errorf("expected a low severity issue, got %v", issues)
+	}
+	json, err := New().WithEscapeMode(EscapeJSON).CompileString("<script>{{{a}}}</script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := json.SecurityLint(); len(issues) != 0 {
+		t.Errorf("expected no issues in the JSON mode, got %v", issues)
+	}
+}
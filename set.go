@@ -0,0 +1,324 @@
+package mustache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// TemplateSet is a collection of named templates which are compiled together, and may include one another as
+// partials. A set may also hold templates which are only used as partials. Partials are resolved within the set.
+//
+// A template may have several versions, identified by labels such as "v2", so that new versions can be tried out
+// alongside the current one. RenderVersion renders a particular version, and a VersionSelector set with SetSelector
+// chooses the version used by Render, for instance to roll a new version out to a percentage of renders.
+type TemplateSet struct {
+	contents atomic.Value // a *setContents
+	selector atomic.Value // a VersionSelector
+	mu       sync.Mutex   // serializes changes to the contents
+}
+
+// setContents holds the templates of a set. It is replaced as a whole when the set changes, and never modified.
+type setContents struct {
+	templates map[string]*Template            // the unversioned templates, by name
+	versions  map[string]map[string]*Template // the versions of templates, by name and label
+	labels    map[string][]string             // the sorted labels of the versions of each template
+}
+
+// VersionSelector chooses the version of a template used by TemplateSet.Render, given the name of the template, the
+// sorted labels of its versions and the data it is being rendered with. Returning an empty string selects the
+// unversioned template. A VersionSelector may be called concurrently.
+type VersionSelector func(name string, versions []string, data []interface{}) string
+
+// Rollout returns a VersionSelector which chooses versions at random in proportion to their weights. The empty label
+// stands for the unversioned template. So Rollout(map[string]int{"": 90, "v2": 10}) renders v2 for roughly a tenth
+// of renders. Templates with none of the weighted versions are rendered unversioned.
+func Rollout(weights map[string]int) VersionSelector {
+	labels := make([]string, 0, len(weights))
+	for label := range weights {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return func(name string, versions []string, data []interface{}) string {
+		total := 0
+		for _, label := range labels {
+			if weights[label] > 0 && hasVersion(versions, label) {
+				total += weights[label]
+			}
+		}
+		if total == 0 {
+			return ""
+		}
+		n := rand.Intn(total)
+		for _, label := range labels {
+			if weights[label] <= 0 || !hasVersion(versions, label) {
+				continue
+			}
+			if n < weights[label] {
+				return label
+			}
+			n -= weights[label]
+		}
+		return ""
+	}
+}
+
+// hasVersion reports whether label is the empty label or one of versions.
+func hasVersion(versions []string, label string) bool {
+	if label == "" {
+		return true
+	}
+	i := sort.SearchStrings(versions, label)
+	return i < len(versions) && versions[i] == label
+}
+
+// setSource is the source of one template in a TemplateSet.
+type setSource struct {
+	name     string
+	version  string
+	data     []byte
+	mode     EscapeMode
+	hasMode  bool
+	partial  bool // only available as a partial
+	metadata Metadata
+}
+
+// compileSet compiles the templates of a set. Partials are provided by the other unversioned sources, with the
+// compiler's own escape mode unless the source sets one.
+func (r *Compiler) compileSet(sources []setSource) (*TemplateSet, error) {
+	prov := &StaticProvider{
+		Partials:      make(map[string]string),
+		ReportMissing: true,
+		EscapeModes:   make(map[string]EscapeMode),
+	}
+	seen := make(map[[2]string]bool)
+	for _, src := range sources {
+		key := [2]string{src.name, src.version}
+		if seen[key] {
+			if src.version != "" {
+				return nil, fmt.Errorf("duplicate template %q version %q", src.name, src.version)
+			}
+			return nil, fmt.Errorf("duplicate template %q", src.name)
+		}
+		seen[key] = true
+		if src.version != "" {
+			continue
+		}
+		prov.Partials[src.name] = string(src.data)
+		if src.hasMode {
+			prov.EscapeModes[src.name] = src.mode
+		}
+	}
+
+	cmpl := *r
+	cmpl.partial = prov
+	if cmpl.compiledPartials != nil {
+		// the partials of the set include the other partials of the set, rather than those of a set compiled before
+		cmpl.compiledPartials = newCompiledPartials()
+	}
+	contents := newSetContents()
+	for _, src := range sources {
+		mode := cmpl.outputMode
+		if src.hasMode {
+			mode = src.mode
+		}
+		tmpl, err := cmpl.compileInMode(context.Background(), src.name, src.data, mode)
+		if err != nil {
+			if src.version != "" {
+				return nil, fmt.Errorf("%s@%s: %w", src.name, src.version, err)
+			}
+			return nil, fmt.Errorf("%s: %w", src.name, err)
+		}
+		if src.partial {
+			continue
+		}
+		if src.metadata != nil {
+			tmpl = tmpl.WithMetadata(src.metadata)
+		}
+		contents.add(src.name, src.version, tmpl)
+	}
+	set := &TemplateSet{}
+	set.contents.Store(contents)
+	return set, nil
+}
+
+func newSetContents() *setContents {
+	return &setContents{
+		templates: make(map[string]*Template),
+		versions:  make(map[string]map[string]*Template),
+		labels:    make(map[string][]string),
+	}
+}
+
+// add adds a template to the contents, which must not yet have been stored in a set.
+func (c *setContents) add(name, version string, tmpl *Template) {
+	if version == "" {
+		c.templates[name] = tmpl
+		return
+	}
+	if c.versions[name] == nil {
+		c.versions[name] = make(map[string]*Template)
+	}
+	if _, ok := c.versions[name][version]; !ok {
+		labels := append(c.labels[name], version)
+		sort.Strings(labels)
+		c.labels[name] = labels
+	}
+	c.versions[name][version] = tmpl
+}
+
+// clone returns a copy of the contents which can be modified.
+func (c *setContents) clone() *setContents {
+	clone := newSetContents()
+	if c == nil {
+		return clone
+	}
+	for name, tmpl := range c.templates {
+		clone.templates[name] = tmpl
+	}
+	for name, versions := range c.versions {
+		clone.versions[name] = make(map[string]*Template, len(versions))
+		for version, tmpl := range versions {
+			clone.versions[name][version] = tmpl
+		}
+		clone.labels[name] = append([]string(nil), c.labels[name]...)
+	}
+	return clone
+}
+
+// current returns the contents of the set.
+func (s *TemplateSet) current() *setContents {
+	if c := s.loadContents(); c != nil {
+		return c
+	}
+	return newSetContents()
+}
+
+// loadContents returns the contents stored in the set, or nil if there are none.
+func (s *TemplateSet) loadContents() *setContents {
+	c, _ := s.contents.Load().(*setContents)
+	return c
+}
+
+// Lookup returns the named template, or nil if the set has no unversioned template with that name.
+func (s *TemplateSet) Lookup(name string) *Template {
+	return s.current().templates[name]
+}
+
+// LookupVersion returns a version of the named template, or the unversioned template if version is empty. It returns
+// nil if the set has no such template.
+func (s *TemplateSet) LookupVersion(name, version string) *Template {
+	if version == "" {
+		return s.Lookup(name)
+	}
+	return s.current().versions[name][version]
+}
+
+// Names returns the names of the templates in the set, in sorted order. Templates which are only used as partials
+// are not included.
+func (s *TemplateSet) Names() []string {
+	c := s.current()
+	names := make([]string, 0, len(c.templates))
+	for name := range c.templates {
+		names = append(names, name)
+	}
+	for name := range c.versions {
+		if _, ok := c.templates[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Versions returns the sorted labels of the versions of the named template.
+func (s *TemplateSet) Versions(name string) []string {
+	return append([]string(nil), s.current().labels[name]...)
+}
+
+// AddVersion adds a version of the named template to the set, or replaces it if the set already has that version.
+// An empty version adds or replaces the unversioned template. Unlike the templates compiled with the set, tmpl
+// resolves partials with its own compiler's PartialProvider.
+func (s *TemplateSet) AddVersion(name, version string, tmpl *Template) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.loadContents().clone()
+	c.add(name, version, tmpl)
+	s.contents.Store(c)
+}
+
+// SetSelector sets the VersionSelector used by Render to choose between the versions of a template. With no
+// selector, Render uses the unversioned template.
+func (s *TemplateSet) SetSelector(sel VersionSelector) {
+	s.selector.Store(sel)
+}
+
+// Replace atomically replaces the templates in the set with those of other, for instance to deploy a new version of
+// the templates without interrupting the service using them. Renders which are already in progress finish with the
+// templates they started with. The set keeps its own VersionSelector.
+func (s *TemplateSet) Replace(other *TemplateSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contents.Store(other.loadContents())
+}
+
+// choose returns the template Render uses for name.
+func (s *TemplateSet) choose(name string, data []interface{}) (*Template, error) {
+	c := s.current()
+	version := ""
+	if sel, _ := s.selector.Load().(VersionSelector); sel != nil && len(c.labels[name]) > 0 {
+		version = sel(name, c.labels[name], data)
+	}
+	if version == "" {
+		if tmpl := c.templates[name]; tmpl != nil {
+			return tmpl, nil
+		}
+		return nil, fmt.Errorf("%s: %w", name, ErrTemplateNotFound)
+	}
+	if tmpl := c.versions[name][version]; tmpl != nil {
+		return tmpl, nil
+	}
+	return nil, fmt.Errorf("%s@%s: %w", name, version, ErrTemplateNotFound)
+}
+
+// Frender renders the named template to an io.Writer, using the version chosen by the set's VersionSelector if it has
+// one. It returns an error wrapping ErrTemplateNotFound if the set has no such template.
+func (s *TemplateSet) Frender(out io.Writer, name string, data ...interface{}) error {
+	tmpl, err := s.choose(name, data)
+	if err != nil {
+		return err
+	}
+	return tmpl.Frender(out, data...)
+}
+
+// Render renders the named template and returns the output.
+func (s *TemplateSet) Render(name string, data ...interface{}) (string, error) {
+	var buf bytes.Buffer
+	err := s.Frender(&buf, name, data...)
+	return buf.String(), err
+}
+
+// FrenderVersion renders a version of the named template to an io.Writer, or the unversioned template if version is
+// empty. It returns an error wrapping ErrTemplateNotFound if the set has no such template.
+func (s *TemplateSet) FrenderVersion(out io.Writer, name, version string, data ...interface{}) error {
+	tmpl := s.LookupVersion(name, version)
+	if tmpl == nil {
+		if version != "" {
+			name += "@" + version
+		}
+		return fmt.Errorf("%s: %w", name, ErrTemplateNotFound)
+	}
+	return tmpl.Frender(out, data...)
+}
+
+// RenderVersion renders a version of the named template and returns the output.
+func (s *TemplateSet) RenderVersion(name, version string, data ...interface{}) (string, error) {
+	var buf bytes.Buffer
+	err := s.FrenderVersion(&buf, name, version, data...)
+	return buf.String(), err
+}
@@ -0,0 +1,34 @@
+package mustache
+
+import (
+	"testing"
+)
+
+func TestRollout(t *testing.T) {
+	set := &TemplateSet{}
+	for _, version := range []string{"", "a", "b"} {
+		tmpl, err := New().CompileString(version + ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		set.AddVersion("t", version, tmpl)
+	}
+
+	counts := make(map[string]int)
+	set.SetSelector(Rollout(map[string]int{"": 1, "b": 3, "missing": 100}))
+	for i := 0; i < 2000; i++ {
+		output, err := set.Render("t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[output]++
+	}
+	if counts["a."] != 0 || counts["."] < 300 || counts["b."] < 1300 {
+		t.Errorf("unexpected rollout %v", counts)
+	}
+
+	set.SetSelector(Rollout(map[string]int{"a": 1}))
+	if output, _ := set.Render("t"); output != "a." {
+		t.Errorf("expected version a, got %q", output)
+	}
+}
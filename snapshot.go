@@ -0,0 +1,177 @@
+package mustache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WithContextSnapshots attaches a snapshot of the context chain to the error of a render which fails in strict mode,
+// as set with WithErrors, so that a failure can be reproduced from logs without the data it was rendered with. The
+// error is then a *SnapshotError, which wraps the original error and describes each context in the chain at the tag
+// which failed: up to maxKeys keys or fields of each, with values cut to maxValue characters. Lists, maps and structs
+// within the contexts are described by their sizes and types alone.
+//
+// The values of keys and fields whose names contain any of the redact words, ignoring case, such as "password" or
+// "token", are left out of the snapshot. A maxKeys of 0 turns snapshots off.
+func (r *Compiler) WithContextSnapshots(maxKeys, maxValue int, redact ...string) *Compiler {
+	r.snapshots = snapshotOptions{maxKeys: maxKeys, maxValue: maxValue, redact: redact}
+	return r
+}
+
+type snapshotOptions struct {
+	maxKeys  int
+	maxValue int
+	redact   []string
+}
+
+// SnapshotError is returned by renders which fail with context snapshots enabled.
+type SnapshotError struct {
+	Err    error
+	Frames []SnapshotFrame // the context chain at the failure, innermost first
+}
+
+func (e *SnapshotError) Error() string {
+	frames := make([]string, len(e.Frames))
+	for i, f := range e.Frames {
+		frames[i] = f.String()
+	}
+	return fmt.Sprintf("%s (context: %s)", e.Err, strings.Join(frames, "; "))
+}
+
+func (e *SnapshotError) Unwrap() error {
+	return e.Err
+}
+
+// SnapshotFrame describes one context of the chain.
+type SnapshotFrame struct {
+	Type    string          // the Go type of the context
+	Value   string          // the value of a context without keys or fields, such as an element of a list of strings
+	Fields  []SnapshotField // the first keys or fields of a map or struct, in order of name for maps
+	Omitted int             // the number of keys or fields left out
+}
+
+func (f SnapshotFrame) String() string {
+	if f.Fields == nil && f.Omitted == 0 {
+		return f.Type + " " + f.Value
+	}
+	fields := make([]string, 0, len(f.Fields)+1)
+	for _, field := range f.Fields {
+		fields = append(fields, field.Name+": "+field.Value)
+	}
+	if f.Omitted > 0 {
+		fields = append(fields, fmt.Sprintf("%d more", f.Omitted))
+	}
+	return f.Type + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// SnapshotField is a key or field of a context.
+type SnapshotField struct {
+	Name  string
+	Value string // the value, cut short, "[redacted]", or a summary such as "[3 items]"
+}
+
+// snapshotError attaches a snapshot of the context chain to err, if snapshots are enabled and it has none yet.
+func (tmpl *Template) snapshotError(err error, contextChain []reflect.Value) error {
+	opts := tmpl.parent.snapshots
+	var se *SnapshotError
+	if opts.maxKeys <= 0 || !tmpl.errorOnMissing || err == nil || errors.As(err, &se) {
+		return err
+	}
+	se = &SnapshotError{Err: err, Frames: make([]SnapshotFrame, len(contextChain))}
+	for i, v := range contextChain {
+		se.Frames[i] = opts.frame(v)
+	}
+	return se
+}
+
+func (opts snapshotOptions) frame(v reflect.Value) SnapshotFrame {
+	v = indirect(v)
+	if !v.IsValid() {
+		return SnapshotFrame{Type: "nil", Value: "nil"}
+	}
+	f := SnapshotFrame{Type: v.Type().String()}
+	switch v.Kind() {
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		f.Fields = []SnapshotField{}
+		for _, key := range keys {
+			if len(f.Fields) == opts.maxKeys {
+				f.Omitted++
+				continue
+			}
+			f.Fields = append(f.Fields, opts.field(fmt.Sprint(key), v.MapIndex(key)))
+		}
+	case reflect.Struct:
+		f.Fields = []SnapshotField{}
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if len(f.Fields) == opts.maxKeys {
+				f.Omitted++
+				continue
+			}
+			f.Fields = append(f.Fields, opts.field(v.Type().Field(i).Name, v.Field(i)))
+		}
+	default:
+		f.Value = opts.value(v)
+	}
+	return f
+}
+
+func (opts snapshotOptions) field(name string, v reflect.Value) SnapshotField {
+	lower := strings.ToLower(name)
+	for _, word := range opts.redact {
+		if strings.Contains(lower, strings.ToLower(word)) {
+			return SnapshotField{Name: name, Value: "[redacted]"}
+		}
+	}
+	return SnapshotField{Name: name, Value: opts.value(v)}
+}
+
+// value describes a value within a context: a scalar cut short, or the size or type of anything larger.
+func (opts snapshotOptions) value(v reflect.Value) string {
+	v = indirect(v)
+	if !v.IsValid() {
+		return "nil"
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		return fmt.Sprintf("[%d keys]", v.Len())
+	case reflect.Slice, reflect.Array:
+		return fmt.Sprintf("[%d items]", v.Len())
+	case reflect.Struct:
+		if v.CanInterface() && (v.Type().Implements(stringerType) || v.Type().Implements(errorType)) {
+			return opts.cut(fmt.Sprint(v.Interface()))
+		}
+		return v.Type().String() + "{…}"
+	case reflect.String:
+		return strconv.Quote(opts.cut(v.String()))
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return v.Type().String()
+	}
+	if !v.CanInterface() {
+		return v.Type().String()
+	}
+	return opts.cut(fmt.Sprint(v.Interface()))
+}
+
+// cut cuts s to the maximum length of a value, in characters, marking the cut with an ellipsis.
+func (opts snapshotOptions) cut(s string) string {
+	if opts.maxValue <= 0 {
+		return s
+	}
+	n := 0
+	for i := range s {
+		if n == opts.maxValue {
+			return s[:i] + "…"
+		}
+		n++
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package mustache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestContextSnapshots(t *testing.T) {
+	skipLight(t)
+	type item struct {
+		ID     int
+		Name   string
+		Tags   []string
+		secret string
+	}
+	data := map[string]interface{}{
+		"user":     map[string]interface{}{"name": "Jo"},
+		"items":    []item{{ID: 7, Name: "A very long product name", Tags: []string{"a", "b"}, secret: "x"}},
+		"apiToken": "abc123",
+		"count":    3,
+	}
+	tmpl, err := New().WithErrors(true).WithContextSnapshots(2, 8, "token").CompileString("{{#items}}{{ID}}: {{Price}}{{/items}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tmpl.Render(data)
+	var se *SnapshotError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected a SnapshotError, got %v", err)
+	}
+	expected := `line 1, column 19: missing variable "Price" (context: mustache.item{ID: 7, Name: "A very l…", 1 more}; ` +
+		`map[string]interface {}{apiToken: [redacted], count: 3, 2 more})`
+	if err.Error() != expected {
+		t.Errorf("expected %s, got %s", expected, err)
+	}
+	if !strings.Contains(errors.Unwrap(err).Error(), "missing variable") {
+		t.Errorf("expected the original error to be wrapped, got %v", errors.Unwrap(err))
+	}
+
+	// the snapshot is taken where the render fails, once, and only in strict mode
+	partials := &StaticProvider{Partials: map[string]string{"p": "{{#user}}{{age}}{{/user}}"}}
+	tmpl, err = New().WithErrors(true).WithContextSnapshots(5, 10).WithPartials(partials).CompileString("{{>p}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tmpl.Render(data)
+	if !errors.As(err, &se) || len(se.Frames) != 2 || se.Frames[0].String() != `map[string]interface {}{name: "Jo"}` {
+		t.Errorf("unexpected snapshot %v", err)
+	}
+	tmpl, err = New().WithContextSnapshots(5, 10).CompileString("{{#user}}{{age}}{{/user}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Render(data); err != nil {
+		t.Errorf("expected no error without strict mode, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package mustache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// sourceDelimiters are the delimiters Source writes tags with, the first which appear in neither the text nor the tags
+// of the template.
+var sourceDelimiters = [][2]string{{"{{", "}}"}, {"<%", "%>"}, {"[[", "]]"}, {"<<", ">>"}, {"{%", "%}"}, {"((", "))"}}
+
+// Source returns Mustache source for the template, which compiles with the template's compiler to the same AST, apart
+// from the positions of its tags, for tools which rewrite templates with Transform and save the result. The source is
+// written from the AST, so comments are left out, and the delimiters are changed with a set delimiter tag at its start
+// only if the text of the template holds the usual ones. Tags are written as they stand in the AST, so those the
+// parser would have taken as standalone, removing their lines, are followed by an empty comment, {{!}}, which keeps
+// them in place; partial, parent and block tags with indentation are written standalone on lines of their own.
+//
+// Source fails for templates it can't write, such as those holding sections with Handlebars conditions or partials with
+// hash parameters, which have no Mustache syntax, and checks the source it writes by compiling it, failing if it
+// compiles differently.
+func (tmpl *Template) Source() (string, error) {
+	ast := tmpl.AST()
+	nodes := sourceNodes(ast.Nodes)
+	w := &sourceWriter{blank: true, clear: true}
+	for _, delims := range sourceDelimiters {
+		if !sourceHolds(nodes, delims) {
+			w.otag, w.ctag = delims[0], delims[1]
+			break
+		}
+	}
+	if w.otag == "" {
+		return "", errors.New("mustache: source: the template holds the text of each of the delimiters")
+	}
+	if w.otag != "{{" {
+		w.b.WriteString("{{=" + w.otag + " " + w.ctag + "=}}\n")
+	}
+	if ast.Escape != "" {
+		if err := w.standalone("", "%ESCAPE "+ast.Escape); err != nil {
+			return "", err
+		}
+	}
+	if err := w.nodes(nodes); err != nil {
+		return "", fmt.Errorf("mustache: source: %w", err)
+	}
+	w.end()
+	src := w.b.String()
+
+	// the passes of the compiler were run before the AST was written, and would be run again by compiling its source
+	cmpl := *tmpl.parent
+	cmpl.transforms, cmpl.parseTrace, cmpl.maxTemplateBytes = nil, nil, 0
+	check, err := cmpl.parse(tmpl.name, []byte(src))
+	if err != nil {
+		return "", fmt.Errorf("mustache: source: the source written does not compile: %w", err)
+	}
+	if check.escapePragma != tmpl.escapePragma || !reflect.DeepEqual(sourceNodes(check.AST().Nodes), nodes) {
+		return "", errors.New("mustache: source: the source written compiles to a different template")
+	}
+	return src, nil
+}
+
+// sourceNodes returns nodes without the positions of their tags, with adjacent text joined, as the AST of their source
+// would hold them.
+func sourceNodes(nodes []ASTNode) []ASTNode {
+	out := make([]ASTNode, 0, len(nodes))
+	for _, node := range nodes {
+		node.Line, node.Column = 0, 0
+		if node.Nodes != nil {
+			node.Nodes = sourceNodes(node.Nodes)
+		}
+		if n := len(out); node.Type == NodeText && n > 0 && out[n-1].Type == NodeText {
+			out[n-1].Text += node.Text
+			continue
+		}
+		out = append(out, node)
+	}
+	return out
+}
+
+// sourceHolds reports whether the text of nodes holds the opening delimiter of delims, or their tags the closing one.
+func sourceHolds(nodes []ASTNode, delims [2]string) bool {
+	for _, node := range nodes {
+		if node.Type == NodeText && strings.Contains(node.Text, delims[0]) ||
+			node.Type != NodeText && strings.Contains(node.Name, delims[1]) ||
+			sourceHolds(node.Nodes, delims) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceWriter writes the source of AST nodes, following what precedes each tag on its line so that tags which would
+// stand alone can be kept from doing so.
+type sourceWriter struct {
+	b          strings.Builder
+	otag, ctag string
+	// blank is set when only spaces and tabs, held in pad, precede the end of the source on its line
+	blank bool
+	pad   string
+	// clear is set when only spaces, tabs and tags which may stand alone precede the end of the source on its line
+	clear bool
+	// guard is set when the last tag written would stand alone if its line ended after it, and padded when spaces or
+	// tabs around it would be removed if it did
+	guard, padded bool
+}
+
+func (w *sourceWriter) nodes(nodes []ASTNode) error {
+	for _, node := range nodes {
+		if err := w.node(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *sourceWriter) node(node ASTNode) error {
+	switch node.Type {
+	case NodeText:
+		w.text(node.Text)
+	case NodeVariable, NodeExpression:
+		w.variable(node.Name, node.Raw)
+	case NodeHelper:
+		elem := &helperElement{name: node.Name}
+		for _, arg := range node.Args {
+			elem.args = append(elem.args, helperArg{name: arg.Name, value: arg.Value})
+		}
+		w.variable(elem.String(), node.Raw)
+	case NodeCustom:
+		w.tag(node.Name, false)
+	case NodeSection:
+		if node.Condition {
+			return fmt.Errorf("section %s: conditions have no Mustache syntax", node.Name)
+		}
+		sigil := "#"
+		if node.Inverted {
+			sigil = "^"
+		}
+		w.tag(sigil+node.Name, false)
+		if err := w.nodes(node.Nodes); err != nil {
+			return err
+		}
+		w.tag("/"+node.Name, false)
+	case NodeParent, NodeBlock:
+		sigil := string(rune(parentSigil))
+		if node.Type == NodeBlock {
+			sigil = string(rune(blockSigil))
+		}
+		// the tag closing a parent stands alone by whether its opening tag could, and moves the spaces before the
+		// opening tag into the indentation of the construct if it does
+		clear, padded := true, false
+		if node.Indent != "" {
+			if err := w.standalone(node.Indent, sigil+node.Name); err != nil {
+				return fmt.Errorf("%s %s: %w", node.Type, node.Name, err)
+			}
+		} else {
+			clear, padded = w.clear, w.blank && w.pad != ""
+			w.tag(sigil+node.Name, clear)
+		}
+		if err := w.nodes(node.Nodes); err != nil {
+			return err
+		}
+		if node.Type == NodeBlock {
+			clear = w.clear
+		}
+		w.tag("/"+node.Name, clear)
+		w.padded = w.padded || padded
+	case NodePartial:
+		if node.Context != "" || len(node.Params) > 0 {
+			return fmt.Errorf("partial %s: contexts and hash parameters have no Mustache syntax", node.Name)
+		}
+		tag := ">" + node.Name
+		if node.Raw {
+			tag += " raw"
+		}
+		if node.Indent != "" {
+			if err := w.standalone(node.Indent, tag); err != nil {
+				return fmt.Errorf("partial %s: %w", node.Name, err)
+			}
+			break
+		}
+		w.tag(tag, false)
+	default:
+		return fmt.Errorf("unknown node type %q", node.Type)
+	}
+	return nil
+}
+
+// text writes literal text, after the empty comment which keeps the tag before it in place if the text would
+// otherwise end the tag's line.
+func (w *sourceWriter) text(text string) {
+	if w.guard {
+		switch rest := strings.TrimLeft(text, " \t"); {
+		case rest == "":
+			// the comment may as well follow the spaces, if the source ends after them
+			w.padded = true
+		case rest[0] == '\n' || strings.HasPrefix(rest, "\r\n"):
+			w.b.WriteString(w.otag + "!" + w.ctag)
+			fallthrough
+		default:
+			w.guard = false
+		}
+	}
+	w.b.WriteString(text)
+	line := text
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		line = text[i+1:]
+		w.blank, w.clear, w.pad = true, true, ""
+	}
+	if strings.Trim(line, " \t") != "" {
+		w.blank, w.clear = false, false
+	}
+	if w.blank {
+		w.pad += line
+	}
+}
+
+// variable writes a variable tag, or a triple mustache if raw is set.
+func (w *sourceWriter) variable(name string, raw bool) {
+	if raw {
+		name = "{" + name + "}"
+	}
+	w.tag(name, false)
+}
+
+// tag writes a tag which does not stand alone. inherit is set for the tags of template inheritance which would stand
+// alone if their line ended after them, as they do when only other tags precede them on their line.
+func (w *sourceWriter) tag(tag string, inherit bool) {
+	may := strings.Contains(SkipWhitespaceTagTypes, tag[:1])
+	w.guard = may && w.blank || inherit
+	w.padded = w.blank && w.pad != ""
+	w.b.WriteString(w.otag + tag + w.ctag)
+	w.clear = w.clear && may
+	w.blank = false
+}
+
+// standalone writes a standalone tag with the given indentation on a line of its own, which must begin at the end of
+// the source, or follow a tag which can stand alone there itself.
+func (w *sourceWriter) standalone(indent, tag string) error {
+	switch {
+	case w.guard && w.pad == "":
+		// the tag before, alone on its line, can stand alone as well
+		w.b.WriteString("\n")
+	case !w.blank || w.pad != "":
+		return errors.New("its indentation can't be written, as the tag does not begin a line")
+	}
+	w.b.WriteString(indent + w.otag + tag + w.ctag + "\n")
+	w.blank, w.clear, w.pad, w.guard = true, true, "", false
+	return nil
+}
+
+// end finishes the source, keeping the last tag in place if the spaces and tabs before it would be removed.
+func (w *sourceWriter) end() {
+	if w.guard && w.padded {
+		w.b.WriteString(w.otag + "!" + w.ctag)
+	}
+}
@@ -0,0 +1,70 @@
+package mustache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	partials := &StaticProvider{Partials: map[string]string{"layout": "<{{$title}}page{{/title}}>", "item": "- {{.}}\n"}}
+	cmpl := New().WithPartials(partials).WithHelper("upper", func(args ...interface{}) (string, error) {
+		return strings.ToUpper(args[0].(string)), nil
+	})
+	tests := []struct {
+		template string
+		source   string
+	}{
+		{"Hello {{name}}, {{{html}}}{{! a comment }}", "Hello {{name}}, {{{html}}}"},
+		{"{{#items}}\n  {{>item}}\n{{/items}}\n", "{{#items}}\n  {{>item}}\n{{/items}}"},
+		{"{{^items}}\n  none\n{{/items}}\n", "{{^items}}  none\n{{/items}}"},
+		{"{{#a}} {{/a}}\n", "{{#a}} {{/a}}\n"},
+		{"  {{#a}}{{/a}} x", "  {{#a}}{{/a}} x"},
+		{"{{#a}}x{{/a}}  \ny", "{{#a}}x{{/a}}  \ny"}, {"{{#a}}{{/a}}\n{{>item}}{{! c }}  ", "{{#a}}{{/a}}\n{{>item}}  {{!}}"},
+		{"x\n  {{! c }}{{>item}}\n", "x\n  {{>item}}{{!}}\n"},
+		{"{{%ESCAPE JSON}}\n{{=<% %>=}}{\"a\": <%a%>}", "{{%ESCAPE JSON}}\n{\"a\": {{a}}}"},
+		{"{{=| |=}}{{x}} |x|", "{{=<% %>=}}\n{{x}} <%x%>"},
+		{"{{upper name}} {{{upper \"a\"}}}", "{{upper name}} {{{upper \"a\"}}}"},
+		{"{{<layout}}\n{{$title}}home{{/title}}\n{{/layout}}\n", "{{<layout}}{{$title}}home{{/title}}\n{{/layout}}"},
+		{"{{#list}}\n  {{<layout}}\n  {{/layout}}\n{{/list}}", "{{#list}}\n  {{<layout}}\n{{/layout}}{{/list}}"},
+	}
+	for _, test := range tests {
+		tmpl, err := cmpl.CompileString(test.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src, err := tmpl.Source()
+		if err != nil {
+			t.Errorf("%q: %v", test.template, err)
+			continue
+		}
+		if src != test.source {
+			t.Errorf("%q: expected the source %q, got %q", test.template, test.source, src)
+		}
+	}
+
+	// a rewritten template
+	tmpl, err := cmpl.CompileString("{{#user}}\n  Hi {{first}}\n{{/user}}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err = tmpl.Transform(func(node ASTNode) ASTNode {
+		if node.Type == NodeVariable && node.Name == "first" {
+			node.Name = "firstName"
+		}
+		return node
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src, err := tmpl.Source(); err != nil || src != "{{#user}}  Hi {{firstName}}\n{{/user}}" {
+		t.Errorf("expected the renamed variable, got %q, %v", src, err)
+	}
+
+	tmpl, err = New().WithSyntax(Handlebars).CompileString("{{#if ok}}yes{{/if}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Source(); err == nil || !strings.Contains(err.Error(), "section ok: conditions have no Mustache syntax") {
+		t.Errorf("expected an error for a condition, got %v", err)
+	}
+}
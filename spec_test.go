@@ -29,7 +29,6 @@ var disabledTests = map[string]map[string]struct{}{
 		"Section - Alternate Delimiters":       struct{}{},
 		"Inverted Section":                     struct{}{},
 	},
-	"~inheritance.json": {}, // not implemented
 }
 
 type specTest struct {
@@ -111,7 +110,7 @@ func runTest(t *testing.T, file string, test *specTest) {
 	var out string
 	var oerr error
 	if len(test.Partials) > 0 {
-		tmpl, err := New().WithPartials(&StaticProvider{test.Partials}).CompileString(test.Template)
+		tmpl, err := New().WithPartials(&StaticProvider{Partials: test.Partials}).CompileString(test.Template)
 		if err != nil {
 			t.Error(err)
 		}
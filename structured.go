@@ -0,0 +1,68 @@
+package mustache
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+)
+
+// WithStructuredValues makes variable tags in the JSON escape modes write slices, arrays and maps as JSON, while
+// sections over them still iterate, so that a template can both list {{#tags}} and pass {{tags}} on whole. A tag
+// outside the string literals of the template writes the JSON as it is, and a tag inside one writes it escaped as the
+// contents of the string, so {{tags}} gives ["a","b"] and "{{tags}}" gives "[\"a\",\"b\"]".
+//
+// In the EscapeJSONValue mode, which already writes every value as JSON, tags inside string literals write their
+// values as the contents of the string rather than with their own quotes, so "Hello, {{name}}!" is valid too, and
+// missing values write nothing there rather than null. JSONTemplate enables this.
+//
+// Strings are found from the text of the templates and partials as it is rendered, so text written by raw tags and
+// lambdas is not taken into account.
+func (r *Compiler) WithStructuredValues(b bool) *Compiler {
+	r.structuredValues = b
+	return r
+}
+
+// jsonStrings tracks whether the output of a render is inside a JSON string literal of the templates' text.
+type jsonStrings struct {
+	inString bool
+	escaped  bool // set after a backslash in a string
+}
+
+// scan updates the state with text from a template.
+func (js *jsonStrings) scan(text []byte) {
+	for _, c := range text {
+		switch {
+		case js.escaped:
+			js.escaped = false
+		case c == '"':
+			js.inString = !js.inString
+		case c == '\\' && js.inString:
+			js.escaped = true
+		}
+	}
+}
+
+// isStructured reports whether a value written by a tag is written as JSON by WithStructuredValues.
+func (tmpl *Template) isStructured(raw bool, value any) bool {
+	if raw || !tmpl.parent.structuredValues || tmpl.outputMode != EscapeJSON && tmpl.outputMode != EscapeJSONValue {
+		return false
+	}
+	switch indirect(reflect.ValueOf(value)).Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// writeInString writes the JSON of a value, s, as the contents of the JSON string the output is in: a string without
+// its quotes, and anything else, other than null, as it is.
+func writeInString(buf io.Writer, s string) error {
+	if s == "null" {
+		return nil
+	}
+	var str string
+	if len(s) > 0 && s[0] == '"' && json.Unmarshal([]byte(s), &str) == nil {
+		s = str
+	}
+	return JSONEscape(buf, s)
+}
@@ -0,0 +1,56 @@
+package mustache
+
+import "testing"
+
+func TestStructuredValues(t *testing.T) {
+	data := map[string]interface{}{
+		"name":  `Jo "JJ"`,
+		"tags":  []string{"a", "<b>"},
+		"sizes": map[string]int{"s": 1},
+		"count": 2,
+	}
+	tests := []struct {
+		mode     EscapeMode
+		tmpl     string
+		expected string
+	}{
+		{EscapeJSON, `{"tags": {{tags}}, "sizes": {{sizes}}, "list": "{{#tags}}{{.}};{{/tags}}", "name": "{{name}}"}`,
+			`{"tags": ["a","\u003cb\u003e"], "sizes": {"s":1}, "list": "a;<b>;", "name": "Jo \"JJ\""}`},
+		{EscapeJSON, `{"text": "tags: {{tags}} \"{{sizes}}\""}`, `{"text": "tags: [\"a\",\"\\u003cb\\u003e\"] \"{\"s\":1}\""}`},
+		{EscapeJSONValue, `{"tags": {{tags}}, "text": "{{name}} has {{count}} {{tags}}{{missing}}", "missing": {{missing}}}`,
+			`{"tags": ["a","\u003cb\u003e"], "text": "Jo \"JJ\" has 2 [\"a\",\"\\u003cb\\u003e\"]", "missing": null}`},
+		{EscapeJSONValue, `{"n": "{{count}} {{plural count "item" "items"}}", "first": {{#tags}}{{^-last}}{{.}}{{/-last}}{{/tags}}}`, `{"n": "2 items", "first": "a"}`},
+	}
+	for _, test := range tests {
+		tmpl, err := New().WithEscapeMode(test.mode).WithStructuredValues(true).CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := tmpl.Render(data)
+		if err != nil {
+			t.Errorf("%q: %v", test.tmpl, err)
+		} else if output != test.expected {
+			t.Errorf("%q: expected %s, got %s", test.tmpl, test.expected, output)
+		}
+	}
+
+	// without the option, the JSON mode writes slices with fmt
+	tmpl, err := New().WithEscapeMode(EscapeJSON).CompileString(`{{tags}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(data); err != nil || output != "[a <b>]" {
+		t.Errorf("got %q, %v", output, err)
+	}
+
+	tmpl, err = JSONTemplate(`{"greeting": "Hello, {{name}}!", "tags": {{tags}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(data); err != nil || output != `{"greeting": "Hello, Jo \"JJ\"!", "tags": ["a","\u003cb\u003e"]}` {
+		t.Errorf("got %s, %v", output, err)
+	}
+	if issues := tmpl.JSONLint(); len(issues) != 0 {
+		t.Errorf("expected no issues, got %v", issues)
+	}
+}
@@ -0,0 +1,49 @@
+package mustache
+
+import (
+	"io"
+	"time"
+)
+
+// RenderSummary describes a single render of a template, for observability. It is reported to the function set with
+// WithRenderSummary once each render finishes.
+type RenderSummary struct {
+	Template    string        // the name of the template, if it has one
+	Duration    time.Duration // how long the render took
+	OutputBytes int64         // the number of bytes written
+	Tags        int           // the number of variable and section tags evaluated
+	Misses      int           // the number of tags whose names could not be resolved
+	Partials    int           // the number of partials loaded
+	Err         error         // the error the render failed with, if any
+}
+
+// WithRenderSummary sets a function which is called with a summary of each render of the compiled templates, including
+// renders which fail.
+func (r *Compiler) WithRenderSummary(f func(RenderSummary)) *Compiler {
+	r.renderSummary = f
+	return r
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// measure runs render, which writes to out, and reports the summary to report.
+func (s *RenderSummary) measure(report func(RenderSummary), out io.Writer, render func(io.Writer) error) error {
+	cw := &countingWriter{w: out}
+	start := time.Now()
+	err := render(cw)
+	s.Duration = time.Since(start)
+	s.OutputBytes = cw.n
+	s.Err = err
+	report(*s)
+	return err
+}
@@ -0,0 +1,30 @@
+//go:build go1.21
+
+package mustache
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithRenderLogger logs a summary of each render of the compiled templates to l, as a single structured record at
+// level Debug, or Error if the render failed. It replaces any function set with WithRenderSummary. It needs Go 1.21,
+// which added log/slog.
+func (r *Compiler) WithRenderLogger(l *slog.Logger) *Compiler {
+	return r.WithRenderSummary(func(s RenderSummary) {
+		level := slog.LevelDebug
+		attrs := []slog.Attr{
+			slog.String("template", s.Template),
+			slog.Duration("duration", s.Duration),
+			slog.Int64("output_bytes", s.OutputBytes),
+			slog.Int("tags", s.Tags),
+			slog.Int("misses", s.Misses),
+			slog.Int("partials", s.Partials),
+		}
+		if s.Err != nil {
+			level = slog.LevelError
+			attrs = append(attrs, slog.String("error", s.Err.Error()))
+		}
+		l.LogAttrs(context.Background(), level, "mustache render", attrs...)
+	})
+}
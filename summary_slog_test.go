@@ -0,0 +1,30 @@
+//go:build go1.21
+
+package mustache
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestRenderLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	tmpl, err := New().WithErrors(true).WithRenderLogger(logger).CompileFS(os.DirFS("."), "tests/test1.mustache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tmpl.Render(map[string]string{}); err == nil {
+		t.Fatal("expected missing variable error")
+	}
+	line := buf.String()
+	for _, want := range []string{"level=ERROR", "msg=\"mustache render\"", "template=" + path.Join("tests", "test1.mustache"), "misses=1", "error="} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in log line %q", want, line)
+		}
+	}
+}
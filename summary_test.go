@@ -0,0 +1,80 @@
+package mustache
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderSummary(t *testing.T) {
+	var summaries []RenderSummary
+	cmpl := New().
+		WithPartials(&StaticProvider{Partials: map[string]string{"p": "<{{name}}>"}}).
+		WithRenderSummary(func(s RenderSummary) { summaries = append(summaries, s) })
+	tmpl, err := cmpl.CompileString("{{#items}}{{>p}}{{/items}}{{missing}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := tmpl.Render(map[string]interface{}{"items": []map[string]string{{"name": "a"}, {"name": "b"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("expected 1 summary, got %d", len(summaries))
+	}
+	s := summaries[0]
+	if s.OutputBytes != int64(len(output)) || s.Tags != 4 || s.Misses != 1 || s.Partials != 2 || s.Err != nil {
+		t.Errorf("unexpected summary %+v", s)
+	}
+
+	tmpl, err = cmpl.CompileFS(os.DirFS("."), "tests/test1.mustache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tmpl.Render(nil); err != nil {
+		t.Fatal(err)
+	}
+	if s := summaries[len(summaries)-1]; s.Template != tmpl.Name() || !strings.HasSuffix(s.Template, "test1.mustache") {
+		t.Errorf("expected the template name in the summary, got %q", s.Template)
+	}
+}
+
+// TestReportingRenders checks that the methods which report on a render share the path of Render, with its summaries
+// and fallback.
+func TestReportingRenders(t *testing.T) {
+	var summaries int
+	cmpl := New().WithRenderSummary(func(RenderSummary) { summaries++ })
+	tmpl, err := cmpl.CompileString(`{"n": {{n}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{"n": 1}
+	renders := []func() error{
+		func() error { _, err := tmpl.RenderValue(data); return err },
+		func() error { _, err := tmpl.Coverage(data); return err },
+		func() error { _, _, err := tmpl.RenderUnused(data); return err },
+		func() error { _, _, err := tmpl.RenderResolutions(data); return err },
+	}
+	for i, render := range renders {
+		if err := render(); err != nil {
+			t.Fatal(err)
+		}
+		if summaries != i+1 {
+			t.Errorf("render %d: expected %d summaries, got %d", i, i+1, summaries)
+		}
+	}
+
+	fallback, err := New().CompileString(`{"n": 0}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err = New().WithErrors(true).CompileString(`{"n": {{missing}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := tmpl.WithFallback(fallback, nil).RenderValue(data)
+	if err != nil || !reflect.DeepEqual(v, map[string]interface{}{"n": 0.0}) {
+		t.Errorf("expected the value of the fallback, got %v, %v", v, err)
+	}
+}
@@ -0,0 +1,103 @@
+package mustache
+
+import (
+	"context"
+)
+
+// Tracer creates spans covering the work done to compile and render templates, so that renders can be reported to a
+// distributed tracing system. The otelmustache module provides a Tracer backed by OpenTelemetry.
+type Tracer interface {
+	// Start begins a span with the given name and attributes, as a child of any span held by ctx, and returns a
+	// context holding the new span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a span begun by a Tracer.
+type Span interface {
+	// SetAttributes adds attributes to the span, for those only known once the work it covers is under way.
+	SetAttributes(attrs ...Attribute)
+	// End ends the span, recording err if it is not nil.
+	End(err error)
+}
+
+// Attribute is a key-value pair describing a span. Values are strings, ints or bools.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// The names of the spans created for a Tracer.
+const (
+	SpanCompile = "mustache.compile" // compiling a template, partial or lambda result
+	SpanRender  = "mustache.render"  // rendering a template
+	SpanPartial = "mustache.partial" // fetching and compiling a partial
+	SpanLambda  = "mustache.lambda"  // calling a lambda
+)
+
+// The keys of the attributes set on spans.
+const (
+	AttrTemplate   = "mustache.template"      // the name of the template, if it has one
+	AttrSize       = "mustache.template.size" // the size of the template in bytes
+	AttrPartials   = "mustache.partials"      // the number of partial tags in the template
+	AttrEscapeMode = "mustache.escape_mode"   // the escape mode of the template
+	AttrPartial    = "mustache.partial"       // the name of the partial being fetched
+	AttrLambda     = "mustache.lambda"        // the name of the lambda being called
+	AttrTimedOut   = "mustache.timed_out"     // set on the span of a lambda which did not return within its timeout
+)
+
+// WithTracer sets a Tracer which is used to create spans as templates are compiled and rendered. Compiling creates a
+// root span, since the compile methods take no context; use FrenderContext or RenderContext to make the spans of a
+// render children of the caller's span.
+func (r *Compiler) WithTracer(t Tracer) *Compiler {
+	r.tracer = t
+	return r
+}
+
+// startSpan begins a span if the compiler has a Tracer, and otherwise returns a span which does nothing.
+func (r *Compiler) startSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	if r.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return r.tracer.Start(ctx, name, attrs...)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) End(error)                  {}
+
+// startRenderSpan starts the span of a render of the template. The template is described only if a tracer is set, as
+// doing so walks the whole template.
+func (tmpl *Template) startRenderSpan(ctx context.Context) (context.Context, Span) {
+	if tmpl.parent.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return tmpl.parent.tracer.Start(ctx, SpanRender, tmpl.spanAttributes()...)
+}
+
+// spanAttributes returns the attributes describing a compiled template.
+func (tmpl *Template) spanAttributes() []Attribute {
+	attrs := []Attribute{
+		{AttrTemplate, tmpl.name},
+		{AttrSize, len(tmpl.data)},
+		{AttrPartials, countPartials(tmpl.elems)},
+		{AttrEscapeMode, tmpl.outputMode.String()},
+	}
+	return append(attrs, tmpl.metadataAttributes()...)
+}
+
+func countPartials(elems []interface{}) int {
+	n := 0
+	for _, elem := range elems {
+		switch elem := elem.(type) {
+		case *partialElement:
+			n++
+		case *sectionElement:
+			if elem.sigil == parentSigil {
+				n++
+			}
+			n += countPartials(elem.elems)
+		}
+	}
+	return n
+}
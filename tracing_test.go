@@ -0,0 +1,113 @@
+package mustache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// recordingTracer records spans as "parent>name" paths, with their attributes and errors.
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	path  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+type spanKey struct{}
+
+func (rt *recordingTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	path := name
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		path = parent.path + ">" + name
+	}
+	span := &recordedSpan{path: path, attrs: make(map[string]interface{})}
+	span.SetAttributes(attrs...)
+	rt.spans = append(rt.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...Attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+func (rt *recordingTracer) paths() string {
+	var paths []string
+	for _, span := range rt.spans {
+		if !span.ended {
+			paths = append(paths, span.path+"(open)")
+			continue
+		}
+		paths = append(paths, span.path)
+	}
+	return strings.Join(paths, " ")
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	cmpl := New().WithTracer(tracer).WithPartials(&StaticProvider{Partials: map[string]string{"p": "<{{name}}>"}})
+	tmpl, err := cmpl.CompileString("{{>p}}{{#lambda}}{{name}}{{/lambda}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compile := tracer.spans[0]
+	if compile.path != SpanCompile || compile.attrs[AttrPartials] != 1 || compile.attrs[AttrSize] != 36 || compile.attrs[AttrEscapeMode] != "HTML" {
+		t.Errorf("unexpected compile span %+v", compile)
+	}
+
+	tracer.spans = nil
+	ctx, parent := tracer.Start(context.Background(), "request")
+	output, err := tmpl.RenderContext(ctx, map[string]interface{}{
+		"name": "x",
+		"lambda": func(text string, render RenderFn) (string, error) {
+			return render(text + text)
+		},
+	})
+	parent.End(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "<x>xx" {
+		t.Errorf("expected %q, got %q", "<x>xx", output)
+	}
+	expected := "request request>mustache.render request>mustache.render>mustache.partial " +
+		"request>mustache.render>mustache.partial>mustache.compile request>mustache.render>mustache.lambda " +
+		"request>mustache.render>mustache.lambda>mustache.compile"
+	if paths := tracer.paths(); paths != expected {
+		t.Errorf("expected spans\n%s\ngot\n%s", expected, paths)
+	}
+	if name := tracer.spans[2].attrs[AttrPartial]; name != "p" {
+		t.Errorf("expected partial span for %q, got %v", "p", name)
+	}
+
+	failure := errors.New("lambda failed")
+	tracer.spans = nil
+	_, err = tmpl.Render(map[string]interface{}{
+		"lambda": func(text string, render RenderFn) (string, error) {
+			return "", failure
+		},
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected lambda error, got %v", err)
+	}
+	for _, span := range tracer.spans {
+		if (span.path == "mustache.render" || span.path == "mustache.render>mustache.lambda") && !errors.Is(span.err, failure) {
+			t.Errorf("expected span %s to record the error, got %v", span.path, span.err)
+		}
+	}
+	if paths := tracer.paths(); !strings.HasPrefix(paths, "mustache.render ") {
+		t.Errorf("expected a root render span, got %s", paths)
+	}
+}
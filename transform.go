@@ -0,0 +1,73 @@
+package mustache
+
+import "fmt"
+
+// WithTransform adds a pass which rewrites each template the compiler compiles from source, including partials and
+// the text returned by lambdas, so that rewrites such as prefixing asset URLs or injecting tracking pixels can be
+// applied to all templates in one place. A pass is given the nodes of the template's AST once it has been parsed, and
+// defined sections and constants have been folded, and returns the nodes to compile in their place. Passes run in the
+// order they were added, each given the nodes returned by the one before, and nodes which CompileJSON would reject,
+// such as helper calls to unknown helpers, fail the compile.
+//
+// Only sections keep their positions through a pass, so errors and tools such as RenderResolutions report no line
+// for other tags. Templates compiled with CompileJSON are not transformed again.
+func (r *Compiler) WithTransform(pass func(nodes []ASTNode) []ASTNode) *Compiler {
+	r.transforms = append(r.transforms, pass)
+	return r
+}
+
+// transform runs the compiler's passes over the elements of a parsed template.
+func (tmpl *Template) transform() error {
+	if len(tmpl.parent.transforms) == 0 {
+		return nil
+	}
+	nodes := astNodes(tmpl.elems)
+	for _, pass := range tmpl.parent.transforms {
+		nodes = pass(nodes)
+	}
+	elems, err := tmpl.astElems(nodes, 0)
+	if err != nil {
+		return fmt.Errorf("mustache: transform: %w", err)
+	}
+	tmpl.elems = elems
+	return nil
+}
+
+// Transform returns a copy of the template rewritten by fn, for refactoring tools which rename variables, inline
+// partials or wrap sections, and which may then render the copy or write its source with Source. fn is called with
+// each node of the template's AST, the nodes within a section, parent or block before the node holding them, and
+// returns the node to put in its place. A node returned with an empty Type is replaced by the nodes it holds, so that
+// a node can be replaced by several, such as the nodes of an inlined partial, or removed by returning ASTNode{}.
+//
+// The copy is compiled from the rewritten nodes as CompileJSON would compile them, so nodes it would reject fail the
+// transform, and the compiler's passes added with WithTransform are not run again. The template itself is unchanged.
+func (tmpl *Template) Transform(fn func(node ASTNode) ASTNode) (*Template, error) {
+	t := *tmpl
+	t.data, t.checkpoints, t.delimiterChanges = nil, nil, nil
+	elems, err := t.astElems(transformNodes(astNodes(tmpl.elems), fn), 0)
+	if err != nil {
+		return nil, fmt.Errorf("mustache: transform: %w", err)
+	}
+	t.elems = elems
+	t.foldDefines()
+	t.expandConstants()
+	return &t, nil
+}
+
+// transformNodes calls fn with each of nodes, after the nodes within it, splicing in those of nodes returned without a
+// type.
+func transformNodes(nodes []ASTNode, fn func(ASTNode) ASTNode) []ASTNode {
+	out := make([]ASTNode, 0, len(nodes))
+	for _, node := range nodes {
+		if len(node.Nodes) > 0 {
+			node.Nodes = transformNodes(node.Nodes, fn)
+		}
+		node = fn(node)
+		if node.Type == "" {
+			out = append(out, node.Nodes...)
+			continue
+		}
+		out = append(out, node)
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package mustache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	var prefix func(nodes []ASTNode) []ASTNode
+	prefix = func(nodes []ASTNode) []ASTNode {
+		for i := range nodes {
+			if nodes[i].Type == NodeText {
+				nodes[i].Text = strings.ReplaceAll(nodes[i].Text, `src="/`, `src="https://cdn.example.com/`)
+			}
+			nodes[i].Nodes = prefix(nodes[i].Nodes)
+		}
+		return nodes
+	}
+	pixel := func(nodes []ASTNode) []ASTNode {
+		return append(nodes, ASTNode{Type: NodeText, Text: `<img src="/p?id=`}, ASTNode{Type: NodeVariable, Name: "id"},
+			ASTNode{Type: NodeText, Text: `">`})
+	}
+	partials := &StaticProvider{Partials: map[string]string{"logo": `<img src="/logo.png">`}}
+	cmpl := New().WithPartials(partials).WithTransform(prefix).WithTransform(pixel)
+	tmpl, err := cmpl.CompileString(`{{#items}}<img src="/{{.}}">{{/items}}{{>logo}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<img src="https://cdn.example.com/a.png"><img src="https://cdn.example.com/logo.png">` +
+		`<img src="/p?id=7"><img src="/p?id=7">`
+	if output, err := tmpl.Render(map[string]interface{}{"items": []string{"a.png"}, "id": 7}); err != nil || output != expected {
+		t.Errorf("expected %q, got %q, %v", expected, output, err)
+	}
+
+	edited, err := tmpl.Reparse(Edit{Start: 0, End: 0, Text: `<img src="/b.png">`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := edited.Render(map[string]interface{}{"id": 7}); !strings.HasPrefix(output, `<img src="https://cdn.example.com/b.png">`) {
+		t.Errorf("expected the edit to be transformed, got %q", output)
+	}
+
+	_, err = New().WithTransform(func(nodes []ASTNode) []ASTNode {
+		return append(nodes, ASTNode{Type: NodeHelper, Name: "unknown"})
+	}).CompileString("x")
+	if err == nil || !strings.Contains(err.Error(), `transform: unknown helper "unknown"`) {
+		t.Errorf("expected an error for an invalid node, got %v", err)
+	}
+}
+
+func TestTemplateTransform(t *testing.T) {
+	partials := &StaticProvider{Partials: map[string]string{"price": "{{amount}} {{currency}}"}}
+	cmpl := New().WithPartials(partials)
+	tmpl, err := cmpl.CompileString("{{#items}}{{title}}: {{>price}}\n{{/items}}{{! note }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inline, err := cmpl.CompileString(partials.Partials["price"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewritten, err := tmpl.Transform(func(node ASTNode) ASTNode {
+		switch {
+		case node.Type == NodeVariable && node.Name == "title":
+			node.Name = "name"
+		case node.Type == NodePartial && node.Name == "price":
+			// inline the partial, in place of its tag
+			return ASTNode{Nodes: inline.AST().Nodes}
+		case node.Type == NodeSection && node.Name == "items":
+			// wrap the list in a section which hides it when it is empty
+			return ASTNode{Type: NodeSection, Name: "items", Condition: true, Nodes: []ASTNode{node}}
+		}
+		return node
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{"items": []map[string]interface{}{{"name": "pen", "amount": 2, "currency": "EUR"}}}
+	if output, err := rewritten.Render(data); err != nil || output != "pen: 2 EUR\n" {
+		t.Errorf("expected %q, got %q, %v", "pen: 2 EUR\n", output, err)
+	}
+	if output, err := tmpl.Render(data); err != nil || output != ": 2 EUR\n" {
+		t.Errorf("expected the template to be unchanged, got %q, %v", output, err)
+	}
+
+	// nodes returned without a type and without nodes are removed
+	removed, err := tmpl.Transform(func(node ASTNode) ASTNode {
+		if node.Type == NodeText {
+			return ASTNode{}
+		}
+		return node
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := removed.Render(data); err != nil || output != "2 EUR" {
+		t.Errorf("expected %q, got %q, %v", "2 EUR", output, err)
+	}
+
+	_, err = tmpl.Transform(func(node ASTNode) ASTNode {
+		if node.Type == NodeVariable {
+			node.Type = NodeHelper
+		}
+		return node
+	})
+	if err == nil || !strings.Contains(err.Error(), `transform: unknown helper "title"`) {
+		t.Errorf("expected an error for an invalid node, got %v", err)
+	}
+}
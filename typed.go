@@ -0,0 +1,205 @@
+package mustache
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Typed wraps a compiled Template so that it can only be rendered with a context of type T. When the Typed is
+// created, the names used by the template are checked against T, so that a misspelled or removed field is reported
+// once at compile time rather than silently rendering as an empty string.
+type Typed[T any] struct {
+	tmpl *Template
+}
+
+// TypeError is returned when the names used by a template cannot be resolved against a context type.
+type TypeError struct {
+	Type    reflect.Type
+	Missing []string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("%s has no field, method or key for %s", e.Type, strings.Join(quoteAll(e.Missing), ", "))
+}
+
+func quoteAll(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return quoted
+}
+
+// NewTyped checks that every name used by tmpl can be resolved against T and returns the typed template. Names that
+// pass through interfaces, string-keyed maps or lambdas can't be checked statically, and are accepted.
+// Partials are not checked.
+func NewTyped[T any](tmpl *Template) (*Typed[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	var missing []string
+	checkNames(tmpl.elems, []reflect.Type{typ}, false, &missing)
+	if len(missing) > 0 {
+		return nil, &TypeError{Type: typ, Missing: missing}
+	}
+	return &Typed[T]{tmpl}, nil
+}
+
+// CompileTyped compiles a template with the given compiler, then checks it against T as NewTyped does.
+func CompileTyped[T any](c *Compiler, data string) (*Typed[T], error) {
+	tmpl, err := c.CompileString(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewTyped[T](tmpl)
+}
+
+// Template returns the underlying untyped template.
+func (t *Typed[T]) Template() *Template {
+	return t.tmpl
+}
+
+// Render renders the template with data as its context.
+func (t *Typed[T]) Render(data T) (string, error) {
+	return t.tmpl.Render(data)
+}
+
+// Frender renders the template with data as its context to an io.Writer.
+func (t *Typed[T]) Frender(out io.Writer, data T) error {
+	return t.tmpl.Frender(out, data)
+}
+
+// checkNames resolves the names used by elems against a chain of types, innermost first, and appends those which
+// can't be resolved to missing. A nil type in the chain stands for a context whose type isn't known statically. list
+// is set within sections over lists, where names such as -index refer to the position in the list.
+func checkNames(elems []interface{}, chain []reflect.Type, list bool, missing *[]string) {
+	for _, elem := range elems {
+		switch elem := elem.(type) {
+		case *varElement:
+			if _, ok := checkName(chain, list, elem.name); !ok {
+				*missing = append(*missing, elem.name)
+			}
+		case *helperElement:
+			for _, arg := range elem.args {
+				if _, ok := checkName(chain, list, arg.name); arg.name != "" && !ok {
+					*missing = append(*missing, arg.name)
+				}
+			}
+		case *exprElement:
+			for _, name := range elem.names() {
+				if _, ok := checkName(chain, list, name); !ok {
+					*missing = append(*missing, name)
+				}
+			}
+		case *sectionElement:
+			if elem.sigil != 0 {
+				// the contents of parent and block tags are rendered in the context around them
+				checkNames(elem.elems, chain, list, missing)
+				continue
+			}
+			typ, ok := checkName(chain, list, elem.name)
+			if !ok {
+				*missing = append(*missing, elem.name)
+				continue
+			}
+			if elem.inverted || elem.cond {
+				checkNames(elem.elems, chain, list, missing)
+				continue
+			}
+			if typ != nil && typ.Kind() == reflect.Func {
+				// lambda sections render their text however they like
+				continue
+			}
+			inList := list
+			if typ != nil {
+				switch indirectType(typ).Kind() {
+				case reflect.Slice, reflect.Array:
+					inList = true
+				}
+			}
+			checkNames(elem.elems, append([]reflect.Type{sectionType(typ)}, chain...), inList, missing)
+		}
+	}
+}
+
+// sectionType returns the type of the context a section over a value of type typ pushes.
+func sectionType(typ reflect.Type) reflect.Type {
+	if typ == nil {
+		return nil
+	}
+	typ = indirectType(typ)
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array:
+		return typ.Elem()
+	}
+	return typ
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// checkName resolves a name used by a tag against a chain of types as Template.lookup does, including names such as
+// -index within sections over lists, and names with a leading dot, which refer explicitly to the data.
+func checkName(chain []reflect.Type, list bool, name string) (reflect.Type, bool) {
+	if len(name) > 1 && name[0] == '.' {
+		return lookupType(chain, name[1:])
+	}
+	if list {
+		switch name {
+		case iterFirst, iterLast:
+			return reflect.TypeOf(false), true
+		case iterIndex, iterIndex0:
+			return reflect.TypeOf(0), true
+		}
+	}
+	return lookupType(chain, name)
+}
+
+// lookupType mirrors lookup at the type level, returning the type of the value a name resolves to. It returns a nil
+// type if the name may resolve but its type isn't known statically.
+func lookupType(chain []reflect.Type, name string) (reflect.Type, bool) {
+	if name != "." && strings.Contains(name, ".") {
+		parts := strings.SplitN(name, ".", 2)
+		typ, ok := lookupType(chain, parts[0])
+		if !ok {
+			return nil, false
+		}
+		return lookupType([]reflect.Type{typ}, parts[1])
+	}
+
+	for _, typ := range chain {
+		if typ == nil {
+			return nil, true
+		}
+		if name == "." {
+			return typ, true
+		}
+		if indirectType(typ).Kind() == reflect.Interface {
+			return nil, true
+		}
+		for {
+			if m, ok := typ.MethodByName(name); ok && m.Type.NumIn() == 1 && m.Type.NumOut() > 0 {
+				return m.Type.Out(0), true
+			}
+			if typ.Kind() != reflect.Ptr {
+				break
+			}
+			typ = typ.Elem()
+		}
+		switch typ.Kind() {
+		case reflect.Struct:
+			if f, ok := typ.FieldByName(name); ok {
+				return f.Type, true
+			}
+		case reflect.Map:
+			if typ.Key().Kind() == reflect.String {
+				return typ.Elem(), true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,81 @@
+package mustache
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type typedItem struct {
+	Title string
+	Price float64
+}
+
+type typedPage struct {
+	Name  string
+	Items []typedItem
+	User  *User
+	Extra map[string]interface{}
+}
+
+func (p typedPage) Total() float64 {
+	var total float64
+	for _, item := range p.Items {
+		total += item.Price
+	}
+	return total
+}
+
+func TestTyped(t *testing.T) {
+	skipLight(t)
+	typed, err := CompileTyped[typedPage](New(), "{{Name}}: {{#Items}}{{Title}} {{Name}};{{/Items}}{{^Items}}none{{/Items}} {{Total}} {{User.Func2}} {{Extra.anything.at.all}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := typed.Render(typedPage{
+		Name:  "cart",
+		Items: []typedItem{{"a", 1}, {"b", 2}},
+		User:  &User{"Mike", 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "cart: a cart;b cart; 3 Mike "
+	if output != expected {
+		t.Errorf("expected %q got %q", expected, output)
+	}
+
+	// the position in a list, and explicit references to the data
+	typed, err = CompileTyped[typedPage](New(), "{{.Name}}: {{#Items}}{{-index}}.{{.Title}}{{^-last}}, {{/-last}}{{/Items}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err = typed.Render(typedPage{Name: "cart", Items: []typedItem{{"a", 1}, {"b", 2}}})
+	if expected := "cart: 1.a, 2.b"; err != nil || output != expected {
+		t.Errorf("expected %q, got %q, %v", expected, output, err)
+	}
+}
+
+func TestTypedMissing(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		missing []string
+	}{
+		{"{{Nmae}}", []string{"Nmae"}},
+		{"{{#Items}}{{Titel}}{{/Items}}", []string{"Titel"}},
+		{"{{User.Email}} {{User.Func1}}", []string{"User.Email"}},
+		{"{{#Missing}}{{Name}}{{/Missing}}", []string{"Missing"}},
+		{"{{-index}} {{.Nmae}} {{#User}}{{-first}}{{/User}}", []string{"-index", ".Nmae", "-first"}},
+	}
+	for _, test := range tests {
+		_, err := CompileTyped[typedPage](New(), test.tmpl)
+		var te *TypeError
+		if !errors.As(err, &te) {
+			t.Errorf("%q expected a TypeError, got %v", test.tmpl, err)
+			continue
+		}
+		if !reflect.DeepEqual(te.Missing, test.missing) {
+			t.Errorf("%q expected missing %q, got %q", test.tmpl, test.missing, te.Missing)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package mustache
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maxUnusedDepth bounds how deeply RenderUnused descends into the contexts, which may be cyclic.
+const maxUnusedDepth = 32
+
+// RenderUnused renders the template like Render, and also reports the fields and map keys of the contexts which the
+// template never referred to, as dotted paths such as "user.Email". Elements of slices and arrays share the path of
+// the slice. A value interpolated as a whole, such as a map rendered with a JSON value stringer, counts as a use of
+// everything inside it. Only the outermost unused path is reported, so if "user" is unused "user.Email" is not listed.
+//
+// This is intended for tests which check that the data passed to a template and the template itself haven't drifted
+// apart.
+func (tmpl *Template) RenderUnused(data ...interface{}) (string, []string, error) {
+	var usage *usageTracker
+	var buf bytes.Buffer
+	err := tmpl.frenderPrepared(context.Background(), &buf, data, func(_ *Template, st *renderState) {
+		usage = newUsageTracker(len(data))
+		st.usage = usage
+	})
+	if err != nil {
+		return buf.String(), nil, err
+	}
+
+	var unused []string
+	for _, c := range data {
+		usage.findUnused(reflect.ValueOf(c), "", 0, &unused)
+	}
+	sort.Strings(unused)
+	// the same path may be reported for several elements of a slice or several contexts
+	unused = dedupe(unused)
+	return buf.String(), unused, nil
+}
+
+// usageTracker records the paths in the contexts that a render refers to.
+type usageTracker struct {
+	// used holds each path that a name resolved to
+	used map[string]struct{}
+	// whole holds paths that were interpolated, and so used in their entirety
+	whole map[string]struct{}
+	// paths holds the path of each context in the context chain, outermost first
+	paths []string
+	// section is the path of the context pushed by the section most recently looked up
+	section string
+}
+
+func newUsageTracker(contexts int) *usageTracker {
+	return &usageTracker{
+		used:  make(map[string]struct{}),
+		whole: make(map[string]struct{}),
+		paths: make([]string, contexts),
+	}
+}
+
+func joinPath(base, name string) string {
+	if base == "" {
+		return name
+	}
+	return base + "." + name
+}
+
+// use records that name was resolved against the context at index frame of contextChain, and returns the path of the
+// value it resolved to.
+func (u *usageTracker) use(contextChain []reflect.Value, frame int, name string, whole bool) string {
+	if frame < 0 {
+		return ""
+	}
+	path := u.paths[len(contextChain)-1-frame]
+	if name != "." {
+		for _, part := range strings.Split(name, ".") {
+			path = joinPath(path, part)
+			u.used[path] = struct{}{}
+		}
+	}
+	if whole {
+		u.whole[path] = struct{}{}
+	}
+	return path
+}
+
+// findUnused appends the paths of fields and map keys within v which were never used.
+func (u *usageTracker) findUnused(v reflect.Value, path string, depth int, unused *[]string) {
+	if depth > maxUnusedDepth {
+		return
+	}
+	if _, ok := u.whole[path]; ok {
+		return
+	}
+	v = indirect(v)
+	if !v.IsValid() {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			u.check(iter.Value(), joinPath(path, iter.Key().String()), depth, unused)
+		}
+	case reflect.Struct:
+		u.findUnusedFields(v, path, depth, unused)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			u.findUnused(v.Index(i), path, depth+1, unused)
+		}
+	}
+}
+
+func (u *usageTracker) findUnusedFields(v reflect.Value, path string, depth int, unused *[]string) {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Anonymous && indirectType(f.Type).Kind() == reflect.Struct {
+			// fields of embedded structs are promoted, so they share the path of the outer struct
+			if fv := indirect(v.Field(i)); fv.IsValid() {
+				u.findUnusedFields(fv, path, depth+1, unused)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		u.check(v.Field(i), joinPath(path, f.Name), depth, unused)
+	}
+}
+
+func (u *usageTracker) check(v reflect.Value, path string, depth int, unused *[]string) {
+	if _, ok := u.used[path]; !ok {
+		*unused = append(*unused, path)
+		return
+	}
+	u.findUnused(v, path, depth+1, unused)
+}
+
+func dedupe(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package mustache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderUnused(t *testing.T) {
+	skipLight(t)
+	type address struct {
+		City    string
+		Country string
+	}
+	type person struct {
+		Name    string
+		Email   string
+		Address address
+		Tags    []string
+	}
+	tests := []struct {
+		tmpl     string
+		context  interface{}
+		expected string
+		unused   []string
+	}{
+		{
+			`{{Name}}`,
+			person{Name: "Jo"},
+			"Jo",
+			[]string{"Address", "Email", "Tags"},
+		},
+		{
+			`{{Name}} {{Address.City}}{{#Tags}}{{.}}{{/Tags}}`,
+			person{Name: "Jo", Address: address{City: "Oslo"}, Tags: []string{"a"}},
+			"Jo Osloa",
+			[]string{"Address.Country", "Email"},
+		},
+		{
+			`{{#people}}{{Name}}{{#Address}}{{City}}{{/Address}}{{/people}}{{title}}`,
+			map[string]interface{}{
+				"title":  "!",
+				"unused": 1,
+				"people": []person{{Name: "a", Address: address{City: "x"}}, {Name: "b"}},
+			},
+			"axb!",
+			[]string{"people.Address.Country", "people.Email", "people.Tags", "unused"},
+		},
+		{
+			`{{#person}}{{Address}}{{/person}}`,
+			map[string]interface{}{"person": person{Name: "Jo"}},
+			"{ }",
+			[]string{"person.Email", "person.Name", "person.Tags"},
+		},
+	}
+	for _, test := range tests {
+		tmpl, err := New().CompileString(test.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, unused, err := tmpl.RenderUnused(test.context)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if output != test.expected {
+			t.Errorf("%q expected %q got %q", test.tmpl, test.expected, output)
+		}
+		if !reflect.DeepEqual(unused, test.unused) {
+			t.Errorf("%q expected unused %q got %q", test.tmpl, test.unused, unused)
+		}
+	}
+}
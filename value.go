@@ -0,0 +1,106 @@
+package mustache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RenderValue renders the template like Render and parses the output as JSON, returning the value it holds: a
+// map[string]interface{}, a []interface{}, a string, a float64, a bool or nil. It suits templates in the JSON escape
+// modes, whose output is usually unmarshaled as soon as it is rendered.
+//
+// If the output is not valid JSON, the error is a *ValueError, which locates the problem both in the output and in the
+// template, at the last tag rendered before it. Post processors which change the output throw the template position
+// off.
+func (tmpl *Template) RenderValue(data ...interface{}) (interface{}, error) {
+	var origins *originRecorder
+	var buf bytes.Buffer
+	err := tmpl.frenderPrepared(context.Background(), &buf, data, func(_ *Template, st *renderState) {
+		origins = &originRecorder{}
+		st.origins = origins
+	})
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		var oe *OutputError
+		if !errors.As(ValidateJSON(buf.Bytes()), &oe) {
+			return nil, err
+		}
+		ve := &ValueError{Output: oe}
+		if origin, ok := origins.before(oe.Offset); ok {
+			ve.Template, ve.Tag, ve.Line, ve.Column = origin.template, origin.tag, origin.line, origin.col
+		}
+		return nil, ve
+	}
+	return v, nil
+}
+
+// ValueError is returned by RenderValue when the output is not valid JSON. The tag is the last one rendered before the
+// problem, which is usually the one, or the section around the text, responsible.
+type ValueError struct {
+	Output   *OutputError // where the problem is in the output
+	Template string       // the name of the template or partial holding the tag, if it has one
+	Tag      string       // the tag, such as "{{name}}", or "" if no tag was rendered before the problem
+	Line     int          // the line of the tag in its template
+	Column   int          // the column of the tag, counting bytes from 1
+}
+
+func (e *ValueError) Error() string {
+	if e.Tag == "" {
+		return e.Output.Error()
+	}
+	where := fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+	if e.Template != "" {
+		where = e.Template + " " + where
+	}
+	return fmt.Sprintf("%s (after %s at %s)", e.Output, e.Tag, where)
+}
+
+func (e *ValueError) Unwrap() error {
+	return e.Output
+}
+
+// originRecorder records where in the output the tags of a render begin.
+type originRecorder struct {
+	written *int64 // the number of bytes of output written so far
+	origins []origin
+}
+
+type origin struct {
+	offset    int
+	template  string
+	tag       string
+	line, col int
+}
+
+// wrap returns a writer which counts the output written to w.
+func (or *originRecorder) wrap(w io.Writer) io.Writer {
+	cw := &countingWriter{w: w}
+	or.written = &cw.n
+	return cw
+}
+
+// record records that the element of tmpl is about to be rendered, if it is a tag compiled from source.
+func (or *originRecorder) record(tmpl *Template, elem interface{}) {
+	line, col := elementPosition(elem)
+	if line == 0 || or.written == nil {
+		return
+	}
+	or.origins = append(or.origins, origin{int(*or.written), tmpl.name, "{{" + tagKey(elem) + "}}", line, col})
+}
+
+// before returns the last tag which began before the byte of the output at offset.
+func (or *originRecorder) before(offset int) (origin, bool) {
+	for i := len(or.origins) - 1; i >= 0; i-- {
+		if or.origins[i].offset <= offset {
+			return or.origins[i], true
+		}
+	}
+	return origin{}, false
+}
@@ -0,0 +1,45 @@
+package mustache
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderValue(t *testing.T) {
+	cmpl := New().WithEscapeMode(EscapeJSONValue)
+	tmpl, err := cmpl.CompileString(`{"name": {{name}}, "tags": [{{#tags}}{{.}}{{^-last}}, {{/-last}}{{/tags}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := tmpl.RenderValue(map[string]interface{}{"name": "a\"b", "tags": []int{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"name": "a\"b", "tags": []interface{}{1.0, 2.0}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected %v, got %v", expected, v)
+	}
+
+	tmpl, err = cmpl.CompileString("{\n  \"tags\": [{{#tags}}{{.}},{{/tags}}],\n  \"n\": 1\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tmpl.RenderValue(map[string]interface{}{"tags": []int{1, 2}})
+	var ve *ValueError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected a *ValueError, got %v", err)
+	}
+	if ve.Tag != "{{.}}" || ve.Line != 2 || ve.Column != 21 || ve.Output.Line != 2 || ve.Output.Column != 16 {
+		t.Errorf("expected the trailing comma after {{.}} at line 2, column 21, got %+v, %+v", ve, ve.Output)
+	}
+	var se *json.SyntaxError
+	if !errors.As(err, &se) {
+		t.Errorf("expected the error to wrap a *json.SyntaxError")
+	}
+	if !strings.Contains(err.Error(), "after {{.}} at line 2, column 21") {
+		t.Errorf("unexpected message %q", err)
+	}
+}
@@ -0,0 +1,164 @@
+package mustache
+
+import "strings"
+
+// Walk calls fn for each element of the template in document order: text, comments, variables, sections, partials,
+// helpers, expressions and custom tags, so that linters and analyzers can inspect every part of a template without
+// handling each kind of container themselves. A section is visited before its contents, which are skipped if fn
+// returns false for it; closing tags are not visited, and partials are not loaded. Text is visited as the parser split
+// it, without the whitespace of standalone lines, and without a position. Templates built from an AST, by CompileJSON
+// or a WithTransform pass, have no comments.
+func (tmpl *Template) Walk(fn func(Tag) bool) {
+	walkElems(tmpl.elems, fn)
+}
+
+func walkElems(elems []interface{}, fn func(Tag) bool) {
+	for _, elem := range elems {
+		switch elem := elem.(type) {
+		case *textElement:
+			// the parser leaves empty text elements around tags
+			if len(elem.text) > 0 {
+				fn(elem)
+			}
+		case *sectionElement:
+			if fn(elem) {
+				walkElems(elem.elems, fn)
+			}
+		case Tag:
+			fn(elem)
+		}
+	}
+}
+
+// commentElement is a comment tag, which renders nothing.
+type commentElement struct {
+	text   string
+	line   int // the line of the tag, or 0 if it was not compiled from source
+	col    int // the column of the tag, counting bytes from 1
+	offset int // the offset of the tag in the source
+}
+
+func (e *textElement) Type() TagType {
+	return Text
+}
+
+// Name returns the text.
+func (e *textElement) Name() string {
+	return string(e.text)
+}
+
+func (e *textElement) Tags() []Tag {
+	panic("mustache: Tags on Text type")
+}
+
+func (e *textElement) Line() int {
+	return 0
+}
+
+func (e *textElement) Column() int {
+	return 0
+}
+
+func (e *textElement) ByteOffset() int {
+	return 0
+}
+
+func (e *commentElement) Type() TagType {
+	return Comment
+}
+
+// Name returns the text of the comment, without the surrounding whitespace.
+func (e *commentElement) Name() string {
+	return strings.TrimSpace(e.text)
+}
+
+func (e *commentElement) Tags() []Tag {
+	panic("mustache: Tags on Comment type")
+}
+
+func (e *commentElement) Line() int {
+	return e.line
+}
+
+func (e *commentElement) Column() int {
+	return e.col
+}
+
+func (e *commentElement) ByteOffset() int {
+	return e.offset
+}
+
+func (elem *helperElement) Type() TagType {
+	return HelperTag
+}
+
+// Name returns the name of the helper.
+func (elem *helperElement) Name() string {
+	return elem.name
+}
+
+func (elem *helperElement) Tags() []Tag {
+	panic("mustache: Tags on HelperTag type")
+}
+
+func (elem *helperElement) Line() int {
+	return elem.line
+}
+
+func (elem *helperElement) Column() int {
+	return elem.col
+}
+
+func (elem *helperElement) ByteOffset() int {
+	return elem.offset
+}
+
+func (elem *exprElement) Type() TagType {
+	return Expression
+}
+
+// Name returns the source of the expression.
+func (elem *exprElement) Name() string {
+	return elem.src
+}
+
+func (elem *exprElement) Tags() []Tag {
+	panic("mustache: Tags on Expression type")
+}
+
+func (elem *exprElement) Line() int {
+	return elem.line
+}
+
+func (elem *exprElement) Column() int {
+	return elem.col
+}
+
+func (elem *exprElement) ByteOffset() int {
+	return elem.offset
+}
+
+func (elem *customTagElement) Type() TagType {
+	return CustomTag
+}
+
+// Name returns the text of the tag, including its sigil.
+func (elem *customTagElement) Name() string {
+	return elem.String()
+}
+
+func (elem *customTagElement) Tags() []Tag {
+	panic("mustache: Tags on CustomTag type")
+}
+
+func (elem *customTagElement) Line() int {
+	return elem.line
+}
+
+func (elem *customTagElement) Column() int {
+	return elem.col
+}
+
+func (elem *customTagElement) ByteOffset() int {
+	return elem.offset
+}
@@ -0,0 +1,55 @@
+package mustache
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	cmpl := New().WithExpressions(true).WithHelper("upper", func(args ...interface{}) (string, error) {
+		return strings.ToUpper(fmt.Sprint(args...)), nil
+	})
+	tmpl, err := cmpl.CompileString("{{! list }}\n<ul>\n{{#items}}\n  <li>{{name}} {{upper name}}</li>{{>item}}\n{{/items}}{{^items}}{{=<% %>=}}<%(1 + 2)%><%/items%>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visited []string
+	tmpl.Walk(func(tag Tag) bool {
+		visited = append(visited, fmt.Sprintf("%s %q %d:%d@%d", tag.Type(), tag.Name(), tag.Line(), tag.Column(), tag.ByteOffset()))
+		return true
+	})
+	expected := []string{
+		`Comment "list" 1:1@0`,
+		`Text "<ul>\n" 0:0@0`,
+		`Section "items" 3:1@17`,
+		`Text "  <li>" 0:0@0`,
+		`Variable "name" 4:7@34`,
+		`Text " " 0:0@0`,
+		`HelperTag "upper" 4:16@43`,
+		`Text "</li>" 0:0@0`,
+		`Partial "item" 4:35@62`,
+		`Text "\n" 0:0@0`,
+		`InvertedSection "items" 5:11@82`,
+		`Expression "(1 + 2)" 5:32@103`,
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(visited, "\n"))
+	}
+
+	// returning false skips the contents of a section
+	visited = nil
+	tmpl.Walk(func(tag Tag) bool {
+		visited = append(visited, tag.Type().String())
+		return tag.Type() != Section
+	})
+	if expected := []string{"Comment", "Text", "Section", "InvertedSection", "Expression"}; !reflect.DeepEqual(visited, expected) {
+		t.Errorf("expected %v, got %v", expected, visited)
+	}
+
+	// comments render nothing
+	if output, err := tmpl.Render(map[string]interface{}{}); err != nil || output != "<ul>\n3" {
+		t.Errorf("expected %q, got %q, %v", "<ul>\n3", output, err)
+	}
+}
@@ -0,0 +1,240 @@
+//go:build !mustache_minimal
+
+package mustache
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TemplateSource provides the files from which a Watcher compiles a TemplateSet, keyed by their slash separated paths.
+// The templates are those listed by a manifest.json file, as for bundles, or if there is none, every file with a
+// .mustache or .stache extension, named by its path without the extension.
+type TemplateSource interface {
+	Files(ctx context.Context) (map[string][]byte, error)
+}
+
+// DirSource is a TemplateSource which reads the files in a directory and its subdirectories. Files and directories
+// whose names begin with '.' are skipped.
+type DirSource string
+
+// Files implements TemplateSource.
+func (d DirSource) Files(ctx context.Context) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(string(d), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != string(d) && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(string(d), path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err == nil {
+			data, err = decodeFile(data)
+		}
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// BundleURL is a TemplateSource which downloads a bundle over HTTP. It makes conditional requests using the ETag of
+// the last response, so an unchanged bundle is not downloaded again. A bundle larger than MaxBytes fails with a
+// *LimitError wrapping ErrBundleTooLarge, and when it is watched, no more of each file is read than the limit set on
+// the compiler with WithMaxTemplateBytes.
+type BundleURL struct {
+	URL    string
+	Client *http.Client // the client to use, or nil for http.DefaultClient
+	// MaxBytes is the most bytes of the bundle read from the response, or zero for DefaultMaxBundleBytes.
+	MaxBytes int
+
+	etag  string
+	files map[string][]byte
+}
+
+// DefaultMaxBundleBytes is the size of the largest bundle a BundleURL downloads, unless its MaxBytes is set.
+const DefaultMaxBundleBytes = 64 << 20
+
+// limitedSource is implemented by a TemplateSource which can stop reading each file past a limit, which Watcher sets
+// to the compiler's WithMaxTemplateBytes.
+type limitedSource interface {
+	limitedFiles(ctx context.Context, limit int) (map[string][]byte, error)
+}
+
+// Files implements TemplateSource.
+func (b *BundleURL) Files(ctx context.Context) (map[string][]byte, error) {
+	return b.limitedFiles(ctx, 0)
+}
+
+func (b *BundleURL) limitedFiles(ctx context.Context, limit int) (map[string][]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if b.etag != "" {
+		req.Header.Set("If-None-Match", b.etag)
+	}
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && b.files != nil:
+		return b.files, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: %s", b.URL, resp.Status)
+	}
+	maxBytes := b.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBundleBytes
+	}
+	body := &io.LimitedReader{R: resp.Body, N: int64(maxBytes) + 1}
+	files, err := readBundle(body, limit)
+	if body.N == 0 {
+		err = &LimitError{Err: ErrBundleTooLarge, Max: maxBytes}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.URL, err)
+	}
+	b.etag, b.files = resp.Header.Get("ETag"), files
+	return files, nil
+}
+
+// Watcher keeps a TemplateSet up to date with a TemplateSource, recompiling the templates when the source changes and
+// swapping them into the set with TemplateSet.Replace. If the source can't be read or the new templates fail to
+// compile, the set keeps its current templates.
+type Watcher struct {
+	cmpl   *Compiler
+	source TemplateSource
+	set    *TemplateSet
+
+	mu  sync.Mutex // serializes reloads
+	sum [sha256.Size]byte
+	err atomic.Value // the *error of the last reload
+}
+
+// Watch compiles a TemplateSet from source, and returns a Watcher which keeps it up to date. If interval is positive,
+// the source is checked for changes at that interval until ctx is done; otherwise it is only checked when Reload is
+// called, for instance when a deployment pushes new templates.
+func (r *Compiler) Watch(ctx context.Context, source TemplateSource, interval time.Duration) (*Watcher, error) {
+	w := &Watcher{cmpl: r, source: source, set: &TemplateSet{}}
+	if _, err := w.Reload(ctx); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		go w.poll(ctx, interval)
+	}
+	return w, nil
+}
+
+func (w *Watcher) poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Reload(ctx)
+		}
+	}
+}
+
+// Set returns the TemplateSet kept up to date by the watcher. The same set is returned each time.
+func (w *Watcher) Set() *TemplateSet {
+	return w.set
+}
+
+// Reload checks the source for changes, and if there are any, recompiles the templates and swaps them into the set.
+// It reports whether the templates were replaced. The error is also recorded, to be returned by Err.
+func (w *Watcher) Reload(ctx context.Context) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	replaced, err := w.reload(ctx)
+	w.err.Store(&err)
+	return replaced, err
+}
+
+func (w *Watcher) reload(ctx context.Context) (bool, error) {
+	var files map[string][]byte
+	var err error
+	if ls, ok := w.source.(limitedSource); ok {
+		files, err = ls.limitedFiles(ctx, w.cmpl.maxTemplateBytes)
+	} else {
+		files, err = w.source.Files(ctx)
+	}
+	if err != nil {
+		return false, err
+	}
+	sum := fingerprint(files)
+	if sum == w.sum && w.set.loadContents() != nil {
+		return false, nil
+	}
+	set, err := w.cmpl.compileFiles(files)
+	if err != nil {
+		return false, err
+	}
+	w.set.Replace(set)
+	w.sum = sum
+	return true, nil
+}
+
+// Err returns the error from the last check of the source, or nil if it succeeded.
+func (w *Watcher) Err() error {
+	if err, _ := w.err.Load().(*error); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// fingerprint returns a checksum of the paths and contents of a set of files.
+func fingerprint(files map[string][]byte) [sha256.Size]byte {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(h, "%d:%s%d:", len(path), path, len(files[path]))
+		h.Write(files[path])
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
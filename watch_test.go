@@ -0,0 +1,155 @@
+//go:build !mustache_minimal
+
+package mustache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("page.mustache", "<{{>parts/name}}>")
+	write("parts/name.stache", "{{name}}")
+	write("notes.txt", "not a template")
+	write(".git/HEAD.mustache", "{{#broken}}")
+
+	w, err := New().Watch(context.Background(), DirSource(dir), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := w.Set()
+	if names := set.Names(); len(names) != 2 || names[0] != "page" || names[1] != "parts/name" {
+		t.Errorf("unexpected templates %v", names)
+	}
+	ctx := map[string]string{"name": "x"}
+	if output, err := set.Render("page", ctx); err != nil || output != "<x>" {
+		t.Errorf("expected %q, got %q, %v", "<x>", output, err)
+	}
+
+	if replaced, err := w.Reload(context.Background()); replaced || err != nil {
+		t.Errorf("expected no change, got %v, %v", replaced, err)
+	}
+
+	old := set.Lookup("page")
+	write("parts/name.stache", "{{name}}{{name}}")
+	if replaced, err := w.Reload(context.Background()); !replaced || err != nil {
+		t.Errorf("expected the templates to be replaced, got %v, %v", replaced, err)
+	}
+	if output, _ := set.Render("page", ctx); output != "<xx>" {
+		t.Errorf("expected the new templates, got %q", output)
+	}
+	if output, _ := old.Render(ctx); output != "<x>" {
+		t.Errorf("expected the old template to keep its partials, got %q", output)
+	}
+
+	write("page.mustache", "{{#page}}")
+	if replaced, err := w.Reload(context.Background()); replaced || err == nil {
+		t.Errorf("expected a compile error, got %v, %v", replaced, err)
+	}
+	if w.Err() == nil {
+		t.Error("expected the error to be recorded")
+	}
+	if output, _ := set.Render("page", ctx); output != "<xx>" {
+		t.Errorf("expected the last good templates to be kept, got %q", output)
+	}
+}
+
+func TestWatchNestedPartials(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("page.mustache", "{{>header}}")
+	write("header.mustache", "H[{{>footer}}]")
+	write("footer.mustache", "old")
+
+	w, err := New().Watch(context.Background(), DirSource(dir), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := w.Set()
+	if output, err := set.Render("page"); err != nil || output != "H[old]" {
+		t.Errorf("expected %q, got %q, %v", "H[old]", output, err)
+	}
+	// partials included by partials are those of the reloaded set
+	write("footer.mustache", "new")
+	if replaced, err := w.Reload(context.Background()); !replaced || err != nil {
+		t.Errorf("expected the templates to be replaced, got %v, %v", replaced, err)
+	}
+	if output, err := set.Render("page"); err != nil || output != "H[new]" {
+		t.Errorf("expected %q, got %q, %v", "H[new]", output, err)
+	}
+}
+
+func TestWatchBundleURL(t *testing.T) {
+	bundle := zipBundle(t, manifestFile(t, BundleEntry{Name: "a"}), bundleFile{"a", "v{{v}}"})
+	var requests, downloads int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("If-None-Match") == `"1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		w.Header().Set("ETag", `"1"`)
+		w.Write(bundle)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := New().Watch(ctx, &BundleURL{URL: srv.URL}, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := w.Set().Render("a", map[string]int{"v": 1}); err != nil || output != "v1" {
+		t.Errorf("expected %q, got %q, %v", "v1", output, err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&requests) < 3; {
+		if time.Now().After(deadline) {
+			t.Fatal("the watcher did not poll the bundle URL")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&downloads); n != 1 {
+		t.Errorf("expected the bundle to be downloaded once, got %d", n)
+	}
+}
+
+func TestBundleURLLimits(t *testing.T) {
+	bundle := zipBundle(t, manifestFile(t, BundleEntry{Name: "a"}), bundleFile{"a", strings.Repeat("x", 1000)})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bundle)
+	}))
+	defer srv.Close()
+
+	if _, err := (&BundleURL{URL: srv.URL}).Files(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&BundleURL{URL: srv.URL, MaxBytes: len(bundle) - 1}).Files(context.Background()); !errors.Is(err, ErrBundleTooLarge) {
+		t.Errorf("expected ErrBundleTooLarge, got %v", err)
+	}
+	if _, err := New().WithMaxTemplateBytes(100).Watch(context.Background(), &BundleURL{URL: srv.URL}, 0); !errors.Is(err, ErrTemplateTooLarge) {
+		t.Errorf("expected ErrTemplateTooLarge, got %v", err)
+	}
+}
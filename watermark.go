@@ -0,0 +1,98 @@
+package mustache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// WatermarkInfo describes a render, for the function set with WithWatermark which formats its watermark.
+type WatermarkInfo struct {
+	Template   string     // the name of the template, if it has one
+	EscapeMode EscapeMode // the escape mode of the template
+	// Checksum is the hex encoded SHA-256 checksum of the template's source, or empty if the template was not compiled
+	// from source, as for templates loaded with CompileJSON.
+	Checksum string
+	Metadata Metadata  // the metadata of the template, which may record the version it was built from
+	Time     time.Time // the time of the render, which is zero in deterministic mode
+}
+
+// String formats the information as space separated key=value pairs: the template name, the first 12 digits of the
+// checksum, the metadata and the render time, each left out if it is empty.
+func (wi WatermarkInfo) String() string {
+	var fields []string
+	if wi.Template != "" {
+		fields = append(fields, "template="+wi.Template)
+	}
+	if wi.Checksum != "" {
+		sum := wi.Checksum
+		if len(sum) > 12 {
+			sum = sum[:12]
+		}
+		fields = append(fields, "sha256="+sum)
+	}
+	if len(wi.Metadata) > 0 {
+		fields = append(fields, wi.Metadata.String())
+	}
+	if !wi.Time.IsZero() {
+		fields = append(fields, "rendered="+wi.Time.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(fields, " ")
+}
+
+// WithWatermark sets a function which formats a watermark, such as an HTML comment recording the template and the
+// commit it was built from, which is written after the output of each render of the compiled templates, for tracing
+// generated files back to their templates. The watermark follows the output of any post processors, and is checked by
+// any post validator. Nothing is written if format returns an empty string, and passing nil turns watermarks off, so
+// they can be set for some environments only. HTMLWatermark and CommentWatermark format the common comments.
+//
+// Partials carry no watermark of their own, but the template of a layout and the template rendered into it each do.
+func (r *Compiler) WithWatermark(format func(WatermarkInfo) string) *Compiler {
+	r.watermark = format
+	return r
+}
+
+// CommentWatermark returns a function for WithWatermark which writes the information of a render between open and
+// close, such as "/* " and " */", or "# " and "" for comments which run to the end of the line. Occurrences of close,
+// and line breaks, are removed from the information so that it can't end the comment early.
+func CommentWatermark(open, close string) func(WatermarkInfo) string {
+	return func(wi WatermarkInfo) string {
+		s := strings.NewReplacer("\r", " ", "\n", " ").Replace(wi.String())
+		if close != "" {
+			s = strings.ReplaceAll(s, close, "")
+		}
+		return open + s + close + "\n"
+	}
+}
+
+// HTMLWatermark writes the information of a render as an HTML comment, for use with WithWatermark. It writes nothing
+// for templates in other escape modes, so that the JSON a compiler renders stays valid.
+func HTMLWatermark(wi WatermarkInfo) string {
+	if wi.EscapeMode != EscapeHTML {
+		return ""
+	}
+	s := strings.NewReplacer("\r", " ", "\n", " ").Replace(wi.String())
+	// "--" may not appear within HTML comments.
+	for strings.Contains(s, "--") {
+		s = strings.ReplaceAll(s, "--", "- -")
+	}
+	return "<!-- " + s + " -->\n"
+}
+
+// watermarkText returns the watermark of a render of the template, or an empty string if it has none.
+func (tmpl *Template) watermarkText() string {
+	format := tmpl.parent.watermark
+	if format == nil {
+		return ""
+	}
+	wi := WatermarkInfo{Template: tmpl.name, EscapeMode: tmpl.outputMode, Metadata: tmpl.Metadata()}
+	if tmpl.data != nil {
+		sum := sha256.Sum256(tmpl.data)
+		wi.Checksum = hex.EncodeToString(sum[:])
+	}
+	if !tmpl.parent.deterministic {
+		wi.Time = time.Now()
+	}
+	return format(wi)
+}
@@ -0,0 +1,52 @@
+package mustache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWatermark(t *testing.T) {
+	cmpl := New().WithDeterministic(true).WithMetadata(Metadata{MetaCommit: "abc--def"}).WithWatermark(HTMLWatermark)
+	tmpl, err := cmpl.CompileString("<p>{{name}}</p>\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.name = "page.html"
+	expected := "<p>Jo</p>\n<!-- template=page.html sha256=c6d988df9729 commit=abc- -def -->\n"
+	if output, err := tmpl.Render(map[string]string{"name": "Jo"}); err != nil || output != expected {
+		t.Errorf("expected %q, got %q, %v", expected, output, err)
+	}
+
+	cmpl.WithEscapeMode(EscapeJSON)
+	tmpl, err = cmpl.CompileString(`{"name": "{{name}}"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(map[string]string{"name": "Jo"}); err != nil || output != `{"name": "Jo"}` {
+		t.Errorf("expected no watermark in JSON, got %q, %v", output, err)
+	}
+
+	cmpl = New().WithWatermark(CommentWatermark("# ", "")).WithPostProcessor(func(b []byte) ([]byte, error) {
+		return []byte(strings.ToUpper(string(b))), nil
+	})
+	tmpl, err = cmpl.CompileString("port: {{port}}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := tmpl.Render(map[string]int{"port": 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(output, "PORT: 80\n# sha256=") || !strings.Contains(output, " rendered=") {
+		t.Errorf("expected a comment with the render time after the processed output, got %q", output)
+	}
+
+	cmpl.WithWatermark(nil)
+	tmpl, err = cmpl.CompileString("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := tmpl.Render(nil); err != nil || output != "X" {
+		t.Errorf("expected no watermark once it is turned off, got %q, %v", output, err)
+	}
+}